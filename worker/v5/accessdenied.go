@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// AccessDeniedError is the terminal error of the worker whose receives are denied MaxAccessDenied times in a row.
+// It names the IAM action and the resource to be granted, so that the misconfigured deployment fails loudly and fixably
+// instead of retrying blindly forever.
+type AccessDeniedError struct {
+	QueueName string
+	// Resource is the ARN of the queue, or the KMS key of the queue for the denied kms:Decrypt
+	Resource string
+	// Action is the denied IAM action, e.g. sqs:ReceiveMessage
+	Action string
+	// Attempts is the number of the consecutive denied calls
+	Attempts int
+	// Err is the error of the last denied call
+	Err error
+}
+
+func (e *AccessDeniedError) Error() string {
+	return fmt.Sprintf("worker: access denied %d times in a row, grant %s on %s to the role of the worker, queue=%s, err=%v",
+		e.Attempts, e.Action, e.Resource, e.QueueName, e.Err)
+}
+
+func (e *AccessDeniedError) Unwrap() error {
+	return e.Err
+}
+
+// deniedReceive counts the denied receive, and returns the AccessDeniedError when it reaches the MaxAccessDenied
+func (worker *Worker) deniedReceive(err error) *AccessDeniedError {
+	attempts := int(atomic.AddInt32(&worker.accessDenied, 1))
+	if attempts < worker.Config.MaxAccessDenied {
+		return nil
+	}
+	e := &AccessDeniedError{
+		QueueName: worker.Config.QueueName,
+		Resource:  queueARN(worker.Config.QueueURL),
+		Action:    "sqs:ReceiveMessage",
+		Attempts:  attempts,
+		Err:       err,
+	}
+	// the SSE-KMS queue denies the receive without kms:Decrypt on its key
+	if strings.HasPrefix(apiErrorCode(err), "KMS.") {
+		e.Action, e.Resource = "kms:Decrypt", "the KMS key of "+e.Resource
+	}
+	return e
+}
+
+// allowedReceive resets the count of the denied receives after the successful receive
+func (worker *Worker) allowedReceive() {
+	if atomic.LoadInt32(&worker.accessDenied) != 0 {
+		atomic.StoreInt32(&worker.accessDenied, 0)
+	}
+}
+
+// fail logs the terminal error as the event, and reports it by the OnFatalError hook
+func (worker *Worker) fail(ctx context.Context, event LogEvent, err error) {
+	worker.logEventWith(ctx, event, requestFields(err), "%s", err.Error())
+	if worker.Config.Hooks.OnFatalError != nil {
+		worker.Config.Hooks.OnFatalError(ctx, err)
+	}
+}
+
+// queueARN returns the ARN of the queue from the URL (https://sqs.region.amazonaws.com/account-id/queue-name), or the URL if it's not parsable
+func queueARN(queueURL string) string {
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return queueURL
+	}
+	path := strings.Split(strings.Trim(u.Path, "/"), "/")
+	host := strings.Split(u.Hostname(), ".")
+	if len(path) != 2 || len(host) < 3 || host[0] != "sqs" {
+		return queueURL
+	}
+	region := host[1]
+	partition := "aws"
+	switch {
+	case strings.HasSuffix(u.Hostname(), ".cn"):
+		partition = "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		partition = "aws-us-gov"
+	}
+	return fmt.Sprintf("arn:%s:sqs:%s:%s:%s", partition, region, path[0], path[1])
+}
@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessDenied(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized to perform: sqs:receivemessage"}
+
+	t.Run("fatal", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		client := &scriptedReceiveSqsClient{
+			mockedSqsClient: &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}},
+			errs:            []error{denied, denied, denied, denied},
+			cancel:          cancel,
+		}
+		var fatal error
+		worker := New(ctx, client, &Config{
+			QueueName:        "my-sqs-queue",
+			ThrottleCooldown: time.Millisecond,
+			Hooks:            Hooks{OnFatalError: func(ctx context.Context, err error) { fatal = err }},
+		})
+		err := worker.Run(ctx, HandlerFunc(func(msg *types.Message) error { return nil }))
+
+		var deniedErr *AccessDeniedError
+		if assert.True(t, errors.As(err, &deniedErr), "the worker stops with the terminal error") {
+			assert.Equal(t, "sqs:ReceiveMessage", deniedErr.Action)
+			assert.Equal(t, "arn:aws:sqs:eu-west-1:123456789:my-sqs-queue", deniedErr.Resource)
+			assert.Equal(t, 3, deniedErr.Attempts)
+			assert.True(t, IsAccessDenied(err))
+		}
+		assert.Same(t, err, fatal)
+		assert.Len(t, client.calls, 3, "the polling stops at the MaxAccessDenied")
+		assert.NotNil(t, worker.ShutdownReport(), "the in-flight messages are drained")
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", MaxAccessDenied: 2})
+		assert.Nil(t, worker.deniedReceive(denied))
+		worker.allowedReceive()
+		assert.Nil(t, worker.deniedReceive(denied), "the count is reset by the successful receive")
+		assert.NotNil(t, worker.deniedReceive(denied))
+	})
+
+	t.Run("kms", func(t *testing.T) {
+		worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", MaxAccessDenied: 1})
+		err := worker.deniedReceive(&smithy.GenericAPIError{Code: "KMS.AccessDeniedException"})
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "kms:Decrypt", err.Action)
+			assert.Contains(t, err.Error(), "the KMS key of arn:aws:sqs:eu-west-1:123456789:my-sqs-queue")
+		}
+	})
+}
+
+func TestQueueARN(t *testing.T) {
+	cases := map[string]string{
+		"https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue":          "arn:aws:sqs:eu-west-1:123456789:my-sqs-queue",
+		"https://sqs.cn-north-1.amazonaws.com.cn/123456789/my-sqs-queue":      "arn:aws-cn:sqs:cn-north-1:123456789:my-sqs-queue",
+		"https://sqs.us-gov-west-1.amazonaws.com/123456789/my-sqs-queue.fifo": "arn:aws-us-gov:sqs:us-gov-west-1:123456789:my-sqs-queue.fifo",
+		"http://localhost:4566/000000000000/my-sqs-queue":                     "http://localhost:4566/000000000000/my-sqs-queue",
+	}
+	for queueURL, want := range cases {
+		assert.Equal(t, want, queueARN(queueURL), queueURL)
+	}
+}
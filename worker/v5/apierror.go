@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"errors"
+
+	"github.com/aws/smithy-go"
+)
+
+// throttlingErrorCodes are the error codes of SQS rejecting the calls over the limit
+var throttlingErrorCodes = map[string]bool{
+	"OverLimit":           true,
+	"RequestThrottled":    true,
+	"ThrottlingException": true,
+	"Throttling":          true,
+}
+
+// queueMissingErrorCodes are the error codes of SQS for the queue not existing or deleted
+var queueMissingErrorCodes = map[string]bool{
+	"AWS.SimpleQueueService.NonExistentQueue": true,
+	"QueueDoesNotExist":                       true,
+}
+
+// accessDeniedErrorCodes are the error codes of the calls denied by the IAM or the queue policy
+var accessDeniedErrorCodes = map[string]bool{
+	"AccessDenied":              true,
+	"AccessDeniedException":     true,
+	"KMS.AccessDeniedException": true,
+}
+
+// apiErrorCode returns the error code of the smithy.APIError in the chain of the error, or empty
+func apiErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+	return apiErr.ErrorCode()
+}
+
+// IsThrottled reports whether the error of the AWS SDK is the throttling of the API, not a transient network error.
+// It unwraps the operation errors of the SDK, so the error returned by the clients can be passed as is.
+func IsThrottled(err error) bool {
+	return throttlingErrorCodes[apiErrorCode(err)]
+}
+
+// IsQueueMissing reports whether the error of the AWS SDK is the queue not existing, e.g. deleted or in the other region
+func IsQueueMissing(err error) bool {
+	return queueMissingErrorCodes[apiErrorCode(err)]
+}
+
+// IsAccessDenied reports whether the error of the AWS SDK is the call denied by the IAM policy, the queue policy or the KMS key policy
+func IsAccessDenied(err error) bool {
+	return accessDeniedErrorCodes[apiErrorCode(err)]
+}
@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIErrorPredicates(t *testing.T) {
+	operation := func(err error) error {
+		return &smithy.OperationError{ServiceID: "SQS", OperationName: "ReceiveMessage", Err: err}
+	}
+	cases := []struct {
+		err          error
+		throttled    bool
+		queueMissing bool
+		accessDenied bool
+	}{
+		{err: operation(&smithy.GenericAPIError{Code: "RequestThrottled"}), throttled: true},
+		{err: operation(&types.QueueDoesNotExist{}), queueMissing: true},
+		{err: operation(&smithy.GenericAPIError{Code: "AWS.SimpleQueueService.NonExistentQueue"}), queueMissing: true},
+		{err: operation(&smithy.GenericAPIError{Code: "AccessDenied"}), accessDenied: true},
+		{err: operation(&smithy.GenericAPIError{Code: "KMS.AccessDeniedException"}), accessDenied: true},
+		{err: operation(&smithy.GenericAPIError{Code: "InvalidParameterValue"})},
+		{err: errors.New("connection reset by peer")},
+		{err: nil},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.throttled, IsThrottled(c.err), "%v", c.err)
+		assert.Equal(t, c.queueMissing, IsQueueMissing(c.err), "%v", c.err)
+		assert.Equal(t, c.accessDenied, IsAccessDenied(c.err), "%v", c.err)
+	}
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// AuditRecord is the record of a processed message
+type AuditRecord struct {
+	MessageID   string        `json:"message_id"`
+	QueueURL    string        `json:"queue_url"`
+	Outcome     Outcome       `json:"outcome"`
+	Duration    time.Duration `json:"duration"`
+	Error       string        `json:"error,omitempty"`
+	Usage       Usage         `json:"usage,omitempty"`
+	ProcessedAt time.Time     `json:"processed_at"`
+}
+
+// AuditSink interface receives the audit records of processed messages (e.g. audit package)
+type AuditSink interface {
+	Record(ctx context.Context, record *AuditRecord) error
+}
+
+func (worker *Worker) audit(ctx context.Context, m *types.Message, outcome Outcome, duration time.Duration, usage Usage, err error) {
+	if worker.Config.AuditSink == nil {
+		return
+	}
+	record := &AuditRecord{
+		MessageID:   aws.ToString(m.MessageId),
+		QueueURL:    worker.Config.QueueURL,
+		Outcome:     outcome,
+		Duration:    duration,
+		Usage:       usage,
+		ProcessedAt: worker.now(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if err := worker.Config.AuditSink.Record(ctx, record); err != nil {
+		worker.Log.Warnf(ctx, "worker: Failed to record the audit, id=%s, err=%+v", record.MessageID, err)
+	}
+}
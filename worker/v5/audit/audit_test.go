@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockedExecer struct {
+	query string
+	args  []interface{}
+}
+
+func (m *mockedExecer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	m.query, m.args = query, args
+	return nil, nil
+}
+
+type mockedS3 struct {
+	keys   []string
+	bodies []string
+}
+
+func (m *mockedS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, _ := io.ReadAll(params.Body)
+	m.keys = append(m.keys, aws.ToString(params.Key))
+	m.bodies = append(m.bodies, string(body))
+	return &s3.PutObjectOutput{}, nil
+}
+
+func newRecord(id string) *worker.AuditRecord {
+	return &worker.AuditRecord{
+		MessageID:   id,
+		Outcome:     worker.OutcomeSucceeded,
+		Duration:    1500 * time.Millisecond,
+		ProcessedAt: time.Unix(1650000000, 0),
+	}
+}
+
+func TestSQLSink(t *testing.T) {
+	db := &mockedExecer{}
+	sink := NewSQLSink(db, "message_audit")
+
+	assert.NoError(t, sink.Record(context.Background(), newRecord("id-1")))
+	assert.True(t, strings.HasPrefix(db.query, "INSERT INTO message_audit "))
+	assert.Equal(t, []interface{}{"id-1", "", "succeeded", int64(1500), "", time.Unix(1650000000, 0)}, db.args)
+}
+
+func TestS3Sink(t *testing.T) {
+	client := &mockedS3{}
+	sink := NewS3Sink(client, "bucket", "audit")
+	sink.MaxRecords = 2
+	sink.now = func() time.Time { return time.Unix(1650000000, 0) }
+	ctx := context.Background()
+
+	assert.NoError(t, sink.Record(ctx, newRecord("id-1")))
+	assert.Empty(t, client.keys, "records are buffered")
+	assert.NoError(t, sink.Record(ctx, newRecord("id-2")))
+	assert.Equal(t, []string{"audit/2022/04/15/1650000000000000000.jsonl"}, client.keys)
+	assert.Equal(t, 2, strings.Count(client.bodies[0], "\n"), "one line per record")
+
+	assert.NoError(t, sink.Flush(ctx))
+	assert.Len(t, client.keys, 1, "empty buffer is not written")
+}
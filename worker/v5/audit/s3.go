@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+)
+
+// S3PutAPI interface is the minimum interface required for the S3Sink
+type S3PutAPI interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// S3Sink buffers the audit records and writes them to S3 as JSONL files.
+// The object key is {Prefix}/yyyy/mm/dd/{unix-nano}.jsonl.
+// Call Flush periodically and on shutdown to write the buffered records.
+type S3Sink struct {
+	Client S3PutAPI
+	Bucket string
+	Prefix string
+	// MaxRecords is the number of buffered records to trigger the flush (default: 1000)
+	MaxRecords int
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+	n   int
+	now func() time.Time
+}
+
+// NewS3Sink creates S3Sink struct
+func NewS3Sink(client S3PutAPI, bucket, prefix string) *S3Sink {
+	return &S3Sink{
+		Client:     client,
+		Bucket:     bucket,
+		Prefix:     prefix,
+		MaxRecords: 1000,
+		now:        time.Now,
+	}
+}
+
+// Record buffers the audit record, and flushes the buffer when it's full
+func (s *S3Sink) Record(ctx context.Context, record *worker.AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf.Write(line)
+	s.buf.WriteByte('\n')
+	s.n++
+	if s.n < s.MaxRecords {
+		return nil
+	}
+	return s.flush(ctx)
+}
+
+// Flush writes the buffered records to S3
+func (s *S3Sink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush(ctx)
+}
+
+func (s *S3Sink) flush(ctx context.Context) error {
+	if s.n == 0 {
+		return nil
+	}
+	now := s.now().UTC()
+	key := fmt.Sprintf("%s/%s/%d.jsonl", s.Prefix, now.Format("2006/01/02"), now.UnixNano())
+	if _, err := s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(s.buf.Bytes()),
+		ContentType: aws.String("application/x-ndjson"),
+	}); err != nil {
+		return fmt.Errorf("failed to put the audit records, key=%s, err=%w", key, err)
+	}
+	s.buf.Reset()
+	s.n = 0
+	return nil
+}
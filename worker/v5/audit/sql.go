@@ -0,0 +1,45 @@
+// Package audit provides the reference worker.AuditSink implementations.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+)
+
+// Execer interface is the minimum interface required for the SQLSink (e.g. *sql.DB)
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// SQLSink inserts the audit records into the database table.
+// The table requires the columns: message_id, queue_url, outcome, duration_ms, error, processed_at.
+type SQLSink struct {
+	DB Execer
+	// Query is the insert statement with the bind variables in the order of the columns above.
+	// The default uses "?" (MySQL), override it for other drivers (e.g. "$1" for PostgreSQL).
+	Query string
+}
+
+// NewSQLSink creates SQLSink struct
+func NewSQLSink(db Execer, table string) *SQLSink {
+	return &SQLSink{
+		DB:    db,
+		Query: fmt.Sprintf("INSERT INTO %s (message_id, queue_url, outcome, duration_ms, error, processed_at) VALUES (?, ?, ?, ?, ?, ?)", table),
+	}
+}
+
+// Record inserts the audit record
+func (s *SQLSink) Record(ctx context.Context, record *worker.AuditRecord) error {
+	_, err := s.DB.ExecContext(ctx, s.Query,
+		record.MessageID,
+		record.QueueURL,
+		string(record.Outcome),
+		record.Duration.Milliseconds(),
+		record.Error,
+		record.ProcessedAt,
+	)
+	return err
+}
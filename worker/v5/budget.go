@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// RetryBudget is the budget of the retries shared across the worker.
+// In each Window, up to Ratio of the processed messages (at least MinRetries) may be retried,
+// which prevents retry storms from amplifying downstream outages.
+type RetryBudget struct {
+	// Ratio is the maximum ratio of the retried messages to the processed messages (default: 0.2)
+	Ratio float64
+	// Window is the accounting window (default: 1 minute)
+	Window time.Duration
+	// MinRetries is the number of retries always allowed in each Window (default: 10)
+	MinRetries int
+}
+
+func (b *RetryBudget) populateDefaultValues() {
+	if b.Ratio <= 0 {
+		b.Ratio = 0.2
+	}
+	if b.Window <= 0 {
+		b.Window = time.Minute
+	}
+	if b.MinRetries <= 0 {
+		b.MinRetries = 10
+	}
+}
+
+type retryBudget struct {
+	config    *RetryBudget
+	mu        sync.Mutex
+	start     time.Time
+	processed int
+	retried   int
+	now       func() time.Time
+}
+
+func newRetryBudget(config *RetryBudget) *retryBudget {
+	if config == nil {
+		return nil
+	}
+	config.populateDefaultValues()
+	return &retryBudget{config: config, now: time.Now}
+}
+
+func (b *retryBudget) roll() {
+	if now := b.now(); now.Sub(b.start) >= b.config.Window {
+		b.start, b.processed, b.retried = now, 0, 0
+	}
+}
+
+// record counts the processed message
+func (b *retryBudget) record() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll()
+	b.processed++
+}
+
+// allow reports whether the retry is within the budget, and consumes it
+func (b *retryBudget) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll()
+	limit := int(math.Floor(b.config.Ratio * float64(b.processed)))
+	if limit < b.config.MinRetries {
+		limit = b.config.MinRetries
+	}
+	if b.retried >= limit {
+		return false
+	}
+	b.retried++
+	return true
+}
+
+// deadLetter sends the message to the dead-letter queue and deletes the original,
+// or leaves it to the redrive policy when the dead-letter queue is unknown
+func (worker *Worker) deadLetter(ctx context.Context, m *types.Message, cause error) (Outcome, error) {
+	if worker.deadLetterQueueURL == "" {
+		return OutcomeFailed, fmt.Errorf("worker: retry budget exhausted, message is left to the redrive policy: %w", cause)
+	}
+	if err := worker.requeue(ctx, worker.deadLetterQueueURL, m, 0, RetryAttempt(m)); err != nil {
+		return OutcomeFailed, fmt.Errorf("worker: failed to send the message to the dead-letter queue, err=%+v: %w", err, cause)
+	}
+	return OutcomeDeadLettered, fmt.Errorf("worker: retry budget exhausted, message is sent to the dead-letter queue: %w", cause)
+}
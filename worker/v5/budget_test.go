@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRetryBudget(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newRetryBudget(&RetryBudget{Ratio: 0.5, MinRetries: 1})
+	b.now = func() time.Time { return now }
+
+	b.record()
+	assert.True(t, b.allow(), "MinRetries is always allowed")
+	assert.False(t, b.allow())
+	for i := 0; i < 3; i++ {
+		b.record()
+	}
+	assert.True(t, b.allow(), "50% of 4 processed messages")
+	assert.False(t, b.allow())
+
+	now = now.Add(time.Minute)
+	assert.True(t, b.allow(), "the budget is reset in the next window")
+	assert.True(t, newRetryBudget(nil).allow(), "unlimited without the budget")
+}
+
+func TestRetryBudgetDeadLetter(t *testing.T) {
+	client := &mockedSenderSqsClient{&mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+	worker := New(context.Background(), client, &Config{
+		QueueName:      "my-sqs-queue",
+		RetryQueueName: "my-sqs-queue-retry",
+		RetryBudget:    &RetryBudget{MinRetries: 1, Ratio: 0.01},
+	})
+	worker.deadLetterQueueURL = "https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue-dlq"
+	failing := HandlerFunc(func(msg *types.Message) error { return errors.New("failure") })
+	client.On("SendMessage", mock.MatchedBy(func(in *sqs.SendMessageInput) bool {
+		return aws.ToString(in.QueueUrl) == worker.Config.RetryQueueURL
+	})).Return().Once()
+	client.On("SendMessage", mock.MatchedBy(func(in *sqs.SendMessageInput) bool {
+		return aws.ToString(in.QueueUrl) == worker.deadLetterQueueURL && in.DelaySeconds == 0
+	})).Return().Once()
+	client.On("DeleteMessage", mock.Anything).Return().Twice()
+
+	ctx := context.Background()
+	outcome, err := worker.handle(ctx, &types.Message{Body: aws.String("1"), ReceiptHandle: aws.String("r1")}, failing)
+	assert.Error(t, err)
+	assert.Equal(t, OutcomeRetried, outcome)
+	outcome, err = worker.handle(ctx, &types.Message{Body: aws.String("2"), ReceiptHandle: aws.String("r2")}, failing)
+	assert.Error(t, err)
+	assert.Equal(t, OutcomeDeadLettered, outcome, "the budget is exhausted")
+	client.AssertExpectations(t)
+
+	worker.deadLetterQueueURL = ""
+	outcome, _ = worker.handle(ctx, &types.Message{Body: aws.String("3"), ReceiptHandle: aws.String("r3")}, failing)
+	assert.Equal(t, OutcomeFailed, outcome, "left to the redrive policy without the dead-letter queue")
+}
@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// BufferPoolStats is a snapshot of the statistics of the buffers served by Buffer
+type BufferPoolStats struct {
+	// Gets is the number of buffers taken by the transformers and the handlers
+	Gets int64
+	// Allocated is the number of buffers allocated since the pool was empty
+	Allocated int64
+	// Returned is the number of buffers returned to the pool after the message was processed
+	Returned int64
+	// Discarded is the number of buffers not returned since they grew over the MaxPooledBufferSize
+	Discarded int64
+}
+
+// bufferPool is the sync.Pool of the buffers shared by the messages of the worker
+type bufferPool struct {
+	pool    sync.Pool
+	maxSize int
+
+	gets      int64
+	allocated int64
+	returned  int64
+	discarded int64
+}
+
+func newBufferPool(maxSize int) *bufferPool {
+	p := &bufferPool{maxSize: maxSize}
+	p.pool.New = func() interface{} {
+		atomic.AddInt64(&p.allocated, 1)
+		return new(bytes.Buffer)
+	}
+	return p
+}
+
+func (p *bufferPool) get() *bytes.Buffer {
+	if p == nil {
+		return new(bytes.Buffer)
+	}
+	atomic.AddInt64(&p.gets, 1)
+	buf := p.pool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func (p *bufferPool) put(buf *bytes.Buffer) {
+	if p == nil {
+		return
+	}
+	if buf.Cap() > p.maxSize {
+		// keeping the large buffers retains the memory of the largest payload ever
+		atomic.AddInt64(&p.discarded, 1)
+		return
+	}
+	atomic.AddInt64(&p.returned, 1)
+	p.pool.Put(buf)
+}
+
+func (p *bufferPool) stats() BufferPoolStats {
+	if p == nil {
+		return BufferPoolStats{}
+	}
+	return BufferPoolStats{
+		Gets:      atomic.LoadInt64(&p.gets),
+		Allocated: atomic.LoadInt64(&p.allocated),
+		Returned:  atomic.LoadInt64(&p.returned),
+		Discarded: atomic.LoadInt64(&p.discarded),
+	}
+}
+
+// Buffer returns an empty buffer from the pool of the worker for the decompression, decode and transform stages
+// and the handler of the message, so that the large payloads don't churn the GC.
+// The buffer is returned to the pool when the message is processed, so it must not be retained after the handler
+// and the body must be copied out of it (e.g. string(buf.Bytes())).
+// Outside of the message context, a new buffer is returned.
+func Buffer(ctx context.Context) *bytes.Buffer {
+	p, ok := ctx.Value(progressKey{}).(*progress)
+	if !ok {
+		return new(bytes.Buffer)
+	}
+	buf := p.worker.buffers.get()
+	p.mu.Lock()
+	p.buffers = append(p.buffers, buf)
+	p.mu.Unlock()
+	return buf
+}
+
+// releaseBuffers returns the buffers taken by the message to the pool
+func (p *progress) releaseBuffers() {
+	p.mu.Lock()
+	buffers := p.buffers
+	p.buffers = nil
+	p.mu.Unlock()
+	for _, buf := range buffers {
+		p.worker.buffers.put(buf)
+	}
+}
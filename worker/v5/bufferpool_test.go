@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipTransformer() TransformerFunc {
+	return func(ctx context.Context, msg *types.Message) error {
+		r, err := gzip.NewReader(bytes.NewBufferString(aws.ToString(msg.Body)))
+		if err != nil {
+			return err
+		}
+		buf := Buffer(ctx)
+		if _, err := io.Copy(buf, r); err != nil {
+			return err
+		}
+		msg.Body = aws.String(buf.String())
+		return nil
+	}
+}
+
+func gzipBody(t *testing.T, body string) string {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(body))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return buf.String()
+}
+
+func TestBuffer(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{
+		QueueName:    "my-sqs-queue",
+		Transformers: []TransformStage{{Name: "gunzip", Transformer: gzipTransformer()}},
+	})
+	var received []string
+	h := HandlerFunc(func(msg *types.Message) error {
+		received = append(received, aws.ToString(msg.Body))
+		return nil
+	})
+	for _, body := range []string{"scan-1", "scan-2"} {
+		m := &types.Message{MessageId: aws.String(body), Body: aws.String(gzipBody(t, body)), ReceiptHandle: aws.String(body)}
+		assert.NoError(t, worker.handleMessage(context.Background(), m, h))
+	}
+
+	assert.Equal(t, []string{"scan-1", "scan-2"}, received)
+	stats := worker.Stats().Buffers
+	assert.Equal(t, int64(2), stats.Gets)
+	assert.Equal(t, int64(2), stats.Returned, "the buffers are returned after the message is processed")
+	assert.Equal(t, int64(0), stats.Discarded)
+}
+
+func TestBufferDiscarded(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", MaxPooledBufferSize: 16})
+	m := &types.Message{MessageId: aws.String("large"), ReceiptHandle: aws.String("large")}
+	err := worker.handleMessage(context.Background(), m, ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+		Buffer(ctx).Write(make([]byte, 1024))
+		return nil
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), worker.Stats().Buffers.Discarded, "the buffer grown over the limit is not pooled")
+	assert.Equal(t, int64(0), worker.Stats().Buffers.Returned)
+}
+
+func TestBufferOutsideMessage(t *testing.T) {
+	buf := Buffer(context.Background())
+	buf.WriteString("scan")
+	assert.Equal(t, "scan", buf.String(), "a new buffer is returned outside of the message context")
+}
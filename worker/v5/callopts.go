@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// CallOptions configures the retries and the timeouts of the worker's own SQS calls,
+// since the call profile of the worker (long polls and many small deletes) differs from the rest of the application
+// sharing the client.
+type CallOptions struct {
+	// RetryMode is the SDK retry mode of the calls, aws.RetryModeStandard or aws.RetryModeAdaptive (default: the retryer of the client).
+	// The adaptive mode rate-limits the attempts of the worker on throttling apart from the rest of the application.
+	RetryMode aws.RetryMode
+	// MaxAttempts is the maximum number of attempts per call including the first one (default: the default of the retryer)
+	MaxAttempts int
+	// ReceiveTimeout is the timeout of each ReceiveMessage call including the retries (default: 0, none).
+	// It must be longer than the WaitTimeSecond of the long polling.
+	ReceiveTimeout time.Duration
+	// DeleteTimeout is the timeout of each DeleteMessage and DeleteMessageBatch call including the retries (default: 0, none)
+	DeleteTimeout time.Duration
+
+	once    sync.Once
+	retryer aws.Retryer
+}
+
+// getRetryer returns the retryer shared by the calls of the worker, so that the adaptive mode keeps its token bucket
+func (o *CallOptions) getRetryer() aws.Retryer {
+	o.once.Do(func() {
+		maxAttempts := func(so *retry.StandardOptions) {
+			if o.MaxAttempts > 0 {
+				so.MaxAttempts = o.MaxAttempts
+			}
+		}
+		switch o.RetryMode {
+		case aws.RetryModeAdaptive:
+			o.retryer = retry.NewAdaptiveMode(func(ao *retry.AdaptiveModeOptions) {
+				ao.StandardOptions = append(ao.StandardOptions, maxAttempts)
+			})
+		case aws.RetryModeStandard:
+			o.retryer = retry.NewStandard(maxAttempts)
+		}
+	})
+	return o.retryer
+}
+
+// apply sets the retryer to the options of the call
+func (o *CallOptions) apply(so *sqs.Options) {
+	if r := o.getRetryer(); r != nil {
+		so.Retryer = r
+		return
+	}
+	if o.MaxAttempts > 0 {
+		so.RetryMaxAttempts = o.MaxAttempts
+	}
+}
+
+// callContext returns the context with the timeout of the call when set
+func callContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (config *Config) receiveTimeout() time.Duration {
+	if config.CallOptions == nil {
+		return 0
+	}
+	return config.CallOptions.ReceiveTimeout
+}
+
+func (config *Config) deleteTimeout() time.Duration {
+	if config.CallOptions == nil {
+		return 0
+	}
+	return config.CallOptions.DeleteTimeout
+}
@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// optionsRecordingSqsClient records the deadline and the options of the calls
+type optionsRecordingSqsClient struct {
+	*mockedSqsClient
+	deadlines map[string]time.Duration
+	options   map[string]sqs.Options
+	cancel    context.CancelFunc
+}
+
+func (c *optionsRecordingSqsClient) record(ctx context.Context, op string, optFns []func(*sqs.Options)) {
+	if c.deadlines == nil {
+		c.deadlines, c.options = map[string]time.Duration{}, map[string]sqs.Options{}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.deadlines[op] = time.Until(deadline)
+	}
+	var o sqs.Options
+	for _, fn := range optFns {
+		fn(&o)
+	}
+	c.options[op] = o
+}
+
+func (c *optionsRecordingSqsClient) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	c.record(ctx, "ReceiveMessage", optFns)
+	return &sqs.ReceiveMessageOutput{Messages: []types.Message{{ReceiptHandle: aws.String("receipt")}}}, nil
+}
+
+func (c *optionsRecordingSqsClient) DeleteMessage(ctx context.Context, input *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	c.record(ctx, "DeleteMessage", optFns)
+	c.cancel()
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func TestCallOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := &optionsRecordingSqsClient{mockedSqsClient: &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}, cancel: cancel}
+	worker := New(ctx, client, &Config{
+		QueueName: "my-sqs-queue",
+		CallOptions: &CallOptions{
+			RetryMode:      aws.RetryModeAdaptive,
+			MaxAttempts:    5,
+			ReceiveTimeout: 25 * time.Second,
+			DeleteTimeout:  3 * time.Second,
+		},
+	})
+	worker.Start(ctx, HandlerFunc(func(msg *types.Message) error { return nil }))
+
+	assert.InDelta(t, float64(25*time.Second), float64(client.deadlines["ReceiveMessage"]), float64(time.Second))
+	assert.InDelta(t, float64(3*time.Second), float64(client.deadlines["DeleteMessage"]), float64(time.Second))
+	receiveRetryer := client.options["ReceiveMessage"].Retryer
+	assert.IsType(t, &retry.AdaptiveMode{}, receiveRetryer)
+	assert.Equal(t, 5, receiveRetryer.MaxAttempts())
+	assert.Same(t, receiveRetryer, client.options["DeleteMessage"].Retryer, "the retryer is shared across the calls of the worker")
+}
+
+func TestCallOptionsMaxAttemptsOnly(t *testing.T) {
+	config := &Config{CallOptions: &CallOptions{MaxAttempts: 2}}
+	var o sqs.Options
+	for _, fn := range config.sqsOptions() {
+		fn(&o)
+	}
+	assert.Nil(t, o.Retryer, "the retryer of the client is kept")
+	assert.Equal(t, 2, o.RetryMaxAttempts)
+	assert.Empty(t, (&Config{}).sqsOptions())
+}
@@ -0,0 +1,160 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// maxRememberedCancellations is the number of the canceled correlation keys remembered for the prefetched messages
+const maxRememberedCancellations = 10000
+
+// CanceledMessageAction is the action for the message whose correlation key was canceled by CancelRelated
+type CanceledMessageAction string
+
+const (
+	// CanceledDelete deletes the message without the handler
+	CanceledDelete CanceledMessageAction = "delete"
+	// CanceledPark moves the message to the ParkingLot, or deletes it when no ParkingLot is configured
+	CanceledPark CanceledMessageAction = "park"
+)
+
+// cancellations tracks the canceled correlation keys and the cancel functions of the running handlers by the key
+type cancellations struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu       sync.Mutex
+	canceled map[string]time.Time
+	order    []string
+	running  map[string]map[*progress]context.CancelFunc
+}
+
+func newCancellations(ttl time.Duration, now func() time.Time) *cancellations {
+	return &cancellations{
+		ttl:      ttl,
+		now:      now,
+		canceled: map[string]time.Time{},
+		running:  map[string]map[*progress]context.CancelFunc{},
+	}
+}
+
+// isCanceled reports whether the key was canceled within the ttl
+func (c *cancellations) isCanceled(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiresAt, ok := c.canceled[key]
+	return ok && c.now().Before(expiresAt)
+}
+
+// cancel remembers the key and cancels the running handlers of the key other than the caller, and returns their number
+func (c *cancellations) cancel(key string, caller *progress) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.canceled[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.canceled[key] = c.now().Add(c.ttl)
+	for len(c.order) > maxRememberedCancellations {
+		delete(c.canceled, c.order[0])
+		c.order = c.order[1:]
+	}
+	n := 0
+	for p, cancel := range c.running[key] {
+		if p == caller {
+			continue
+		}
+		p.mu.Lock()
+		p.canceled = true
+		p.mu.Unlock()
+		cancel()
+		n++
+	}
+	return n
+}
+
+// watch registers the handler of the key to be canceled by CancelRelated, and the returned function unregisters it
+func (c *cancellations) watch(ctx context.Context, key string, p *progress) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	if c.running[key] == nil {
+		c.running[key] = map[*progress]context.CancelFunc{}
+	}
+	c.running[key][p] = cancel
+	c.mu.Unlock()
+	return ctx, func() {
+		c.mu.Lock()
+		delete(c.running[key], p)
+		if len(c.running[key]) == 0 {
+			delete(c.running, key)
+		}
+		c.mu.Unlock()
+		cancel()
+	}
+}
+
+// CancelRelated cancels the other messages sharing the correlation key derived by Config.CorrelationKey,
+// e.g. the chunked sub-messages of the aborted scan job, from the context of the ContextHandler.
+// The running handlers of the key are canceled and their messages are handled by the CanceledMessageAction
+// after they return, and the messages of the key received within the CancellationTTL skip the handler.
+// It returns the number of the running handlers canceled, and it's a no-op outside of the handler context.
+func CancelRelated(ctx context.Context, key string) int {
+	p, ok := ctx.Value(progressKey{}).(*progress)
+	if !ok || p.worker.cancels == nil || key == "" {
+		return 0
+	}
+	n := p.worker.cancels.cancel(key, p)
+	p.worker.logEvent(ctx, LogEventCanceled, "worker: Canceled the related messages, key=%s, running=%d", key, n)
+	return n
+}
+
+// correlationKey returns the correlation key of the message, or empty without Config.CorrelationKey
+func (worker *Worker) correlationKey(m *types.Message) string {
+	if worker.cancels == nil {
+		return ""
+	}
+	return worker.Config.CorrelationKey(m)
+}
+
+// watchCancellation registers the handler of the message to be canceled by CancelRelated
+func (worker *Worker) watchCancellation(ctx context.Context, key string, p *progress) (context.Context, func()) {
+	if key == "" {
+		return ctx, func() {}
+	}
+	if worker.cancels.isCanceled(key) {
+		p.mu.Lock()
+		p.canceled = true
+		p.mu.Unlock()
+	}
+	return worker.cancels.watch(ctx, key, p)
+}
+
+// canceledByKey reports whether the correlation key of the message in the context was canceled
+func canceledByKey(ctx context.Context) bool {
+	p, ok := ctx.Value(progressKey{}).(*progress)
+	if !ok {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.canceled
+}
+
+// skipCanceled deletes or parks the message of the canceled correlation key by the CanceledMessageAction
+func (worker *Worker) skipCanceled(ctx context.Context, m *types.Message) (Outcome, error) {
+	worker.stats.addCanceled()
+	key := worker.correlationKey(m)
+	if worker.Config.CanceledMessageAction == CanceledPark && worker.Config.ParkingLot != nil {
+		worker.logEvent(ctx, LogEventCanceled, "worker: Parking the message of the canceled key, id=%s, key=%s", aws.ToString(m.MessageId), key)
+		return worker.parkMessage(ctx, m, &ParkError{Reason: fmt.Sprintf("canceled by the related message, key=%s", key)})
+	}
+	worker.logEvent(ctx, LogEventCanceled, "worker: Deleting the message of the canceled key, id=%s, key=%s", aws.ToString(m.MessageId), key)
+	if err := worker.deleteMessage(ctx, m); err != nil {
+		return OutcomeFailed, fmt.Errorf("worker: failed to delete the canceled message, err=%w", err)
+	}
+	return OutcomeCanceled, nil
+}
@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func scanChunk(id, scanID string) *types.Message {
+	return &types.Message{
+		MessageId:     aws.String(id),
+		ReceiptHandle: aws.String(id),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"scan_id": {DataType: aws.String("String"), StringValue: aws.String(scanID)},
+		},
+	}
+}
+
+func TestCancelRelated(t *testing.T) {
+	ctx := context.Background()
+	client := &countingDeleteSqsClient{}
+	worker := New(ctx, client, &Config{
+		QueueName:      "my-sqs-queue",
+		CorrelationKey: func(m *types.Message) string { return NewMessage(m).Attr("scan_id") },
+	})
+	running := make(chan struct{})
+	var handled int64
+	h := ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+		atomic.AddInt64(&handled, 1)
+		switch aws.ToString(msg.MessageId) {
+		case "running":
+			close(running)
+			<-ctx.Done()
+			return ctx.Err()
+		case "abort":
+			<-running
+			assert.Equal(t, 1, CancelRelated(ctx, "scan-1"), "the running sibling is canceled")
+		}
+		return nil
+	})
+
+	outcomes := make(chan Outcome, 1)
+	go func() {
+		outcome, _ := worker.handle(ctx, scanChunk("running", "scan-1"), h)
+		outcomes <- outcome
+	}()
+	outcome, err := worker.handle(ctx, scanChunk("abort", "scan-1"), h)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeSucceeded, outcome, "the message canceling the key is processed as usual")
+	assert.Equal(t, OutcomeCanceled, <-outcomes, "the canceled sibling is deleted instead of failed")
+
+	outcome, err = worker.handle(ctx, scanChunk("prefetched", "scan-1"), h)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeCanceled, outcome, "the message of the canceled key skips the handler")
+	outcome, err = worker.handle(ctx, scanChunk("other", "scan-2"), h)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeSucceeded, outcome, "the other keys are not affected")
+
+	assert.Equal(t, int64(3), atomic.LoadInt64(&handled))
+	assert.Equal(t, int64(4), atomic.LoadInt64(&client.deleted))
+	assert.Equal(t, int64(2), worker.Stats().Canceled)
+}
+
+func TestCancelRelatedPark(t *testing.T) {
+	ctx := context.Background()
+	lot := &mockedParkingLot{}
+	worker := New(ctx, &countingDeleteSqsClient{}, &Config{
+		QueueName:             "my-sqs-queue",
+		CorrelationKey:        func(m *types.Message) string { return NewMessage(m).Attr("scan_id") },
+		CanceledMessageAction: CanceledPark,
+		ParkingLot:            lot,
+	})
+	h := ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+		CancelRelated(ctx, "scan-1")
+		return nil
+	})
+	_, err := worker.handle(ctx, scanChunk("abort", "scan-1"), h)
+	assert.NoError(t, err)
+	outcome, err := worker.handle(ctx, scanChunk("prefetched", "scan-1"), h)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeParked, outcome)
+	assert.Len(t, lot.parked, 1)
+}
+
+func TestCancelRelatedOutsideHandler(t *testing.T) {
+	assert.Equal(t, 0, CancelRelated(context.Background(), "scan-1"))
+}
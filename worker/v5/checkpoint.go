@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// progress is the checkpoint state and the usage of the message being handled
+type progress struct {
+	worker *Worker
+	m      *types.Message
+
+	mu           sync.Mutex
+	last         time.Time
+	checkpoints  int
+	lastExtended time.Time
+	usage        Usage
+	// expiresAt is the end of the visibility of the message watched by CancelOnVisibilityExpiry
+	expiresAt time.Time
+	lost      bool
+	// canceled is set when the correlation key of the message was canceled by CancelRelated
+	canceled bool
+	// buffers are taken from the pool by Buffer and returned when the message is processed
+	buffers []*bytes.Buffer
+}
+
+type progressKey struct{}
+
+func (worker *Worker) withProgress(ctx context.Context, m *types.Message) (context.Context, *progress) {
+	p := &progress{worker: worker, m: m}
+	return context.WithValue(ctx, progressKey{}, p), p
+}
+
+// snapshot returns the last checkpoint time and the number of checkpoints
+func (p *progress) snapshot() (time.Time, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.last, p.checkpoints
+}
+
+// takeUsage returns the usage recorded by the handler
+func (p *progress) takeUsage() Usage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	usage := p.usage
+	p.usage = nil
+	return usage
+}
+
+// Checkpoint records the progress of the long multi-step handler from the context of the ContextHandler.
+// With Config.CheckpointVisibilityTimeout, the visibility of the message is extended to the timeout
+// at most once per half of it. The checkpoints are included in the watchdog diagnostics.
+// It's a no-op outside of the handler context.
+func Checkpoint(ctx context.Context) error {
+	p, ok := ctx.Value(progressKey{}).(*progress)
+	if !ok {
+		return nil
+	}
+	timeout := p.worker.Config.CheckpointVisibilityTimeout
+	now := p.worker.now()
+	p.mu.Lock()
+	p.last = now
+	p.checkpoints++
+	extend := timeout > 0 && (p.lastExtended.IsZero() || now.Sub(p.lastExtended) >= time.Duration(timeout)*time.Second/2)
+	if extend {
+		p.lastExtended = now
+	}
+	p.mu.Unlock()
+	if !extend {
+		return nil
+	}
+	if err := p.worker.changeVisibility(ctx, p.m, timeout); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.expiresAt = now.Add(time.Duration(timeout) * time.Second)
+	p.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCheckpoint(t *testing.T) {
+	client := &mockedVisibilitySqsClient{&mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+	client.On("DeleteMessage", mock.Anything).Return()
+	client.On("ChangeMessageVisibility", &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String("https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue"),
+		ReceiptHandle:     aws.String("receipt"),
+		VisibilityTimeout: 60,
+	}).Return().Once()
+	events := make(chan *StuckHandlerEvent, 1)
+	worker := New(context.Background(), client, &Config{
+		QueueName:                   "my-sqs-queue",
+		CheckpointVisibilityTimeout: 60,
+		WatchdogTimeout:             10 * time.Millisecond,
+		Hooks: Hooks{
+			OnStuckHandler: func(ctx context.Context, event *StuckHandlerEvent) { events <- event },
+		},
+	})
+
+	assert.NoError(t, Checkpoint(context.Background()), "no-op outside of the handler")
+	var event *StuckHandlerEvent
+	err := worker.handleMessage(context.Background(), &types.Message{ReceiptHandle: aws.String("receipt")}, ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+		assert.NoError(t, Checkpoint(ctx))
+		assert.NoError(t, Checkpoint(ctx), "the visibility is not extended again within the half of the timeout")
+		event = <-events
+		return nil
+	}))
+	assert.NoError(t, err)
+	client.AssertExpectations(t)
+	assert.Equal(t, 2, event.Checkpoints)
+	assert.False(t, event.LastCheckpoint.IsZero())
+}
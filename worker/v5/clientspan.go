@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"context"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// clientSpanName is the operation name of the client spans of the SQS calls
+const clientSpanName = "sqs.request"
+
+func finishNoSpan(err error) {}
+
+// startClientSpan counts the SQS call for the RequestStats, and starts its client span as the child of the span in the context
+// when Config.TraceSQSCalls is set, so that the SQS latency is distinguishable from the handler latency in the traces.
+// The returned function finishes the span with the error of the call.
+func (worker *Worker) startClientSpan(ctx context.Context, operation string) (context.Context, func(err error)) {
+	worker.countRequest(ctx, operation)
+	if !worker.Config.TraceSQSCalls {
+		return ctx, finishNoSpan
+	}
+	span, ctx := tracer.StartSpanFromContext(ctx, clientSpanName,
+		tracer.ResourceName("SQS."+operation),
+		tracer.SpanType(ext.SpanTypeHTTP),
+		tracer.Tag("span.kind", "client"),
+		tracer.Tag("aws.service", "SQS"),
+		tracer.Tag("aws.operation", operation),
+		tracer.Tag("queue_name", worker.Config.QueueName),
+		tracer.Tag("queue_url", worker.Config.QueueURL),
+	)
+	return ctx, func(err error) {
+		for k, v := range requestFields(err) {
+			span.SetTag(k, v)
+		}
+		span.Finish(tracer.WithError(err))
+	}
+}
@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+func TestClientSpans(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	handle := HandlerFunc(func(msg *types.Message) error { return nil })
+
+	t.Run("traced", func(t *testing.T) {
+		defer mt.Reset()
+		worker := New(context.Background(), &batchesSqsClient{batches: [][]types.Message{testBatch("1")}}, &Config{QueueName: "my-sqs-queue", TraceSQSCalls: true})
+		parent, ctx := tracer.StartSpanFromContext(context.Background(), "worker.test")
+		assert.NoError(t, worker.DrainOnce(ctx, handle))
+		parent.Finish()
+
+		var startup, resources []string
+		for _, span := range mt.FinishedSpans() {
+			if span.OperationName() != clientSpanName {
+				continue
+			}
+			assert.Equal(t, "client", span.Tag("span.kind"))
+			if span.ParentID() != parent.Context().SpanID() {
+				// the calls of New are not under the span in the context
+				startup = append(startup, span.Tag("resource.name").(string))
+				continue
+			}
+			resources = append(resources, span.Tag("resource.name").(string))
+			assert.Equal(t, "https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue", span.Tag("queue_url"))
+		}
+		assert.Equal(t, []string{"SQS.GetQueueUrl"}, startup, "the startup calls are traced too")
+		assert.Equal(t, []string{"SQS.ReceiveMessage", "SQS.DeleteMessage"}, resources[:2], "the client spans are the children of the span in the context")
+		assert.NotContains(t, resources[2:], "SQS.DeleteMessage", "the empty receives until the queue is drained follow")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		defer mt.Reset()
+		worker := New(context.Background(), &batchesSqsClient{batches: [][]types.Message{testBatch("1")}}, &Config{QueueName: "my-sqs-queue"})
+		assert.NoError(t, worker.DrainOnce(context.Background(), handle))
+		assert.Empty(t, mt.FinishedSpans())
+	})
+}
@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is the source of the time used by the backoffs, the heartbeats, the batching windows and the idle detection
+// of the worker, so that the time-dependent behavior can be tested without sleeps (e.g. workertest.FakeClock).
+// The durations reported in the logs and the stats are measured by the wall clock regardless of it.
+type Clock interface {
+	Now() time.Time
+	// NewTimer creates the Timer sending the time on its channel after the duration
+	NewTimer(d time.Duration) Timer
+	// AfterFunc calls the function in its own goroutine after the duration
+	AfterFunc(d time.Duration, f func()) Timer
+	// NewTicker creates the Ticker sending the time on its channel every duration
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is the timer created by the Clock
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Ticker is the ticker created by the Clock
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SystemClock is the Clock of the time package, used by default
+type SystemClock struct{}
+
+// Now returns the current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer wraps time.NewTimer
+func (SystemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+// AfterFunc wraps time.AfterFunc
+func (SystemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+// NewTicker wraps time.NewTicker
+func (SystemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// clock returns the Clock of the config
+func (config *Config) clock() Clock {
+	if config.Clock == nil {
+		return SystemClock{}
+	}
+	return config.Clock
+}
+
+// clock returns the Clock of the worker
+func (worker *Worker) clock() Clock {
+	return worker.Config.clock()
+}
+
+// clockFromContext returns the Clock of the worker handling the message in the context, or the SystemClock outside of the handler
+func clockFromContext(ctx context.Context) Clock {
+	if p, ok := ctx.Value(progressKey{}).(*progress); ok {
+		return p.worker.clock()
+	}
+	return SystemClock{}
+}
+
+func (worker *Worker) now() time.Time {
+	return worker.clock().Now()
+}
+
+func (worker *Worker) since(t time.Time) time.Duration {
+	return worker.now().Sub(t)
+}
+
+func (worker *Worker) until(t time.Time) time.Duration {
+	return t.Sub(worker.now())
+}
@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// skippingClock fires every timer immediately and records the requested durations
+type skippingClock struct {
+	SystemClock
+	requested []time.Duration
+}
+
+func (c *skippingClock) NewTimer(d time.Duration) Timer {
+	c.requested = append(c.requested, d)
+	return c.SystemClock.NewTimer(0)
+}
+
+func TestClock(t *testing.T) {
+	ctx := context.Background()
+	clock := &skippingClock{}
+	worker := New(ctx, &countingDeleteSqsClient{}, &Config{
+		QueueName:    "my-sqs-queue",
+		HandlerRetry: &HandlerRetry{MaxAttempts: 3, Backoff: time.Hour, MaxBackoff: 2 * time.Hour},
+		Clock:        clock,
+	})
+	m := &types.Message{MessageId: aws.String("blip"), ReceiptHandle: aws.String("blip")}
+
+	start := time.Now()
+	outcome, _ := worker.handle(ctx, m, HandlerFunc(func(msg *types.Message) error { return errors.New("connection reset") }))
+	assert.Equal(t, OutcomeFailed, outcome)
+	assert.Equal(t, []time.Duration{time.Hour, 2 * time.Hour}, clock.requested, "the backoffs wait on the Clock")
+	assert.Less(t, int64(time.Since(start)), int64(time.Minute))
+}
+
+func TestSystemClock(t *testing.T) {
+	var clock Clock = SystemClock{}
+	timer := clock.NewTimer(time.Millisecond)
+	<-timer.C()
+	assert.False(t, timer.Stop(), "the fired timer can't be stopped")
+
+	ticker := clock.NewTicker(time.Millisecond)
+	<-ticker.C()
+	<-ticker.C()
+	ticker.Stop()
+
+	fired := make(chan struct{})
+	clock.AfterFunc(time.Millisecond, func() { close(fired) })
+	<-fired
+}
+
+func TestClockSupervisor(t *testing.T) {
+	clock := &skippingClock{}
+	worker := New(context.Background(), &panickingSqsClient{}, &Config{QueueName: "my-sqs-queue", DrainTimeout: time.Second, Clock: clock})
+	handled := make(chan struct{})
+	supervisor := NewSupervisor()
+	supervisor.MinBackoff = time.Hour
+	supervisor.Add(worker, HandlerFunc(func(msg *types.Message) error {
+		close(handled)
+		return nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		supervisor.Run(ctx)
+	}()
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("the worker is not restarted by the Clock")
+	}
+	cancel()
+	<-done
+	assert.Contains(t, clock.requested, time.Hour, "the restart backoff waits on the Clock")
+}
+
+func TestClockRouter(t *testing.T) {
+	clock := &skippingClock{}
+	worker := New(context.Background(), &countingDeleteSqsClient{}, &Config{QueueName: "my-sqs-queue", Clock: clock})
+	record := HandlerFunc(func(msg *types.Message) error { return nil })
+	router := NewRouter(AttributeRoute("Type")).Handle("aws", record).RateLimit("aws", 0.5)
+	router.RateLimitMaxWait = time.Minute
+
+	for i := 0; i < 2; i++ {
+		outcome, err := worker.handle(context.Background(), routedMessage("aws"), router)
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeSucceeded, outcome)
+	}
+	if assert.Len(t, clock.requested, 1) {
+		assert.InDelta(t, float64(2*time.Second), float64(clock.requested[0]), float64(100*time.Millisecond), "the rate limit waits on the Clock")
+	}
+}
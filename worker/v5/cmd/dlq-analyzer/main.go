@@ -0,0 +1,50 @@
+// dlq-analyzer samples a dead-letter queue and prints the summary of the messages grouped by the error or the payload shape.
+//
+//	dlq-analyzer -queue my-sqs-queue-dlq -region ap-northeast-1 -error-attribute ErrorMessage
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+	"github.com/ca-risken/go-sqs-poller/worker/v5/dlqanalyzer"
+)
+
+func main() {
+	queue := flag.String("queue", "", "the name of the dead-letter queue (required)")
+	region := flag.String("region", os.Getenv("AWS_REGION"), "the region of the queue")
+	endpoint := flag.String("endpoint", "", "the endpoint of sqs (e.g. http://localhost:4566 for LocalStack)")
+	samples := flag.Int("samples", 100, "the maximum number of sampled messages")
+	errorAttribute := flag.String("error-attribute", "", "the message attribute grouping the messages")
+	flag.Parse()
+	if *queue == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(context.Background(), *queue, *region, *endpoint, dlqanalyzer.Options{Samples: *samples, ErrorAttribute: *errorAttribute}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, queue, region, endpoint string, opts dlqanalyzer.Options) error {
+	client, err := worker.CreateQueueClient(ctx, &worker.Config{Region: region}, endpoint)
+	if err != nil {
+		return err
+	}
+	url, err := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queue)})
+	if err != nil {
+		return fmt.Errorf("failed to get the queue url: %w", err)
+	}
+	report, err := dlqanalyzer.Analyze(ctx, client, aws.ToString(url.QueueUrl), opts)
+	if err != nil {
+		return err
+	}
+	return report.Print(os.Stdout)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/common/pkg/logging"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+)
+
+func consume(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("consume", flag.ExitOnError)
+	queue := flags.String("queue", "", "the name of the queue (required)")
+	region := flags.String("region", os.Getenv("AWS_REGION"), "the region of the queue")
+	endpoint := flags.String("endpoint", "", "the endpoint of sqs (e.g. http://localhost:4566 for LocalStack)")
+	command := flags.String("exec", "", "the shell command run per message with the body on stdin, the message is deleted on exit 0 (default: print)")
+	attributes := flags.Bool("attributes", false, "print the message attributes with the print handler")
+	maxMessages := flags.Int("max-messages", 10, "the maximum number of messages per receive")
+	wait := flags.Int("wait", 20, "the wait time seconds of the receive")
+	concurrency := flags.Int("concurrency", 1, "the maximum number of concurrent handlers")
+	verbose := flags.Bool("verbose", false, "enable the debug logs of the worker")
+	_ = flags.Parse(args)
+	if *queue == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	config := &worker.Config{
+		QueueName:          *queue,
+		Region:             *region,
+		MaxNumberOfMessage: int32(*maxMessages),
+		WaitTimeSecond:     int32(*wait),
+		Concurrency:        *concurrency,
+	}
+	client, err := worker.CreateQueueClient(ctx, config, *endpoint)
+	if err != nil {
+		return err
+	}
+	w := worker.New(ctx, client, config)
+	if !*verbose {
+		w.Log.Level(logging.WarnLevel)
+	}
+
+	var h worker.Handler = printHandler(*attributes)
+	if *command != "" {
+		h = execHandler(*command)
+	}
+	w.Start(ctx, h)
+	return nil
+}
+
+// printHandler prints the message body to stdout, and the attributes when enabled
+func printHandler(attributes bool) worker.ContextHandlerFunc {
+	return func(ctx context.Context, msg *types.Message) error {
+		if attributes {
+			for name, v := range msg.MessageAttributes {
+				fmt.Printf("# %s=%s\n", name, aws.ToString(v.StringValue))
+			}
+		}
+		fmt.Println(aws.ToString(msg.Body))
+		return nil
+	}
+}
+
+// execHandler runs the command with the body on stdin, and the message ID and attributes in the environment
+func execHandler(command string) worker.ContextHandlerFunc {
+	return func(ctx context.Context, msg *types.Message) error {
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Stdin = strings.NewReader(aws.ToString(msg.Body))
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(), "SQS_MESSAGE_ID="+aws.ToString(msg.MessageId))
+		for name, v := range msg.MessageAttributes {
+			if v.StringValue != nil {
+				cmd.Env = append(cmd.Env, "SQS_ATTRIBUTE_"+strings.ToUpper(name)+"="+aws.ToString(v.StringValue))
+			}
+		}
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to run the command, id=%s, err=%w", aws.ToString(msg.MessageId), err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,45 @@
+// sqs-poller is the command line tool for debugging the queues, e.g. on LocalStack.
+//
+//	sqs-poller consume -queue my-sqs-queue -endpoint http://localhost:4566
+//	sqs-poller consume -queue my-sqs-queue -exec 'jq .'
+//	echo '{"project_id":1}' | sqs-poller send -queue my-sqs-queue -attribute TenantID=123
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const usage = `usage: sqs-poller <command> [flags]
+
+commands:
+  consume   consume the queue with the print or exec handler
+  send      send the JSON payloads from a file or stdin
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "consume":
+		err = consume(ctx, os.Args[2:])
+	case "send":
+		err = send(ctx, os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+)
+
+// attributeFlags is the repeatable name=value flag of the message attributes
+type attributeFlags map[string]types.MessageAttributeValue
+
+func (a attributeFlags) String() string {
+	return fmt.Sprint(map[string]types.MessageAttributeValue(a))
+}
+
+func (a attributeFlags) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("invalid attribute %q, expected name=value", value)
+	}
+	a[kv[0]] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(kv[1])}
+	return nil
+}
+
+func send(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("send", flag.ExitOnError)
+	queue := flags.String("queue", "", "the name of the queue (required)")
+	region := flags.String("region", os.Getenv("AWS_REGION"), "the region of the queue")
+	endpoint := flags.String("endpoint", "", "the endpoint of sqs (e.g. http://localhost:4566 for LocalStack)")
+	file := flags.String("file", "-", "the JSON payload file, - for stdin")
+	lines := flags.Bool("lines", false, "send each line of the payload as a message (JSON Lines)")
+	delay := flags.Int("delay", 0, "the delay seconds of the messages (0-900)")
+	group := flags.String("group", "", "the message group ID for FIFO queues")
+	attributes := attributeFlags{}
+	flags.Var(attributes, "attribute", "the string message attribute as name=value, repeatable")
+	_ = flags.Parse(args)
+	if *queue == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *delay < 0 || *delay > 900 {
+		return fmt.Errorf("invalid delay: %d", *delay)
+	}
+
+	payloads, err := readPayloads(*file, *lines)
+	if err != nil {
+		return err
+	}
+	config := &worker.Config{QueueName: *queue, Region: *region}
+	client, err := worker.CreateQueueClient(ctx, config, *endpoint)
+	if err != nil {
+		return err
+	}
+	sender, ok := client.(worker.SenderAPI)
+	if !ok {
+		return errors.New("the sqs client does not support SendMessage")
+	}
+	url, err := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(*queue)})
+	if err != nil {
+		return fmt.Errorf("failed to get the queue url: %w", err)
+	}
+	for i, payload := range payloads {
+		input := &sqs.SendMessageInput{
+			QueueUrl:     url.QueueUrl,
+			MessageBody:  aws.String(payload),
+			DelaySeconds: int32(*delay),
+		}
+		if len(attributes) > 0 {
+			input.MessageAttributes = attributes
+		}
+		if *group != "" {
+			input.MessageGroupId = aws.String(*group)
+		}
+		out, err := sender.SendMessage(ctx, input)
+		if err != nil {
+			return fmt.Errorf("failed to send the message #%d: %w", i+1, err)
+		}
+		fmt.Println(aws.ToString(out.MessageId))
+	}
+	return nil
+}
+
+// readPayloads reads the JSON payloads from the file or stdin, and validates them
+func readPayloads(file string, lines bool) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var payloads []string
+	if lines {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 256*1024)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				payloads = append(payloads, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		payloads = []string{strings.TrimSpace(string(data))}
+	}
+	for i, p := range payloads {
+		if !json.Valid([]byte(p)) {
+			return nil, fmt.Errorf("invalid JSON payload #%d", i+1)
+		}
+	}
+	return payloads, nil
+}
@@ -0,0 +1,274 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"gopkg.in/yaml.v3"
+)
+
+// FileConfig is the worker configuration loaded by LoadConfig
+type FileConfig struct {
+	// Region is the default AWS region of the queues
+	Region string `yaml:"region"`
+	// Endpoint is the custom SQS endpoint (e.g. LocalStack) of the clients created by QueueClient
+	Endpoint string        `yaml:"endpoint"`
+	Queues   []QueueConfig `yaml:"queues"`
+}
+
+// QueueConfig is the configuration of a worker per queue
+type QueueConfig struct {
+	Name                        string        `yaml:"name"`
+	Region                      string        `yaml:"region"`
+	Profile                     string        `yaml:"profile"`
+	QueueOwnerAWSAccountID      string        `yaml:"queue_owner_aws_account_id"`
+	MaxNumberOfMessage          int32         `yaml:"max_number_of_message"`
+	WaitTimeSecond              int32         `yaml:"wait_time_second"`
+	VisibilityTimeout           int32         `yaml:"visibility_timeout"`
+	QueueTagOverrides           bool          `yaml:"queue_tag_overrides"`
+	TraceSQSCalls               bool          `yaml:"trace_sqs_calls"`
+	MessageAttributeNames       []string      `yaml:"message_attribute_names"`
+	MessageSystemAttributeNames []string      `yaml:"message_system_attribute_names"`
+	Concurrency                 int           `yaml:"concurrency"`
+	SubBatchSize                int           `yaml:"sub_batch_size"`
+	RateLimit                   float64       `yaml:"rate_limit"`
+	KeyedLanes                  int           `yaml:"keyed_lanes"`
+	WarmPool                    int           `yaml:"warm_pool"`
+	PriorityAttribute           string        `yaml:"priority_attribute"`
+	PriorityLanes               int           `yaml:"priority_lanes"`
+	DeadLetterPollInterval      time.Duration `yaml:"dead_letter_poll_interval"`
+	DrainTimeout                time.Duration `yaml:"drain_timeout"`
+	MaxHops                     int           `yaml:"max_hops"`
+	HopLimitAction              string        `yaml:"hop_limit_action"`
+	MaxMessageAge               time.Duration `yaml:"max_message_age"`
+	MessageDeadline             time.Duration `yaml:"message_deadline"`
+	CleanupMargin               time.Duration `yaml:"cleanup_margin"`
+	ExpiredMessagePolicy        string        `yaml:"expired_message_policy"`
+	SplitMode                   string        `yaml:"split_mode"`
+	PanicPolicy                 string        `yaml:"panic_policy"`
+	TapMode                     bool          `yaml:"tap_mode"`
+	Retry                       *RetryConfig  `yaml:"retry"`
+}
+
+// RetryConfig is the configuration of the retry topology
+type RetryConfig struct {
+	QueueName   string          `yaml:"queue_name"`
+	Delays      []time.Duration `yaml:"delays"`
+	MaxAttempts int             `yaml:"max_attempts"`
+}
+
+// ValidationError is the invalid field of the configuration
+type ValidationError struct {
+	// Field is the path of the field (e.g. queues[0].max_number_of_message)
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors is the list of ValidationError
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, v := range e {
+		msgs = append(msgs, v.Error())
+	}
+	return "invalid config: " + strings.Join(msgs, ", ")
+}
+
+// LoadConfig loads the configuration from the YAML or JSON file and validates it.
+// The durations are written as strings (e.g. "30s", "5m").
+func LoadConfig(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig parses the YAML or JSON configuration and validates it
+func ParseConfig(data []byte) (*FileConfig, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var config FileConfig
+	if err := dec.Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	for i := range config.Queues {
+		if config.Queues[i].Region == "" {
+			config.Queues[i].Region = config.Region
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate validates the configuration and returns ValidationErrors
+func (c *FileConfig) Validate() error {
+	var errs ValidationErrors
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	if len(c.Queues) == 0 {
+		add("queues", "at least one queue is required")
+	}
+	names := map[string]bool{}
+	for i, q := range c.Queues {
+		path := fmt.Sprintf("queues[%d]", i)
+		if q.Name == "" {
+			add(path+".name", "required")
+		} else if names[q.Name] {
+			add(path+".name", "duplicate queue %q", q.Name)
+		}
+		names[q.Name] = true
+		if q.Profile != "" {
+			if _, err := ProfileByName(q.Profile); err != nil {
+				add(path+".profile", "must be low_latency, cost_optimized or bulk, got %q", q.Profile)
+			}
+		}
+		if q.MaxNumberOfMessage < 0 || q.MaxNumberOfMessage > 10 {
+			add(path+".max_number_of_message", "must be between 1 and 10, got %d", q.MaxNumberOfMessage)
+		}
+		if q.WaitTimeSecond < 0 || q.WaitTimeSecond > 20 {
+			add(path+".wait_time_second", "must be between 0 and 20, got %d", q.WaitTimeSecond)
+		}
+		if q.VisibilityTimeout < 0 || q.VisibilityTimeout > 43200 {
+			add(path+".visibility_timeout", "must be between 0 and 43200, got %d", q.VisibilityTimeout)
+		}
+		if q.Concurrency < 0 {
+			add(path+".concurrency", "must not be negative, got %d", q.Concurrency)
+		}
+		if q.WarmPool < 0 {
+			add(path+".warm_pool", "must not be negative, got %d", q.WarmPool)
+		}
+		if q.SubBatchSize < 0 {
+			add(path+".sub_batch_size", "must not be negative, got %d", q.SubBatchSize)
+		}
+		if q.RateLimit < 0 {
+			add(path+".rate_limit", "must not be negative, got %v", q.RateLimit)
+		}
+		if q.KeyedLanes < 0 {
+			add(path+".keyed_lanes", "must not be negative, got %d", q.KeyedLanes)
+		}
+		if q.PriorityLanes < 0 {
+			add(path+".priority_lanes", "must not be negative, got %d", q.PriorityLanes)
+		}
+		if q.DrainTimeout < 0 {
+			add(path+".drain_timeout", "must not be negative, got %s", q.DrainTimeout)
+		}
+		if q.MaxHops < 0 {
+			add(path+".max_hops", "must not be negative, got %d", q.MaxHops)
+		}
+		if a := HopLimitAction(q.HopLimitAction); a != "" && a != HopLimitPark && a != HopLimitDrop {
+			add(path+".hop_limit_action", "must be park or drop, got %q", q.HopLimitAction)
+		}
+		if q.MaxMessageAge < 0 {
+			add(path+".max_message_age", "must not be negative, got %s", q.MaxMessageAge)
+		}
+		if q.MessageDeadline < 0 {
+			add(path+".message_deadline", "must not be negative, got %s", q.MessageDeadline)
+		}
+		if q.CleanupMargin < 0 || (q.CleanupMargin > 0 && q.CleanupMargin >= q.MessageDeadline) {
+			add(path+".cleanup_margin", "must be between 0s and the message_deadline, got %s", q.CleanupMargin)
+		}
+		if p := ExpiredMessagePolicy(q.ExpiredMessagePolicy); p != "" && p != ExpiredDelete && p != ExpiredDeadLetter && p != ExpiredHandle {
+			add(path+".expired_message_policy", "must be delete, dead_letter or handle, got %q", q.ExpiredMessagePolicy)
+		}
+		if m := SplitMode(q.SplitMode); m != "" && m != SplitAllOrNothing && m != SplitPerEvent {
+			add(path+".split_mode", "must be all_or_nothing or per_event, got %q", q.SplitMode)
+		}
+		if p := PanicPolicy(q.PanicPolicy); p != "" && p != PanicCrash && p != PanicFail && p != PanicPark && p != PanicDeadLetter {
+			add(path+".panic_policy", "must be crash, fail, park or dead_letter, got %q", q.PanicPolicy)
+		}
+		if q.Retry != nil {
+			if q.Retry.QueueName == "" {
+				add(path+".retry.queue_name", "required")
+			}
+			for j, d := range q.Retry.Delays {
+				if d < 0 || d > maxDelaySeconds*time.Second {
+					add(fmt.Sprintf("%s.retry.delays[%d]", path, j), "must be between 0s and 15m, got %s", d)
+				}
+			}
+			if q.Retry.MaxAttempts < 0 {
+				add(path+".retry.max_attempts", "must not be negative, got %d", q.Retry.MaxAttempts)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Queue returns the queue configuration by the name
+func (c *FileConfig) Queue(name string) (*QueueConfig, error) {
+	for i := range c.Queues {
+		if c.Queues[i].Name == name {
+			return &c.Queues[i], nil
+		}
+	}
+	return nil, errors.New("queue not found in config: " + name)
+}
+
+// QueueClient creates the sqs client for the Region of the queue on the Endpoint, which can be passed to New
+func (c *FileConfig) QueueClient(ctx context.Context, q *QueueConfig) (QueueAPI, error) {
+	return CreateQueueClient(ctx, q.WorkerConfig(), c.Endpoint)
+}
+
+// WorkerConfig converts the queue configuration to the Config for New
+func (q *QueueConfig) WorkerConfig() *Config {
+	config := &Config{
+		QueueName:              q.Name,
+		Region:                 q.Region,
+		QueueOwnerAWSAccountID: q.QueueOwnerAWSAccountID,
+		MaxNumberOfMessage:     q.MaxNumberOfMessage,
+		WaitTimeSecond:         q.WaitTimeSecond,
+		VisibilityTimeout:      q.VisibilityTimeout,
+		QueueTagOverrides:      q.QueueTagOverrides,
+		TraceSQSCalls:          q.TraceSQSCalls,
+		MessageAttributeNames:  q.MessageAttributeNames,
+		Concurrency:            q.Concurrency,
+		SubBatchSize:           q.SubBatchSize,
+		RateLimit:              q.RateLimit,
+		KeyedLanes:             q.KeyedLanes,
+		WarmPool:               q.WarmPool,
+		PriorityAttribute:      q.PriorityAttribute,
+		PriorityLanes:          q.PriorityLanes,
+		DeadLetterPollInterval: q.DeadLetterPollInterval,
+		DrainTimeout:           q.DrainTimeout,
+		MaxHops:                q.MaxHops,
+		HopLimitAction:         HopLimitAction(q.HopLimitAction),
+		MaxMessageAge:          q.MaxMessageAge,
+		ExpiredMessagePolicy:   ExpiredMessagePolicy(q.ExpiredMessagePolicy),
+		MessageDeadline:        q.MessageDeadline,
+		CleanupMargin:          q.CleanupMargin,
+		PanicPolicy:            PanicPolicy(q.PanicPolicy),
+		TapMode:                q.TapMode,
+	}
+	for _, name := range q.MessageSystemAttributeNames {
+		config.MessageSystemAttributeNames = append(config.MessageSystemAttributeNames, types.MessageSystemAttributeName(name))
+	}
+	if q.SplitMode != "" {
+		config.Splitting = &Splitting{Mode: SplitMode(q.SplitMode)}
+	}
+	if q.Retry != nil {
+		config.RetryQueueName = q.Retry.QueueName
+		config.RetryDelays = q.Retry.Delays
+		config.MaxRetryAttempts = q.Retry.MaxAttempts
+	}
+	if p, err := ProfileByName(q.Profile); err == nil {
+		p.Apply(config)
+	}
+	return config
+}
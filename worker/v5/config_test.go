@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("yaml", func(t *testing.T) {
+		path := filepath.Join(dir, "worker.yaml")
+		assert.NoError(t, os.WriteFile(path, []byte(`
+region: ap-northeast-1
+endpoint: http://localhost:4566
+queues:
+  - name: my-sqs-queue
+    max_number_of_message: 5
+    concurrency: 3
+    drain_timeout: 1m
+    retry:
+      queue_name: my-sqs-queue-retry
+      delays: [10s, 5m]
+`), 0600))
+
+		config, err := LoadConfig(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "ap-northeast-1", config.Region)
+		wc := config.Queues[0].WorkerConfig()
+		assert.Equal(t, &Config{
+			QueueName:          "my-sqs-queue",
+			Region:             "ap-northeast-1",
+			MaxNumberOfMessage: 5,
+			Concurrency:        3,
+			DrainTimeout:       time.Minute,
+			RetryQueueName:     "my-sqs-queue-retry",
+			RetryDelays:        []time.Duration{10 * time.Second, 5 * time.Minute},
+		}, wc)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		path := filepath.Join(dir, "worker.json")
+		assert.NoError(t, os.WriteFile(path, []byte(`{"queues": [{"name": "my-sqs-queue", "wait_time_second": 10}]}`), 0600))
+
+		config, err := LoadConfig(path)
+		assert.NoError(t, err)
+		q, err := config.Queue("my-sqs-queue")
+		assert.NoError(t, err)
+		assert.Equal(t, int32(10), q.WaitTimeSecond)
+	})
+
+	t.Run("validation errors have field paths", func(t *testing.T) {
+		_, err := ParseConfig([]byte(`
+queues:
+  - name: my-sqs-queue
+    max_number_of_message: 11
+    retry:
+      delays: [1h]
+`))
+		errs, ok := err.(ValidationErrors)
+		assert.True(t, ok)
+		var fields []string
+		for _, e := range errs {
+			fields = append(fields, e.Field)
+		}
+		assert.Equal(t, []string{"queues[0].max_number_of_message", "queues[0].retry.queue_name", "queues[0].retry.delays[0]"}, fields)
+	})
+
+	t.Run("unknown fields are rejected", func(t *testing.T) {
+		_, err := ParseConfig([]byte(`{"queues": [{"name": "my-sqs-queue", "concurency": 1}]}`))
+		assert.Error(t, err)
+	})
+}
+
+func TestFileConfigQueueClient(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	called := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called <- struct{}{}
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(`<GetQueueUrlResponse><GetQueueUrlResult><QueueUrl>http://localhost/000000000000/my-sqs-queue</QueueUrl></GetQueueUrlResult></GetQueueUrlResponse>`))
+	}))
+	defer server.Close()
+
+	config, err := ParseConfig([]byte(`
+region: ap-northeast-1
+endpoint: ` + server.URL + `
+queues:
+  - name: my-sqs-queue
+`))
+	assert.NoError(t, err)
+	client, err := config.QueueClient(context.Background(), &config.Queues[0])
+	assert.NoError(t, err)
+	out, err := client.GetQueueUrl(context.Background(), &sqs.GetQueueUrlInput{QueueName: aws.String("my-sqs-queue")})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost/000000000000/my-sqs-queue", aws.ToString(out.QueueUrl))
+	assert.Len(t, called, 1, "the client calls the Endpoint")
+}
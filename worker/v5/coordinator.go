@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ca-risken/common/pkg/logging"
+)
+
+// ShutdownCoordinator drains the workers of the process in stages with a shared deadline,
+// e.g. the workers producing to other queues first and the workers consuming them last,
+// instead of each worker racing the others for the termination grace period.
+// The workers of a stage are drained concurrently, and the stages in the order of AddStage.
+type ShutdownCoordinator struct {
+	Log logging.Logger
+	// Deadline is the total time for draining all stages (default: 30 seconds)
+	Deadline time.Duration
+
+	mu     sync.Mutex
+	stages [][]*Worker
+}
+
+// DrainResult is the drain result of a worker by the ShutdownCoordinator
+type DrainResult struct {
+	HandoffResult
+	QueueName string
+	// Stage is the index of the stage of the worker
+	Stage int
+	// Duration is the time spent for draining the worker
+	Duration time.Duration
+}
+
+// NewShutdownCoordinator creates ShutdownCoordinator struct with the shared deadline (default: 30 seconds)
+func NewShutdownCoordinator(deadline time.Duration) *ShutdownCoordinator {
+	if deadline <= 0 {
+		deadline = 30 * time.Second
+	}
+	return &ShutdownCoordinator{
+		Log:      logging.NewLogger(),
+		Deadline: deadline,
+	}
+}
+
+// AddStage registers the workers drained together after the previously added stages
+func (c *ShutdownCoordinator) AddStage(workers ...*Worker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stages = append(c.stages, workers)
+}
+
+// Shutdown stops the polling of all workers and drains them stage by stage.
+// Each worker waits for its in-flight messages up to the smaller of its DrainTimeout and the rest of the Deadline,
+// and the unfinished messages are released with the HandoffVisibilityTimeout as Handoff does.
+// The workers of the later stages keep polling while the earlier stages drain, so they consume what the earlier ones produced.
+func (c *ShutdownCoordinator) Shutdown(ctx context.Context) []DrainResult {
+	c.mu.Lock()
+	stages := append([][]*Worker{}, c.stages...)
+	c.mu.Unlock()
+
+	deadline := time.Now().Add(c.Deadline)
+	var results []DrainResult
+	for i, workers := range stages {
+		stageResults := make([]DrainResult, len(workers))
+		var wg sync.WaitGroup
+		for j, w := range workers {
+			wg.Add(1)
+			go func(j int, w *Worker) {
+				defer wg.Done()
+				timeout := time.Until(deadline)
+				if timeout > w.Config.DrainTimeout {
+					timeout = w.Config.DrainTimeout
+				}
+				start := time.Now()
+				result := w.handoff(ctx, timeout)
+				stageResults[j] = DrainResult{HandoffResult: result, QueueName: w.Config.QueueName, Stage: i, Duration: time.Since(start)}
+			}(j, w)
+		}
+		wg.Wait()
+		for _, r := range stageResults {
+			c.Log.Infof(ctx, "coordinator: Drained the worker, stage=%d, queue=%s, status=%s, drained=%d, released=%d, failed=%d",
+				r.Stage, r.QueueName, r.Status, r.Drained, r.Released, r.ReleaseFailed)
+		}
+		results = append(results, stageResults...)
+	}
+	return results
+}
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestShutdownCoordinator(t *testing.T) {
+	newWorker := func(name string) (*Worker, *mockedVisibilitySqsClient) {
+		client := &mockedVisibilitySqsClient{&mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+		client.On("DeleteMessage", mock.Anything).Return().Maybe()
+		client.On("ChangeMessageVisibility", mock.Anything).Return().Maybe()
+		return New(context.Background(), client, &Config{QueueName: name, DrainTimeout: time.Minute}), client
+	}
+	producer, _ := newWorker("producer")
+	stuck, stuckClient := newWorker("stuck")
+	consumer, _ := newWorker("consumer")
+
+	// the producer finishes its in-flight message during the drain
+	producerDone := make(chan time.Time, 1)
+	go func() {
+		_ = producer.handleMessage(context.Background(), &types.Message{ReceiptHandle: aws.String("p")}, HandlerFunc(func(msg *types.Message) error {
+			time.Sleep(20 * time.Millisecond)
+			producerDone <- time.Now()
+			return nil
+		}))
+	}()
+	// the stuck one exhausts the shared deadline
+	release := make(chan struct{})
+	defer close(release)
+	go func() {
+		_ = stuck.handleMessage(context.Background(), &types.Message{ReceiptHandle: aws.String("s")}, HandlerFunc(func(msg *types.Message) error {
+			<-release
+			return nil
+		}))
+	}()
+	for producer.inflight.count() == 0 || stuck.inflight.count() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	coordinator := NewShutdownCoordinator(100 * time.Millisecond)
+	coordinator.AddStage(producer, stuck)
+	coordinator.AddStage(consumer)
+	start := time.Now()
+	results := coordinator.Shutdown(context.Background())
+
+	assert.Len(t, results, 3)
+	assert.Equal(t, "producer", results[0].QueueName)
+	assert.Equal(t, HandoffStatusDrained, results[0].Status)
+	assert.Equal(t, 1, results[0].Drained)
+	assert.Equal(t, "stuck", results[1].QueueName)
+	assert.Equal(t, HandoffStatusReleased, results[1].Status, "the unfinished message is released at the shared deadline")
+	stuckClient.AssertNumberOfCalls(t, "ChangeMessageVisibility", 1)
+	assert.Equal(t, "consumer", results[2].QueueName)
+	assert.Equal(t, 1, results[2].Stage)
+	assert.True(t, (<-producerDone).After(start))
+	assert.True(t, time.Since(start) < time.Minute, "the DrainTimeout is bounded by the shared deadline")
+}
@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// FieldsExtractor returns the correlation fields of the message (e.g. project_id, scan_id).
+// The fields are attached to the logs of the message, the span in the context, and the handler context.
+type FieldsExtractor func(ctx context.Context, m *types.Message) map[string]interface{}
+
+type logFieldsKey struct{}
+
+// LogFieldsFromContext returns the correlation fields attached by Config.FieldsExtractor
+func LogFieldsFromContext(ctx context.Context) map[string]interface{} {
+	fields, _ := ctx.Value(logFieldsKey{}).(map[string]interface{})
+	return fields
+}
+
+// RISKENFields extracts the RISKEN-standard project_id and scan_id from the JSON body
+func RISKENFields(ctx context.Context, m *types.Message) map[string]interface{} {
+	var body struct {
+		ProjectID json.RawMessage `json:"project_id"`
+		ScanID    json.RawMessage `json:"scan_id"`
+	}
+	if err := json.Unmarshal([]byte(aws.ToString(m.Body)), &body); err != nil {
+		return nil
+	}
+	fields := map[string]interface{}{}
+	for name, raw := range map[string]json.RawMessage{"project_id": body.ProjectID, "scan_id": body.ScanID} {
+		var v interface{}
+		if len(raw) == 0 || json.Unmarshal(raw, &v) != nil || v == nil {
+			continue
+		}
+		if n, ok := v.(float64); ok && n == float64(uint64(n)) {
+			v = uint64(n)
+		}
+		fields[name] = v
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// correlationContext attaches the extracted fields to the context and tags the span in the context with them
+func (worker *Worker) correlationContext(ctx context.Context, m *types.Message) context.Context {
+	if worker.Config.FieldsExtractor == nil {
+		return ctx
+	}
+	fields := worker.Config.FieldsExtractor(ctx, m)
+	if len(fields) == 0 {
+		return ctx
+	}
+	if span, ok := tracer.SpanFromContext(ctx); ok {
+		for k, v := range fields {
+			span.SetTag(k, v)
+		}
+	}
+	return context.WithValue(ctx, logFieldsKey{}, fields)
+}
@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+func TestRISKENFields(t *testing.T) {
+	cases := []struct {
+		body string
+		want map[string]interface{}
+	}{
+		{body: `{"project_id":1001,"scan_id":"abc","other":1}`, want: map[string]interface{}{"project_id": uint64(1001), "scan_id": "abc"}},
+		{body: `{"project_id":1,"scan_id":null}`, want: map[string]interface{}{"project_id": uint64(1)}},
+		{body: `{"foo":"bar"}`, want: nil},
+		{body: `not json`, want: nil},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, RISKENFields(context.Background(), &types.Message{Body: aws.String(c.body)}), c.body)
+	}
+}
+
+func TestFieldsExtractor(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	core, logs := observer.New(zapcore.DebugLevel)
+	worker := New(context.Background(), &nopSqsClient{}, &Config{
+		QueueName:       "my-sqs-queue",
+		FieldsExtractor: RISKENFields,
+	}).WithZap(zap.New(core))
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "worker.test")
+	var handlerFields map[string]interface{}
+	err := worker.handleMessage(ctx, &types.Message{Body: aws.String(`{"project_id":1001}`)}, ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+		handlerFields = LogFieldsFromContext(ctx)
+		worker.Log.Warn(ctx, "logged by the handler")
+		return NewInvalidEventError("event", "invalid")
+	}))
+	span.Finish()
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"project_id": uint64(1001)}, handlerFields)
+	entries := logs.AllUntimed()
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, uint64(1001), entries[0].ContextMap()["project_id"], "the handler log has the fields")
+		assert.Equal(t, uint64(1001), entries[1].ContextMap()["project_id"], "the worker log has the fields")
+		assert.Equal(t, "invalid_event", entries[1].ContextMap()["event"])
+	}
+	if spans := mt.FinishedSpans(); assert.Len(t, spans, 1) {
+		assert.Equal(t, uint64(1001), spans[0].Tag("project_id"))
+	}
+}
@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"context"
+	"time"
+)
+
+type messageDeadlineKey struct{}
+
+// handlerDeadline returns the context of the handler bounded by the MessageDeadline from the receive of the message,
+// ending the CleanupMargin earlier so that the handler has the margin for the compensating actions by CleanupContext.
+// The deadline propagates to the downstream calls made with the context, e.g. http.NewRequestWithContext and the gRPC calls
+// send it as the grpc-timeout, so that no downstream call outlives the message.
+// The worker deletes or retries the message with the context before the deadline, so it's not bounded by it.
+func (worker *Worker) handlerDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if worker.Config.MessageDeadline <= 0 {
+		return ctx, func() {}
+	}
+	deadline := worker.receivedAt(ctx).Add(worker.Config.MessageDeadline)
+	ctx = context.WithValue(ctx, messageDeadlineKey{}, deadline)
+	return context.WithDeadline(ctx, deadline.Add(-worker.Config.CleanupMargin))
+}
+
+// MessageDeadline returns the deadline of the message in the handler context including the CleanupMargin,
+// or false outside of the handler or without the MessageDeadline
+func MessageDeadline(ctx context.Context) (time.Time, bool) {
+	deadline, ok := ctx.Value(messageDeadlineKey{}).(time.Time)
+	return deadline, ok
+}
+
+// CleanupContext returns the context for the compensating actions after the handler context is done by the MessageDeadline,
+// e.g. releasing the reservation made by the handler. It keeps the values of the handler context without its cancellation,
+// and is bounded by the end of the CleanupMargin instead. It's the same as the handler context without the MessageDeadline.
+func CleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := MessageDeadline(ctx)
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(withoutCancel{ctx}, deadline)
+}
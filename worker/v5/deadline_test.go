@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageDeadline(t *testing.T) {
+	m := func() *types.Message {
+		return &types.Message{MessageId: aws.String("m"), ReceiptHandle: aws.String("m")}
+	}
+
+	t.Run("deadline", func(t *testing.T) {
+		client := &countingDeleteSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", MessageDeadline: time.Minute, CleanupMargin: 10 * time.Second})
+		received := time.Now().Add(-time.Second)
+		var handlerDeadline, messageDeadline time.Time
+		outcome, err := worker.handle(withReceivedAt(context.Background(), received), m(), ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+			handlerDeadline, _ = ctx.Deadline()
+			messageDeadline, _ = MessageDeadline(ctx)
+			return nil
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeSucceeded, outcome)
+		assert.Equal(t, received.Add(50*time.Second), handlerDeadline, "the handler context ends the CleanupMargin before the deadline")
+		assert.Equal(t, received.Add(time.Minute), messageDeadline)
+		assert.Equal(t, int64(1), client.deleted)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		client := &countingDeleteSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", MessageDeadline: 20 * time.Millisecond, CleanupMargin: 10 * time.Millisecond})
+		var compensated error
+		outcome, err := worker.handle(context.Background(), m(), ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+			<-ctx.Done()
+			cleanupCtx, cancel := CleanupContext(ctx)
+			defer cancel()
+			compensated = cleanupCtx.Err()
+			<-cleanupCtx.Done()
+			return ctx.Err()
+		}))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.Equal(t, OutcomeFailed, outcome)
+		assert.NoError(t, compensated, "the cleanup context outlives the handler context")
+		assert.Zero(t, client.deleted)
+	})
+
+	t.Run("queued in the work pool", func(t *testing.T) {
+		client := &batchSqsClient{batches: make(chan []types.Message, 1)}
+		client.batches <- []types.Message{
+			{MessageId: aws.String("slow"), Body: aws.String("slow"), ReceiptHandle: aws.String("r1")},
+			{MessageId: aws.String("queued"), Body: aws.String("queued"), ReceiptHandle: aws.String("r2")},
+		}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Workers: 1, MessageDeadline: 200 * time.Millisecond})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		queuedErr := make(chan error, 1)
+		go worker.Start(ctx, ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+			if aws.ToString(msg.Body) == "slow" {
+				time.Sleep(300 * time.Millisecond)
+				return nil
+			}
+			queuedErr <- ctx.Err()
+			return nil
+		}))
+		select {
+		case err := <-queuedErr:
+			assert.ErrorIs(t, err, context.DeadlineExceeded, "the deadline counts from the receive, not from the start of the handler")
+		case <-time.After(time.Second):
+			t.Fatal("the queued message is not handled")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		worker := New(context.Background(), &countingDeleteSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+		_, _ = worker.handle(context.Background(), m(), ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+			_, ok := ctx.Deadline()
+			assert.False(t, ok)
+			_, ok = MessageDeadline(ctx)
+			assert.False(t, ok)
+			return nil
+		}))
+	})
+}
@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// recentMessages is the window of recently dispatched MessageIds, which suppresses the duplicate dispatch
+// of a message received twice before the deletion (e.g. by prefetching or parallel polling).
+type recentMessages struct {
+	window time.Duration
+	mu     sync.Mutex
+	seen   map[string]time.Time
+	order  []recentMessage
+	now    func() time.Time
+}
+
+type recentMessage struct {
+	id string
+	at time.Time
+}
+
+func newRecentMessages(window time.Duration) *recentMessages {
+	if window <= 0 {
+		return nil
+	}
+	return &recentMessages{window: window, seen: map[string]time.Time{}, now: time.Now}
+}
+
+// dispatch records the message and reports whether it's not a duplicate in the window
+func (r *recentMessages) dispatch(m *types.Message) bool {
+	if r == nil || m.MessageId == nil {
+		return true
+	}
+	id := aws.ToString(m.MessageId)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.prune(now)
+	if _, ok := r.seen[id]; ok {
+		return false
+	}
+	r.seen[id] = now
+	r.order = append(r.order, recentMessage{id: id, at: now})
+	return true
+}
+
+// forget removes the message from the window, so that the redelivery after a failure is dispatched
+func (r *recentMessages) forget(m *types.Message) {
+	if r == nil || m.MessageId == nil {
+		return
+	}
+	r.mu.Lock()
+	delete(r.seen, aws.ToString(m.MessageId))
+	r.mu.Unlock()
+}
+
+// prune drops the expired entries, the order is sorted by the dispatch time
+func (r *recentMessages) prune(now time.Time) {
+	i := 0
+	for ; i < len(r.order); i++ {
+		e := r.order[i]
+		if now.Sub(e.at) < r.window {
+			break
+		}
+		if at, ok := r.seen[e.id]; ok && at.Equal(e.at) {
+			delete(r.seen, e.id)
+		}
+	}
+	r.order = r.order[i:]
+}
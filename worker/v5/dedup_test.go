@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentMessages(t *testing.T) {
+	now := time.Unix(0, 0)
+	r := newRecentMessages(time.Minute)
+	r.now = func() time.Time { return now }
+	m := &types.Message{MessageId: aws.String("id")}
+
+	assert.True(t, r.dispatch(m))
+	assert.False(t, r.dispatch(m), "duplicate within the window")
+	assert.True(t, r.dispatch(&types.Message{MessageId: aws.String("other")}))
+
+	now = now.Add(time.Minute)
+	assert.True(t, r.dispatch(m), "the window is expired")
+	assert.Len(t, r.seen, 1)
+
+	r.forget(m)
+	assert.True(t, r.dispatch(m), "forgotten after the failure")
+
+	assert.True(t, newRecentMessages(0).dispatch(m), "disabled")
+}
+
+func TestDedupWindow(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", DedupWindow: time.Minute})
+	calls := 0
+	h := HandlerFunc(func(msg *types.Message) error {
+		calls++
+		return nil
+	})
+	m := &types.Message{MessageId: aws.String("id"), ReceiptHandle: aws.String("receipt")}
+	assert.NoError(t, worker.handleMessage(context.Background(), m, h))
+	assert.NoError(t, worker.handleMessage(context.Background(), m, h))
+	assert.Equal(t, 1, calls)
+
+	failed := &types.Message{MessageId: aws.String("failed"), ReceiptHandle: aws.String("receipt")}
+	failing := HandlerFunc(func(msg *types.Message) error { return errors.New("failure") })
+	assert.Error(t, worker.handleMessage(context.Background(), failed, failing))
+	assert.Error(t, worker.handleMessage(context.Background(), failed, failing), "the failed message is dispatched again")
+}
@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errDeferExpired is returned when the deferred completion is not signaled within the visibility of the message
+var errDeferExpired = errors.New("worker: the deferred completion was not signaled within the visibility timeout")
+
+// DeferredError is returned by the two-phase handler enqueuing the async downstream work,
+// and the message is deleted only after the work signals the completion by Complete.
+// The message is left in the queue when the completion has the error, or is not signaled within the VisibilityTimeout (extended by Checkpoint),
+// and the worker waits for the completion until the handler context is done without the VisibilityTimeout.
+type DeferredError struct {
+	once sync.Once
+	done chan error
+}
+
+// Defer returns the DeferredError, call Complete when the async work completes and return it from the handler
+func Defer() *DeferredError {
+	return &DeferredError{done: make(chan error, 1)}
+}
+
+func (e *DeferredError) Error() string {
+	return "message deletion is deferred"
+}
+
+// Complete signals the completion of the deferred work, the err is handled as the error of the handler.
+// The calls after the first are ignored.
+func (e *DeferredError) Complete(err error) {
+	e.once.Do(func() { e.done <- err })
+}
+
+// awaitDeferred waits for the deferred completion within the visibility of the message
+func (worker *Worker) awaitDeferred(ctx context.Context, deferred *DeferredError) error {
+	var expired <-chan time.Time
+	if worker.Config.VisibilityTimeout > 0 {
+		deadline := worker.receivedAt(ctx).Add(time.Duration(worker.Config.VisibilityTimeout) * time.Second)
+		if p, ok := ctx.Value(progressKey{}).(*progress); ok {
+			p.mu.Lock()
+			if p.expiresAt.After(deadline) {
+				deadline = p.expiresAt
+			}
+			p.mu.Unlock()
+		}
+		timer := worker.clock().NewTimer(worker.until(deadline))
+		defer timer.Stop()
+		expired = timer.C()
+	}
+	select {
+	case err := <-deferred.done:
+		return err
+	case <-expired:
+		return errDeferExpired
+	case <-ctx.Done():
+		return errDeferExpired
+	}
+}
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingDeleteSqsClient struct {
+	nopSqsClient
+	deleted int64
+}
+
+func (c *countingDeleteSqsClient) DeleteMessage(ctx context.Context, input *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	atomic.AddInt64(&c.deleted, 1)
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func TestDefer(t *testing.T) {
+	client := &countingDeleteSqsClient{}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", VisibilityTimeout: 30})
+	m := &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt")}
+	deferTo := func(complete func(d *DeferredError)) Handler {
+		return HandlerFunc(func(msg *types.Message) error {
+			d := Defer()
+			go complete(d)
+			return d
+		})
+	}
+
+	outcome, err := worker.processMessage(context.Background(), m, deferTo(func(d *DeferredError) {
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, int64(0), atomic.LoadInt64(&client.deleted), "the message is not deleted before the completion")
+		d.Complete(nil)
+		d.Complete(errors.New("ignored"))
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeSucceeded, outcome)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&client.deleted))
+
+	failure := errors.New("downstream failed")
+	outcome, err = worker.processMessage(context.Background(), m, deferTo(func(d *DeferredError) { d.Complete(failure) }))
+	assert.True(t, errors.Is(err, failure))
+	assert.Equal(t, OutcomeFailed, outcome)
+
+	// the visibility expires in 20ms
+	ctx := withReceivedAt(context.Background(), time.Now().Add(-30*time.Second+20*time.Millisecond))
+	outcome, err = worker.processMessage(ctx, m, deferTo(func(d *DeferredError) {}))
+	assert.True(t, errors.Is(err, errDeferExpired))
+	assert.Equal(t, OutcomeFailed, outcome)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&client.deleted), "the failed messages are not deleted")
+}
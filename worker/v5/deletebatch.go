@@ -0,0 +1,180 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// DeleteBatchAPI interface is optionally implemented by the client to delete messages in batch
+type DeleteBatchAPI interface {
+	DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+}
+
+// DeleteBatching buffers the deletes of the handled messages and sends them with DeleteMessageBatch.
+// The buffer is flushed when it's full, after MaxWait, or FlushMargin before the visibility timeout
+// of the oldest buffered message expires, so a lingering delete never lets the message be re-delivered.
+// Each handler waits for the result of its own entry, so a failed delete is reported as the failure of the message.
+type DeleteBatching struct {
+	// MaxSize is the maximum number of deletes in a batch (default: 10, the limit of SQS)
+	MaxSize int
+	// MaxWait is the maximum time a delete is buffered (default: 1 second)
+	MaxWait time.Duration
+	// VisibilityTimeout is the visibility timeout of the queue (default: Config.VisibilityTimeout or 30 seconds, the default of SQS)
+	VisibilityTimeout time.Duration
+	// FlushMargin is the margin before the visibility timeout expires since the receive (default: 5 seconds)
+	FlushMargin time.Duration
+}
+
+func (b *DeleteBatching) populateDefaultValues() {
+	if b.MaxSize <= 0 || b.MaxSize > 10 {
+		b.MaxSize = 10
+	}
+	if b.MaxWait <= 0 {
+		b.MaxWait = time.Second
+	}
+	if b.VisibilityTimeout <= 0 {
+		b.VisibilityTimeout = 30 * time.Second
+	}
+	if b.FlushMargin <= 0 {
+		b.FlushMargin = 5 * time.Second
+	}
+}
+
+type receivedAtKey struct{}
+
+// withReceivedAt records the time the messages were received, which the visibility timeout starts from
+func withReceivedAt(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, receivedAtKey{}, t)
+}
+
+// receivedAt returns the time recorded by withReceivedAt, or the current time of the Clock when not recorded
+func (worker *Worker) receivedAt(ctx context.Context) time.Time {
+	if t, ok := ctx.Value(receivedAtKey{}).(time.Time); ok {
+		return t
+	}
+	return worker.now()
+}
+
+type deleteEntry struct {
+	m    *types.Message
+	done chan error
+}
+
+type deleteBatcher struct {
+	worker *Worker
+	client DeleteBatchAPI
+	config *DeleteBatching
+
+	mu      sync.Mutex
+	pending []*deleteEntry
+	ctx     context.Context
+	flushAt time.Time
+	timer   Timer
+}
+
+func (worker *Worker) newDeleteBatcher(ctx context.Context) *deleteBatcher {
+	config := worker.Config.DeleteBatching
+	if config == nil {
+		return nil
+	}
+	client, ok := worker.SqsClient.(DeleteBatchAPI)
+	if !ok {
+		worker.Log.Warnf(ctx, "worker: DeleteBatching is disabled, the sqs client does not support DeleteMessageBatch")
+		return nil
+	}
+	if config.VisibilityTimeout <= 0 && worker.Config.VisibilityTimeout > 0 {
+		config.VisibilityTimeout = time.Duration(worker.Config.VisibilityTimeout) * time.Second
+	}
+	config.populateDefaultValues()
+	return &deleteBatcher{worker: worker, client: client, config: config}
+}
+
+// delete buffers the delete of the message and waits for the result of the batch
+func (b *deleteBatcher) delete(ctx context.Context, m *types.Message) error {
+	entry := &deleteEntry{m: m, done: make(chan error, 1)}
+	now := b.worker.now()
+	flushAt := now.Add(b.config.MaxWait)
+	if deadline := b.worker.receivedAt(ctx).Add(b.config.VisibilityTimeout - b.config.FlushMargin); deadline.Before(flushAt) {
+		flushAt = deadline
+	}
+
+	b.mu.Lock()
+	if len(b.pending) == 0 {
+		b.ctx = withoutCancel{ctx}
+	}
+	b.pending = append(b.pending, entry)
+	var full []*deleteEntry
+	var flushCtx context.Context
+	switch {
+	case len(b.pending) >= b.config.MaxSize || !flushAt.After(now):
+		full, flushCtx = b.take()
+	case b.timer == nil || flushAt.Before(b.flushAt):
+		if b.timer != nil {
+			b.timer.Stop()
+		}
+		b.flushAt = flushAt
+		b.timer = b.worker.clock().AfterFunc(flushAt.Sub(now), b.flushDue)
+	}
+	b.mu.Unlock()
+
+	if full != nil {
+		b.flush(flushCtx, full)
+	}
+	return <-entry.done
+}
+
+// take removes the pending entries, the lock must be held
+func (b *deleteBatcher) take() ([]*deleteEntry, context.Context) {
+	entries, ctx := b.pending, b.ctx
+	b.pending, b.ctx = nil, nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return entries, ctx
+}
+
+func (b *deleteBatcher) flushDue() {
+	b.mu.Lock()
+	entries, ctx := b.take()
+	b.mu.Unlock()
+	if len(entries) > 0 {
+		b.flush(ctx, entries)
+	}
+}
+
+// flush deletes the entries and sends the result to each entry
+func (b *deleteBatcher) flush(ctx context.Context, entries []*deleteEntry) {
+	params := &sqs.DeleteMessageBatchInput{
+		QueueUrl: aws.String(b.worker.Config.QueueURL),
+		Entries:  make([]types.DeleteMessageBatchRequestEntry, len(entries)),
+	}
+	for i, e := range entries {
+		params.Entries[i] = types.DeleteMessageBatchRequestEntry{Id: aws.String(strconv.Itoa(i)), ReceiptHandle: e.m.ReceiptHandle}
+	}
+	ctx, cancel := callContext(ctx, b.worker.Config.deleteTimeout())
+	defer cancel()
+	ctx, finishSpan := b.worker.startClientSpan(ctx, "DeleteMessageBatch")
+	out, err := b.client.DeleteMessageBatch(ctx, params, b.worker.Config.sqsOptions()...)
+	finishSpan(err)
+	if err != nil {
+		for _, e := range entries {
+			e.done <- err
+		}
+		return
+	}
+	failed := make(map[string]error, len(out.Failed))
+	for _, f := range out.Failed {
+		failed[aws.ToString(f.Id)] = fmt.Errorf("worker: failed to delete the message in batch, code=%s, message=%s", aws.ToString(f.Code), aws.ToString(f.Message))
+	}
+	for i, e := range entries {
+		e.done <- failed[strconv.Itoa(i)]
+	}
+}
@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type batchDeleteSqsClient struct {
+	*mockedSqsClient
+	mu      sync.Mutex
+	batches [][]string
+	at      []time.Time
+	fail    map[string]bool
+}
+
+func (c *batchDeleteSqsClient) DeleteMessageBatch(ctx context.Context, input *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := &sqs.DeleteMessageBatchOutput{}
+	handles := make([]string, 0, len(input.Entries))
+	for _, e := range input.Entries {
+		handles = append(handles, aws.ToString(e.ReceiptHandle))
+		if c.fail[aws.ToString(e.ReceiptHandle)] {
+			out.Failed = append(out.Failed, types.BatchResultErrorEntry{Id: e.Id, Code: aws.String("ReceiptHandleIsInvalid"), SenderFault: true})
+		} else {
+			out.Successful = append(out.Successful, types.DeleteMessageBatchResultEntry{Id: e.Id})
+		}
+	}
+	c.batches = append(c.batches, handles)
+	c.at = append(c.at, time.Now())
+	return out, nil
+}
+
+func newBatchDeleteWorker(client *batchDeleteSqsClient, batching *DeleteBatching) *Worker {
+	return New(context.Background(), client, &Config{QueueName: "my-sqs-queue", DeleteBatching: batching})
+}
+
+func handleMessages(ctx context.Context, worker *Worker, n int) []error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m := &types.Message{MessageId: aws.String(fmt.Sprint(i)), ReceiptHandle: aws.String(fmt.Sprint(i))}
+			errs[i] = worker.handleMessage(ctx, m, HandlerFunc(func(msg *types.Message) error { return nil }))
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestDeleteBatching(t *testing.T) {
+	t.Run("full", func(t *testing.T) {
+		client := &batchDeleteSqsClient{mockedSqsClient: &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+		worker := newBatchDeleteWorker(client, &DeleteBatching{MaxSize: 5, MaxWait: time.Minute, VisibilityTimeout: time.Hour})
+
+		errs := handleMessages(context.Background(), worker, 10)
+
+		for _, err := range errs {
+			assert.NoError(t, err)
+		}
+		assert.Len(t, client.batches, 2, "the full batches are flushed without waiting for MaxWait")
+		assert.Equal(t, int64(10), worker.Stats().Succeeded)
+	})
+
+	t.Run("max wait", func(t *testing.T) {
+		client := &batchDeleteSqsClient{mockedSqsClient: &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+		worker := newBatchDeleteWorker(client, &DeleteBatching{MaxWait: 20 * time.Millisecond, VisibilityTimeout: time.Hour})
+
+		errs := handleMessages(context.Background(), worker, 3)
+
+		for _, err := range errs {
+			assert.NoError(t, err)
+		}
+		assert.Len(t, client.batches, 1)
+		assert.ElementsMatch(t, []string{"0", "1", "2"}, client.batches[0])
+	})
+
+	t.Run("result aware", func(t *testing.T) {
+		client := &batchDeleteSqsClient{mockedSqsClient: &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}, fail: map[string]bool{"1": true}}
+		worker := newBatchDeleteWorker(client, &DeleteBatching{MaxSize: 3, MaxWait: time.Minute, VisibilityTimeout: time.Hour})
+
+		errs := handleMessages(context.Background(), worker, 3)
+
+		assert.NoError(t, errs[0])
+		assert.Error(t, errs[1], "the failed entry is reported to its handler")
+		assert.Contains(t, errs[1].Error(), "ReceiptHandleIsInvalid")
+		assert.NoError(t, errs[2])
+		assert.Equal(t, int64(1), worker.Stats().DeleteFailed)
+	})
+}
+
+func TestDeleteBatchingVisibilitySafety(t *testing.T) {
+	client := &batchDeleteSqsClient{mockedSqsClient: &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+	visibility := 200 * time.Millisecond
+	worker := newBatchDeleteWorker(client, &DeleteBatching{MaxWait: time.Minute, VisibilityTimeout: visibility, FlushMargin: 50 * time.Millisecond})
+
+	received := time.Now()
+	ctx := withReceivedAt(context.Background(), received)
+	errs := handleMessages(ctx, worker, 2)
+
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.Len(t, client.batches, 1)
+	assert.True(t, client.at[0].Before(received.Add(visibility)), "the deletes are flushed before the visibility timeout expires, so the messages are not re-delivered")
+
+	// the message received long ago is flushed immediately
+	errs = handleMessages(withReceivedAt(context.Background(), time.Now().Add(-visibility)), worker, 1)
+	assert.NoError(t, errs[0])
+	assert.Len(t, client.batches, 2)
+}
+
+func TestDeleteBatchingNotSupported(t *testing.T) {
+	client := &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", DeleteBatching: &DeleteBatching{}})
+	assert.Nil(t, worker.deletes, "the deletes are sent one by one")
+}
@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"context"
+	"sort"
+)
+
+// Description returns the fully-resolved configuration of the worker as the structured fields,
+// including the defaults populated by New and the queue tags, e.g. for the startup log and the debug endpoints.
+// The features lists the optional components enabled by the Config.
+func (worker *Worker) Description() map[string]interface{} {
+	worker.mu.Lock()
+	defer worker.mu.Unlock()
+	c := worker.Config
+	fields := map[string]interface{}{
+		"queue_name":                  c.QueueName,
+		"queue_url":                   c.QueueURL,
+		"max_number_of_message":       c.MaxNumberOfMessage,
+		"wait_time_second":            c.WaitTimeSecond,
+		"visibility_timeout":          c.VisibilityTimeout,
+		"concurrency":                 c.Concurrency,
+		"workers":                     c.Workers,
+		"warm_pool":                   c.WarmPool,
+		"sub_batch_size":              c.SubBatchSize,
+		"rate_limit":                  c.RateLimit,
+		"drain_timeout":               c.DrainTimeout.String(),
+		"throttle_cooldown":           c.ThrottleCooldown.String(),
+		"cancel_on_visibility_expiry": c.CancelOnVisibilityExpiry,
+		"tap_mode":                    c.TapMode,
+	}
+	if worker.deadLetterQueueARN != "" {
+		fields["dead_letter_queue_arn"] = worker.deadLetterQueueARN
+	}
+	if c.RetryQueueURL != "" {
+		fields["retry_queue_url"] = c.RetryQueueURL
+		fields["max_retry_attempts"] = c.MaxRetryAttempts
+	}
+	if c.KeyFunc != nil {
+		fields["keyed_lanes"] = c.KeyedLanes
+	}
+	if c.PriorityAttribute != "" {
+		fields["priority_attribute"] = c.PriorityAttribute
+		fields["priority_lanes"] = c.PriorityLanes
+	}
+	if c.MaxHops > 0 {
+		fields["max_hops"] = c.MaxHops
+		fields["hop_limit_action"] = string(c.HopLimitAction)
+	}
+	if c.MaxMessageAge > 0 {
+		fields["max_message_age"] = c.MaxMessageAge.String()
+		fields["expired_message_policy"] = string(worker.expiredMessagePolicy())
+	}
+	if c.HandlerRetry != nil {
+		fields["handler_retry_max_attempts"] = c.HandlerRetry.MaxAttempts
+	}
+	if c.MessageDeadline > 0 {
+		fields["message_deadline"] = c.MessageDeadline.String()
+		fields["cleanup_margin"] = c.CleanupMargin.String()
+	}
+	if c.Mirror != nil {
+		fields["mirror_queue_url"] = c.Mirror.QueueURL
+		fields["mirror_timing"] = string(c.Mirror.Timing)
+	}
+	if c.RequestBudget != nil {
+		fields["request_budget_monthly_cost"] = c.RequestBudget.MonthlyCost
+	}
+	if c.PanicPolicy != "" {
+		fields["panic_policy"] = string(worker.panicPolicy())
+	}
+	if c.Splitting != nil {
+		fields["split_mode"] = string(c.Splitting.mode())
+	}
+	if c.WatchdogTimeout > 0 {
+		fields["watchdog_timeout"] = c.WatchdogTimeout.String()
+	}
+	if c.DedupWindow > 0 {
+		fields["dedup_window"] = c.DedupWindow.String()
+	}
+
+	var features []string
+	for name, enabled := range map[string]bool{
+		"keyed":                       c.KeyFunc != nil,
+		"dead_letter_handler":         c.DeadLetterHandler != nil,
+		"retry_budget":                c.RetryBudget != nil,
+		"payload_offloading":          c.PayloadStore != nil,
+		"health_probe":                c.HealthProbe != nil,
+		"handler_retry":               c.HandlerRetry != nil,
+		"failure_sampling":            c.FailureSampling != nil,
+		"delete_batching":             worker.deletes != nil,
+		"prefetch_visibility":         worker.prefetch != nil,
+		"mirror":                      c.Mirror != nil,
+		"transformers":                len(c.Transformers) > 0,
+		"splitting":                   c.Splitting != nil,
+		"parking_lot":                 c.ParkingLot != nil,
+		"processing_lock":             c.ProcessingLock != nil,
+		"audit":                       c.AuditSink != nil,
+		"correlation_cancellation":    c.CorrelationKey != nil,
+		"tenant_config":               c.TenantConfig != nil,
+		"fields_extractor":            c.FieldsExtractor != nil,
+		"log_sampling":                c.LogSampling != nil,
+		"trace_sqs_calls":             c.TraceSQSCalls,
+		"queue_tag_overrides":         c.QueueTagOverrides,
+		"keep_visibility_on_shutdown": c.KeepVisibilityOnShutdown,
+	} {
+		if enabled {
+			features = append(features, name)
+		}
+	}
+	sort.Strings(features)
+	fields["features"] = features
+	if len(c.Transformers) > 0 {
+		stages := make([]string, len(c.Transformers))
+		for i, s := range c.Transformers {
+			stages[i] = s.Name
+		}
+		fields["transformers"] = stages
+	}
+	return fields
+}
+
+// logStarted emits the Description as one structured record on the start of the polling
+func (worker *Worker) logStarted(ctx context.Context) {
+	if !worker.logEnabled(LogEventStarted) {
+		return
+	}
+	worker.logEventWith(ctx, LogEventStarted, worker.Description(), "worker: Started polling, queue=%s", worker.Config.QueueName)
+}
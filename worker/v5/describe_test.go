@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescription(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{
+		QueueName:     "my-sqs-queue",
+		Concurrency:   5,
+		MaxMessageAge: time.Hour,
+		ParkingLot:    &mockedParkingLot{},
+		Transformers:  []TransformStage{{Name: "gunzip", Transformer: gzipTransformer()}},
+	})
+	d := worker.Description()
+
+	assert.Equal(t, "https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue", d["queue_url"])
+	assert.Equal(t, int32(10), d["max_number_of_message"], "the defaults are resolved")
+	assert.Equal(t, int32(20), d["wait_time_second"])
+	assert.Equal(t, 5, d["concurrency"])
+	assert.Equal(t, "delete", d["expired_message_policy"])
+	assert.Equal(t, []string{"parking_lot", "transformers"}, d["features"])
+	assert.Equal(t, []string{"gunzip"}, d["transformers"])
+	assert.NotContains(t, d, "max_hops", "the disabled policies are omitted")
+}
+
+func TestLogStarted(t *testing.T) {
+	worker := New(context.Background(), &batchesSqsClient{batches: [][]types.Message{}}, &Config{QueueName: "my-sqs-queue"})
+	var buf bytes.Buffer
+	worker.Log.Output(&buf)
+	assert.NoError(t, worker.DrainOnce(context.Background(), HandlerFunc(func(msg *types.Message) error { return nil })))
+
+	var started map[string]interface{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var line map[string]interface{}
+		assert.NoError(t, dec.Decode(&line))
+		if line["event"] == string(LogEventStarted) {
+			started = line
+		}
+	}
+	if assert.NotNil(t, started, "one record describes the worker on start") {
+		assert.Equal(t, "my-sqs-queue", started["queue_name"])
+		assert.Equal(t, "https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue", started["queue_url"])
+		assert.Equal(t, float64(20), started["wait_time_second"])
+	}
+}
@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/ca-risken/common/pkg/logging"
+)
+
+// ListQueuesAPI interface is optionally implemented by the client to discover the queues
+type ListQueuesAPI interface {
+	ListQueues(ctx context.Context, params *sqs.ListQueuesInput, optFns ...func(*sqs.Options)) (*sqs.ListQueuesOutput, error)
+}
+
+// DiscoveryAPI interface is the client required by Discovery
+type DiscoveryAPI interface {
+	QueueAPI
+	ListQueuesAPI
+}
+
+// Discovery consumes every queue whose name starts with the Prefix with a worker per queue and the shared handler,
+// so that the sharded per-tenant queues are consumed without maintaining a static list.
+// The queues of several accounts are discovered with a client per account (e.g. with the assumed role).
+// The queues created later are picked up at every Interval, and the workers of the deleted queues are stopped.
+type Discovery struct {
+	Log logging.Logger
+	// Prefix is the queue name prefix passed to ListQueues
+	Prefix string
+	// Template is the config copied for the worker of each queue with the QueueName of the queue
+	Template Config
+	// Interval is the interval of listing the queues on the Clock of the Template (default: 5 minutes)
+	Interval time.Duration
+
+	clients []DiscoveryAPI
+	group   *workerGroup
+}
+
+// NewDiscovery creates Discovery struct listing the queues of the prefix with the clients
+func NewDiscovery(prefix string, template Config, clients ...DiscoveryAPI) *Discovery {
+	return &Discovery{
+		Log:      logging.NewLogger(),
+		Prefix:   prefix,
+		Template: template,
+		Interval: 5 * time.Minute,
+		clients:  clients,
+		group:    newWorkerGroup("discovery"),
+	}
+}
+
+// Run discovers the queues and runs their workers with the handler until the context is done.
+// It returns after all workers stopped.
+func (d *Discovery) Run(ctx context.Context, h Handler) {
+	ticker := d.Template.clock().NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		d.sync(ctx, h)
+		select {
+		case <-ctx.Done():
+			d.group.wait()
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// sync starts the workers of the new queues and stops the workers of the queues not listed anymore
+func (d *Discovery) sync(ctx context.Context, h Handler) {
+	want := map[string]func() *Worker{}
+	for _, client := range d.clients {
+		urls, err := listQueues(ctx, client, d.Prefix)
+		if err != nil {
+			// the workers of the account are kept running until the next listing succeeds
+			d.Log.Warnf(ctx, "discovery: Failed to list the queues, prefix=%s, err=%+v", d.Prefix, err)
+			for url, w := range d.group.snapshot() {
+				if w.SqsClient == client {
+					want[url] = nil
+				}
+			}
+			continue
+		}
+		for _, url := range urls {
+			client, name := client, queueNameOf(url)
+			want[url] = func() *Worker {
+				config := d.Template
+				config.QueueName = name
+				return New(ctx, client, &config)
+			}
+		}
+	}
+	d.group.reconcile(ctx, d.Log, h, want)
+}
+
+// Workers returns the workers of the discovered queues
+func (d *Discovery) Workers() []*Worker {
+	running := d.group.snapshot()
+	workers := make([]*Worker, 0, len(running))
+	for _, w := range running {
+		workers = append(workers, w)
+	}
+	return workers
+}
+
+func listQueues(ctx context.Context, client ListQueuesAPI, prefix string) ([]string, error) {
+	var urls []string
+	paginator := sqs.NewListQueuesPaginator(client, &sqs.ListQueuesInput{
+		QueueNamePrefix: aws.String(prefix),
+		MaxResults:      aws.Int32(1000),
+	})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the queues, prefix=%s: %w", prefix, err)
+		}
+		urls = append(urls, out.QueueUrls...)
+	}
+	return urls, nil
+}
+
+// queueNameOf returns the queue name of the queue URL
+func queueNameOf(url string) string {
+	return url[strings.LastIndex(url, "/")+1:]
+}
@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type listingSqsClient struct {
+	blockingReceiveSqsClient
+	account string
+	mu      sync.Mutex
+	names   []string
+}
+
+func (c *listingSqsClient) setNames(names ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.names = names
+}
+
+func (c *listingSqsClient) GetQueueUrl(ctx context.Context, input *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("https://sqs.ap-northeast-1.amazonaws.com/" + c.account + "/" + aws.ToString(input.QueueName))}, nil
+}
+
+func (c *listingSqsClient) ListQueues(ctx context.Context, input *sqs.ListQueuesInput, optFns ...func(*sqs.Options)) (*sqs.ListQueuesOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := &sqs.ListQueuesOutput{}
+	for _, name := range c.names {
+		out.QueueUrls = append(out.QueueUrls, "https://sqs.ap-northeast-1.amazonaws.com/"+c.account+"/"+name)
+	}
+	return out, nil
+}
+
+func discoveredQueues(d *Discovery) []string {
+	var names []string
+	for _, w := range d.Workers() {
+		names = append(names, w.Config.QueueURL)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestDiscovery(t *testing.T) {
+	account1 := &listingSqsClient{blockingReceiveSqsClient: blockingReceiveSqsClient{polling: make(chan struct{}, 1)}, account: "111111111111"}
+	account2 := &listingSqsClient{blockingReceiveSqsClient: blockingReceiveSqsClient{polling: make(chan struct{}, 1)}, account: "222222222222"}
+	account1.setNames("tenant-a", "tenant-b")
+	account2.setNames("tenant-c")
+	d := NewDiscovery("tenant-", Config{WaitTimeSecond: 1}, account1, account2)
+	d.Interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx, HandlerFunc(func(msg *types.Message) error { return nil }))
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{
+			"https://sqs.ap-northeast-1.amazonaws.com/111111111111/tenant-a",
+			"https://sqs.ap-northeast-1.amazonaws.com/111111111111/tenant-b",
+			"https://sqs.ap-northeast-1.amazonaws.com/222222222222/tenant-c",
+		}, discoveredQueues(d))
+	}, time.Second, 5*time.Millisecond)
+	workers := d.Workers()
+	for _, w := range workers {
+		assert.Equal(t, int32(1), w.Config.WaitTimeSecond, "the template is applied")
+	}
+
+	account1.setNames("tenant-b", "tenant-d")
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{
+			"https://sqs.ap-northeast-1.amazonaws.com/111111111111/tenant-b",
+			"https://sqs.ap-northeast-1.amazonaws.com/111111111111/tenant-d",
+			"https://sqs.ap-northeast-1.amazonaws.com/222222222222/tenant-c",
+		}, discoveredQueues(d))
+	}, time.Second, 5*time.Millisecond, "the new queue is picked up and the removed one is stopped")
+
+	cancel()
+	<-done
+	for _, w := range workers {
+		assert.Eventually(t, func() bool { return !w.Running() }, time.Second, 5*time.Millisecond)
+	}
+}
+
+func TestQueueNameOf(t *testing.T) {
+	assert.Equal(t, "tenant-a", queueNameOf("https://sqs.ap-northeast-1.amazonaws.com/111111111111/tenant-a"))
+}
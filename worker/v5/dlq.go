@@ -0,0 +1,147 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// QueueAttributesAPI interface is optionally implemented by the client to detect the queue's redrive policy
+type QueueAttributesAPI interface {
+	GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error)
+}
+
+type redrivePolicy struct {
+	DeadLetterTargetArn string      `json:"deadLetterTargetArn"`
+	MaxReceiveCount     json.Number `json:"maxReceiveCount"`
+}
+
+// detectDeadLetterQueue reads the redrive policy of the queue and returns the dead-letter queue ARN
+func detectDeadLetterQueue(ctx context.Context, client QueueAttributesAPI, queueURL string, optFns ...func(*sqs.Options)) (string, error) {
+	out, err := client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(queueURL),
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameRedrivePolicy},
+	}, optFns...)
+	if err != nil {
+		return "", err
+	}
+	raw, ok := out.Attributes[string(types.QueueAttributeNameRedrivePolicy)]
+	if !ok || raw == "" {
+		return "", nil
+	}
+	var policy redrivePolicy
+	if err := json.Unmarshal([]byte(raw), &policy); err != nil {
+		return "", fmt.Errorf("invalid redrive policy: %w", err)
+	}
+	return policy.DeadLetterTargetArn, nil
+}
+
+// parseQueueARN returns the account ID and queue name from the queue ARN(arn:aws:sqs:region:account-id:queue-name)
+func parseQueueARN(arn string) (accountID, queueName string, err error) {
+	parts := strings.Split(arn, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sqs" {
+		return "", "", fmt.Errorf("invalid queue arn: %s", arn)
+	}
+	return parts[4], parts[5], nil
+}
+
+func (worker *Worker) initDeadLetterQueue(ctx context.Context, client QueueAPI) {
+	attrClient, ok := client.(QueueAttributesAPI)
+	if !ok {
+		return
+	}
+	callCtx, finishSpan := worker.startClientSpan(ctx, "GetQueueAttributes")
+	arn, err := detectDeadLetterQueue(callCtx, attrClient, worker.Config.QueueURL, worker.Config.sqsOptions()...)
+	finishSpan(err)
+	if err != nil {
+		worker.Log.Warnf(ctx, "worker: Failed to get the redrive policy, err=%+v", err)
+		return
+	}
+	if arn == "" {
+		worker.Log.Warnf(ctx, "worker: No dead-letter queue is configured for the queue: %s", worker.Config.QueueName)
+		return
+	}
+	worker.deadLetterQueueARN = arn
+	if !worker.sendsToDeadLetterQueue() {
+		return
+	}
+
+	accountID, queueName, err := parseQueueARN(arn)
+	if err != nil {
+		worker.Log.Warnf(ctx, "worker: Failed to parse the dead-letter queue arn, err=%+v", err)
+		return
+	}
+	callCtx, finishSpan = worker.startClientSpan(ctx, "GetQueueUrl")
+	out, err := client.GetQueueUrl(callCtx, &sqs.GetQueueUrlInput{
+		QueueName:              aws.String(queueName),
+		QueueOwnerAWSAccountId: aws.String(accountID),
+	}, worker.Config.sqsOptions()...)
+	finishSpan(err)
+	if err != nil {
+		worker.Log.Warnf(ctx, "worker: Failed to get the dead-letter queue url, err=%+v", err)
+		return
+	}
+	worker.deadLetterQueueURL = aws.ToString(out.QueueUrl)
+	if worker.Config.DeadLetterHandler == nil {
+		return
+	}
+	worker.deadLetterWorker = &Worker{
+		Config: &Config{
+			MaxNumberOfMessage: worker.Config.MaxNumberOfMessage,
+			QueueName:          queueName,
+			QueueURL:           worker.deadLetterQueueURL,
+			KeyedLanes:         worker.Config.KeyedLanes,
+			Region:             worker.Config.Region,
+			LogLevels:          worker.Config.LogLevels,
+			// the attributes of the retries, the lineage and the correlation are received for the DeadLetterHandler
+			MessageAttributeNames:       worker.Config.MessageAttributeNames,
+			MessageSystemAttributeNames: worker.Config.MessageSystemAttributeNames,
+		},
+		Log:       worker.Log,
+		SqsClient: worker.SqsClient,
+	}
+}
+
+// sendsToDeadLetterQueue reports whether any of the configured policies needs the dead-letter queue url
+func (worker *Worker) sendsToDeadLetterQueue() bool {
+	return worker.Config.DeadLetterHandler != nil || worker.Config.RetryBudget != nil ||
+		worker.Config.ExpiredMessagePolicy == ExpiredDeadLetter || worker.Config.PanicPolicy == PanicDeadLetter
+}
+
+// pollDeadLetterQueue polls the dead-letter queue at the DeadLetterPollInterval under the ctx,
+// and routes messages to the DeadLetterHandler under the handlerCtx as the messages of the queue
+func (worker *Worker) pollDeadLetterQueue(ctx, handlerCtx context.Context) {
+	dlq := worker.deadLetterWorker
+	ticker := worker.clock().NewTicker(worker.Config.DeadLetterPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			receiveCtx, finishSpan := worker.startClientSpan(ctx, "ReceiveMessage")
+			resp, err := dlq.SqsClient.ReceiveMessage(receiveCtx, &sqs.ReceiveMessageInput{
+				QueueUrl:              aws.String(dlq.Config.QueueURL),
+				MaxNumberOfMessages:   dlq.Config.MaxNumberOfMessage,
+				AttributeNames:        systemAttributeNames(dlq.Config.MessageSystemAttributeNames),
+				MessageAttributeNames: dlq.Config.MessageAttributeNames,
+			}, dlq.Config.sqsOptions()...)
+			finishSpan(err)
+			if err != nil {
+				worker.logEvent(ctx, LogEventReceiveError, "worker: Failed to receive from the dead-letter queue, err=%+v", err)
+				continue
+			}
+			if len(resp.Messages) > 0 {
+				received := time.Now()
+				outcomes := dlq.run(handlerCtx, worker.Config.DeadLetterHandler, resp.Messages)
+				dlq.logPoll(ctx, pollSummary{messages: len(resp.Messages), dispatch: time.Since(received), outcomes: outcomes})
+			}
+		}
+	}
+}
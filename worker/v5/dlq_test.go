@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockedAttributesSqsClient struct {
+	*mockedSqsClient
+	Attributes map[string]string
+}
+
+func (c *mockedAttributesSqsClient) GetQueueAttributes(ctx context.Context, input *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	return &sqs.GetQueueAttributesOutput{Attributes: c.Attributes}, nil
+}
+
+func TestDeadLetterQueue(t *testing.T) {
+	awsConfig := &aws.Config{Region: "eu-west-1"}
+
+	t.Run("the worker exposes the dead-letter queue arn", func(t *testing.T) {
+		client := &mockedAttributesSqsClient{
+			mockedSqsClient: &mockedSqsClient{Config: awsConfig},
+			Attributes: map[string]string{
+				"RedrivePolicy": `{"deadLetterTargetArn":"arn:aws:sqs:eu-west-1:123456789:my-sqs-queue-dlq","maxReceiveCount":5}`,
+			},
+		}
+		handler := HandlerFunc(func(msg *types.Message) error { return nil })
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", DeadLetterHandler: handler})
+
+		assert.Equal(t, "arn:aws:sqs:eu-west-1:123456789:my-sqs-queue-dlq", worker.Stats().DeadLetterQueueARN)
+		assert.NotNil(t, worker.deadLetterWorker, "the dead-letter poller is configured")
+		assert.Equal(t, "https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue-dlq", worker.deadLetterWorker.Config.QueueURL)
+		assert.Equal(t, []string{"All"}, worker.deadLetterWorker.Config.MessageAttributeNames, "the attributes of the queue are received")
+	})
+
+	for _, c := range []struct {
+		name   string
+		config Config
+	}{
+		{name: "ExpiredDeadLetter", config: Config{MaxMessageAge: time.Hour, ExpiredMessagePolicy: ExpiredDeadLetter}},
+		{name: "PanicDeadLetter", config: Config{PanicPolicy: PanicDeadLetter}},
+	} {
+		t.Run("the dead-letter queue url is resolved for the "+c.name, func(t *testing.T) {
+			client := &mockedAttributesSqsClient{
+				mockedSqsClient: &mockedSqsClient{Config: awsConfig},
+				Attributes: map[string]string{
+					"RedrivePolicy": `{"deadLetterTargetArn":"arn:aws:sqs:eu-west-1:123456789:my-sqs-queue-dlq","maxReceiveCount":5}`,
+				},
+			}
+			config := c.config
+			config.QueueName = "my-sqs-queue"
+			worker := New(context.Background(), client, &config)
+
+			assert.Equal(t, "https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue-dlq", worker.deadLetterQueueURL)
+			assert.Nil(t, worker.deadLetterWorker, "no dead-letter poller without the DeadLetterHandler")
+		})
+	}
+
+	t.Run("the worker has no dead-letter queue without redrive policy", func(t *testing.T) {
+		client := &mockedAttributesSqsClient{mockedSqsClient: &mockedSqsClient{Config: awsConfig}}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue"})
+
+		assert.Equal(t, "", worker.Stats().DeadLetterQueueARN)
+		assert.Nil(t, worker.deadLetterWorker)
+	})
+}
+
+func TestParseQueueARN(t *testing.T) {
+	accountID, queueName, err := parseQueueARN("arn:aws:sqs:ap-northeast-1:123456789012:my-queue")
+	assert.NoError(t, err)
+	assert.Equal(t, "123456789012", accountID)
+	assert.Equal(t, "my-queue", queueName)
+
+	_, _, err = parseQueueARN("my-queue")
+	assert.Error(t, err)
+}
+
+// receivingSqsClient returns the message to every receive, and records the inputs
+type receivingSqsClient struct {
+	countingDeleteSqsClient
+	inputs chan *sqs.ReceiveMessageInput
+}
+
+func (c *receivingSqsClient) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	select {
+	case c.inputs <- input:
+	default:
+	}
+	return &sqs.ReceiveMessageOutput{Messages: []types.Message{{MessageId: aws.String("dead"), ReceiptHandle: aws.String("dead")}}}, nil
+}
+
+func TestPollDeadLetterQueue(t *testing.T) {
+	client := &mockedAttributesSqsClient{
+		mockedSqsClient: &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}},
+		Attributes: map[string]string{
+			"RedrivePolicy": `{"deadLetterTargetArn":"arn:aws:sqs:eu-west-1:123456789:my-sqs-queue-dlq","maxReceiveCount":5}`,
+		},
+	}
+	pollCtx, stopPolling := context.WithCancel(context.Background())
+	handlerErrs := make(chan error, 1)
+	worker := New(context.Background(), client, &Config{
+		QueueName:              "my-sqs-queue",
+		DeadLetterPollInterval: time.Millisecond,
+		DeadLetterHandler: ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+			stopPolling()
+			handlerErrs <- ctx.Err()
+			return nil
+		}),
+	})
+	dlqClient := &receivingSqsClient{inputs: make(chan *sqs.ReceiveMessageInput, 1)}
+	worker.deadLetterWorker.SqsClient = dlqClient
+
+	worker.pollDeadLetterQueue(pollCtx, context.Background())
+	assert.NoError(t, <-handlerErrs, "the handler runs under the handler context, not under the poll context")
+	input := <-dlqClient.inputs
+	assert.Equal(t, []string{"All"}, input.MessageAttributeNames)
+	assert.Equal(t, []types.QueueAttributeName{"All"}, input.AttributeNames)
+}
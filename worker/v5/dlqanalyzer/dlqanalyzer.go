@@ -0,0 +1,170 @@
+// Package dlqanalyzer samples a dead-letter queue and summarizes the messages by the error attribute or the payload shape
+package dlqanalyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// ReceiverAPI interface is the minimum interface required for Analyze
+type ReceiverAPI interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+}
+
+// Options is the sampling options of Analyze
+type Options struct {
+	// Samples is the maximum number of distinct messages sampled (default: 100)
+	Samples int
+	// MaxPolls is the maximum number of receives (default: Samples/10 + 5)
+	MaxPolls int
+	// ErrorAttribute is the message attribute grouping the messages when set and present,
+	// the messages without it are grouped by the payload shape
+	ErrorAttribute string
+	// ExamplesPerGroup is the number of example message IDs kept per group (default: 3)
+	ExamplesPerGroup int
+}
+
+func (o *Options) populateDefaultValues() {
+	if o.Samples <= 0 {
+		o.Samples = 100
+	}
+	if o.MaxPolls <= 0 {
+		o.MaxPolls = o.Samples/10 + 5
+	}
+	if o.ExamplesPerGroup <= 0 {
+		o.ExamplesPerGroup = 3
+	}
+}
+
+// Group is the messages sharing the error attribute or the payload shape
+type Group struct {
+	Key      string
+	Count    int
+	Examples []string
+	// OldestSentAt is the oldest SentTimestamp in the group
+	OldestSentAt time.Time
+	// MaxReceiveCount is the maximum ApproximateReceiveCount in the group
+	MaxReceiveCount int
+}
+
+// Report is the summary of the sampled messages, the groups are sorted by the count
+type Report struct {
+	QueueURL string
+	Sampled  int
+	Groups   []*Group
+}
+
+// Analyze samples the queue without consuming it and groups the messages.
+// The messages are received with zero visibility timeout, so they stay visible to other consumers.
+// Note the sampling increments the ApproximateReceiveCount of the messages.
+func Analyze(ctx context.Context, client ReceiverAPI, queueURL string, opts Options) (*Report, error) {
+	opts.populateDefaultValues()
+	input := &sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(queueURL),
+		MaxNumberOfMessages:   10,
+		VisibilityTimeout:     0,
+		AttributeNames:        []types.QueueAttributeName{types.QueueAttributeNameAll},
+		MessageAttributeNames: []string{"All"},
+	}
+	seen := map[string]struct{}{}
+	groups := map[string]*Group{}
+	for poll := 0; poll < opts.MaxPolls && len(seen) < opts.Samples; poll++ {
+		resp, err := client.ReceiveMessage(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive messages: %w", err)
+		}
+		if len(resp.Messages) == 0 {
+			break
+		}
+		for i := range resp.Messages {
+			m := &resp.Messages[i]
+			id := aws.ToString(m.MessageId)
+			if _, ok := seen[id]; ok || len(seen) >= opts.Samples {
+				continue
+			}
+			seen[id] = struct{}{}
+			key := groupKey(m, opts.ErrorAttribute)
+			g, ok := groups[key]
+			if !ok {
+				g = &Group{Key: key}
+				groups[key] = g
+			}
+			g.add(m, opts.ExamplesPerGroup)
+		}
+	}
+
+	report := &Report{QueueURL: queueURL, Sampled: len(seen)}
+	for _, g := range groups {
+		report.Groups = append(report.Groups, g)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if report.Groups[i].Count != report.Groups[j].Count {
+			return report.Groups[i].Count > report.Groups[j].Count
+		}
+		return report.Groups[i].Key < report.Groups[j].Key
+	})
+	return report, nil
+}
+
+func (g *Group) add(m *types.Message, examples int) {
+	g.Count++
+	if len(g.Examples) < examples {
+		g.Examples = append(g.Examples, aws.ToString(m.MessageId))
+	}
+	if ms, err := strconv.ParseInt(m.Attributes[string(types.MessageSystemAttributeNameSentTimestamp)], 10, 64); err == nil {
+		sent := time.Unix(0, ms*int64(time.Millisecond))
+		if g.OldestSentAt.IsZero() || sent.Before(g.OldestSentAt) {
+			g.OldestSentAt = sent
+		}
+	}
+	if n, err := strconv.Atoi(m.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)]); err == nil && n > g.MaxReceiveCount {
+		g.MaxReceiveCount = n
+	}
+}
+
+// groupKey returns the error attribute, or the payload shape (the sorted top-level keys of the JSON body)
+func groupKey(m *types.Message, errorAttribute string) string {
+	if errorAttribute != "" {
+		if v, ok := m.MessageAttributes[errorAttribute]; ok && v.StringValue != nil {
+			return "error: " + aws.ToString(v.StringValue)
+		}
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(aws.ToString(m.Body)), &body); err != nil {
+		return "shape: (not a JSON object)"
+	}
+	keys := make([]string, 0, len(body))
+	for k := range body {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return "shape: {" + strings.Join(keys, ",") + "}"
+}
+
+// Print writes the report as a human readable summary
+func (r *Report) Print(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "queue: %s\nsampled: %d messages, %d groups\n", r.QueueURL, r.Sampled, len(r.Groups)); err != nil {
+		return err
+	}
+	for _, g := range r.Groups {
+		oldest := "-"
+		if !g.OldestSentAt.IsZero() {
+			oldest = g.OldestSentAt.UTC().Format(time.RFC3339)
+		}
+		if _, err := fmt.Fprintf(w, "\n%6d  %s\n        oldest=%s max_receive_count=%d examples=%s\n",
+			g.Count, g.Key, oldest, g.MaxReceiveCount, strings.Join(g.Examples, ",")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package dlqanalyzer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeReceiver struct {
+	batches [][]types.Message
+	calls   int
+}
+
+func (f *fakeReceiver) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	f.calls++
+	if params.VisibilityTimeout != 0 {
+		panic("the sampling must not hide the messages")
+	}
+	if len(f.batches) == 0 {
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+	messages := f.batches[0]
+	f.batches = f.batches[1:]
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func message(id, body, errorMessage string) types.Message {
+	m := types.Message{
+		MessageId:  aws.String(id),
+		Body:       aws.String(body),
+		Attributes: map[string]string{"SentTimestamp": "1650000000000", "ApproximateReceiveCount": "4"},
+	}
+	if errorMessage != "" {
+		m.MessageAttributes = map[string]types.MessageAttributeValue{
+			"ErrorMessage": {DataType: aws.String("String"), StringValue: aws.String(errorMessage)},
+		}
+	}
+	return m
+}
+
+func TestAnalyze(t *testing.T) {
+	client := &fakeReceiver{batches: [][]types.Message{
+		{
+			message("1", `{"project_id":1,"scan_id":2}`, ""),
+			message("2", `{"scan_id":3,"project_id":1}`, ""),
+			message("3", `not json`, ""),
+		},
+		{
+			message("1", `{"project_id":1,"scan_id":2}`, ""),
+			message("4", `{}`, "timeout"),
+		},
+	}}
+	report, err := Analyze(context.Background(), client, "https://sqs/dlq", Options{ErrorAttribute: "ErrorMessage"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 4, report.Sampled, "the duplicate receive is counted once")
+	assert.Equal(t, 3, client.calls, "stops at the empty receive")
+	if assert.Len(t, report.Groups, 3) {
+		assert.Equal(t, "shape: {project_id,scan_id}", report.Groups[0].Key)
+		assert.Equal(t, 2, report.Groups[0].Count)
+		assert.Equal(t, []string{"1", "2"}, report.Groups[0].Examples)
+		assert.Equal(t, 4, report.Groups[0].MaxReceiveCount)
+		assert.Equal(t, int64(1650000000), report.Groups[0].OldestSentAt.Unix())
+		assert.Equal(t, "error: timeout", report.Groups[1].Key)
+		assert.Equal(t, "shape: (not a JSON object)", report.Groups[2].Key)
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.Print(&buf))
+	assert.True(t, strings.Contains(buf.String(), "sampled: 4 messages, 3 groups"))
+}
+
+func TestAnalyzeSamples(t *testing.T) {
+	client := &fakeReceiver{batches: [][]types.Message{
+		{message("1", `{}`, ""), message("2", `{}`, ""), message("3", `{}`, "")},
+	}}
+	report, err := Analyze(context.Background(), client, "https://sqs/dlq", Options{Samples: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Sampled)
+}
@@ -0,0 +1,135 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// drainEmptyReceives is the number of the consecutive empty short polls regarded as the empty queue,
+// as a short poll samples only a subset of the SQS servers and may return no messages from the non-empty queue.
+const drainEmptyReceives = 3
+
+// DrainOnce short-polls the queue and processes the received batches until the queue is empty, then returns.
+// It's for the batch jobs and the cron-style consumers emptying the queue and exiting rather than running forever.
+// The batches are processed one by one like Start without the Workers, and the receive error other than the throttling is returned.
+func (worker *Worker) DrainOnce(ctx context.Context, h Handler) error {
+	pollCtx, handlerCtx, end, err := worker.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer worker.stopHandling()
+	defer end()
+	empty := 0
+	return worker.drainUntil(ctx, pollCtx, handlerCtx, h, true, func(received bool) bool {
+		if received {
+			empty = 0
+			return false
+		}
+		empty++
+		return empty >= drainEmptyReceives
+	})
+}
+
+// drainUntil processes the batches until idle returns true after a receive, which is short-polled if shortPoll is true
+func (worker *Worker) drainUntil(ctx, pollCtx, handlerCtx context.Context, h Handler, shortPoll bool, idle func(received bool) bool) error {
+	defer worker.stopHandlingAfter(ctx)()
+	for {
+		if pollCtx.Err() != nil {
+			return ctx.Err()
+		}
+		params := worker.receiveParams()
+		if shortPoll {
+			shortParams := *params
+			shortParams.WaitTimeSeconds = 0
+			params = &shortParams
+		}
+		receiveCtx, cancelReceive := callContext(pollCtx, worker.Config.receiveTimeout())
+		receiveCtx, finishSpan := worker.startClientSpan(receiveCtx, "ReceiveMessage")
+		polled := time.Now()
+		resp, err := worker.SqsClient.ReceiveMessage(receiveCtx, params, worker.Config.sqsOptions()...)
+		finishSpan(err)
+		cancelReceive()
+		summary := pollSummary{wait: time.Since(polled)}
+		if err != nil && IsThrottled(err) {
+			worker.stats.addThrottled()
+			worker.logEventWith(ctx, LogEventThrottled, requestFields(err), "worker: Receive was throttled, cooling down for %s, err=%+v", worker.Config.ThrottleCooldown, err)
+			worker.cooldown(pollCtx)
+			continue
+		}
+		if err != nil && IsAccessDenied(err) && pollCtx.Err() == nil {
+			if fatal := worker.deniedReceive(err); fatal != nil {
+				worker.fail(ctx, LogEventAccessDenied, fatal)
+				return fatal
+			}
+			worker.logEventWith(ctx, LogEventAccessDenied, requestFields(err), "worker: Receive was denied, retrying in %s, queue=%s, err=%+v", worker.Config.ThrottleCooldown, worker.Config.QueueName, err)
+			worker.cooldown(pollCtx)
+			continue
+		}
+		if err != nil {
+			if pollCtx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("worker: failed to receive messages, queue=%s, err=%w", worker.Config.QueueName, err)
+		}
+		worker.allowedReceive()
+		if worker.Config.Hooks.OnBatchReceived != nil {
+			worker.Config.Hooks.OnBatchReceived(ctx, resp)
+		}
+		worker.observeReceive(ctx, len(resp.Messages))
+		if summary.messages = len(resp.Messages); summary.messages > 0 {
+			received := time.Now()
+			summary.outcomes = worker.run(withReceivedAt(handlerCtx, worker.now()), h, resp.Messages)
+			summary.dispatch = time.Since(received)
+		}
+		worker.logPoll(ctx, summary)
+		if idle(len(resp.Messages) > 0) {
+			worker.Log.Infof(ctx, "worker: Drained the queue, queue=%s", worker.Config.QueueName)
+			return nil
+		}
+	}
+}
+
+// RunSummary is the summary of RunUntilEmpty for the job logs
+type RunSummary struct {
+	QueueName string
+	// Received is the number of messages received during the run
+	Received int64
+	// Succeeded is the number of messages handled and deleted successfully
+	Succeeded int64
+	// Failed is the number of messages that failed to be handled or deleted
+	Failed   int64
+	Duration time.Duration
+}
+
+// RunUntilEmpty processes the messages like Start, and returns after the queue stays empty for the idleThreshold
+// with the summary of the run, which is logged too. It's for the CLI and the cron jobs processing the queue exhaustively.
+// The receives are long-polled with the WaitTimeSecond, so the idleThreshold shorter than it ends the run on the first empty receive.
+func (worker *Worker) RunUntilEmpty(ctx context.Context, h Handler, idleThreshold time.Duration) (RunSummary, error) {
+	before, started := worker.Stats(), time.Now()
+	pollCtx, handlerCtx, end, err := worker.begin(ctx)
+	if err != nil {
+		return RunSummary{QueueName: worker.Config.QueueName}, err
+	}
+	lastReceived := worker.now()
+	err = worker.drainUntil(ctx, pollCtx, handlerCtx, h, false, func(received bool) bool {
+		if received {
+			lastReceived = worker.now()
+			return false
+		}
+		return worker.since(lastReceived) >= idleThreshold
+	})
+	end()
+	worker.stopHandling()
+	after := worker.Stats()
+	summary := RunSummary{
+		QueueName: worker.Config.QueueName,
+		Received:  after.Received - before.Received,
+		Succeeded: after.Succeeded - before.Succeeded,
+		Failed:    after.Failed - before.Failed,
+		Duration:  time.Since(started),
+	}
+	worker.Log.Infof(ctx, "worker: Finished the run, queue=%s, received=%d, succeeded=%d, failed=%d, duration=%s",
+		summary.QueueName, summary.Received, summary.Succeeded, summary.Failed, summary.Duration)
+	return summary, err
+}
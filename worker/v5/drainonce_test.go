@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// batchesSqsClient returns the batches in order from ReceiveMessage, then the empty ones or the err
+type batchesSqsClient struct {
+	nopSqsClient
+	mu      sync.Mutex
+	batches [][]types.Message
+	err     error
+	waits   []int32
+}
+
+func (c *batchesSqsClient) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits = append(c.waits, input.WaitTimeSeconds)
+	if len(c.batches) == 0 {
+		if c.err != nil {
+			return nil, c.err
+		}
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+	batch := c.batches[0]
+	c.batches = c.batches[1:]
+	return &sqs.ReceiveMessageOutput{Messages: batch}, nil
+}
+
+func testBatch(ids ...string) []types.Message {
+	batch := make([]types.Message, len(ids))
+	for i, id := range ids {
+		batch[i] = types.Message{MessageId: aws.String(id), ReceiptHandle: aws.String(id)}
+	}
+	return batch
+}
+
+func TestDrainOnce(t *testing.T) {
+	client := &batchesSqsClient{batches: [][]types.Message{testBatch("1", "2"), {}, testBatch("3")}}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", WaitTimeSecond: 20})
+	var mu sync.Mutex
+	var handled []string
+	err := worker.DrainOnce(context.Background(), HandlerFunc(func(msg *types.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, aws.ToString(msg.MessageId))
+		return nil
+	}))
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1", "2", "3"}, handled, "a single empty receive doesn't end the drain")
+	assert.Equal(t, []int32{0, 0, 0, 0, 0, 0}, client.waits, "the queue is short-polled until the empty receives continue")
+	assert.False(t, worker.Running())
+
+	client.err = errors.New("access denied")
+	assert.Error(t, worker.DrainOnce(context.Background(), HandlerFunc(func(msg *types.Message) error { return nil })))
+}
+
+func TestRunUntilEmpty(t *testing.T) {
+	client := &batchesSqsClient{batches: [][]types.Message{testBatch("1", "2"), {}, testBatch("3")}}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", WaitTimeSecond: 20})
+	summary, err := worker.RunUntilEmpty(context.Background(), HandlerFunc(func(msg *types.Message) error {
+		if aws.ToString(msg.MessageId) == "2" {
+			return errors.New("failed")
+		}
+		return nil
+	}), 20*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, "my-sqs-queue", summary.QueueName)
+	assert.Equal(t, int64(3), summary.Received)
+	assert.Equal(t, int64(2), summary.Succeeded)
+	assert.Equal(t, int64(1), summary.Failed)
+	assert.True(t, summary.Duration >= 20*time.Millisecond, "the run ends after the queue stays empty for the idle threshold")
+	assert.Equal(t, int32(20), client.waits[0], "the queue is long-polled")
+
+	client.err = errors.New("access denied")
+	_, err = worker.RunUntilEmpty(context.Background(), HandlerFunc(func(msg *types.Message) error { return nil }), time.Second)
+	assert.Error(t, err)
+}
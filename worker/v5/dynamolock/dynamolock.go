@@ -0,0 +1,145 @@
+// Package dynamolock provides the DynamoDB-backed worker.ProcessingLock implementation.
+//
+// The table requires a string partition key(default: "id"), and the TTL should be enabled on the expiration attribute(default: "expires_at").
+package dynamolock
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+)
+
+const (
+	statusProcessing = "processing"
+	statusDone       = "done"
+)
+
+// DynamoDBAPI interface is the minimum interface required for the Lock
+type DynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+// Lock is the processing lock using DynamoDB conditional writes
+type Lock struct {
+	Client    DynamoDBAPI
+	TableName string
+	// KeyAttribute is the partition key name (default: id)
+	KeyAttribute string
+	// ExpiresAttribute is the TTL attribute name (default: expires_at)
+	ExpiresAttribute string
+	// LockTTL is the lifetime of the lock while processing, it should be longer than the visibility timeout (default: 15 minutes)
+	LockTTL time.Duration
+	// DoneTTL is the lifetime of the record after processing to detect duplicates (default: 24 hours)
+	DoneTTL time.Duration
+
+	now func() time.Time
+}
+
+// New creates Lock struct with default values
+func New(client DynamoDBAPI, tableName string) *Lock {
+	return &Lock{
+		Client:           client,
+		TableName:        tableName,
+		KeyAttribute:     "id",
+		ExpiresAttribute: "expires_at",
+		LockTTL:          15 * time.Minute,
+		DoneTTL:          24 * time.Hour,
+		now:              time.Now,
+	}
+}
+
+func (l *Lock) key(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{l.KeyAttribute: &types.AttributeValueMemberS{Value: key}}
+}
+
+func unix(t time.Time) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Unix(), 10)}
+}
+
+// Acquire puts the lock item unless the key is locked or processed and not expired.
+// When the key exists, its status is read to tell the processed key from the one still processing.
+func (l *Lock) Acquire(ctx context.Context, key string) (worker.LockState, error) {
+	now := l.now()
+	item := l.key(key)
+	item["status"] = &types.AttributeValueMemberS{Value: statusProcessing}
+	item[l.ExpiresAttribute] = unix(now.Add(l.LockTTL))
+	_, err := l.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(l.TableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#key) OR #expires < :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#key":     l.KeyAttribute,
+			"#expires": l.ExpiresAttribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":now": unix(now)},
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return l.state(ctx, key)
+	}
+	if err != nil {
+		return "", err
+	}
+	return worker.LockAcquired, nil
+}
+
+// state returns the state of the existing lock item, the item expired or deleted meanwhile is in progress to be retried
+func (l *Lock) state(ctx context.Context, key string) (worker.LockState, error) {
+	out, err := l.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(l.TableName),
+		Key:            l.key(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if status, ok := out.Item["status"].(*types.AttributeValueMemberS); ok && status.Value == statusDone {
+		return worker.LockDone, nil
+	}
+	return worker.LockInProgress, nil
+}
+
+// Confirm marks the key as processed and keeps it until the DoneTTL
+func (l *Lock) Confirm(ctx context.Context, key string) error {
+	_, err := l.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(l.TableName),
+		Key:              l.key(key),
+		UpdateExpression: aws.String("SET #status = :done, #expires = :expires"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":  "status",
+			"#expires": l.ExpiresAttribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":done":    &types.AttributeValueMemberS{Value: statusDone},
+			":expires": unix(l.now().Add(l.DoneTTL)),
+		},
+	})
+	return err
+}
+
+// Release deletes the lock item if it's still processing
+func (l *Lock) Release(ctx context.Context, key string) error {
+	_, err := l.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                aws.String(l.TableName),
+		Key:                      l.key(key),
+		ConditionExpression:      aws.String("#status = :processing"),
+		ExpressionAttributeNames: map[string]string{"#status": "status"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":processing": &types.AttributeValueMemberS{Value: statusProcessing},
+		},
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package dynamolock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockedDynamoDB struct {
+	mock.Mock
+}
+
+func (m *mockedDynamoDB) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	args := m.Called(params)
+	out, _ := args.Get(0).(*dynamodb.GetItemOutput)
+	return out, args.Error(1)
+}
+
+func (m *mockedDynamoDB) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return &dynamodb.PutItemOutput{}, m.Called(params).Error(0)
+}
+
+func (m *mockedDynamoDB) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return &dynamodb.UpdateItemOutput{}, m.Called(params).Error(0)
+}
+
+func (m *mockedDynamoDB) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	return &dynamodb.DeleteItemOutput{}, m.Called(params).Error(0)
+}
+
+func TestAcquire(t *testing.T) {
+	now := time.Unix(1650000000, 0)
+	item := func(status string) *dynamodb.GetItemOutput {
+		return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{"status": &types.AttributeValueMemberS{Value: status}}}
+	}
+	cases := []struct {
+		name    string
+		putErr  error
+		got     *dynamodb.GetItemOutput
+		want    worker.LockState
+		wantErr bool
+	}{
+		{name: "lock is acquired", want: worker.LockAcquired},
+		{name: "duplicate is detected", putErr: &types.ConditionalCheckFailedException{}, got: item("done"), want: worker.LockDone},
+		{name: "lock is in progress", putErr: &types.ConditionalCheckFailedException{}, got: item("processing"), want: worker.LockInProgress},
+		{name: "lock is gone meanwhile", putErr: &types.ConditionalCheckFailedException{}, got: &dynamodb.GetItemOutput{}, want: worker.LockInProgress},
+		{name: "dynamodb error", putErr: errors.New("boom"), wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client := &mockedDynamoDB{}
+			client.On("PutItem", mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+				expires := in.Item["expires_at"].(*types.AttributeValueMemberN).Value
+				return *in.TableName == "locks" && expires == "1650000900"
+			})).Return(c.putErr)
+			if c.got != nil {
+				client.On("GetItem", mock.MatchedBy(func(in *dynamodb.GetItemInput) bool {
+					return *in.TableName == "locks" && *in.ConsistentRead
+				})).Return(c.got, nil)
+			}
+			lock := New(client, "locks")
+			lock.now = func() time.Time { return now }
+
+			got, err := lock.Acquire(context.Background(), "message-id")
+			assert.Equal(t, c.wantErr, err != nil)
+			assert.Equal(t, c.want, got)
+			client.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRelease(t *testing.T) {
+	client := &mockedDynamoDB{}
+	client.On("DeleteItem", mock.Anything).Return(&types.ConditionalCheckFailedException{})
+	lock := New(client, "locks")
+
+	assert.NoError(t, lock.Release(context.Background(), "message-id"), "already confirmed lock is not an error")
+}
@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// ExpiredMessagePolicy is the action for the message older than the MaxMessageAge
+type ExpiredMessagePolicy string
+
+const (
+	// ExpiredDelete deletes the message without the handler
+	ExpiredDelete ExpiredMessagePolicy = "delete"
+	// ExpiredDeadLetter sends the message to the dead-letter queue, or deletes it when the dead-letter queue is unknown
+	ExpiredDeadLetter ExpiredMessagePolicy = "dead_letter"
+	// ExpiredHandle dispatches the message to the ExpiredMessageHandler instead of the handler
+	ExpiredHandle ExpiredMessagePolicy = "handle"
+)
+
+// ExpiredMessageEvent is passed to the OnExpiredMessage hook
+type ExpiredMessageEvent struct {
+	QueueName string
+	Message   *types.Message
+	// Age is the time since the message was sent
+	Age    time.Duration
+	MaxAge time.Duration
+	// Policy is the ExpiredMessagePolicy applied after the hook
+	Policy ExpiredMessagePolicy
+}
+
+// messageAge returns the time since the message was sent, or 0 when the SentTimestamp was not received
+func (worker *Worker) messageAge(m *types.Message) time.Duration {
+	sent := NewMessage(m).SentAt()
+	if sent.IsZero() {
+		return 0
+	}
+	return worker.since(sent)
+}
+
+// expired reports whether the message is older than the MaxMessageAge
+func (worker *Worker) expired(m *types.Message) (time.Duration, bool) {
+	if worker.Config.MaxMessageAge <= 0 {
+		return 0, false
+	}
+	age := worker.messageAge(m)
+	return age, age > worker.Config.MaxMessageAge
+}
+
+// onExpiredMessage calls the OnExpiredMessage hook with the policy applied to the message
+func (worker *Worker) onExpiredMessage(ctx context.Context, m *types.Message, age time.Duration, policy ExpiredMessagePolicy) {
+	if worker.Config.Hooks.OnExpiredMessage == nil {
+		return
+	}
+	worker.Config.Hooks.OnExpiredMessage(worker.correlationContext(ctx, m), &ExpiredMessageEvent{
+		QueueName: worker.Config.QueueName,
+		Message:   m,
+		Age:       age,
+		MaxAge:    worker.Config.MaxMessageAge,
+		Policy:    policy,
+	})
+}
+
+// expiredMessagePolicy returns the policy applied to the expired message
+func (worker *Worker) expiredMessagePolicy() ExpiredMessagePolicy {
+	switch worker.Config.ExpiredMessagePolicy {
+	case ExpiredHandle:
+		if worker.Config.ExpiredMessageHandler != nil {
+			return ExpiredHandle
+		}
+	case ExpiredDeadLetter:
+		if worker.deadLetterQueueURL != "" {
+			return ExpiredDeadLetter
+		}
+	}
+	return ExpiredDelete
+}
+
+// expireMessage applies the ExpiredMessagePolicy other than ExpiredHandle to the stale message
+func (worker *Worker) expireMessage(ctx context.Context, m *types.Message, age time.Duration, policy ExpiredMessagePolicy) (Outcome, error) {
+	worker.stats.addExpired()
+	reason := fmt.Sprintf("message expired(age=%s > %s)", age.Truncate(time.Second), worker.Config.MaxMessageAge)
+	if configured := worker.Config.ExpiredMessagePolicy; configured != "" && configured != policy {
+		worker.Log.Warnf(ctx, "worker: The ExpiredMessagePolicy %s is not available, falling back to %s, id=%s", configured, policy, aws.ToString(m.MessageId))
+	}
+	if policy == ExpiredDeadLetter {
+		worker.logEvent(ctx, LogEventExpired, "worker: Sending the expired message to the dead-letter queue, id=%s, %s", aws.ToString(m.MessageId), reason)
+		if err := worker.requeue(ctx, worker.deadLetterQueueURL, m, 0, RetryAttempt(m)); err != nil {
+			return OutcomeFailed, fmt.Errorf("worker: failed to send the expired message to the dead-letter queue, err=%w", err)
+		}
+		return OutcomeDeadLettered, nil
+	}
+	worker.logEvent(ctx, LogEventExpired, "worker: Deleting the expired message, id=%s, %s", aws.ToString(m.MessageId), reason)
+	if err := worker.deleteMessage(ctx, m); err != nil {
+		return OutcomeFailed, fmt.Errorf("worker: failed to delete the expired message, err=%w", err)
+	}
+	return OutcomeExpired, nil
+}
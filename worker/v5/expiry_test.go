@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func sentAgo(id string, age time.Duration) *types.Message {
+	sent := time.Now().Add(-age).UnixNano() / int64(time.Millisecond)
+	return &types.Message{
+		MessageId:     aws.String(id),
+		ReceiptHandle: aws.String(id),
+		Attributes: map[string]string{
+			string(types.MessageSystemAttributeNameSentTimestamp): strconv.FormatInt(sent, 10),
+		},
+	}
+}
+
+func TestMaxMessageAge(t *testing.T) {
+	var handled int64
+	h := HandlerFunc(func(msg *types.Message) error {
+		atomic.AddInt64(&handled, 1)
+		return nil
+	})
+	ctx := context.Background()
+
+	t.Run("delete", func(t *testing.T) {
+		client := &countingDeleteSqsClient{}
+		worker := New(ctx, client, &Config{QueueName: "my-sqs-queue", MaxMessageAge: time.Hour})
+		outcome, err := worker.handle(ctx, sentAgo("fresh", time.Minute), h)
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeSucceeded, outcome)
+		outcome, err = worker.handle(ctx, sentAgo("stale", 2*time.Hour), h)
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeExpired, outcome)
+		outcome, err = worker.handle(ctx, &types.Message{MessageId: aws.String("unknown"), ReceiptHandle: aws.String("unknown")}, h)
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeSucceeded, outcome, "the message without the SentTimestamp is never expired")
+
+		assert.Equal(t, int64(2), atomic.LoadInt64(&handled), "the handler is skipped for the expired message")
+		assert.Equal(t, int64(3), atomic.LoadInt64(&client.deleted))
+		assert.Equal(t, int64(1), worker.Stats().Expired)
+	})
+
+	t.Run("dead letter", func(t *testing.T) {
+		client := &mockedSenderSqsClient{&mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+		client.On("SendMessage", mock.MatchedBy(func(in *sqs.SendMessageInput) bool {
+			return aws.ToString(in.QueueUrl) == "https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue-dlq"
+		})).Return().Once()
+		client.On("DeleteMessage", mock.Anything).Return().Once()
+		worker := New(ctx, client, &Config{QueueName: "my-sqs-queue", MaxMessageAge: time.Hour, ExpiredMessagePolicy: ExpiredDeadLetter})
+		worker.deadLetterQueueURL = "https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue-dlq"
+		outcome, err := worker.handle(ctx, sentAgo("stale", 2*time.Hour), h)
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeDeadLettered, outcome)
+		client.AssertExpectations(t)
+	})
+
+	t.Run("handle", func(t *testing.T) {
+		var expired []string
+		worker := New(ctx, &countingDeleteSqsClient{}, &Config{
+			QueueName:            "my-sqs-queue",
+			MaxMessageAge:        time.Hour,
+			ExpiredMessagePolicy: ExpiredHandle,
+			ExpiredMessageHandler: HandlerFunc(func(msg *types.Message) error {
+				expired = append(expired, aws.ToString(msg.MessageId))
+				return nil
+			}),
+		})
+		outcome, err := worker.handle(ctx, sentAgo("stale", 2*time.Hour), h)
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeSucceeded, outcome, "the expired message is deleted after the ExpiredMessageHandler")
+		assert.Equal(t, []string{"stale"}, expired)
+	})
+}
+
+func TestMaxMessageAgeSystemAttributes(t *testing.T) {
+	config := &Config{
+		QueueName:                   "my-sqs-queue",
+		MaxMessageAge:               time.Hour,
+		MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameApproximateReceiveCount},
+	}
+	config.populateDefaultValues()
+	assert.Contains(t, config.MessageSystemAttributeNames, types.MessageSystemAttributeNameSentTimestamp, "the SentTimestamp is received for the TTL")
+}
+
+func TestOnExpiredMessage(t *testing.T) {
+	var events []*ExpiredMessageEvent
+	var projectID interface{}
+	worker := New(context.Background(), &countingDeleteSqsClient{}, &Config{
+		QueueName:            "my-sqs-queue",
+		MaxMessageAge:        time.Hour,
+		ExpiredMessagePolicy: ExpiredDeadLetter,
+		FieldsExtractor: func(ctx context.Context, m *types.Message) map[string]interface{} {
+			return map[string]interface{}{"project_id": 1001}
+		},
+		Hooks: Hooks{
+			OnExpiredMessage: func(ctx context.Context, event *ExpiredMessageEvent) {
+				events = append(events, event)
+				projectID = LogFieldsFromContext(ctx)["project_id"]
+			},
+		},
+	})
+	h := HandlerFunc(func(msg *types.Message) error { return nil })
+	_, err := worker.handle(context.Background(), sentAgo("fresh", time.Minute), h)
+	assert.NoError(t, err)
+	outcome, err := worker.handle(context.Background(), sentAgo("stale", 2*time.Hour), h)
+	assert.NoError(t, err)
+
+	assert.Equal(t, OutcomeExpired, outcome, "the message is deleted since the dead-letter queue is unknown")
+	if assert.Len(t, events, 1, "the hook is called only for the expired message") {
+		assert.Equal(t, "stale", aws.ToString(events[0].Message.MessageId))
+		assert.Equal(t, ExpiredDelete, events[0].Policy, "the hook receives the policy actually applied")
+		assert.True(t, events[0].Age > time.Hour)
+		assert.Equal(t, time.Hour, events[0].MaxAge)
+	}
+	assert.Equal(t, 1001, projectID, "the hook context has the correlation fields")
+}
@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxVisibilityTimeout is the maximum visibility timeout of SQS
+const maxVisibilityTimeout = 12 * time.Hour
+
+// ErrNotInHandler is returned by ExtendVisibility called outside of the handler context
+var ErrNotInHandler = errors.New("worker: not called in the handler context")
+
+// ExtendVisibility changes the visibility timeout of the message being handled to the duration from now
+// (rounded up to seconds, up to 12 hours since the message was received, as SQS measures the maximum from the receive)
+// from the context of the ContextHandler,
+// for the handler which occasionally needs more time without the periodic Checkpoint.
+// The extended visibility postpones the CancelOnVisibilityExpiry and the deadline of the HandlerRetry.
+func ExtendVisibility(ctx context.Context, d time.Duration) error {
+	p, ok := ctx.Value(progressKey{}).(*progress)
+	if !ok {
+		return ErrNotInHandler
+	}
+	remaining := (maxVisibilityTimeout - p.worker.since(p.worker.receivedAt(ctx))).Truncate(time.Second)
+	if d <= 0 || d > remaining {
+		return fmt.Errorf("worker: the visibility timeout must be between 1s and %s remaining of %s since the receive, got %s", remaining, maxVisibilityTimeout, d)
+	}
+	timeout := int32((d + time.Second - 1) / time.Second)
+	now := p.worker.now()
+	if err := p.worker.changeVisibility(ctx, p.m, timeout); err != nil {
+		return fmt.Errorf("worker: failed to extend the visibility, err=%w", err)
+	}
+	p.mu.Lock()
+	p.lastExtended = now
+	p.expiresAt = now.Add(time.Duration(timeout) * time.Second)
+	p.mu.Unlock()
+	return nil
+}
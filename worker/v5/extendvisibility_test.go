@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type extendingSqsClient struct {
+	countingDeleteSqsClient
+	mu       sync.Mutex
+	extended []*sqs.ChangeMessageVisibilityInput
+}
+
+func (c *extendingSqsClient) ChangeMessageVisibility(ctx context.Context, input *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.extended = append(c.extended, input)
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func TestExtendVisibility(t *testing.T) {
+	m := func() *types.Message {
+		return &types.Message{MessageId: aws.String("m"), ReceiptHandle: aws.String("receipt")}
+	}
+
+	t.Run("extended", func(t *testing.T) {
+		client := &extendingSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue"})
+		_, err := worker.handle(context.Background(), m(), ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+			return ExtendVisibility(ctx, 90*time.Second+time.Millisecond)
+		}))
+		assert.NoError(t, err)
+		if assert.Len(t, client.extended, 1) {
+			assert.Equal(t, "receipt", aws.ToString(client.extended[0].ReceiptHandle))
+			assert.Equal(t, int32(91), client.extended[0].VisibilityTimeout, "rounded up to seconds")
+		}
+	})
+
+	t.Run("postpones the visibility expiry", func(t *testing.T) {
+		client := &extendingSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", VisibilityTimeout: 1, CancelOnVisibilityExpiry: true})
+		var canceled bool
+		_, err := worker.handle(context.Background(), m(), ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+			if err := ExtendVisibility(ctx, time.Minute); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				canceled = true
+				return ctx.Err()
+			case <-time.After(1500 * time.Millisecond):
+				return nil
+			}
+		}))
+		assert.NoError(t, err)
+		assert.False(t, canceled, "the handler isn't canceled at the expiry of the original visibility")
+		assert.Zero(t, worker.Stats().LostOwnership)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		worker := New(context.Background(), &extendingSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+		_, err := worker.handle(context.Background(), m(), ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+			assert.Error(t, ExtendVisibility(ctx, 0))
+			assert.Error(t, ExtendVisibility(ctx, 13*time.Hour))
+			return nil
+		}))
+		assert.NoError(t, err)
+	})
+
+	t.Run("beyond 12 hours since the receive", func(t *testing.T) {
+		client := &extendingSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue"})
+		ctx := withReceivedAt(context.Background(), time.Now().Add(-11*time.Hour))
+		_, err := worker.handle(ctx, m(), ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+			assert.Error(t, ExtendVisibility(ctx, 2*time.Hour), "SQS caps the visibility at 12 hours from the receive")
+			return ExtendVisibility(ctx, 30*time.Minute)
+		}))
+		assert.NoError(t, err)
+		assert.Len(t, client.extended, 1)
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		worker := New(context.Background(), &countingDeleteSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+		_, _ = worker.handle(context.Background(), m(), ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+			assert.ErrorIs(t, ExtendVisibility(ctx, time.Minute), errVisibilityNotSupported)
+			return nil
+		}))
+	})
+
+	t.Run("outside of the handler", func(t *testing.T) {
+		assert.ErrorIs(t, ExtendVisibility(context.Background(), time.Minute), ErrNotInHandler)
+	})
+}
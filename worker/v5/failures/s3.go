@@ -0,0 +1,53 @@
+// Package failures provides the worker.FailureSink implementation storing the sampled failed payloads to S3.
+package failures
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+)
+
+// S3PutAPI interface is the minimum interface required for the S3Sink
+type S3PutAPI interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// S3Sink writes each failed payload to S3 as a JSON object.
+// The object key is {Prefix}/yyyy/mm/dd/{message-id}.json, so the redeliveries of the same message overwrite it.
+// Configure a lifecycle rule on the prefix to delete the samples after the postmortem.
+type S3Sink struct {
+	Client S3PutAPI
+	Bucket string
+	Prefix string
+}
+
+// NewS3Sink creates S3Sink struct
+func NewS3Sink(client S3PutAPI, bucket, prefix string) *S3Sink {
+	return &S3Sink{Client: client, Bucket: bucket, Prefix: prefix}
+}
+
+// Store writes the failed payload to S3
+func (s *S3Sink) Store(ctx context.Context, payload *worker.FailedPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s/%s.json", payload.FailedAt.UTC().Format("2006/01/02"), payload.MessageID)
+	if s.Prefix != "" {
+		key = s.Prefix + "/" + key
+	}
+	if _, err := s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	}); err != nil {
+		return fmt.Errorf("failed to put the failed payload, key=%s, err=%w", key, err)
+	}
+	return nil
+}
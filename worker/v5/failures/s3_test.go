@@ -0,0 +1,49 @@
+package failures
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ worker.FailureSink = (*S3Sink)(nil)
+
+type fakeS3 struct {
+	objects map[string][]byte
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)] = body
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestS3Sink(t *testing.T) {
+	client := &fakeS3{objects: map[string][]byte{}}
+	sink := NewS3Sink(client, "my-bucket", "failures")
+	err := sink.Store(context.Background(), &worker.FailedPayload{
+		MessageID: "message-1",
+		Body:      `{"scan_id":1}`,
+		Error:     "broken",
+		FailedAt:  time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC),
+	})
+	assert.NoError(t, err)
+
+	object, ok := client.objects["my-bucket/failures/2022/05/01/message-1.json"]
+	if assert.True(t, ok) {
+		var payload worker.FailedPayload
+		assert.NoError(t, json.Unmarshal(object, &payload))
+		assert.Equal(t, `{"scan_id":1}`, payload.Body)
+		assert.Equal(t, "broken", payload.Error)
+	}
+}
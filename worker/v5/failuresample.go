@@ -0,0 +1,164 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"golang.org/x/time/rate"
+)
+
+// redactedValue replaces the values of the redacted fields
+const redactedValue = "[REDACTED]"
+
+// DefaultRedactedFields is the JSON fields redacted by the default FailureSampling.Redact
+var DefaultRedactedFields = []string{"password", "secret", "token", "access_key", "secret_access_key", "api_key", "authorization"}
+
+// FailedPayload is the payload of the failed message stored by the FailureSink for the postmortem
+type FailedPayload struct {
+	QueueURL     string            `json:"queue_url"`
+	MessageID    string            `json:"message_id"`
+	Body         string            `json:"body"`
+	Truncated    bool              `json:"truncated,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	ReceiveCount int               `json:"receive_count"`
+	Error        string            `json:"error"`
+	FailedAt     time.Time         `json:"failed_at"`
+}
+
+// FailureSink interface stores the sampled payloads of the failed messages (e.g. failures package)
+type FailureSink interface {
+	Store(ctx context.Context, payload *FailedPayload) error
+}
+
+// FailureSampling persists a sampled subset of the payloads failed in the handler, e.g. the malformed producer data,
+// so that they can be analyzed without digging through the dead-letter queue.
+// The samples are limited by the Rate and the MaxPerMinute budget, and the bodies are redacted before they are stored.
+type FailureSampling struct {
+	Sink FailureSink
+	// Rate is the ratio of the failures sampled (default: 0.01)
+	Rate float64
+	// MaxPerMinute is the budget of the samples per minute (default: 10)
+	MaxPerMinute int
+	// MaxBodySize is the size over which the redacted body is truncated (default: 64KiB)
+	MaxBodySize int
+	// Redact redacts the body before it's stored (default: RedactFields(DefaultRedactedFields...))
+	Redact func(body string) string
+}
+
+func (s *FailureSampling) populateDefaultValues() {
+	if s.Rate <= 0 || s.Rate > 1 {
+		s.Rate = 0.01
+	}
+	if s.MaxPerMinute <= 0 {
+		s.MaxPerMinute = 10
+	}
+	if s.MaxBodySize <= 0 {
+		s.MaxBodySize = 64 * 1024
+	}
+	if s.Redact == nil {
+		s.Redact = RedactFields(DefaultRedactedFields...)
+	}
+}
+
+func newFailureBudget(s *FailureSampling) *rate.Limiter {
+	if s == nil {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(float64(s.MaxPerMinute)/60), s.MaxPerMinute)
+}
+
+// RedactFields returns the redactor replacing the values of the JSON fields of the names (case-insensitive) at any depth.
+// The body that isn't a JSON object or array is returned as is.
+func RedactFields(names ...string) func(body string) string {
+	redacted := make(map[string]bool, len(names))
+	for _, name := range names {
+		redacted[strings.ToLower(name)] = true
+	}
+	var redact func(v interface{}) interface{}
+	redact = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, child := range v {
+				if redacted[strings.ToLower(k)] {
+					v[k] = redactedValue
+					continue
+				}
+				v[k] = redact(child)
+			}
+		case []interface{}:
+			for i, child := range v {
+				v[i] = redact(child)
+			}
+		}
+		return v
+	}
+	return func(body string) string {
+		var v interface{}
+		if err := json.Unmarshal([]byte(body), &v); err != nil {
+			return body
+		}
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+		default:
+			return body
+		}
+		b, err := json.Marshal(redact(v))
+		if err != nil {
+			return body
+		}
+		return string(b)
+	}
+}
+
+// sampleFailure stores the payload of the message failed in the handler when it's sampled within the budget
+func (worker *Worker) sampleFailure(ctx context.Context, m *types.Message, cause error) {
+	s := worker.Config.FailureSampling
+	if s == nil || s.Sink == nil || !sampledFailure(cause) {
+		return
+	}
+	if rand.Float64() >= s.Rate || !worker.failureBudget.Allow() {
+		return
+	}
+	// the body is redacted before the truncation, which breaks the JSON
+	body := s.Redact(aws.ToString(m.Body))
+	truncated := len(body) > s.MaxBodySize
+	if truncated {
+		body = body[:s.MaxBodySize]
+	}
+	payload := &FailedPayload{
+		QueueURL:     worker.Config.QueueURL,
+		MessageID:    aws.ToString(m.MessageId),
+		Body:         body,
+		Truncated:    truncated,
+		ReceiveCount: NewMessage(m).ReceiveCount(),
+		Error:        cause.Error(),
+		FailedAt:     worker.now(),
+	}
+	for k, v := range m.MessageAttributes {
+		if v.StringValue == nil {
+			continue
+		}
+		if payload.Attributes == nil {
+			payload.Attributes = map[string]string{}
+		}
+		payload.Attributes[k] = *v.StringValue
+	}
+	if err := s.Sink.Store(withoutCancel{ctx}, payload); err != nil {
+		worker.Log.Warnf(ctx, "worker: Failed to store the failed payload, id=%s, err=%+v", payload.MessageID, err)
+		return
+	}
+	worker.stats.addFailureSampled()
+}
+
+// sampledFailure reports whether the error of the handler is the failure of the message rather than the control of it
+func sampledFailure(err error) bool {
+	var parked *ParkError
+	var paused *PausedError
+	return !errors.As(err, &parked) && !errors.As(err, &paused) && !errors.Is(err, errDeferExpired)
+}
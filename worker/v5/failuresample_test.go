@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryFailureSink struct {
+	payloads []*FailedPayload
+}
+
+func (s *memoryFailureSink) Store(ctx context.Context, payload *FailedPayload) error {
+	s.payloads = append(s.payloads, payload)
+	return nil
+}
+
+func TestFailureSampling(t *testing.T) {
+	sink := &memoryFailureSink{}
+	worker := New(context.Background(), &countingDeleteSqsClient{}, &Config{
+		QueueName:       "my-sqs-queue",
+		FailureSampling: &FailureSampling{Sink: sink, Rate: 1, MaxPerMinute: 2, MaxBodySize: 40},
+	})
+	failing := HandlerFunc(func(msg *types.Message) error { return errors.New("unexpected field") })
+	m := func(id, body string) *types.Message {
+		return &types.Message{
+			MessageId:     aws.String(id),
+			ReceiptHandle: aws.String(id),
+			Body:          aws.String(body),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"project_id": {DataType: aws.String("Number"), StringValue: aws.String("1001")},
+			},
+		}
+	}
+	ctx := context.Background()
+	_, _ = worker.handle(ctx, m("1", `{"scan_id":1,"token":"xxx"}`), failing)
+	_, _ = worker.handle(ctx, m("2", `{"scan_id":2,"padding":"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}`), failing)
+	_, _ = worker.handle(ctx, m("3", `{"scan_id":3}`), failing)
+	_, _ = worker.handle(ctx, m("4", `{"scan_id":4}`), HandlerFunc(func(msg *types.Message) error { return nil }))
+
+	if assert.Len(t, sink.payloads, 2, "the samples are limited by the budget") {
+		assert.Equal(t, `{"scan_id":1,"token":"[REDACTED]"}`, sink.payloads[0].Body)
+		assert.Equal(t, "unexpected field", sink.payloads[0].Error)
+		assert.Equal(t, "1001", sink.payloads[0].Attributes["project_id"])
+		assert.True(t, sink.payloads[1].Truncated)
+		assert.Len(t, sink.payloads[1].Body, 40)
+	}
+	assert.Equal(t, int64(2), worker.Stats().FailuresSampled)
+}
+
+func TestRedactFields(t *testing.T) {
+	redact := RedactFields("Password", "token")
+	assert.Equal(t, `{"user":{"password":"[REDACTED]"},"users":[{"TOKEN":"[REDACTED]"}]}`,
+		redact(`{"user":{"password":"p"},"users":[{"TOKEN":"t"}]}`), "the fields are redacted at any depth case-insensitively")
+	assert.Equal(t, "not json", redact("not json"))
+	assert.Equal(t, `"token"`, redact(`"token"`))
+}
+
+func TestFailureSamplingFailedAt(t *testing.T) {
+	clock := &settableClock{now: time.Unix(1650000000, 0)}
+	sink := &memoryFailureSink{}
+	worker := New(context.Background(), &countingDeleteSqsClient{}, &Config{
+		QueueName:       "my-sqs-queue",
+		FailureSampling: &FailureSampling{Sink: sink, Rate: 1},
+		Clock:           clock,
+	})
+	_, _ = worker.handle(context.Background(), &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("1"), Body: aws.String(`{}`)},
+		HandlerFunc(func(msg *types.Message) error { return errors.New("unexpected field") }))
+	if assert.Len(t, sink.payloads, 1) {
+		assert.Equal(t, clock.now, sink.payloads[0].FailedAt, "the time of the Clock")
+	}
+}
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// FIFOIDs derives the MessageGroupId and the MessageDeduplicationId of the message sent to the FIFO queue
+// from the source message, i.e. the message requeued, resent to the retry or the dead-letter queue, mirrored, or consumed by the publishing handler.
+// The input is the message to send, e.g. with the RetryAttemptAttribute of the resend.
+type FIFOIDs func(source *types.Message, input *sqs.SendMessageInput) (groupID, deduplicationID string)
+
+// DefaultFIFOIDs keeps the MessageGroupId of the source message (or uses its MessageId from the standard queue),
+// so that the order within the group is preserved across the queues.
+// The MessageDeduplicationId is the hash of the deduplication ID of the source (or its MessageId), the retry attempt and the body,
+// so that the redelivery of the source sends the same ID deduplicated by SQS, while the next attempt or
+// the different message published from the source isn't dropped within the deduplication interval.
+func DefaultFIFOIDs(source *types.Message, input *sqs.SendMessageInput) (string, string) {
+	groupID := source.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]
+	if groupID == "" {
+		groupID = aws.ToString(source.MessageId)
+	}
+	sourceID := source.Attributes[string(types.MessageSystemAttributeNameMessageDeduplicationId)]
+	if sourceID == "" {
+		sourceID = aws.ToString(source.MessageId)
+	}
+	attempt := aws.ToString(input.MessageAttributes[RetryAttemptAttribute].StringValue)
+	sum := sha256.Sum256([]byte(sourceID + "\x00" + attempt + "\x00" + aws.ToString(input.MessageBody)))
+	return groupID, hex.EncodeToString(sum[:])
+}
+
+// isFIFOQueue reports whether the queue URL is of the FIFO queue
+func isFIFOQueue(queueURL string) bool {
+	return strings.HasSuffix(queueURL, ".fifo")
+}
+
+// setFIFOIDs sets the IDs derived by the FIFOIDs(default: DefaultFIFOIDs) on the input to the FIFO queue unless already set.
+// The per-message DelaySeconds is cleared since the FIFO queue rejects it.
+func setFIFOIDs(ids FIFOIDs, source *types.Message, input *sqs.SendMessageInput) {
+	if !isFIFOQueue(aws.ToString(input.QueueUrl)) || source == nil {
+		return
+	}
+	if ids == nil {
+		ids = DefaultFIFOIDs
+	}
+	groupID, deduplicationID := ids(source, input)
+	if aws.ToString(input.MessageGroupId) == "" && groupID != "" {
+		input.MessageGroupId = aws.String(groupID)
+	}
+	if aws.ToString(input.MessageDeduplicationId) == "" && deduplicationID != "" {
+		input.MessageDeduplicationId = aws.String(deduplicationID)
+	}
+	input.DelaySeconds = 0
+}
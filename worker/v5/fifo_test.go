@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultFIFOIDs(t *testing.T) {
+	source := &types.Message{MessageId: aws.String("m"), Attributes: map[string]string{
+		string(types.MessageSystemAttributeNameMessageGroupId):         "group",
+		string(types.MessageSystemAttributeNameMessageDeduplicationId): "dedup",
+	}}
+	input := func(attempt, body string) *sqs.SendMessageInput {
+		return &sqs.SendMessageInput{MessageBody: aws.String(body), MessageAttributes: map[string]types.MessageAttributeValue{
+			RetryAttemptAttribute: {DataType: aws.String("Number"), StringValue: aws.String(attempt)},
+		}}
+	}
+
+	groupID, first := DefaultFIFOIDs(source, input("1", "body"))
+	assert.Equal(t, "group", groupID)
+	_, redelivered := DefaultFIFOIDs(source, input("1", "body"))
+	assert.Equal(t, first, redelivered, "the redelivery of the source is deduplicated")
+	_, next := DefaultFIFOIDs(source, input("2", "body"))
+	assert.NotEqual(t, first, next, "the next attempt isn't deduplicated")
+	_, other := DefaultFIFOIDs(source, input("1", "other"))
+	assert.NotEqual(t, first, other, "the other message from the source isn't deduplicated")
+	assert.LessOrEqual(t, len(first), 128)
+
+	groupID, _ = DefaultFIFOIDs(&types.Message{MessageId: aws.String("standard")}, input("1", "body"))
+	assert.Equal(t, "standard", groupID, "the message from the standard queue is grouped by itself")
+}
+
+func TestFIFOIDs(t *testing.T) {
+	const fifoURL = "https://sqs.eu-west-1.amazonaws.com/123456789012/my-sqs-queue.fifo"
+	m := func() *types.Message {
+		return &types.Message{MessageId: aws.String("m"), ReceiptHandle: aws.String("m"), Body: aws.String("body"),
+			Attributes: map[string]string{string(types.MessageSystemAttributeNameMessageGroupId): "group"}}
+	}
+
+	t.Run("requeue", func(t *testing.T) {
+		client := &recordingSenderSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue.fifo"})
+		assert.NoError(t, worker.RequeueTo(context.Background(), fifoURL, m(), time.Minute))
+		if assert.Len(t, client.sent, 1) {
+			assert.Equal(t, "group", aws.ToString(client.sent[0].MessageGroupId))
+			assert.NotEmpty(t, aws.ToString(client.sent[0].MessageDeduplicationId))
+			assert.Zero(t, client.sent[0].DelaySeconds, "the FIFO queue rejects the per-message delay")
+		}
+	})
+
+	t.Run("standard queue", func(t *testing.T) {
+		client := &recordingSenderSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue"})
+		assert.NoError(t, worker.Requeue(context.Background(), m(), time.Minute))
+		if assert.Len(t, client.sent, 1) {
+			assert.Nil(t, client.sent[0].MessageGroupId)
+			assert.Nil(t, client.sent[0].MessageDeduplicationId)
+			assert.Equal(t, int32(60), client.sent[0].DelaySeconds)
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		client := &recordingSenderSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue.fifo",
+			FIFOIDs: func(source *types.Message, input *sqs.SendMessageInput) (string, string) {
+				return "tenant", aws.ToString(source.MessageId) + "-" + aws.ToString(input.MessageAttributes[RetryAttemptAttribute].StringValue)
+			}})
+		assert.NoError(t, worker.RequeueTo(context.Background(), fifoURL, m(), 0))
+		if assert.Len(t, client.sent, 1) {
+			assert.Equal(t, "tenant", aws.ToString(client.sent[0].MessageGroupId))
+			assert.Equal(t, "m-1", aws.ToString(client.sent[0].MessageDeduplicationId))
+		}
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		client := &recordingSenderSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue.fifo"})
+		publisher := NewPublisher(client)
+		_, err := worker.handle(context.Background(), m(), ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+			if _, err := publisher.Publish(ctx, &sqs.SendMessageInput{QueueUrl: aws.String(fifoURL), MessageBody: aws.String("first")}); err != nil {
+				return err
+			}
+			_, err := publisher.Publish(ctx, &sqs.SendMessageInput{QueueUrl: aws.String(fifoURL), MessageBody: aws.String("second"),
+				MessageDeduplicationId: aws.String("explicit")})
+			return err
+		}))
+		assert.NoError(t, err)
+		if assert.Len(t, client.sent, 2) {
+			assert.Equal(t, "group", aws.ToString(client.sent[0].MessageGroupId), "the group of the consumed message is propagated")
+			assert.NotEmpty(t, aws.ToString(client.sent[0].MessageDeduplicationId))
+			assert.Equal(t, "explicit", aws.ToString(client.sent[1].MessageDeduplicationId), "the explicit ID is kept")
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package worker
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// goroutineAccounting counts the goroutines started by the worker, and tracks the running handler calls
+// to detect the handlers leaked past the drain of the shutdown
+type goroutineAccounting struct {
+	owned  int64
+	leaked int64
+
+	mu       sync.Mutex
+	handlers map[*types.Message]time.Time
+	// returned is closed and renewed when a handler returns
+	returned chan struct{}
+}
+
+// goOwned runs the function in the goroutine counted as owned by the worker
+func (worker *Worker) goOwned(f func()) {
+	g := &worker.goroutines
+	atomic.AddInt64(&g.owned, 1)
+	go func() {
+		defer atomic.AddInt64(&g.owned, -1)
+		f()
+	}()
+}
+
+// enterHandler tracks the running handler of the message, and the returned function untracks it
+func (worker *Worker) enterHandler(m *types.Message) func() {
+	g := &worker.goroutines
+	g.mu.Lock()
+	if g.handlers == nil {
+		g.handlers = map[*types.Message]time.Time{}
+	}
+	g.handlers[m] = worker.now()
+	g.mu.Unlock()
+	return func() {
+		g.mu.Lock()
+		delete(g.handlers, m)
+		if g.returned != nil {
+			close(g.returned)
+			g.returned = nil
+		}
+		g.mu.Unlock()
+	}
+}
+
+// waitHandlers waits until no handler is running or the timeout elapses
+func (worker *Worker) waitHandlers(timeout time.Duration) {
+	g := &worker.goroutines
+	timer := worker.clock().NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		g.mu.Lock()
+		if len(g.handlers) == 0 {
+			g.mu.Unlock()
+			return
+		}
+		if g.returned == nil {
+			g.returned = make(chan struct{})
+		}
+		returned := g.returned
+		g.mu.Unlock()
+		select {
+		case <-returned:
+		case <-timer.C():
+			return
+		}
+	}
+}
+
+// runningHandlers returns the number of the running handlers
+func (g *goroutineAccounting) runningHandlers() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.handlers)
+}
+
+// LeakedHandler is the handler still running after the shutdown, i.e. past the DrainTimeout and the cancellation of its context
+type LeakedHandler struct {
+	MessageID string
+	// Running is the time the handler has been running at the end of the shutdown
+	Running time.Duration
+}
+
+// detectLeaks returns the handlers still running the LeakGracePeriod after the cancellation of their context
+// at the end of the shutdown in the order of their start, and logs them, since they ignore the cancellation
+// and hold the resources such as the connections and the goroutines they started
+func (worker *Worker) detectLeaks(ctx context.Context) []LeakedHandler {
+	worker.waitHandlers(worker.Config.LeakGracePeriod)
+	g := &worker.goroutines
+	g.mu.Lock()
+	now := worker.now()
+	leaked := make([]LeakedHandler, 0, len(g.handlers))
+	for m, start := range g.handlers {
+		leaked = append(leaked, LeakedHandler{MessageID: aws.ToString(m.MessageId), Running: now.Sub(start)})
+	}
+	g.mu.Unlock()
+	if len(leaked) == 0 {
+		return nil
+	}
+	sort.SliceStable(leaked, func(i, j int) bool { return leaked[i].Running > leaked[j].Running })
+	atomic.AddInt64(&g.leaked, int64(len(leaked)))
+	ids := make([]string, len(leaked))
+	for i, l := range leaked {
+		ids[i] = l.MessageID
+	}
+	worker.logEvent(ctx, LogEventLeakedHandler, "worker: Handlers are still running after the shutdown, ignoring the cancellation of the context, queue=%s, count=%d, oldest=%s, ids=%s",
+		worker.Config.QueueName, len(leaked), leaked[0].Running.Truncate(time.Millisecond), strings.Join(ids, ","))
+	return leaked
+}
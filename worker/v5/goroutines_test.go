@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeakedHandlers(t *testing.T) {
+	newClient := func() *ctxDeleteSqsClient {
+		client := &ctxDeleteSqsClient{batchSqsClient{batches: make(chan []types.Message, 1)}, make(chan error, 1)}
+		client.batches <- []types.Message{{MessageId: aws.String("m"), ReceiptHandle: aws.String("m")}}
+		return client
+	}
+
+	t.Run("leaked", func(t *testing.T) {
+		worker := New(context.Background(), newClient(), &Config{QueueName: "my-sqs-queue", DrainTimeout: 10 * time.Millisecond,
+			LeakGracePeriod: 10 * time.Millisecond, KeepVisibilityOnShutdown: true})
+		ctx, cancel := context.WithCancel(context.Background())
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			worker.Start(ctx, HandlerFunc(func(msg *types.Message) error {
+				close(started)
+				// ignores the cancellation of the context
+				<-release
+				return nil
+			}))
+		}()
+		<-started
+		assert.Equal(t, int64(1), worker.Stats().RunningHandlers)
+		assert.Positive(t, worker.Stats().Goroutines, "the goroutine of the handler is owned by the worker")
+		cancel()
+		<-done
+		leaked := worker.ShutdownReport().Leaked
+		if assert.Len(t, leaked, 1) {
+			assert.Equal(t, "m", leaked[0].MessageID)
+			assert.Positive(t, leaked[0].Running)
+		}
+		assert.Equal(t, int64(1), worker.Stats().LeakedHandlers)
+
+		close(release)
+		assert.Eventually(t, func() bool {
+			stats := worker.Stats()
+			return stats.RunningHandlers == 0 && stats.Goroutines == 0
+		}, time.Second, time.Millisecond, "the goroutines of the worker finish after the handler returns")
+	})
+
+	t.Run("returned within the grace period", func(t *testing.T) {
+		worker := New(context.Background(), newClient(), &Config{QueueName: "my-sqs-queue", DrainTimeout: 10 * time.Millisecond,
+			LeakGracePeriod: time.Minute, KeepVisibilityOnShutdown: true})
+		ctx, cancel := context.WithCancel(context.Background())
+		started := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			worker.Start(ctx, ContextHandlerFunc(func(handlerCtx context.Context, msg *types.Message) error {
+				close(started)
+				<-handlerCtx.Done()
+				return handlerCtx.Err()
+			}))
+		}()
+		<-started
+		cancel()
+		<-done
+		assert.Empty(t, worker.ShutdownReport().Leaked, "the handler returning on the cancellation is not leaked")
+		assert.Zero(t, worker.Stats().LeakedHandlers)
+	})
+}
@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ca-risken/common/pkg/logging"
+)
+
+// workerGroup runs a dynamic set of workers keyed by the queue, for Discovery and ShardedQueues
+type workerGroup struct {
+	name    string
+	mu      sync.Mutex
+	members map[string]*groupMember
+	running sync.WaitGroup
+}
+
+type groupMember struct {
+	worker *Worker
+	stop   context.CancelFunc
+}
+
+func newWorkerGroup(name string) *workerGroup {
+	return &workerGroup{name: name, members: map[string]*groupMember{}}
+}
+
+// reconcile stops the workers not wanted anymore, and starts the wanted ones not running by their constructors.
+// The nil constructor keeps the worker running if any.
+func (g *workerGroup) reconcile(ctx context.Context, log logging.Logger, h Handler, want map[string]func() *Worker) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for key, m := range g.members {
+		if _, ok := want[key]; !ok {
+			log.Infof(ctx, "%s: Stopping the worker, queue=%s", g.name, m.worker.Config.QueueName)
+			m.stop()
+			delete(g.members, key)
+		}
+	}
+	for key, newWorker := range want {
+		if _, ok := g.members[key]; ok || newWorker == nil {
+			continue
+		}
+		worker := newWorker()
+		workerCtx, stop := context.WithCancel(ctx)
+		g.members[key] = &groupMember{worker: worker, stop: stop}
+		log.Infof(ctx, "%s: Starting the worker, queue=%s", g.name, worker.Config.QueueName)
+		g.running.Add(1)
+		go func() {
+			defer g.running.Done()
+			worker.Start(workerCtx, h)
+		}()
+	}
+}
+
+// snapshot returns the running workers by the key
+func (g *workerGroup) snapshot() map[string]*Worker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	workers := make(map[string]*Worker, len(g.members))
+	for key, m := range g.members {
+		workers[key] = m.worker
+	}
+	return workers
+}
+
+// wait waits for all workers started by the group, including the stopped ones
+func (g *workerGroup) wait() {
+	g.running.Wait()
+}
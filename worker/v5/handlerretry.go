@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// HandlerRetry retries the handler in process for the transient errors before the message falls back to
+// the RetryQueue or the SQS redelivery, so that the blips are recovered without the redelivery latency.
+// The backoff doubles from Backoff up to MaxBackoff, and the retry stops at the end of the handler context
+// or when the visibility timeout of the message would expire during the backoff.
+type HandlerRetry struct {
+	// MaxAttempts is the maximum number of the handler calls per delivery including the first one (default: 3)
+	MaxAttempts int
+	// Backoff is the delay before the first retry (default: 100 milliseconds)
+	Backoff time.Duration
+	// MaxBackoff is the maximum delay between the retries (default: 2 seconds)
+	MaxBackoff time.Duration
+	// Retryable reports whether the error is transient (default: any error other than the ones controlling the message,
+	// e.g. InvalidEventError, ParkError, PausedError and DeferredError, the HandlerPanicError, the context errors, and the AWS errors
+	// of IsAccessDenied and IsQueueMissing)
+	Retryable func(err error) bool
+}
+
+func (r *HandlerRetry) populateDefaultValues() {
+	if r.MaxAttempts <= 0 {
+		r.MaxAttempts = 3
+	}
+	if r.Backoff <= 0 {
+		r.Backoff = 100 * time.Millisecond
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = 2 * time.Second
+	}
+	if r.MaxBackoff < r.Backoff {
+		r.MaxBackoff = r.Backoff
+	}
+	if r.Retryable == nil {
+		r.Retryable = transientHandlerError
+	}
+}
+
+// transientHandlerError is the default Retryable
+func transientHandlerError(err error) bool {
+	if _, ok := err.(InvalidEventError); ok {
+		return false
+	}
+	var parked *ParkError
+	var paused *PausedError
+	var deferred *DeferredError
+	var panicked *HandlerPanicError
+	if errors.As(err, &parked) || errors.As(err, &paused) || errors.As(err, &deferred) || errors.As(err, &panicked) {
+		return false
+	}
+	if IsAccessDenied(err) || IsQueueMissing(err) {
+		return false
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// callHandlerWithRetry calls the handler, and retries it by the HandlerRetry while the error is retryable
+func (worker *Worker) callHandlerWithRetry(ctx context.Context, h Handler, msg *types.Message) error {
+	err := worker.callHandlerRecovered(ctx, h, msg)
+	r := worker.Config.HandlerRetry
+	if r == nil {
+		return err
+	}
+	backoff := r.Backoff
+	for attempt := 2; attempt <= r.MaxAttempts && err != nil && r.Retryable(err); attempt++ {
+		if !worker.canRetryInProcess(ctx, backoff) {
+			return err
+		}
+		worker.stats.addHandlerRetried()
+		worker.logEvent(ctx, LogEventHandlerRetry, "worker: Retrying the handler in %s, id=%s, attempt=%d, err=%+v", backoff, aws.ToString(msg.MessageId), attempt, err)
+		timer := worker.clock().NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C():
+		}
+		err = worker.callHandlerRecovered(ctx, h, msg)
+		if backoff *= 2; backoff > r.MaxBackoff {
+			backoff = r.MaxBackoff
+		}
+	}
+	return err
+}
+
+// canRetryInProcess reports whether the handler can be retried after the backoff
+// without the message getting visible to the other consumers
+func (worker *Worker) canRetryInProcess(ctx context.Context, backoff time.Duration) bool {
+	if ctx.Err() != nil || lostOwnership(ctx) || canceledByKey(ctx) {
+		return false
+	}
+	if worker.Config.VisibilityTimeout <= 0 {
+		return true
+	}
+	deadline := worker.receivedAt(ctx).Add(time.Duration(worker.Config.VisibilityTimeout) * time.Second)
+	if p, ok := ctx.Value(progressKey{}).(*progress); ok {
+		p.mu.Lock()
+		if p.expiresAt.After(deadline) {
+			deadline = p.expiresAt
+		}
+		p.mu.Unlock()
+	}
+	return worker.now().Add(backoff).Before(deadline)
+}
@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerRetry(t *testing.T) {
+	ctx := context.Background()
+	newWorker := func(retry *HandlerRetry) *Worker {
+		return New(ctx, &countingDeleteSqsClient{}, &Config{QueueName: "my-sqs-queue", HandlerRetry: retry})
+	}
+	m := func() *types.Message {
+		return &types.Message{MessageId: aws.String("blip"), ReceiptHandle: aws.String("blip")}
+	}
+
+	t.Run("recovered", func(t *testing.T) {
+		worker := newWorker(&HandlerRetry{Backoff: time.Millisecond})
+		var calls int64
+		outcome, err := worker.handle(ctx, m(), HandlerFunc(func(msg *types.Message) error {
+			if atomic.AddInt64(&calls, 1) < 3 {
+				return errors.New("connection reset")
+			}
+			return nil
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeSucceeded, outcome, "the blip is recovered without the redelivery")
+		assert.Equal(t, int64(3), calls)
+		assert.Equal(t, int64(2), worker.Stats().HandlerRetried)
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		worker := newWorker(&HandlerRetry{MaxAttempts: 2, Backoff: time.Millisecond})
+		var calls int64
+		outcome, err := worker.handle(ctx, m(), HandlerFunc(func(msg *types.Message) error {
+			atomic.AddInt64(&calls, 1)
+			return errors.New("connection reset")
+		}))
+		assert.Error(t, err)
+		assert.Equal(t, OutcomeFailed, outcome, "the message falls back to the redelivery")
+		assert.Equal(t, int64(2), calls)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		worker := newWorker(&HandlerRetry{Backoff: time.Millisecond})
+		var calls int64
+		outcome, err := worker.handle(ctx, m(), HandlerFunc(func(msg *types.Message) error {
+			atomic.AddInt64(&calls, 1)
+			return NewInvalidEventError("scan", "broken")
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeInvalid, outcome)
+		assert.Equal(t, int64(1), calls, "the InvalidEventError is not retried")
+	})
+
+	t.Run("access denied", func(t *testing.T) {
+		worker := newWorker(&HandlerRetry{Backoff: time.Millisecond})
+		var calls int64
+		_, err := worker.handle(ctx, m(), HandlerFunc(func(msg *types.Message) error {
+			atomic.AddInt64(&calls, 1)
+			return fmt.Errorf("failed to put the result, err=%w", &smithy.GenericAPIError{Code: "AccessDenied"})
+		}))
+		assert.Error(t, err)
+		assert.Equal(t, int64(1), calls, "the denied call fails the same on the retry")
+	})
+
+	t.Run("visibility", func(t *testing.T) {
+		worker := New(ctx, &countingDeleteSqsClient{}, &Config{
+			QueueName:         "my-sqs-queue",
+			VisibilityTimeout: 1,
+			HandlerRetry:      &HandlerRetry{Backoff: 2 * time.Second},
+		})
+		var calls int64
+		_, err := worker.handle(withReceivedAt(ctx, time.Now()), m(), HandlerFunc(func(msg *types.Message) error {
+			atomic.AddInt64(&calls, 1)
+			return errors.New("connection reset")
+		}))
+		assert.Error(t, err)
+		assert.Equal(t, int64(1), calls, "the retry is skipped when the visibility would expire during the backoff")
+	})
+}
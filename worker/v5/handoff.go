@@ -0,0 +1,174 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/go-sqs-poller/worker/v5/queue"
+)
+
+// VisibilityChangerAPI interface is optionally implemented by the client to change the message visibility.
+// It's an alias of queue.VisibilityChanger.
+type VisibilityChangerAPI = queue.VisibilityChanger
+
+var errVisibilityNotSupported = errors.New("the sqs client does not support ChangeMessageVisibility")
+
+// HandoffStatus is the final status of Handoff
+type HandoffStatus string
+
+const (
+	// HandoffStatusDrained means all in-flight messages finished within the DrainTimeout
+	HandoffStatusDrained HandoffStatus = "drained"
+	// HandoffStatusReleased means some messages were still running and released to the successor
+	HandoffStatusReleased HandoffStatus = "released"
+	// HandoffStatusIncomplete means some unfinished messages could not be released
+	HandoffStatusIncomplete HandoffStatus = "incomplete"
+)
+
+// HandoffResult is the result of Handoff
+type HandoffResult struct {
+	Status HandoffStatus
+	// Drained is the number of in-flight messages finished during Handoff
+	Drained int
+	// Released is the number of unfinished messages whose visibility was reset for the successor
+	Released int
+	// ReleaseFailed is the number of unfinished messages that failed to be released
+	ReleaseFailed int
+}
+
+type inflight struct {
+	mu   sync.Mutex
+	msgs map[*types.Message]struct{}
+	// n is the number of the adds not yet removed
+	n int
+	// idle is closed when n drops to zero, and replaced at the next add
+	idle chan struct{}
+}
+
+func (i *inflight) add(m *types.Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.msgs == nil {
+		i.msgs = map[*types.Message]struct{}{}
+	}
+	i.msgs[m] = struct{}{}
+	if i.n == 0 {
+		i.idle = make(chan struct{})
+	}
+	i.n++
+}
+
+func (i *inflight) remove(m *types.Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.msgs, m)
+	i.n--
+	if i.n == 0 {
+		close(i.idle)
+	}
+}
+
+func (i *inflight) count() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return len(i.msgs)
+}
+
+func (i *inflight) snapshot() []*types.Message {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	msgs := make([]*types.Message, 0, len(i.msgs))
+	for m := range i.msgs {
+		msgs = append(msgs, m)
+	}
+	return msgs
+}
+
+// wait waits until all in-flight messages finish or the timeout elapses, and reports whether they finished
+func (i *inflight) wait(clock Clock, timeout time.Duration) bool {
+	i.mu.Lock()
+	if i.n == 0 {
+		i.mu.Unlock()
+		return true
+	}
+	idle := i.idle
+	i.mu.Unlock()
+	timer := clock.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-idle:
+		return true
+	case <-timer.C():
+		return false
+	}
+}
+
+// Handoff stops receiving for zero-downtime restarts.
+// It waits for in-flight messages up to the DrainTimeout, and then resets the visibility of
+// unfinished messages to the HandoffVisibilityTimeout so that the successor can pick them up promptly.
+// Start returns after the current batch is finished.
+func (worker *Worker) Handoff(ctx context.Context) HandoffResult {
+	return worker.handoff(ctx, worker.Config.DrainTimeout)
+}
+
+// handoff stops receiving and drains the in-flight messages within the timeout
+func (worker *Worker) handoff(ctx context.Context, timeout time.Duration) HandoffResult {
+	worker.mu.Lock()
+	if worker.stopPolling != nil {
+		worker.stopPolling()
+	}
+	worker.mu.Unlock()
+
+	result := worker.drain(ctx, timeout, worker.Config.HandoffVisibilityTimeout)
+	worker.stopHandling()
+	worker.Log.Infof(ctx, "worker: Handoff finished, status=%s, drained=%d, released=%d, failed=%d",
+		result.Status, result.Drained, result.Released, result.ReleaseFailed)
+	return result
+}
+
+// drain waits for in-flight messages up to the timeout, and then resets the visibility of unfinished messages
+func (worker *Worker) drain(ctx context.Context, timeout time.Duration, visibilityTimeout int32) HandoffResult {
+	before := worker.inflight.count()
+	if worker.inflight.wait(worker.clock(), timeout) {
+		return HandoffResult{Status: HandoffStatusDrained, Drained: before}
+	}
+
+	unfinished := worker.inflight.snapshot()
+	result := HandoffResult{Status: HandoffStatusReleased, Drained: before - len(unfinished)}
+	for _, m := range unfinished {
+		if err := worker.changeVisibility(ctx, m, visibilityTimeout); err != nil {
+			worker.Log.Warnf(ctx, "worker: Failed to release the unfinished message, id=%s, err=%+v", aws.ToString(m.MessageId), err)
+			result.ReleaseFailed++
+			continue
+		}
+		worker.stats.addReleased()
+		result.Released++
+	}
+	if result.ReleaseFailed > 0 {
+		result.Status = HandoffStatusIncomplete
+	}
+	return result
+}
+
+func (worker *Worker) changeVisibility(ctx context.Context, m *types.Message, timeout int32) error {
+	if worker.tapped(ctx, m, "ChangeMessageVisibility") {
+		return nil
+	}
+	client, ok := worker.SqsClient.(VisibilityChangerAPI)
+	if !ok {
+		return errVisibilityNotSupported
+	}
+	ctx, finishSpan := worker.startClientSpan(ctx, "ChangeMessageVisibility")
+	_, err := client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(worker.Config.QueueURL),
+		ReceiptHandle:     m.ReceiptHandle,
+		VisibilityTimeout: timeout,
+	}, worker.Config.sqsOptions()...)
+	finishSpan(err)
+	return err
+}
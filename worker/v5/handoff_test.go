@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockedVisibilitySqsClient struct {
+	*mockedSqsClient
+}
+
+func (c *mockedVisibilitySqsClient) ChangeMessageVisibility(ctx context.Context, input *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	c.Called(input)
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func TestHandoff(t *testing.T) {
+	awsConfig := &aws.Config{Region: "eu-west-1"}
+	queueURL := aws.String("https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue")
+	sqsMessage := types.Message{Body: aws.String("body"), ReceiptHandle: aws.String("receipt")}
+
+	t.Run("the worker drains in-flight messages", func(t *testing.T) {
+		client := &mockedVisibilitySqsClient{&mockedSqsClient{
+			Config:   awsConfig,
+			Response: sqs.ReceiveMessageOutput{Messages: []types.Message{sqsMessage}},
+		}}
+		client.On("ReceiveMessage", mock.Anything).Return()
+		client.On("DeleteMessage", mock.Anything).Return()
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", DrainTimeout: time.Second})
+
+		started := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			worker.Start(context.Background(), HandlerFunc(func(msg *types.Message) error {
+				close(started)
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			}))
+			close(done)
+		}()
+		<-started
+
+		result := worker.Handoff(context.Background())
+		<-done
+		assert.Equal(t, HandoffResult{Status: HandoffStatusDrained, Drained: 1}, result)
+		client.AssertNotCalled(t, "ChangeMessageVisibility", mock.Anything)
+	})
+
+	t.Run("the worker releases unfinished messages to the successor", func(t *testing.T) {
+		client := &mockedVisibilitySqsClient{&mockedSqsClient{
+			Config:   awsConfig,
+			Response: sqs.ReceiveMessageOutput{Messages: []types.Message{sqsMessage}},
+		}}
+		client.On("ReceiveMessage", mock.Anything).Return()
+		client.On("DeleteMessage", mock.Anything).Return()
+		client.On("ChangeMessageVisibility", &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          queueURL,
+			ReceiptHandle:     aws.String("receipt"),
+			VisibilityTimeout: 0,
+		}).Return()
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", DrainTimeout: 10 * time.Millisecond})
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			worker.Start(context.Background(), HandlerFunc(func(msg *types.Message) error {
+				close(started)
+				<-release
+				return nil
+			}))
+			close(done)
+		}()
+		<-started
+
+		result := worker.Handoff(context.Background())
+		close(release)
+		<-done
+		assert.Equal(t, HandoffResult{Status: HandoffStatusReleased, Released: 1}, result)
+		client.AssertExpectations(t)
+	})
+}
+
+func TestShutdownVisibilityReset(t *testing.T) {
+	client := &mockedVisibilitySqsClient{&mockedSqsClient{
+		Config:   &aws.Config{Region: "eu-west-1"},
+		Response: sqs.ReceiveMessageOutput{Messages: []types.Message{{Body: aws.String("body"), ReceiptHandle: aws.String("receipt")}}},
+	}}
+	client.On("ReceiveMessage", mock.Anything).Return()
+	client.On("DeleteMessage", mock.Anything).Return().Maybe()
+	client.On("ChangeMessageVisibility", &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String("https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue"),
+		ReceiptHandle:     aws.String("receipt"),
+		VisibilityTimeout: 0,
+	}).Return().Once()
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", DrainTimeout: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	done := make(chan struct{})
+	go func() {
+		worker.Start(ctx, HandlerFunc(func(msg *types.Message) error {
+			close(started)
+			<-release
+			return nil
+		}))
+		close(done)
+	}()
+	<-started
+	cancel()
+
+	<-done
+	client.AssertExpectations(t)
+	report := worker.ShutdownReport()
+	if assert.NotNil(t, report) {
+		assert.Equal(t, 1, report.InFlight)
+		assert.Equal(t, 0, report.Drained)
+		assert.Equal(t, 1, report.VisibilityReset)
+	}
+}
+
+func TestInflightWait(t *testing.T) {
+	var i inflight
+	assert.True(t, i.wait(SystemClock{}, time.Millisecond), "nothing in flight")
+
+	m := &types.Message{MessageId: aws.String("m")}
+	i.add(m)
+	goroutines := runtime.NumGoroutine()
+	assert.False(t, i.wait(SystemClock{}, 10*time.Millisecond))
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines, "the timed out wait leaves no goroutine behind")
+
+	done := make(chan bool)
+	go func() { done <- i.wait(SystemClock{}, time.Second) }()
+	i.remove(m)
+	assert.True(t, <-done)
+
+	i.add(m)
+	assert.False(t, i.wait(SystemClock{}, time.Millisecond), "the add after the idle waits again")
+	i.remove(m)
+	assert.True(t, i.wait(SystemClock{}, time.Millisecond))
+}
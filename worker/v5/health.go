@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"context"
+)
+
+// HealthProbe checks the downstream dependencies of the handler, returning an error when they are unhealthy
+type HealthProbe func(ctx context.Context) error
+
+// waitHealthy blocks while the HealthProbe fails, probing every HealthProbeInterval.
+// The messages are left in the queue as the buffer during the outage instead of failing every handler.
+// It returns false when the context is done before the dependencies become healthy.
+func (worker *Worker) waitHealthy(ctx context.Context) bool {
+	probe := worker.Config.HealthProbe
+	if probe == nil {
+		return true
+	}
+	err := probe(ctx)
+	if err == nil {
+		return true
+	}
+	worker.logEvent(ctx, LogEventUnhealthy, "worker: Paused polling because the health probe failed, err=%+v", err)
+	ticker := worker.clock().NewTicker(worker.Config.HealthProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C():
+			if err := probe(ctx); err != nil {
+				worker.Log.Debugf(ctx, "worker: The health probe still fails, err=%+v", err)
+				continue
+			}
+			worker.Log.Info(ctx, "worker: Resumed polling because the health probe succeeded")
+			return true
+		}
+	}
+}
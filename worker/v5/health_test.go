@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHealthProbe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var probes int32
+	client := &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}
+	client.On("ReceiveMessage", mock.Anything).Return().Run(func(mock.Arguments) {
+		assert.True(t, atomic.LoadInt32(&probes) >= 3, "the polling is paused while the probe fails")
+		cancel()
+	}).Once()
+	worker := New(ctx, client, &Config{
+		QueueName:           "my-sqs-queue",
+		HealthProbeInterval: 5 * time.Millisecond,
+		HealthProbe: func(ctx context.Context) error {
+			if atomic.AddInt32(&probes, 1) < 3 {
+				return errors.New("database is down")
+			}
+			return nil
+		},
+	})
+	worker.Start(ctx, HandlerFunc(func(msg *types.Message) error { return nil }))
+
+	client.AssertNumberOfCalls(t, "ReceiveMessage", 1)
+}
+
+func TestHealthProbeCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	worker := New(ctx, &nopSqsClient{}, &Config{
+		QueueName:           "my-sqs-queue",
+		HealthProbeInterval: time.Hour,
+		HealthProbe:         func(ctx context.Context) error { return errors.New("database is down") },
+	})
+	done := make(chan bool)
+	go func() { done <- worker.waitHealthy(ctx) }()
+	cancel()
+	assert.False(t, <-done, "the wait ends with the context")
+}
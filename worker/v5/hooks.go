@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Hooks is the set of callbacks invoked by the worker, nil hooks are skipped.
+// The hooks are called synchronously, so they should return quickly.
+type Hooks struct {
+	// OnWarmup is called by Run before the polling starts (e.g. open the DB pools, prime the caches),
+	// so that the first messages don't pay the cold start. It may take long, and the error aborts the Run.
+	OnWarmup func(ctx context.Context) error
+	// OnStuckHandler is called when a handler exceeds the WatchdogTimeout
+	OnStuckHandler func(ctx context.Context, event *StuckHandlerEvent)
+	// OnBatchReceived is called with the raw output of every successful ReceiveMessage call, including empty ones
+	OnBatchReceived func(ctx context.Context, output *sqs.ReceiveMessageOutput)
+	// OnBatchProcessed is called after all messages of a received batch finish, with the results in the order of the batch.
+	// It's the flush point for the consumers aggregating the work per batch (e.g. bulk index, single DB commit).
+	OnBatchProcessed func(ctx context.Context, results []BatchResult)
+	// OnUsage is called with the usage recorded by the handler by RecordUsage after each message is processed.
+	// The context has the correlation fields of the message (e.g. project_id) for the per-tenant accounting.
+	OnUsage func(ctx context.Context, usage Usage)
+	// OnSplitEvents is called with the outcomes of the events in the order of the message split by the Splitting,
+	// after the handler is called for the events and before the message is deleted or retried
+	OnSplitEvents func(ctx context.Context, msg *types.Message, results []SplitEventResult)
+	// OnLowUtilization is called at the end of the window of the LowUtilization when the utilization is below the threshold
+	OnLowUtilization func(ctx context.Context, event *UtilizationEvent)
+	// OnExpiredMessage is called with each message older than the MaxMessageAge before the ExpiredMessagePolicy is applied,
+	// e.g. to record "scan skipped due to staleness" rather than dropping it silently.
+	// The context has the correlation fields of the message.
+	OnExpiredMessage func(ctx context.Context, event *ExpiredMessageEvent)
+	// OnHandlerPanic is called with the panic of the handler recovered by the PanicPolicy, before the message is handled by the policy
+	OnHandlerPanic func(ctx context.Context, msg *types.Message, err *HandlerPanicError)
+	// OnFatalError is called with the terminal error stopping the polling, e.g. AccessDeniedError,
+	// so that the deployment fails loudly, typically by exiting the process after Run returns the error
+	OnFatalError func(ctx context.Context, err error)
+	// OnShutdownPhase is called at the end of each ShutdownPhase with the report filled so far,
+	// and the next phase waits for it, e.g. to flush the result buffers of the handlers before the deletes are flushed
+	OnShutdownPhase func(ctx context.Context, phase ShutdownPhase, report *ShutdownReport)
+	// OnShutdown is called with the report after the in-flight messages are drained on the end of the context
+	OnShutdown func(ctx context.Context, report *ShutdownReport)
+}
+
+// BatchResult is the result of a message in the batch passed to OnBatchProcessed
+type BatchResult struct {
+	Message *types.Message
+	Outcome Outcome
+	Err     error
+}
+
+func newBatchResults(messages []types.Message) []BatchResult {
+	results := make([]BatchResult, len(messages))
+	for i := range messages {
+		results[i].Message = &messages[i]
+	}
+	return results
+}
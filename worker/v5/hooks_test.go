@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOnBatchReceived(t *testing.T) {
+	client := &mockedSqsClient{
+		Config:   &aws.Config{Region: "eu-west-1"},
+		Response: sqs.ReceiveMessageOutput{Messages: []types.Message{{Body: aws.String("body"), ReceiptHandle: aws.String("receipt")}}},
+	}
+	client.On("ReceiveMessage", mock.Anything).Return()
+	client.On("DeleteMessage", mock.Anything).Return()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var received *sqs.ReceiveMessageOutput
+	worker := New(ctx, client, &Config{
+		QueueName: "my-sqs-queue",
+		Hooks: Hooks{
+			OnBatchReceived: func(ctx context.Context, output *sqs.ReceiveMessageOutput) {
+				received = output
+				cancel()
+			},
+		},
+	})
+	worker.Start(ctx, HandlerFunc(func(msg *types.Message) error { return nil }))
+
+	if assert.NotNil(t, received) {
+		assert.Len(t, received.Messages, 1)
+		assert.Equal(t, "receipt", aws.ToString(received.Messages[0].ReceiptHandle))
+	}
+}
+
+func TestOnBatchProcessed(t *testing.T) {
+	messages := []types.Message{
+		{MessageId: aws.String("ok"), Body: aws.String("ok"), ReceiptHandle: aws.String("r1")},
+		{MessageId: aws.String("ng"), Body: aws.String("ng"), ReceiptHandle: aws.String("r2")},
+		{MessageId: aws.String("invalid"), Body: aws.String("invalid"), ReceiptHandle: aws.String("r3")},
+	}
+	h := HandlerFunc(func(msg *types.Message) error {
+		switch aws.ToString(msg.Body) {
+		case "ng":
+			return errors.New("failure")
+		case "invalid":
+			return InvalidEventError{event: "invalid", msg: "invalid"}
+		}
+		return nil
+	})
+	for _, keyed := range []bool{false, true} {
+		var results []BatchResult
+		config := &Config{
+			QueueName: "my-sqs-queue",
+			Hooks: Hooks{
+				OnBatchProcessed: func(ctx context.Context, r []BatchResult) { results = r },
+			},
+		}
+		if keyed {
+			config.KeyFunc = func(m *types.Message) string { return aws.ToString(m.MessageId) }
+		}
+		worker := New(context.Background(), &nopSqsClient{}, config)
+		worker.run(context.Background(), h, messages)
+
+		if assert.Len(t, results, 3) {
+			assert.Equal(t, "ok", aws.ToString(results[0].Message.MessageId))
+			assert.Equal(t, OutcomeSucceeded, results[0].Outcome)
+			assert.NoError(t, results[0].Err)
+			assert.Equal(t, OutcomeFailed, results[1].Outcome)
+			assert.Error(t, results[1].Err)
+			assert.Equal(t, OutcomeInvalid, results[2].Outcome)
+		}
+	}
+}
+
+func TestOnWarmup(t *testing.T) {
+	client := &blockingReceiveSqsClient{polling: make(chan struct{}, 1)}
+	warmupErr := errors.New("db is unreachable")
+	worker := New(context.Background(), client, &Config{
+		QueueName: "my-sqs-queue",
+		Hooks:     Hooks{OnWarmup: func(ctx context.Context) error { return warmupErr }},
+	})
+	h := HandlerFunc(func(msg *types.Message) error { return nil })
+
+	err := worker.Run(context.Background(), h)
+	assert.True(t, errors.Is(err, warmupErr), "the warmup error aborts the Run")
+	assert.False(t, worker.Running())
+	assert.Empty(t, client.polling, "the queue is not polled")
+
+	warmupErr = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx, h) }()
+	<-client.polling
+	cancel()
+	assert.NoError(t, <-done)
+}
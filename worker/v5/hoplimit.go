@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// HopLimitAction is the action for the message exceeding the MaxHops
+type HopLimitAction string
+
+const (
+	// HopLimitPark moves the message to the ParkingLot, or fails it for the redrive policy when no ParkingLot is configured
+	HopLimitPark HopLimitAction = "park"
+	// HopLimitDrop deletes the message without the handler
+	HopLimitDrop HopLimitAction = "drop"
+)
+
+// exceedsHopLimit reports whether the HopCount of the message is over the MaxHops
+func (worker *Worker) exceedsHopLimit(m *types.Message) bool {
+	return worker.Config.MaxHops > 0 && HopCount(m) > worker.Config.MaxHops
+}
+
+// bailOutLoop parks or drops the message exceeding the MaxHops, which is likely in a republish cycle
+func (worker *Worker) bailOutLoop(ctx context.Context, m *types.Message) (Outcome, error) {
+	worker.stats.addHopLimited()
+	reason := fmt.Sprintf("hop limit exceeded(%d > %d), origin=%s", HopCount(m), worker.Config.MaxHops, OriginMessageID(m))
+	if worker.Config.HopLimitAction != HopLimitDrop {
+		if worker.Config.ParkingLot == nil {
+			// the message is left to the redrive policy instead of being deleted
+			return OutcomeFailed, fmt.Errorf("worker: the message in a republish loop is not parked without the ParkingLot, id=%s, %s", aws.ToString(m.MessageId), reason)
+		}
+		worker.logEvent(ctx, LogEventHopLimit, "worker: Parking the message in a republish loop, id=%s, %s", aws.ToString(m.MessageId), reason)
+		return worker.parkMessage(ctx, m, &ParkError{Reason: reason})
+	}
+	worker.logEvent(ctx, LogEventHopLimit, "worker: Dropping the message in a republish loop, id=%s, %s", aws.ToString(m.MessageId), reason)
+	if err := worker.deleteMessage(ctx, m); err != nil {
+		return OutcomeFailed, fmt.Errorf("worker: failed to drop the message exceeding the hop limit, err=%w", err)
+	}
+	return OutcomeDropped, nil
+}
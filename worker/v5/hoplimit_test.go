@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHopLimit(t *testing.T) {
+	hops := func(id string, n int) *types.Message {
+		return &types.Message{
+			MessageId:     aws.String(id),
+			ReceiptHandle: aws.String(id),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				HopCountAttribute:        {DataType: aws.String("Number"), StringValue: aws.String(strconv.Itoa(n))},
+				OriginMessageIDAttribute: {DataType: aws.String("String"), StringValue: aws.String("origin")},
+			},
+		}
+	}
+	var handled int64
+	h := HandlerFunc(func(msg *types.Message) error {
+		atomic.AddInt64(&handled, 1)
+		return nil
+	})
+	ctx := context.Background()
+
+	client := &countingDeleteSqsClient{}
+	lot := &mockedParkingLot{}
+	worker := New(ctx, client, &Config{QueueName: "my-sqs-queue", MaxHops: 2, ParkingLot: lot})
+	outcome, err := worker.handle(ctx, hops("1", 2), h)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeSucceeded, outcome, "the message within the limit is handled")
+	outcome, err = worker.handle(ctx, hops("2", 3), h)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeParked, outcome)
+	if assert.Len(t, lot.parked, 1) {
+		assert.True(t, strings.Contains(lot.parked[0].Reason, "origin=origin"))
+	}
+
+	worker = New(ctx, client, &Config{QueueName: "my-sqs-queue", MaxHops: 2, ParkingLot: lot, HopLimitAction: HopLimitDrop})
+	outcome, err = worker.handle(ctx, hops("3", 3), h)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeDropped, outcome)
+	assert.Len(t, lot.parked, 1, "the dropped message is not parked")
+	assert.Equal(t, int64(1), worker.Stats().HopLimited)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&handled), "the handler is skipped over the limit")
+	assert.Equal(t, int64(3), atomic.LoadInt64(&client.deleted))
+
+	worker = New(ctx, client, &Config{QueueName: "my-sqs-queue", MaxHops: 2})
+	outcome, err = worker.handle(ctx, hops("4", 3), h)
+	assert.Error(t, err)
+	assert.Equal(t, OutcomeFailed, outcome, "the message is left to the redrive policy without the ParkingLot")
+	assert.Equal(t, int64(3), atomic.LoadInt64(&client.deleted), "the message is not deleted")
+}
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// ScalerMetrics is the JSON served by KEDAHandler.
+// Use the valueLocation of the KEDA metrics-api scaler to pick a field (e.g. "lag").
+type ScalerMetrics struct {
+	QueueName string `json:"queue_name"`
+	// Lag is the sum of the visible and the not visible messages in the queue
+	Lag                                   int64 `json:"lag"`
+	ApproximateNumberOfMessages           int64 `json:"approximate_number_of_messages"`
+	ApproximateNumberOfMessagesNotVisible int64 `json:"approximate_number_of_messages_not_visible"`
+	ApproximateNumberOfMessagesDelayed    int64 `json:"approximate_number_of_messages_delayed"`
+	// InFlight is the number of messages being processed by this worker
+	InFlight int64 `json:"in_flight"`
+}
+
+// KEDAHandler returns the http.Handler serving the queue lag and the in-flight count as JSON,
+// which is consumable by the KEDA metrics-api scaler.
+// The client must implement the QueueAttributesAPI, otherwise only the in-flight count is served.
+func (worker *Worker) KEDAHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics := ScalerMetrics{
+			QueueName: worker.Config.QueueName,
+			InFlight:  int64(worker.inflight.count()),
+		}
+		if client, ok := worker.SqsClient.(QueueAttributesAPI); ok {
+			callCtx, finishSpan := worker.startClientSpan(r.Context(), "GetQueueAttributes")
+			out, err := client.GetQueueAttributes(callCtx, &sqs.GetQueueAttributesInput{
+				QueueUrl: aws.String(worker.Config.QueueURL),
+				AttributeNames: []types.QueueAttributeName{
+					types.QueueAttributeNameApproximateNumberOfMessages,
+					types.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+					types.QueueAttributeNameApproximateNumberOfMessagesDelayed,
+				},
+			}, worker.Config.sqsOptions()...)
+			finishSpan(err)
+			if err != nil {
+				worker.Log.Warnf(r.Context(), "worker: Failed to get the queue attributes, err=%+v", err)
+				http.Error(w, "failed to get the queue attributes", http.StatusBadGateway)
+				return
+			}
+			metrics.ApproximateNumberOfMessages = attributeInt(out.Attributes, types.QueueAttributeNameApproximateNumberOfMessages)
+			metrics.ApproximateNumberOfMessagesNotVisible = attributeInt(out.Attributes, types.QueueAttributeNameApproximateNumberOfMessagesNotVisible)
+			metrics.ApproximateNumberOfMessagesDelayed = attributeInt(out.Attributes, types.QueueAttributeNameApproximateNumberOfMessagesDelayed)
+			metrics.Lag = metrics.ApproximateNumberOfMessages + metrics.ApproximateNumberOfMessagesNotVisible
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(metrics)
+	})
+}
+
+func attributeInt(attrs map[string]string, name types.QueueAttributeName) int64 {
+	v, _ := strconv.ParseInt(attrs[string(name)], 10, 64)
+	return v
+}
@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKEDAHandler(t *testing.T) {
+	client := &mockedAttributesSqsClient{
+		mockedSqsClient: &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}},
+		Attributes: map[string]string{
+			"ApproximateNumberOfMessages":           "12",
+			"ApproximateNumberOfMessagesNotVisible": "3",
+			"ApproximateNumberOfMessagesDelayed":    "1",
+		},
+	}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue"})
+	before := worker.RequestStats().Requests["GetQueueAttributes"]
+
+	rec := httptest.NewRecorder()
+	worker.KEDAHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, before+1, worker.RequestStats().Requests["GetQueueAttributes"], "the call is counted in the RequestStats")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got ScalerMetrics
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, ScalerMetrics{
+		QueueName:                             "my-sqs-queue",
+		Lag:                                   15,
+		ApproximateNumberOfMessages:           12,
+		ApproximateNumberOfMessagesNotVisible: 3,
+		ApproximateNumberOfMessagesDelayed:    1,
+	}, got)
+}
@@ -0,0 +1,29 @@
+package worker
+
+import (
+	"hash/fnv"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// KeyFunc derives the serialization key of a message.
+// Messages sharing the same key are processed one at a time in receive order.
+type KeyFunc func(msg *types.Message) string
+
+// laneOf returns the lane index(0 <= index < lanes) assigned to the key
+func laneOf(key string, lanes int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(lanes))
+}
+
+// splitLanes groups the messages into lanes by KeyFunc, keeping the receive order in each lane
+func (worker *Worker) splitLanes(messages []types.Message) [][]*types.Message {
+	lanes := make([][]*types.Message, worker.Config.KeyedLanes)
+	for i := range messages {
+		m := &messages[i]
+		idx := laneOf(worker.Config.KeyFunc(m), worker.Config.KeyedLanes)
+		lanes[idx] = append(lanes[idx], m)
+	}
+	return lanes
+}
@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRunKeyed(t *testing.T) {
+	keyOf := func(msg *types.Message) string {
+		return aws.ToString(msg.MessageAttributes["key"].StringValue)
+	}
+	client := &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}
+	client.On("DeleteMessage", mock.Anything).Return()
+	worker := New(context.Background(), client, &Config{
+		QueueName:  "my-sqs-queue",
+		KeyFunc:    keyOf,
+		KeyedLanes: 4,
+	})
+
+	var mu sync.Mutex
+	got := map[string][]string{}
+	handlerFunc := HandlerFunc(func(msg *types.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got[keyOf(msg)] = append(got[keyOf(msg)], aws.ToString(msg.Body))
+		return nil
+	})
+
+	var messages []types.Message
+	for _, m := range []struct{ key, body string }{
+		{"a", "1"}, {"b", "1"}, {"a", "2"}, {"c", "1"}, {"b", "2"}, {"a", "3"},
+	} {
+		messages = append(messages, types.Message{
+			Body:          aws.String(m.body),
+			ReceiptHandle: aws.String(m.key + m.body),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"key": {DataType: aws.String("String"), StringValue: aws.String(m.key)},
+			},
+		})
+	}
+	worker.run(context.Background(), handlerFunc, messages)
+
+	assert.Equal(t, []string{"1", "2", "3"}, got["a"], "messages with key a are processed in order")
+	assert.Equal(t, []string{"1", "2"}, got["b"], "messages with key b are processed in order")
+	assert.Equal(t, []string{"1"}, got["c"], "messages with key c are processed")
+	client.AssertNumberOfCalls(t, "DeleteMessage", len(messages))
+}
+
+func TestLaneOf(t *testing.T) {
+	assert.Equal(t, laneOf("tenant-1", 8), laneOf("tenant-1", 8), "same key is assigned to same lane")
+	for _, key := range []string{"", "a", "tenant-1", "tenant-2"} {
+		lane := laneOf(key, 3)
+		assert.True(t, lane >= 0 && lane < 3, "lane is in range")
+	}
+}
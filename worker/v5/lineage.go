@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// HopCountAttribute is the message attribute counting the re-sends and the publishes from the origin message
+const HopCountAttribute = "HopCount"
+
+// HopCount returns the number of the re-sends (requeue, retry) and the publishes by the Publisher
+// since the origin message (0 for the origin)
+func HopCount(m *types.Message) int {
+	attr, ok := m.MessageAttributes[HopCountAttribute]
+	if !ok {
+		return 0
+	}
+	hops, err := strconv.Atoi(aws.ToString(attr.StringValue))
+	if err != nil || hops < 0 {
+		return 0
+	}
+	return hops
+}
+
+// OriginMessageID returns the MessageId of the origin message, which is its own MessageId for the origin
+func OriginMessageID(m *types.Message) string {
+	if attr, ok := m.MessageAttributes[OriginMessageIDAttribute]; ok && aws.ToString(attr.StringValue) != "" {
+		return aws.ToString(attr.StringValue)
+	}
+	return aws.ToString(m.MessageId)
+}
+
+// HopCount returns the number of the hops of the message, see HopCount
+func (m *Message) HopCount() int {
+	return HopCount(m.Message)
+}
+
+// OriginMessageID returns the MessageId of the origin message, see OriginMessageID
+func (m *Message) OriginMessageID() string {
+	return OriginMessageID(m.Message)
+}
+
+// setLineage sets the lineage attributes of the message sent from m: the HopCountAttribute incremented for the republish
+// (kept for the postponed message, which is not a hop) and the OriginMessageIDAttribute
+func setLineage(attrs map[string]types.MessageAttributeValue, m *types.Message, republish bool) {
+	hops := HopCount(m)
+	if republish {
+		hops++
+	}
+	attrs[HopCountAttribute] = types.MessageAttributeValue{
+		DataType:    aws.String("Number"),
+		StringValue: aws.String(strconv.Itoa(hops)),
+	}
+	if origin := OriginMessageID(m); origin != "" {
+		attrs[OriginMessageIDAttribute] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(origin)}
+	}
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineage(t *testing.T) {
+	origin := &types.Message{MessageId: aws.String("origin")}
+	assert.Equal(t, 0, HopCount(origin))
+	assert.Equal(t, "origin", OriginMessageID(origin))
+
+	attrs := map[string]types.MessageAttributeValue{}
+	setLineage(attrs, origin, true)
+	hop := &types.Message{MessageId: aws.String("hop-1"), MessageAttributes: attrs}
+	assert.Equal(t, 1, NewMessage(hop).HopCount())
+	assert.Equal(t, "origin", NewMessage(hop).OriginMessageID())
+
+	attrs = map[string]types.MessageAttributeValue{}
+	setLineage(attrs, hop, true)
+	assert.Equal(t, 2, HopCount(&types.Message{MessageAttributes: attrs}))
+	assert.Equal(t, "origin", OriginMessageID(&types.Message{MessageAttributes: attrs}), "the origin is kept through the hops")
+
+	attrs = map[string]types.MessageAttributeValue{}
+	setLineage(attrs, hop, false)
+	assert.Equal(t, 1, HopCount(&types.Message{MessageAttributes: attrs}), "the postponed message is not a hop")
+
+	invalid := &types.Message{MessageAttributes: map[string]types.MessageAttributeValue{HopCountAttribute: {StringValue: aws.String("x")}}}
+	assert.Equal(t, 0, HopCount(invalid))
+}
@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// LockState is the state of the key returned by ProcessingLock.Acquire
+type LockState string
+
+const (
+	// LockAcquired means the key is locked for the handler
+	LockAcquired LockState = "acquired"
+	// LockInProgress means the key is locked by another attempt still processing, or by the one which crashed until the lock expires
+	LockInProgress LockState = "in_progress"
+	// LockDone means the key was processed and confirmed
+	LockDone LockState = "done"
+)
+
+// ProcessingLock interface is used to prevent duplicate processing of the same message.
+// The implementation is expected to be backed by a shared storage(e.g. dynamolock package).
+type ProcessingLock interface {
+	// Acquire locks the key before the handler, and returns the state of the key if it's locked or already processed
+	Acquire(ctx context.Context, key string) (LockState, error)
+	// Confirm marks the key as processed after the message is deleted
+	Confirm(ctx context.Context, key string) error
+	// Release unlocks the key when the handler failed, so that the message can be processed on redelivery
+	Release(ctx context.Context, key string) error
+}
+
+// lockKey returns MessageDeduplicationId for FIFO queues, otherwise MessageId
+func lockKey(m *types.Message) string {
+	if id, ok := m.Attributes[string(types.MessageSystemAttributeNameMessageDeduplicationId)]; ok && id != "" {
+		return id
+	}
+	return aws.ToString(m.MessageId)
+}
+
+// processWithLock processes the message only when the processing lock is acquired.
+// The message is deleted as the duplicate only when the key is confirmed done, and it's left in the queue while the key is in progress,
+// so that it's redelivered after the lock of the crashed or the failed attempt expires.
+func (worker *Worker) processWithLock(ctx context.Context, m *types.Message, h Handler) (Outcome, error) {
+	lock := worker.Config.ProcessingLock
+	key := lockKey(m)
+	state, err := lock.Acquire(ctx, key)
+	if err != nil {
+		return OutcomeFailed, fmt.Errorf("worker: failed to acquire the processing lock, key=%s, err=%w", key, err)
+	}
+	switch state {
+	case LockInProgress:
+		worker.Log.Infof(ctx, "worker: Left the message locked by another attempt in the queue, key=%s", key)
+		return OutcomeLocked, nil
+	case LockDone:
+		worker.Log.Infof(ctx, "worker: Skip the duplicate message, key=%s", key)
+		if err := worker.deleteMessage(ctx, m); err != nil {
+			return OutcomeFailed, err
+		}
+		return OutcomeDuplicate, nil
+	case LockAcquired:
+	default:
+		return OutcomeFailed, fmt.Errorf("worker: unknown state of the processing lock, key=%s, state=%s", key, state)
+	}
+
+	outcome, err := worker.processMessage(ctx, m, h)
+	var deleteFailed *deleteFailedError
+	if errors.As(err, &deleteFailed) {
+		// the handler succeeded, so the lock is kept for the redelivery to be deleted as the duplicate
+		if cerr := lock.Confirm(withoutCancel{ctx}, key); cerr != nil {
+			worker.Log.Warnf(ctx, "worker: Failed to confirm the processing lock, key=%s, err=%+v", key, cerr)
+		}
+		return outcome, err
+	}
+	if err != nil || outcome == OutcomePaused || outcome == OutcomeLostOwnership {
+		// the lock is released even when the handler context was canceled
+		if rerr := lock.Release(withoutCancel{ctx}, key); rerr != nil {
+			worker.Log.Warnf(ctx, "worker: Failed to release the processing lock, key=%s, err=%+v", key, rerr)
+		}
+		return outcome, err
+	}
+	if err := lock.Confirm(ctx, key); err != nil {
+		worker.Log.Warnf(ctx, "worker: Failed to confirm the processing lock, key=%s, err=%+v", key, err)
+	}
+	return outcome, nil
+}
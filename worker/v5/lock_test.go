@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockedLock struct {
+	mock.Mock
+}
+
+func (l *mockedLock) Acquire(ctx context.Context, key string) (LockState, error) {
+	args := l.Called(key)
+	return args.Get(0).(LockState), args.Error(1)
+}
+
+func (l *mockedLock) Confirm(ctx context.Context, key string) error {
+	return l.Called(key).Error(0)
+}
+
+func (l *mockedLock) Release(ctx context.Context, key string) error {
+	return l.Called(key).Error(0)
+}
+
+func TestProcessWithLock(t *testing.T) {
+	m := &types.Message{MessageId: aws.String("message-id"), ReceiptHandle: aws.String("receipt")}
+
+	t.Run("the duplicate message is deleted without the handler", func(t *testing.T) {
+		client := &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}
+		client.On("DeleteMessage", mock.Anything).Return().Once()
+		lock := &mockedLock{}
+		lock.On("Acquire", "message-id").Return(LockDone, nil)
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", ProcessingLock: lock})
+
+		err := worker.handleMessage(context.Background(), m, HandlerFunc(func(msg *types.Message) error {
+			t.Fatal("the handler must not be called")
+			return nil
+		}))
+		assert.NoError(t, err)
+		client.AssertExpectations(t)
+	})
+
+	t.Run("the message locked by another attempt is left in the queue", func(t *testing.T) {
+		client := &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}
+		lock := &mockedLock{}
+		lock.On("Acquire", "message-id").Return(LockInProgress, nil)
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", ProcessingLock: lock})
+
+		outcome, err := worker.handle(context.Background(), m, HandlerFunc(func(msg *types.Message) error {
+			t.Fatal("the handler must not be called")
+			return nil
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeLocked, outcome)
+		client.AssertNotCalled(t, "DeleteMessage", mock.Anything)
+		assert.Zero(t, worker.Stats().Succeeded)
+	})
+
+	t.Run("the lock is confirmed after the delete", func(t *testing.T) {
+		client := &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}
+		client.On("DeleteMessage", mock.Anything).Return().Once()
+		lock := &mockedLock{}
+		lock.On("Acquire", "message-id").Return(LockAcquired, nil)
+		lock.On("Confirm", "message-id").Return(nil)
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", ProcessingLock: lock})
+
+		assert.NoError(t, worker.handleMessage(context.Background(), m, HandlerFunc(func(msg *types.Message) error { return nil })))
+		lock.AssertExpectations(t)
+		client.AssertExpectations(t)
+	})
+
+	t.Run("the lock is released on handler failure", func(t *testing.T) {
+		client := &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}
+		lock := &mockedLock{}
+		lock.On("Acquire", "message-id").Return(LockAcquired, nil)
+		lock.On("Release", "message-id").Return(nil)
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", ProcessingLock: lock})
+
+		assert.Error(t, worker.handleMessage(context.Background(), m, HandlerFunc(func(msg *types.Message) error { return errors.New("failure") })))
+		lock.AssertExpectations(t)
+		client.AssertNotCalled(t, "DeleteMessage", mock.Anything)
+	})
+
+	t.Run("the lock is confirmed when only the delete failed", func(t *testing.T) {
+		lock := &mockedLock{}
+		lock.On("Acquire", "message-id").Return(LockAcquired, nil)
+		lock.On("Confirm", "message-id").Return(nil)
+		worker := New(context.Background(), &failingDeleteSqsClient{}, &Config{QueueName: "my-sqs-queue", ProcessingLock: lock})
+
+		assert.Error(t, worker.handleMessage(context.Background(), m, HandlerFunc(func(msg *types.Message) error { return nil })))
+		lock.AssertExpectations(t)
+		lock.AssertNotCalled(t, "Release", "message-id")
+	})
+}
@@ -0,0 +1,159 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/ca-risken/common/pkg/logging"
+)
+
+// LogEvent is the class of the log emitted by the worker, whose level is configurable by Config.LogLevels
+type LogEvent string
+
+const (
+	// LogEventPolling was logged before each receive (default: Debug).
+	//
+	// Deprecated: the poll is logged once after the receive as LogEventReceived or LogEventEmptyReceive.
+	LogEventPolling LogEvent = "polling"
+	// LogEventEmptyReceive is logged when the receive returns no messages, with the wait duration (default: Trace)
+	LogEventEmptyReceive LogEvent = "empty_receive"
+	// LogEventReceived is logged once per poll returning messages, with the wait duration, the number of messages,
+	// the dispatch latency of the batch and the count of the outcomes (default: Info)
+	LogEventReceived LogEvent = "received"
+	// LogEventReceiveError is logged when the receive fails (default: Error)
+	LogEventReceiveError LogEvent = "receive_error"
+	// LogEventAccessDenied is logged when the receive is denied by the IAM, and with the AccessDeniedError stopping the worker (default: Error)
+	LogEventAccessDenied LogEvent = "access_denied"
+	// LogEventThrottled is logged when the receive is throttled by SQS and the polling cools down (default: Warn)
+	LogEventThrottled LogEvent = "throttled"
+	// LogEventHandlerError is logged when the message processing fails (default: Error)
+	LogEventHandlerError LogEvent = "handler_error"
+	// LogEventInvalidEvent is logged when the handler returns InvalidEventError (default: Error)
+	LogEventInvalidEvent LogEvent = "invalid_event"
+	// LogEventDeleted is logged when the message is deleted (default: Debug)
+	LogEventDeleted LogEvent = "deleted"
+	// LogEventDuplicateReceive is logged when the message received again within the DedupWindow is skipped (default: Warn)
+	LogEventDuplicateReceive LogEvent = "duplicate_receive"
+	// LogEventPaused is logged when the message of the paused route is returned to the queue (default: Debug)
+	LogEventPaused LogEvent = "paused"
+	// LogEventParked is logged when the message is moved to the ParkingLot (default: Warn)
+	LogEventParked LogEvent = "parked"
+	// LogEventLostOwnership is logged when the handler is canceled since the visibility of the message expired (default: Warn)
+	LogEventLostOwnership LogEvent = "lost_ownership"
+	// LogEventHopLimit is logged when the message exceeding the MaxHops is parked or dropped (default: Warn)
+	LogEventHopLimit LogEvent = "hop_limit"
+	// LogEventExpired is logged when the message older than the MaxMessageAge is skipped by the ExpiredMessagePolicy (default: Warn)
+	LogEventExpired LogEvent = "expired"
+	// LogEventCanceled is logged when the related messages are canceled by CancelRelated and when they are skipped (default: Info)
+	LogEventCanceled LogEvent = "canceled"
+	// LogEventHandlerRetry is logged when the handler is retried in process by the HandlerRetry (default: Info)
+	LogEventHandlerRetry LogEvent = "handler_retry"
+	// LogEventStarted is logged with the fully-resolved configuration when the polling starts (default: Info)
+	LogEventStarted LogEvent = "started"
+	// LogEventRedelivered is logged when the message is received with ApproximateReceiveCount > 1, with the reason (default: Debug)
+	LogEventRedelivered LogEvent = "redelivered"
+	// LogEventUnhealthy is logged when the polling is paused because the HealthProbe failed (default: Warn)
+	LogEventUnhealthy LogEvent = "unhealthy"
+	// LogEventSplitResent is logged when the failed events of the split message are re-sent by the SplitPerEvent (default: Warn)
+	LogEventSplitResent LogEvent = "split_resent"
+	// LogEventLeakedHandler is logged when the handlers are still running after the shutdown, past the LeakGracePeriod (default: Warn)
+	LogEventLeakedHandler LogEvent = "leaked_handler"
+	// LogEventHandlerPanic is logged with the stack when the panic of the handler is recovered by the PanicPolicy (default: Error)
+	LogEventHandlerPanic LogEvent = "handler_panic"
+	// LogEventPrefetchExtended is logged when the visibility of the queued messages is extended by the PrefetchVisibility (default: Debug)
+	LogEventPrefetchExtended LogEvent = "prefetch_extended"
+	// LogEventRequestBudget is logged when the monthly cost projected from the SQS requests exceeds the RequestBudget (default: Warn)
+	LogEventRequestBudget LogEvent = "request_budget"
+	// LogEventTapped is logged when the delete, the send or the visibility change of the message is skipped by the TapMode (default: Debug)
+	LogEventTapped LogEvent = "tapped"
+	// LogEventMirrorFailed is logged when the message failed to be sent to the mirror queue (default: Warn)
+	LogEventMirrorFailed LogEvent = "mirror_failed"
+)
+
+var defaultLogLevels = map[LogEvent]logging.Level{
+	LogEventPolling:          logging.DebugLevel,
+	LogEventEmptyReceive:     logging.TraceLevel,
+	LogEventReceived:         logging.InfoLevel,
+	LogEventReceiveError:     logging.ErrorLevel,
+	LogEventAccessDenied:     logging.ErrorLevel,
+	LogEventThrottled:        logging.WarnLevel,
+	LogEventHandlerError:     logging.ErrorLevel,
+	LogEventInvalidEvent:     logging.ErrorLevel,
+	LogEventDeleted:          logging.DebugLevel,
+	LogEventDuplicateReceive: logging.WarnLevel,
+	LogEventPaused:           logging.DebugLevel,
+	LogEventParked:           logging.WarnLevel,
+	LogEventLostOwnership:    logging.WarnLevel,
+	LogEventHopLimit:         logging.WarnLevel,
+	LogEventExpired:          logging.WarnLevel,
+	LogEventCanceled:         logging.InfoLevel,
+	LogEventHandlerRetry:     logging.InfoLevel,
+	LogEventStarted:          logging.InfoLevel,
+	LogEventRedelivered:      logging.DebugLevel,
+	LogEventUnhealthy:        logging.WarnLevel,
+	LogEventSplitResent:      logging.WarnLevel,
+	LogEventLeakedHandler:    logging.WarnLevel,
+	LogEventHandlerPanic:     logging.ErrorLevel,
+	LogEventPrefetchExtended: logging.DebugLevel,
+	LogEventRequestBudget:    logging.WarnLevel,
+	LogEventTapped:           logging.DebugLevel,
+	LogEventMirrorFailed:     logging.WarnLevel,
+}
+
+// logLevel returns the configured level of the event, the levels above Error are lowered to Error
+// since Fatal and Panic levels terminate the process.
+func (config *Config) logLevel(event LogEvent) logging.Level {
+	level, ok := config.LogLevels[event]
+	if !ok {
+		level = defaultLogLevels[event]
+	}
+	if level < logging.ErrorLevel {
+		return logging.ErrorLevel
+	}
+	return level
+}
+
+// levelEnabler is implemented by the loggers reporting whether the level is enabled, e.g. ZapLogger
+type levelEnabler interface {
+	Enabled(level logging.Level) bool
+}
+
+// logEnabled reports whether the event is emitted by the logger, so that the disabled events skip building the log
+func (worker *Worker) logEnabled(event LogEvent) bool {
+	level := worker.Config.logLevel(event)
+	switch l := worker.Log.(type) {
+	case *logging.AppLogger:
+		// the levels of logging are in the same order as logrus
+		return uint32(l.GetLevel()) >= uint32(level)
+	case levelEnabler:
+		return l.Enabled(level)
+	}
+	return true
+}
+
+func (worker *Worker) logEvent(ctx context.Context, event LogEvent, format string, args ...interface{}) {
+	worker.logEventWith(ctx, event, nil, format, args...)
+}
+
+// logEventWith logs the event with the extra fields, e.g. the AWS request ID of the failed call
+func (worker *Worker) logEventWith(ctx context.Context, event LogEvent, extra map[string]interface{}, format string, args ...interface{}) {
+	if !worker.logEnabled(event) {
+		return
+	}
+	ok, suppressed := worker.sampler.sample(event)
+	if !ok {
+		return
+	}
+	ctxFields := LogFieldsFromContext(ctx)
+	fields := make(map[string]interface{}, len(ctxFields)+len(extra)+2)
+	for k, v := range ctxFields {
+		fields[k] = v
+	}
+	for k, v := range extra {
+		fields[k] = v
+	}
+	fields["event"] = string(event)
+	if suppressed > 0 {
+		fields["suppressed"] = suppressed
+	}
+	worker.Log.WithItemsf(ctx, worker.Config.logLevel(event), fields, format, args...)
+}
@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/common/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLogLevels(t *testing.T) {
+	client := &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}
+	client.On("DeleteMessage", mock.Anything).Return()
+	worker := New(context.Background(), client, &Config{
+		QueueName: "my-sqs-queue",
+		LogLevels: map[LogEvent]logging.Level{
+			LogEventInvalidEvent: logging.InfoLevel,
+			LogEventReceived:     logging.PanicLevel,
+		},
+	})
+	var buf bytes.Buffer
+	worker.Log.Output(&buf)
+	worker.Log.Level(logging.TraceLevel)
+
+	messages := []types.Message{{Body: aws.String("body")}}
+	outcomes := worker.run(context.Background(), HandlerFunc(func(msg *types.Message) error {
+		return NewInvalidEventError("event", "invalid")
+	}), messages)
+	worker.logPoll(context.Background(), pollSummary{messages: len(messages), outcomes: outcomes})
+
+	levels := map[string]string{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var line map[string]interface{}
+		assert.NoError(t, dec.Decode(&line))
+		if event, ok := line["event"].(string); ok {
+			levels[event] = line["level"].(string)
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"received":      "error",
+		"invalid_event": "info",
+		"deleted":       "debug",
+	}, levels)
+}
@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Message wraps the received message with the typed accessors of the attributes
+// The system attributes are received only when they are in Config.MessageSystemAttributeNames.
+type Message struct {
+	*types.Message
+}
+
+// NewMessage wraps the message
+func NewMessage(m *types.Message) *Message {
+	return &Message{Message: m}
+}
+
+// MessageHandlerFunc is used to define the Handler receiving the wrapped message
+type MessageHandlerFunc func(ctx context.Context, msg *Message) error
+
+// HandleMessage wraps a function for handling the wrapped message with the background context
+func (f MessageHandlerFunc) HandleMessage(msg *types.Message) error {
+	return f(context.Background(), NewMessage(msg))
+}
+
+// HandleMessageWithContext wraps a function for handling the wrapped message with the context
+func (f MessageHandlerFunc) HandleMessageWithContext(ctx context.Context, msg *types.Message) error {
+	return f(ctx, NewMessage(msg))
+}
+
+// BodyBytes returns the body of the message, or nil when the body is empty
+func (m *Message) BodyBytes() []byte {
+	if m.Body == nil {
+		return nil
+	}
+	return []byte(*m.Body)
+}
+
+// Attr returns the string value of the message attribute, or empty when the attribute is missing or binary
+func (m *Message) Attr(name string) string {
+	if v, ok := m.MessageAttributes[name]; ok && v.StringValue != nil {
+		return *v.StringValue
+	}
+	return ""
+}
+
+// SystemAttribute returns the system attribute of the message, or empty when it was not received
+func (m *Message) SystemAttribute(name types.MessageSystemAttributeName) string {
+	return m.Attributes[string(name)]
+}
+
+// AWSTraceHeader returns the X-Ray trace header of the message, or empty when it was not received
+func (m *Message) AWSTraceHeader() string {
+	return m.SystemAttribute(types.MessageSystemAttributeNameAWSTraceHeader)
+}
+
+// ReceiveCount returns the ApproximateReceiveCount of the message, or 0 when it was not received
+func (m *Message) ReceiveCount() int {
+	n, err := strconv.Atoi(m.SystemAttribute(types.MessageSystemAttributeNameApproximateReceiveCount))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// SentAt returns the time when the message was sent to the queue, or the zero time when it was not received
+func (m *Message) SentAt() time.Time {
+	ms, err := strconv.ParseInt(m.SystemAttribute(types.MessageSystemAttributeNameSentTimestamp), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// systemAttributeNames converts the names to the deprecated AttributeNames of the ReceiveMessageInput
+func systemAttributeNames(names []types.MessageSystemAttributeName) []types.QueueAttributeName {
+	attributeNames := make([]types.QueueAttributeName, len(names))
+	for i, name := range names {
+		attributeNames[i] = types.QueueAttributeName(name)
+	}
+	return attributeNames
+}
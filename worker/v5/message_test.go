@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageSystemAttributes(t *testing.T) {
+	m := NewMessage(&types.Message{Attributes: map[string]string{
+		"AWSTraceHeader": "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1",
+		"SentTimestamp":  "1545082649183",
+	}})
+	m.MessageAttributes = map[string]types.MessageAttributeValue{
+		"type":   {DataType: aws.String("String"), StringValue: aws.String("scan")},
+		"binary": {DataType: aws.String("Binary"), BinaryValue: []byte("x")},
+	}
+	assert.Equal(t, "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1", m.AWSTraceHeader())
+	assert.True(t, time.Unix(1545082649, 183000000).Equal(m.SentAt()))
+
+	assert.Equal(t, "scan", m.Attr("type"))
+	assert.Empty(t, m.Attr("binary"))
+	assert.Empty(t, m.Attr("missing"))
+
+	empty := NewMessage(&types.Message{})
+	assert.Empty(t, empty.AWSTraceHeader())
+	assert.True(t, empty.SentAt().IsZero())
+	assert.Zero(t, empty.ReceiveCount())
+	assert.Nil(t, empty.BodyBytes())
+}
+
+func TestMessageHandlerFunc(t *testing.T) {
+	var received *Message
+	h := MessageHandlerFunc(func(ctx context.Context, msg *Message) error {
+		received = msg
+		return nil
+	})
+	err := callHandler(context.Background(), h, &types.Message{
+		Body:       aws.String(`{"id":1}`),
+		Attributes: map[string]string{"ApproximateReceiveCount": "3"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, received.ReceiveCount())
+	assert.Equal(t, []byte(`{"id":1}`), received.BodyBytes())
+}
+
+func TestMessageSystemAttributeNames(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+	assert.Equal(t, []types.QueueAttributeName{"All"}, worker.receiveParams().AttributeNames)
+
+	worker = New(context.Background(), &nopSqsClient{}, &Config{
+		QueueName:                   "my-sqs-queue",
+		MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameAWSTraceHeader, types.MessageSystemAttributeNameSentTimestamp},
+	})
+	assert.Equal(t, []types.QueueAttributeName{"AWSTraceHeader", "SentTimestamp"}, worker.receiveParams().AttributeNames)
+}
@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// MirrorTiming is when the received message is mirrored
+type MirrorTiming string
+
+const (
+	// MirrorBeforeProcessing mirrors the message when it's dispatched to the handler (default)
+	MirrorBeforeProcessing MirrorTiming = "before"
+	// MirrorAfterProcessing mirrors the message after it's processed, whatever the outcome
+	MirrorAfterProcessing MirrorTiming = "after"
+)
+
+// Mirror re-publishes every received message to the mirror queue, e.g. for the shadow environment.
+// The message is sent in its own goroutine without the cancellation of the handler context,
+// and the failure is only logged and counted, so that the mirroring never affects the processing of the primary queue.
+// The duplicate receives skipped by the worker aren't mirrored.
+type Mirror struct {
+	// QueueURL is the URL of the mirror queue, the SqsClient must implement SenderAPI
+	QueueURL string
+	// Timing is when the message is mirrored (default: MirrorBeforeProcessing)
+	Timing MirrorTiming
+	// Timeout is the timeout of the send to the mirror queue (default: 5 seconds)
+	Timeout time.Duration
+}
+
+func (m *Mirror) populateDefaultValues() {
+	if m.Timing == "" {
+		m.Timing = MirrorBeforeProcessing
+	}
+	if m.Timeout <= 0 {
+		m.Timeout = 5 * time.Second
+	}
+}
+
+// mirror sends the copy of the message to the mirror queue in background
+func (worker *Worker) mirror(ctx context.Context, m *types.Message) {
+	config := worker.Config.Mirror
+	client, ok := worker.SqsClient.(SenderAPI)
+	if !ok {
+		worker.stats.addMirrorFailed()
+		worker.logEvent(ctx, LogEventMirrorFailed, "worker: Failed to mirror the message, id=%s, err=%+v", aws.ToString(m.MessageId), errSendNotSupported)
+		return
+	}
+	params := &sqs.SendMessageInput{
+		QueueUrl:          aws.String(config.QueueURL),
+		MessageBody:       m.Body,
+		MessageAttributes: m.MessageAttributes,
+	}
+	setFIFOIDs(worker.Config.FIFOIDs, m, params)
+	ctx = withoutCancel{ctx}
+	worker.goOwned(func() {
+		sendCtx, cancel := callContext(ctx, config.Timeout)
+		defer cancel()
+		sendCtx, finishSpan := worker.startClientSpan(sendCtx, "SendMessage")
+		_, err := client.SendMessage(sendCtx, params, worker.Config.sqsOptions()...)
+		finishSpan(err)
+		if err != nil {
+			worker.stats.addMirrorFailed()
+			worker.logEvent(ctx, LogEventMirrorFailed, "worker: Failed to mirror the message, id=%s, queue_url=%s, err=%+v", aws.ToString(m.MessageId), config.QueueURL, err)
+			return
+		}
+		worker.stats.addMirrored()
+	})
+}
@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// mirrorSqsClient records the sends, failing them with the err
+type mirrorSqsClient struct {
+	countingDeleteSqsClient
+	err error
+
+	mu     sync.Mutex
+	events []string
+	sent   []*sqs.SendMessageInput
+}
+
+func (c *mirrorSqsClient) record(event string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, event)
+}
+
+func (c *mirrorSqsClient) SendMessage(ctx context.Context, input *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, "mirrored")
+	c.sent = append(c.sent, input)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &sqs.SendMessageOutput{MessageId: aws.String("mirrored-message-id")}, nil
+}
+
+func TestMirror(t *testing.T) {
+	const mirrorURL = "https://sqs.eu-west-1.amazonaws.com/123456789012/my-sqs-queue-shadow"
+	m := func() *types.Message {
+		return &types.Message{MessageId: aws.String("m"), ReceiptHandle: aws.String("m"), Body: aws.String("body"),
+			MessageAttributes: map[string]types.MessageAttributeValue{"scan_id": {DataType: aws.String("String"), StringValue: aws.String("1")}}}
+	}
+
+	t.Run("before processing", func(t *testing.T) {
+		client := &mirrorSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Mirror: &Mirror{QueueURL: mirrorURL}})
+		outcome, err := worker.handle(context.Background(), m(), HandlerFunc(func(msg *types.Message) error {
+			// the mirroring runs in its own goroutine, so wait for it to keep the order deterministic
+			assert.Eventually(t, func() bool { return worker.Stats().Mirrored == 1 }, time.Second, time.Millisecond)
+			client.record("handled")
+			return nil
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeSucceeded, outcome)
+		assert.Equal(t, []string{"mirrored", "handled"}, client.events)
+		if assert.Len(t, client.sent, 1) {
+			assert.Equal(t, mirrorURL, aws.ToString(client.sent[0].QueueUrl))
+			assert.Equal(t, "body", aws.ToString(client.sent[0].MessageBody))
+			assert.Equal(t, "1", aws.ToString(client.sent[0].MessageAttributes["scan_id"].StringValue))
+		}
+	})
+
+	t.Run("after processing", func(t *testing.T) {
+		client := &mirrorSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Mirror: &Mirror{QueueURL: mirrorURL, Timing: MirrorAfterProcessing}})
+		_, _ = worker.handle(context.Background(), m(), HandlerFunc(func(msg *types.Message) error {
+			client.record("handled")
+			return errors.New("failed")
+		}))
+		assert.Eventually(t, func() bool { return worker.Stats().Mirrored == 1 }, time.Second, time.Millisecond, "the failed message is mirrored too")
+		assert.Equal(t, []string{"handled", "mirrored"}, client.events)
+	})
+
+	t.Run("failure isolated", func(t *testing.T) {
+		client := &mirrorSqsClient{err: errors.New("access denied")}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Mirror: &Mirror{QueueURL: mirrorURL}})
+		outcome, err := worker.handle(context.Background(), m(), HandlerFunc(func(msg *types.Message) error { return nil }))
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeSucceeded, outcome, "the failure of the mirroring doesn't affect the primary processing")
+		assert.Eventually(t, func() bool { return worker.Stats().MirrorFailed == 1 }, time.Second, time.Millisecond)
+		assert.Equal(t, int64(1), client.deleted)
+	})
+
+	t.Run("fifo", func(t *testing.T) {
+		client := &mirrorSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Mirror: &Mirror{QueueURL: mirrorURL + ".fifo"}})
+		msg := m()
+		msg.Attributes = map[string]string{string(types.MessageSystemAttributeNameMessageGroupId): "group"}
+		_, _ = worker.handle(context.Background(), msg, HandlerFunc(func(msg *types.Message) error { return nil }))
+		assert.Eventually(t, func() bool { return worker.Stats().Mirrored == 1 }, time.Second, time.Millisecond)
+		assert.Equal(t, "group", aws.ToString(client.sent[0].MessageGroupId))
+		assert.NotEmpty(t, aws.ToString(client.sent[0].MessageDeduplicationId))
+	})
+}
@@ -0,0 +1,313 @@
+// Package mocks provides the testify mocks of the worker interfaces for downstream tests.
+//
+//	client := &mocks.QueueAPI{}
+//	client.On("GetQueueUrl", mock.Anything, mock.Anything).Return(&sqs.GetQueueUrlOutput{QueueUrl: aws.String(url)}, nil)
+//	client.On("ReceiveMessage", mock.Anything, mock.Anything).Return(&sqs.ReceiveMessageOutput{}, nil)
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	_ worker.QueueAPI             = (*QueueAPI)(nil)
+	_ worker.QueueAttributesAPI   = (*QueueAPI)(nil)
+	_ worker.VisibilityChangerAPI = (*QueueAPI)(nil)
+	_ worker.SenderAPI            = (*QueueAPI)(nil)
+	_ worker.DeleteBatchAPI       = (*QueueAPI)(nil)
+	_ worker.QueueTagsAPI         = (*QueueAPI)(nil)
+	_ worker.DiscoveryAPI         = (*QueueAPI)(nil)
+	_ worker.ListQueuesAPI        = (*QueueAPI)(nil)
+	_ worker.VisibilityBatchAPI   = (*QueueAPI)(nil)
+	_ worker.ContextHandler       = (*Handler)(nil)
+	_ worker.ProcessingLock       = (*ProcessingLock)(nil)
+	_ worker.AuditSink            = (*AuditSink)(nil)
+	_ worker.ParkingLot           = (*ParkingLot)(nil)
+	_ worker.PayloadStore         = (*PayloadStore)(nil)
+	_ worker.FailureSink          = (*FailureSink)(nil)
+	_ worker.Transformer          = (*Transformer)(nil)
+	_ worker.Splitter             = (*Splitter)(nil)
+	_ worker.Membership           = (*Membership)(nil)
+	_ worker.Tx                   = (*Tx)(nil)
+	_ worker.Clock                = (*Clock)(nil)
+	_ worker.Timer                = (*Timer)(nil)
+	_ worker.Ticker               = (*Ticker)(nil)
+)
+
+// QueueAPI is the mock of worker.QueueAPI, it also implements the optional client interfaces.
+// The methods are called with (ctx, params), and return (output, error).
+type QueueAPI struct {
+	mock.Mock
+}
+
+// GetQueueUrl mocks the sqs API
+func (m *QueueAPI) GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	args := m.Called(ctx, params)
+	out, _ := args.Get(0).(*sqs.GetQueueUrlOutput)
+	return out, args.Error(1)
+}
+
+// ReceiveMessage mocks the sqs API
+func (m *QueueAPI) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	args := m.Called(ctx, params)
+	out, _ := args.Get(0).(*sqs.ReceiveMessageOutput)
+	return out, args.Error(1)
+}
+
+// DeleteMessage mocks the sqs API
+func (m *QueueAPI) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	args := m.Called(ctx, params)
+	out, _ := args.Get(0).(*sqs.DeleteMessageOutput)
+	return out, args.Error(1)
+}
+
+// GetQueueAttributes mocks the sqs API
+func (m *QueueAPI) GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	args := m.Called(ctx, params)
+	out, _ := args.Get(0).(*sqs.GetQueueAttributesOutput)
+	return out, args.Error(1)
+}
+
+// ChangeMessageVisibility mocks the sqs API
+func (m *QueueAPI) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	args := m.Called(ctx, params)
+	out, _ := args.Get(0).(*sqs.ChangeMessageVisibilityOutput)
+	return out, args.Error(1)
+}
+
+// SendMessage mocks the sqs API
+func (m *QueueAPI) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	args := m.Called(ctx, params)
+	out, _ := args.Get(0).(*sqs.SendMessageOutput)
+	return out, args.Error(1)
+}
+
+// DeleteMessageBatch mocks the sqs API
+func (m *QueueAPI) DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	args := m.Called(ctx, params)
+	out, _ := args.Get(0).(*sqs.DeleteMessageBatchOutput)
+	return out, args.Error(1)
+}
+
+// ListQueueTags mocks the sqs API
+func (m *QueueAPI) ListQueueTags(ctx context.Context, params *sqs.ListQueueTagsInput, optFns ...func(*sqs.Options)) (*sqs.ListQueueTagsOutput, error) {
+	args := m.Called(ctx, params)
+	out, _ := args.Get(0).(*sqs.ListQueueTagsOutput)
+	return out, args.Error(1)
+}
+
+// ListQueues mocks the sqs API
+func (m *QueueAPI) ListQueues(ctx context.Context, params *sqs.ListQueuesInput, optFns ...func(*sqs.Options)) (*sqs.ListQueuesOutput, error) {
+	args := m.Called(ctx, params)
+	out, _ := args.Get(0).(*sqs.ListQueuesOutput)
+	return out, args.Error(1)
+}
+
+// ChangeMessageVisibilityBatch mocks the sqs API
+func (m *QueueAPI) ChangeMessageVisibilityBatch(ctx context.Context, params *sqs.ChangeMessageVisibilityBatchInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	args := m.Called(ctx, params)
+	out, _ := args.Get(0).(*sqs.ChangeMessageVisibilityBatchOutput)
+	return out, args.Error(1)
+}
+
+// Handler is the mock of worker.Handler and worker.ContextHandler.
+// HandleMessage is recorded as HandleMessageWithContext with the background context.
+type Handler struct {
+	mock.Mock
+}
+
+// HandleMessage mocks the handler
+func (m *Handler) HandleMessage(msg *types.Message) error {
+	return m.HandleMessageWithContext(context.Background(), msg)
+}
+
+// HandleMessageWithContext mocks the handler
+func (m *Handler) HandleMessageWithContext(ctx context.Context, msg *types.Message) error {
+	return m.Called(ctx, msg).Error(0)
+}
+
+// ProcessingLock is the mock of worker.ProcessingLock
+type ProcessingLock struct {
+	mock.Mock
+}
+
+// Acquire mocks the lock
+func (m *ProcessingLock) Acquire(ctx context.Context, key string) (worker.LockState, error) {
+	args := m.Called(ctx, key)
+	state, _ := args.Get(0).(worker.LockState)
+	return state, args.Error(1)
+}
+
+// Confirm mocks the lock
+func (m *ProcessingLock) Confirm(ctx context.Context, key string) error {
+	return m.Called(ctx, key).Error(0)
+}
+
+// Release mocks the lock
+func (m *ProcessingLock) Release(ctx context.Context, key string) error {
+	return m.Called(ctx, key).Error(0)
+}
+
+// AuditSink is the mock of worker.AuditSink
+type AuditSink struct {
+	mock.Mock
+}
+
+// Record mocks the sink
+func (m *AuditSink) Record(ctx context.Context, record *worker.AuditRecord) error {
+	return m.Called(ctx, record).Error(0)
+}
+
+// ParkingLot is the mock of worker.ParkingLot
+type ParkingLot struct {
+	mock.Mock
+}
+
+// Park mocks the parking lot
+func (m *ParkingLot) Park(ctx context.Context, parked *worker.ParkedMessage) error {
+	return m.Called(ctx, parked).Error(0)
+}
+
+// PayloadStore is the mock of worker.PayloadStore, Put returns (bucket, key, error)
+type PayloadStore struct {
+	mock.Mock
+}
+
+// Put mocks the store
+func (m *PayloadStore) Put(ctx context.Context, body string) (string, string, error) {
+	args := m.Called(ctx, body)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+// FailureSink is the mock of worker.FailureSink
+type FailureSink struct {
+	mock.Mock
+}
+
+// Store mocks the sink
+func (m *FailureSink) Store(ctx context.Context, payload *worker.FailedPayload) error {
+	return m.Called(ctx, payload).Error(0)
+}
+
+// Transformer is the mock of worker.Transformer
+type Transformer struct {
+	mock.Mock
+}
+
+// Transform mocks the transformer
+func (m *Transformer) Transform(ctx context.Context, msg *types.Message) error {
+	return m.Called(ctx, msg).Error(0)
+}
+
+// Splitter is the mock of worker.Splitter
+type Splitter struct {
+	mock.Mock
+}
+
+// Split mocks the splitter
+func (m *Splitter) Split(body string) ([]string, error) {
+	args := m.Called(body)
+	events, _ := args.Get(0).([]string)
+	return events, args.Error(1)
+}
+
+// Join mocks the splitter
+func (m *Splitter) Join(events []string) (string, error) {
+	args := m.Called(events)
+	return args.String(0), args.Error(1)
+}
+
+// Membership is the mock of worker.Membership
+type Membership struct {
+	mock.Mock
+}
+
+// Members mocks the membership
+func (m *Membership) Members(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	members, _ := args.Get(0).([]string)
+	return members, args.Error(1)
+}
+
+// Tx is the mock of worker.Tx
+type Tx struct {
+	mock.Mock
+}
+
+// Commit mocks the transaction
+func (m *Tx) Commit() error {
+	return m.Called().Error(0)
+}
+
+// Rollback mocks the transaction
+func (m *Tx) Rollback() error {
+	return m.Called().Error(0)
+}
+
+// Clock is the mock of worker.Clock, the NewTimer, AfterFunc and NewTicker return the Timer and the Ticker set by Return.
+// AfterFunc is recorded with (d, f), so the Run of the call can invoke the function.
+type Clock struct {
+	mock.Mock
+}
+
+// Now mocks the clock
+func (m *Clock) Now() time.Time {
+	now, _ := m.Called().Get(0).(time.Time)
+	return now
+}
+
+// NewTimer mocks the clock
+func (m *Clock) NewTimer(d time.Duration) worker.Timer {
+	timer, _ := m.Called(d).Get(0).(worker.Timer)
+	return timer
+}
+
+// AfterFunc mocks the clock
+func (m *Clock) AfterFunc(d time.Duration, f func()) worker.Timer {
+	timer, _ := m.Called(d, f).Get(0).(worker.Timer)
+	return timer
+}
+
+// NewTicker mocks the clock
+func (m *Clock) NewTicker(d time.Duration) worker.Ticker {
+	ticker, _ := m.Called(d).Get(0).(worker.Ticker)
+	return ticker
+}
+
+// Timer is the mock of worker.Timer
+type Timer struct {
+	mock.Mock
+}
+
+// C mocks the timer
+func (m *Timer) C() <-chan time.Time {
+	c, _ := m.Called().Get(0).(<-chan time.Time)
+	return c
+}
+
+// Stop mocks the timer
+func (m *Timer) Stop() bool {
+	return m.Called().Bool(0)
+}
+
+// Ticker is the mock of worker.Ticker
+type Ticker struct {
+	mock.Mock
+}
+
+// C mocks the ticker
+func (m *Ticker) C() <-chan time.Time {
+	c, _ := m.Called().Get(0).(<-chan time.Time)
+	return c
+}
+
+// Stop mocks the ticker
+func (m *Ticker) Stop() {
+	m.Called()
+}
@@ -0,0 +1,41 @@
+package mocks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMocksWithWorker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msg := types.Message{MessageId: aws.String("id"), ReceiptHandle: aws.String("receipt")}
+
+	client := &QueueAPI{}
+	client.On("GetQueueUrl", mock.Anything, mock.Anything).Return(&sqs.GetQueueUrlOutput{QueueUrl: aws.String("https://queue")}, nil)
+	client.On("GetQueueAttributes", mock.Anything, mock.Anything).Return(&sqs.GetQueueAttributesOutput{}, nil)
+	client.On("ReceiveMessage", mock.Anything, mock.Anything).Return(&sqs.ReceiveMessageOutput{Messages: []types.Message{msg}}, nil).Once()
+	client.On("ReceiveMessage", mock.Anything, mock.Anything).Return(&sqs.ReceiveMessageOutput{}, nil)
+	client.On("DeleteMessage", mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil).Run(func(mock.Arguments) { cancel() })
+	handler := &Handler{}
+	handler.On("HandleMessageWithContext", mock.Anything, mock.Anything).Return(nil).Once()
+	transformer := &Transformer{}
+	transformer.On("Transform", mock.Anything, mock.Anything).Return(nil).Once()
+	audit := &AuditSink{}
+	audit.On("Record", mock.Anything, mock.MatchedBy(func(r *worker.AuditRecord) bool { return r.Outcome == worker.OutcomeSucceeded })).Return(nil)
+
+	w := worker.New(ctx, client, &worker.Config{QueueName: "my-sqs-queue", AuditSink: audit, Transformers: []worker.TransformStage{{Name: "mock", Transformer: transformer}}})
+	w.Start(ctx, handler)
+
+	assert.Equal(t, "https://queue", w.Config.QueueURL)
+	client.AssertExpectations(t)
+	handler.AssertExpectations(t)
+	transformer.AssertExpectations(t)
+	audit.AssertExpectations(t)
+}
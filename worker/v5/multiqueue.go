@@ -0,0 +1,326 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/common/pkg/logging"
+)
+
+// MultiQueueWorker consumes several queues with a shared pool of handlers.
+// The messages are dispatched by the deficit round robin scheduler, so that a high-volume queue can't starve others,
+// or in the order of the receive by FairnessOldestFirst.
+type MultiQueueWorker struct {
+	Log logging.Logger
+	// Concurrency is the size of the shared handler pool
+	Concurrency int
+	// Fairness is the order of the dispatch across the queues (default: FairnessRoundRobin)
+	Fairness Fairness
+
+	lanes []*queueLane
+	ready chan struct{}
+	seq   uint64
+}
+
+// Fairness is the order of the dispatch of the MultiQueueWorker across the queues
+type Fairness string
+
+const (
+	// FairnessRoundRobin dispatches the messages by the deficit round robin weighted by the quantum of each queue
+	FairnessRoundRobin Fairness = "round_robin"
+	// FairnessOldestFirst dispatches the message received first regardless of the queue and the quantum,
+	// so that the messages of a slower poller aren't overtaken by the ones received later by the faster pollers
+	FairnessOldestFirst Fairness = "oldest_first"
+)
+
+type queueLane struct {
+	worker  *Worker
+	handler Handler
+	quantum int
+	deficit int
+	buf     chan *bufferedMessage
+	// head is the message taken from the buf by FairnessOldestFirst to be compared with the other lanes
+	head *bufferedMessage
+
+	dispatched int64
+}
+
+// bufferedMessage is the received message waiting for the dispatch, ordered by the receive
+type bufferedMessage struct {
+	m        *types.Message
+	received time.Time
+	seq      uint64
+}
+
+func (b *bufferedMessage) before(other *bufferedMessage) bool {
+	if b.received.Equal(other.received) {
+		return b.seq < other.seq
+	}
+	return b.received.Before(other.received)
+}
+
+// QueueStats is the statistics of a queue in the MultiQueueWorker
+type QueueStats struct {
+	Stats
+	// Quantum is the weight of the queue in the scheduler
+	Quantum int
+	// Dispatched is the number of messages dispatched to the handler pool
+	Dispatched int64
+	// Buffered is the number of received messages waiting for the dispatch
+	Buffered int
+}
+
+// NewMultiQueueWorker creates MultiQueueWorker struct with the shared pool size (default: 10)
+func NewMultiQueueWorker(concurrency int) *MultiQueueWorker {
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+	return &MultiQueueWorker{
+		Log:         logging.NewLogger(),
+		Concurrency: concurrency,
+		ready:       make(chan struct{}, 1),
+	}
+}
+
+// Add registers the worker of a queue with the quantum(messages per round, default: 1), it must be called before Start
+func (mq *MultiQueueWorker) Add(worker *Worker, h Handler, quantum int) {
+	if quantum <= 0 {
+		quantum = 1
+	}
+	mq.lanes = append(mq.lanes, &queueLane{
+		worker:  worker,
+		handler: h,
+		quantum: quantum,
+		buf:     make(chan *bufferedMessage, 2*int(worker.Config.MaxNumberOfMessage)),
+	})
+}
+
+// Start starts polling all queues and dispatching messages till the context is done.
+// The received messages not yet dispatched on stop are released by resetting the visibility.
+// The handlers run under the context detached from ctx, and the in-flight ones are drained up to the DrainTimeout of their queue
+// as the Worker does on shutdown, so that the finished work is deleted instead of redelivered.
+func (mq *MultiQueueWorker) Start(ctx context.Context) {
+	handlerCtx, stopHandlers := handlerContext(ctx)
+	defer stopHandlers()
+	var pollers sync.WaitGroup
+	for _, lane := range mq.lanes {
+		pollers.Add(1)
+		go func(lane *queueLane) {
+			defer pollers.Done()
+			mq.poll(ctx, lane)
+		}(lane)
+	}
+	mq.schedule(ctx, handlerCtx)
+	pollers.Wait()
+	mq.releaseBuffered()
+	drainCtx := withoutCancel{ctx}
+	mq.drain(drainCtx)
+	stopHandlers()
+	for _, lane := range mq.lanes {
+		lane.worker.detectLeaks(drainCtx)
+	}
+}
+
+// drain waits for the in-flight handlers of each queue up to its DrainTimeout,
+// and resets the visibility of the unfinished messages unless KeepVisibilityOnShutdown
+func (mq *MultiQueueWorker) drain(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, lane := range mq.lanes {
+		wg.Add(1)
+		go func(worker *Worker) {
+			defer wg.Done()
+			if worker.Config.KeepVisibilityOnShutdown {
+				worker.inflight.wait(worker.clock(), worker.Config.DrainTimeout)
+				return
+			}
+			worker.drain(ctx, worker.Config.DrainTimeout, 0)
+		}(lane.worker)
+	}
+	wg.Wait()
+}
+
+// poll receives messages into the buffer of the lane, it blocks while the buffer is full
+func (mq *MultiQueueWorker) poll(ctx context.Context, lane *queueLane) {
+	for ctx.Err() == nil {
+		receiveCtx, cancel := callContext(ctx, lane.worker.Config.receiveTimeout())
+		receiveCtx, finishSpan := lane.worker.startClientSpan(receiveCtx, "ReceiveMessage")
+		resp, err := lane.worker.SqsClient.ReceiveMessage(receiveCtx, lane.worker.receiveParams(), lane.worker.Config.sqsOptions()...)
+		finishSpan(err)
+		cancel()
+		if err != nil && IsThrottled(err) {
+			lane.worker.stats.addThrottled()
+			lane.worker.logEventWith(ctx, LogEventThrottled, requestFields(err), "worker: Receive was throttled, queue=%s, cooling down for %s, err=%+v",
+				lane.worker.Config.QueueName, lane.worker.Config.ThrottleCooldown, err)
+			lane.worker.cooldown(ctx)
+			continue
+		}
+		if err != nil && IsQueueMissing(err) {
+			lane.worker.logEventWith(ctx, LogEventReceiveError, requestFields(err), "worker: The queue does not exist, retrying in %s, queue=%s, err=%+v",
+				lane.worker.Config.ThrottleCooldown, lane.worker.Config.QueueName, err)
+			lane.worker.cooldown(ctx)
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				lane.worker.logEventWith(ctx, LogEventReceiveError, requestFields(err), "worker: Failed to receive messages, queue=%s, err=%+v", lane.worker.Config.QueueName, err)
+			}
+			continue
+		}
+		lane.worker.stats.addReceived(len(resp.Messages))
+		received := lane.worker.now()
+		for i := range resp.Messages {
+			b := &bufferedMessage{m: &resp.Messages[i], received: received, seq: atomic.AddUint64(&mq.seq, 1)}
+			select {
+			case lane.buf <- b:
+				mq.notify()
+			case <-ctx.Done():
+				lane.release(context.Background(), &resp.Messages[i])
+			}
+		}
+	}
+}
+
+func (mq *MultiQueueWorker) notify() {
+	select {
+	case mq.ready <- struct{}{}:
+	default:
+	}
+}
+
+// schedule dispatches the buffered messages by the Fairness till the context is done, and the handlers run under the handlerCtx
+func (mq *MultiQueueWorker) schedule(ctx, handlerCtx context.Context) {
+	pool := make(chan struct{}, mq.Concurrency)
+	if mq.Fairness == FairnessOldestFirst {
+		mq.scheduleOldestFirst(ctx, handlerCtx, pool)
+		return
+	}
+	for {
+		dispatched := false
+		for _, lane := range mq.lanes {
+			lane.deficit += lane.quantum
+			for lane.deficit > 0 {
+				var b *bufferedMessage
+				select {
+				case b = <-lane.buf:
+				default:
+				}
+				if b == nil {
+					break
+				}
+				select {
+				case pool <- struct{}{}:
+				case <-ctx.Done():
+					lane.release(context.Background(), b.m)
+					return
+				}
+				lane.deficit--
+				dispatched = true
+				mq.dispatch(handlerCtx, pool, lane, b)
+			}
+			if len(lane.buf) == 0 {
+				// the idle lane doesn't accumulate the deficit
+				lane.deficit = 0
+			}
+		}
+		if dispatched {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-mq.ready:
+		}
+	}
+}
+
+// scheduleOldestFirst dispatches the buffered message received first across the lanes till the context is done.
+// The lanes are merged by their heads, as the buffer of each lane is in the order of the receive.
+func (mq *MultiQueueWorker) scheduleOldestFirst(ctx, handlerCtx context.Context, pool chan struct{}) {
+	for {
+		select {
+		case pool <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		// the oldest is chosen after the slot is acquired, so that the messages received meanwhile are compared too
+		var oldest *queueLane
+		for {
+			for _, lane := range mq.lanes {
+				if lane.head == nil {
+					select {
+					case lane.head = <-lane.buf:
+					default:
+					}
+				}
+				if lane.head != nil && (oldest == nil || lane.head.before(oldest.head)) {
+					oldest = lane
+				}
+			}
+			if oldest != nil {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				<-pool
+				return
+			case <-mq.ready:
+			}
+		}
+		b := oldest.head
+		oldest.head = nil
+		mq.dispatch(handlerCtx, pool, oldest, b)
+	}
+}
+
+// dispatch handles the message in its own goroutine, which frees the slot of the pool acquired by the caller.
+// The message is tracked as in-flight of the lane worker before the goroutine starts, so that the drain waits for it,
+// and it's handled with the time of the receive, which the visibility timeout and the deadline start from.
+func (mq *MultiQueueWorker) dispatch(ctx context.Context, pool chan struct{}, lane *queueLane, b *bufferedMessage) {
+	m := b.m
+	ctx = withReceivedAt(ctx, b.received)
+	atomic.AddInt64(&lane.dispatched, 1)
+	lane.worker.inflight.add(m)
+	lane.worker.goOwned(func() {
+		defer func() {
+			<-pool
+			lane.worker.inflight.remove(m)
+		}()
+		_, _ = lane.worker.handleInflight(ctx, m, lane.handler)
+	})
+}
+
+func (mq *MultiQueueWorker) releaseBuffered() {
+	for _, lane := range mq.lanes {
+		if lane.head != nil {
+			lane.release(context.Background(), lane.head.m)
+			lane.head = nil
+		}
+		for len(lane.buf) > 0 {
+			lane.release(context.Background(), (<-lane.buf).m)
+		}
+	}
+}
+
+// release resets the visibility of the undispatched message so that other consumers can receive it promptly
+func (lane *queueLane) release(ctx context.Context, m *types.Message) {
+	if err := lane.worker.changeVisibility(ctx, m, 0); err != nil {
+		lane.worker.Log.Warnf(ctx, "worker: Failed to release the buffered message, queue=%s, err=%+v", lane.worker.Config.QueueName, err)
+	}
+}
+
+// Stats returns the statistics per queue
+func (mq *MultiQueueWorker) Stats() []QueueStats {
+	stats := make([]QueueStats, 0, len(mq.lanes))
+	for _, lane := range mq.lanes {
+		stats = append(stats, QueueStats{
+			Stats:      lane.worker.Stats(),
+			Quantum:    lane.quantum,
+			Dispatched: atomic.LoadInt64(&lane.dispatched),
+			Buffered:   len(lane.buf),
+		})
+	}
+	return stats
+}
@@ -0,0 +1,171 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiQueueSchedule(t *testing.T) {
+	mq := NewMultiQueueWorker(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var order []string
+	total := 0
+	record := HandlerFunc(func(msg *types.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, aws.ToString(msg.Body))
+		if len(order) == total {
+			cancel()
+		}
+		return nil
+	})
+	for _, q := range []struct {
+		name     string
+		quantum  int
+		messages int
+	}{
+		{name: "busy", quantum: 2, messages: 8},
+		{name: "quiet", quantum: 1, messages: 2},
+	} {
+		w := New(context.Background(), &nopSqsClient{}, &Config{QueueName: q.name, MaxNumberOfMessage: 10})
+		mq.Add(w, record, q.quantum)
+		lane := mq.lanes[len(mq.lanes)-1]
+		for i := 0; i < q.messages; i++ {
+			lane.buf <- &bufferedMessage{m: &types.Message{Body: aws.String(q.name)}}
+		}
+		total += q.messages
+	}
+
+	mq.schedule(ctx, ctx)
+	mq.drain(ctx)
+	assert.Equal(t, "busy,busy,quiet,busy,busy,quiet,busy,busy,busy,busy", strings.Join(order, ","),
+		"the quiet queue is not starved by the busy queue")
+	stats := mq.Stats()
+	assert.Equal(t, int64(8), stats[0].Dispatched)
+	assert.Equal(t, int64(2), stats[1].Dispatched)
+	assert.Equal(t, 0, stats[1].Buffered)
+}
+
+func TestMultiQueueOldestFirst(t *testing.T) {
+	mq := NewMultiQueueWorker(1)
+	mq.Fairness = FairnessOldestFirst
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var order []string
+	record := HandlerFunc(func(msg *types.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, aws.ToString(msg.Body))
+		if len(order) == 5 {
+			cancel()
+		}
+		return nil
+	})
+	start := time.Now()
+	for _, q := range []struct {
+		name     string
+		quantum  int
+		received []time.Duration
+	}{
+		{name: "fast", quantum: 10, received: []time.Duration{2, 3, 3}},
+		{name: "slow", quantum: 1, received: []time.Duration{1, 3}},
+	} {
+		w := New(context.Background(), &nopSqsClient{}, &Config{QueueName: q.name, MaxNumberOfMessage: 10})
+		mq.Add(w, record, q.quantum)
+		lane := mq.lanes[len(mq.lanes)-1]
+		for i, d := range q.received {
+			mq.seq++
+			lane.buf <- &bufferedMessage{
+				m:        &types.Message{Body: aws.String(fmt.Sprintf("%s%d", q.name, i))},
+				received: start.Add(d * time.Second),
+				seq:      mq.seq,
+			}
+		}
+	}
+
+	mq.schedule(ctx, ctx)
+	mq.drain(ctx)
+	assert.Equal(t, "slow0,fast0,fast1,fast2,slow1", strings.Join(order, ","),
+		"the messages are dispatched in the order of the receive regardless of the quantum")
+}
+
+// ctxRecordingSqsClient records the error of the context of each DeleteMessage
+type ctxRecordingSqsClient struct {
+	batchSqsClient
+	deleteErrs chan error
+}
+
+func (c *ctxRecordingSqsClient) DeleteMessage(ctx context.Context, input *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	c.deleteErrs <- ctx.Err()
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func TestMultiQueueDrain(t *testing.T) {
+	client := &ctxRecordingSqsClient{batchSqsClient: batchSqsClient{batches: make(chan []types.Message, 1)}, deleteErrs: make(chan error, 1)}
+	client.batches <- []types.Message{{MessageId: aws.String("m"), ReceiptHandle: aws.String("r")}}
+	mq := NewMultiQueueWorker(1)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var handlerErr error
+	mq.Add(New(context.Background(), client, &Config{QueueName: "my-sqs-queue", DrainTimeout: time.Second}), ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+		close(started)
+		<-release
+		handlerErr = ctx.Err()
+		return nil
+	}), 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mq.Start(ctx)
+	}()
+	<-started
+	cancel()
+	select {
+	case <-done:
+		t.Fatal("Start returned before the in-flight handler finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-done
+	assert.NoError(t, handlerErr, "the handler is not canceled with the poll context")
+	select {
+	case err := <-client.deleteErrs:
+		assert.NoError(t, err, "the message is deleted with the context detached from the poll context")
+	default:
+		t.Fatal("the message of the drained handler is not deleted")
+	}
+}
+
+func TestMultiQueueReceivedAt(t *testing.T) {
+	mq := NewMultiQueueWorker(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var handlerErr error
+	w := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", MessageDeadline: 200 * time.Millisecond})
+	mq.Add(w, ContextHandlerFunc(func(handlerCtx context.Context, msg *types.Message) error {
+		handlerErr = handlerCtx.Err()
+		cancel()
+		return nil
+	}), 1)
+	mq.lanes[0].buf <- &bufferedMessage{m: &types.Message{MessageId: aws.String("m"), ReceiptHandle: aws.String("r")}, received: time.Now().Add(-300 * time.Millisecond)}
+
+	mq.schedule(ctx, ctx)
+	mq.drain(ctx)
+	assert.ErrorIs(t, handlerErr, context.DeadlineExceeded, "the deadline counts from the receive, not from the dispatch")
+}
@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// maxMessageSize is the maximum size of the body and the attributes accepted by SQS
+	maxMessageSize = 256 * 1024
+	// maxMessageAttributes is the maximum number of the message attributes accepted by SQS
+	maxMessageAttributes = 10
+
+	// ExtendedPayloadSizeAttribute is the message attribute of the extended client storing the size of the offloaded body
+	ExtendedPayloadSizeAttribute = "ExtendedPayloadSize"
+	// payloadPointerClass is the class name of the S3 pointer in the extended client format
+	payloadPointerClass = "software.amazon.payloadoffloading.PayloadS3Pointer"
+)
+
+var (
+	// ErrMessageTooLarge is returned when the re-sent message exceeds the size limit of SQS and no PayloadStore is configured
+	ErrMessageTooLarge = errors.New("worker: the message exceeds the size limit of SQS")
+	// ErrTooManyAttributes is returned when the re-sent message has more message attributes than SQS accepts
+	ErrTooManyAttributes = errors.New("worker: the message has too many message attributes")
+)
+
+// PayloadStore interface stores the bodies of the oversized re-sent messages (e.g. payload package).
+// Put returns the bucket and the key of the stored body, which is sent as the S3 pointer of the extended client format
+// (amazon-sqs-java-extended-client-lib) so that the extended clients can consume the message.
+type PayloadStore interface {
+	Put(ctx context.Context, body string) (bucket, key string, err error)
+}
+
+type payloadPointer struct {
+	Bucket string `json:"s3BucketName"`
+	Key    string `json:"s3Key"`
+}
+
+// messageSize returns the size of the message counted by SQS: the body and the name, type and value of each attribute
+func messageSize(body string, attrs map[string]types.MessageAttributeValue) int {
+	size := len(body)
+	for name, v := range attrs {
+		size += len(name) + len(aws.ToString(v.DataType)) + len(aws.ToString(v.StringValue)) + len(v.BinaryValue)
+	}
+	return size
+}
+
+// fitMessage validates the limits of SQS on the re-sent message, and offloads the body to the PayloadStore if it's oversized
+func (worker *Worker) fitMessage(ctx context.Context, body string, attrs map[string]types.MessageAttributeValue) (string, map[string]types.MessageAttributeValue, error) {
+	if len(attrs) > maxMessageAttributes {
+		return "", nil, fmt.Errorf("%w(%d > %d)", ErrTooManyAttributes, len(attrs), maxMessageAttributes)
+	}
+	size := messageSize(body, attrs)
+	if size <= maxMessageSize {
+		return body, attrs, nil
+	}
+	if worker.Config.PayloadStore == nil {
+		return "", nil, fmt.Errorf("%w(%d bytes), configure the PayloadStore to offload it", ErrMessageTooLarge, size)
+	}
+	if _, ok := attrs[ExtendedPayloadSizeAttribute]; !ok && len(attrs) == maxMessageAttributes {
+		return "", nil, fmt.Errorf("%w(no room for %s)", ErrTooManyAttributes, ExtendedPayloadSizeAttribute)
+	}
+	bucket, key, err := worker.Config.PayloadStore.Put(ctx, body)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to offload the message body, err=%w", err)
+	}
+	pointer, err := json.Marshal([]interface{}{payloadPointerClass, payloadPointer{Bucket: bucket, Key: key}})
+	if err != nil {
+		return "", nil, err
+	}
+	attrs[ExtendedPayloadSizeAttribute] = types.MessageAttributeValue{
+		DataType:    aws.String("Number"),
+		StringValue: aws.String(strconv.Itoa(len(body))),
+	}
+	if size := messageSize(string(pointer), attrs); size > maxMessageSize {
+		return "", nil, fmt.Errorf("%w(%d bytes of the attributes)", ErrMessageTooLarge, size)
+	}
+	return string(pointer), attrs, nil
+}
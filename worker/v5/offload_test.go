@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type memoryPayloadStore struct {
+	bodies map[string]string
+}
+
+func (s *memoryPayloadStore) Put(ctx context.Context, body string) (string, string, error) {
+	key := fmt.Sprintf("key-%d", len(s.bodies))
+	s.bodies[key] = body
+	return "my-bucket", key, nil
+}
+
+func TestFitMessage(t *testing.T) {
+	large := strings.Repeat("x", maxMessageSize)
+	attrs := func(n int) map[string]types.MessageAttributeValue {
+		m := map[string]types.MessageAttributeValue{}
+		for i := 0; i < n; i++ {
+			m[fmt.Sprintf("attr-%d", i)] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("v")}
+		}
+		return m
+	}
+	ctx := context.Background()
+
+	worker := &Worker{Config: &Config{}}
+	body, _, err := worker.fitMessage(ctx, "small", attrs(1))
+	assert.NoError(t, err)
+	assert.Equal(t, "small", body)
+	_, _, err = worker.fitMessage(ctx, large, attrs(1))
+	assert.True(t, errors.Is(err, ErrMessageTooLarge), "the oversized message fails without the PayloadStore")
+	_, _, err = worker.fitMessage(ctx, "small", attrs(11))
+	assert.True(t, errors.Is(err, ErrTooManyAttributes))
+
+	store := &memoryPayloadStore{bodies: map[string]string{}}
+	worker = &Worker{Config: &Config{PayloadStore: store}}
+	body, offloaded, err := worker.fitMessage(ctx, large, attrs(1))
+	assert.NoError(t, err)
+	assert.Equal(t, `["software.amazon.payloadoffloading.PayloadS3Pointer",{"s3BucketName":"my-bucket","s3Key":"key-0"}]`, body)
+	assert.Equal(t, fmt.Sprint(maxMessageSize), aws.ToString(offloaded[ExtendedPayloadSizeAttribute].StringValue))
+	assert.Equal(t, large, store.bodies["key-0"])
+	_, _, err = worker.fitMessage(ctx, large, attrs(10))
+	assert.True(t, errors.Is(err, ErrTooManyAttributes), "no room for the size attribute")
+}
+
+func TestRequeueOversized(t *testing.T) {
+	client := &mockedSenderSqsClient{&mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+	store := &memoryPayloadStore{bodies: map[string]string{}}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", PayloadStore: store})
+	m := &types.Message{Body: aws.String(strings.Repeat("x", maxMessageSize)), ReceiptHandle: aws.String("receipt")}
+
+	client.On("SendMessage", mock.MatchedBy(func(input *sqs.SendMessageInput) bool {
+		return strings.Contains(aws.ToString(input.MessageBody), `"s3Key":"key-0"`) &&
+			input.MessageAttributes[RetryAttemptAttribute].StringValue != nil
+	})).Return().Once()
+	client.On("DeleteMessage", mock.Anything).Return().Once()
+
+	assert.NoError(t, worker.Requeue(context.Background(), m, 0))
+	client.AssertExpectations(t)
+}
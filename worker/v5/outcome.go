@@ -0,0 +1,33 @@
+package worker
+
+// Outcome is the result of processing a message
+type Outcome string
+
+const (
+	// OutcomeSucceeded means the message was handled and deleted
+	OutcomeSucceeded Outcome = "succeeded"
+	// OutcomeInvalid means the handler returned InvalidEventError and the message was deleted
+	OutcomeInvalid Outcome = "invalid"
+	// OutcomeFailed means the handler or the delete failed and the message was left in the queue
+	OutcomeFailed Outcome = "failed"
+	// OutcomeRetried means the message was re-sent to the retry queue
+	OutcomeRetried Outcome = "retried"
+	// OutcomeDuplicate means the message was skipped as a duplicate by the ProcessingLock
+	OutcomeDuplicate Outcome = "duplicate"
+	// OutcomeLocked means the message was left in the queue since its key is locked by another attempt in progress
+	OutcomeLocked Outcome = "locked"
+	// OutcomeDeadLettered means the message was sent to the dead-letter queue since the RetryBudget was exhausted
+	OutcomeDeadLettered Outcome = "dead_lettered"
+	// OutcomePaused means the message of the paused route was returned to the queue with the delay
+	OutcomePaused Outcome = "paused"
+	// OutcomeParked means the message was moved to the ParkingLot and deleted
+	OutcomeParked Outcome = "parked"
+	// OutcomeDropped means the message exceeding the MaxHops was deleted without the handler
+	OutcomeDropped Outcome = "dropped"
+	// OutcomeExpired means the message older than the MaxMessageAge was deleted without the handler
+	OutcomeExpired Outcome = "expired"
+	// OutcomeCanceled means the message was deleted since its correlation key was canceled by CancelRelated
+	OutcomeCanceled Outcome = "canceled"
+	// OutcomeLostOwnership means the visibility of the message expired during the handler and its context was canceled
+	OutcomeLostOwnership Outcome = "lost_ownership"
+)
@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"time"
+)
+
+// watchOwnership cancels the handler context when the visibility of the message expires while the handler is running,
+// since another consumer may receive the message and duplicate the work from then on.
+// The visibility extended by Checkpoint postpones the expiry.
+// It's enabled by CancelOnVisibilityExpiry with the VisibilityTimeout, and the returned function stops the watch.
+func (worker *Worker) watchOwnership(ctx context.Context, p *progress) (context.Context, func()) {
+	if !worker.Config.CancelOnVisibilityExpiry || worker.Config.VisibilityTimeout <= 0 {
+		return ctx, func() {}
+	}
+	p.mu.Lock()
+	p.expiresAt = worker.receivedAt(ctx).Add(time.Duration(worker.Config.VisibilityTimeout) * time.Second)
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	worker.goOwned(func() {
+		for {
+			p.mu.Lock()
+			d := worker.until(p.expiresAt)
+			if d <= 0 {
+				p.lost = true
+			}
+			p.mu.Unlock()
+			if d <= 0 {
+				cancel()
+				return
+			}
+			timer := worker.clock().NewTimer(d)
+			select {
+			case <-done:
+				timer.Stop()
+				return
+			case <-timer.C():
+			}
+		}
+	})
+	return ctx, func() {
+		close(done)
+		cancel()
+	}
+}
+
+// lostOwnership reports whether the visibility of the message in the context expired during the handler
+func lostOwnership(ctx context.Context) bool {
+	p, ok := ctx.Value(progressKey{}).(*progress)
+	if !ok {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lost
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCancelOnVisibilityExpiry(t *testing.T) {
+	client := &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", VisibilityTimeout: 1, CancelOnVisibilityExpiry: true})
+	assert.Equal(t, int32(1), worker.receiveParams().VisibilityTimeout)
+
+	// the message was received 900ms ago, so the visibility expires during the handler
+	ctx := withReceivedAt(context.Background(), time.Now().Add(-900*time.Millisecond))
+	start := time.Now()
+	outcome, err := worker.handle(ctx, &types.Message{MessageId: aws.String("slow"), ReceiptHandle: aws.String("receipt")}, ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeLostOwnership, outcome)
+	assert.True(t, time.Since(start) < time.Second, "the handler is canceled at the expiry")
+	client.AssertNotCalled(t, "DeleteMessage", mock.Anything)
+	assert.Equal(t, int64(1), worker.Stats().LostOwnership)
+	assert.Equal(t, int64(0), worker.Stats().Failed)
+}
+
+func TestCancelOnVisibilityExpiryExtendedByCheckpoint(t *testing.T) {
+	client := &mockedVisibilitySqsClient{&mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+	client.On("ChangeMessageVisibility", mock.Anything).Return()
+	client.On("DeleteMessage", mock.Anything).Return()
+	worker := New(context.Background(), client, &Config{
+		QueueName:                   "my-sqs-queue",
+		VisibilityTimeout:           1,
+		CancelOnVisibilityExpiry:    true,
+		CheckpointVisibilityTimeout: 60,
+	})
+
+	ctx := withReceivedAt(context.Background(), time.Now().Add(-900*time.Millisecond))
+	outcome, err := worker.handle(ctx, &types.Message{MessageId: aws.String("slow"), ReceiptHandle: aws.String("receipt")}, ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+		if err := Checkpoint(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(300 * time.Millisecond):
+			return nil
+		}
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeSucceeded, outcome, "the extended visibility keeps the ownership")
+	client.AssertNumberOfCalls(t, "DeleteMessage", 1)
+}
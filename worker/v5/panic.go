@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// PanicPolicy is the action for the message whose handler panicked
+type PanicPolicy string
+
+const (
+	// PanicCrash doesn't recover the panic of the handler, so it crashes the process
+	PanicCrash PanicPolicy = "crash"
+	// PanicFail recovers the panic as the HandlerPanicError handled as the failure of the handler,
+	// i.e. the message is retried by the retry topology or the redelivery
+	PanicFail PanicPolicy = "fail"
+	// PanicPark recovers the panic and moves the message to the ParkingLot, or fails it when no ParkingLot is configured
+	PanicPark PanicPolicy = "park"
+	// PanicDeadLetter recovers the panic and sends the message to the dead-letter queue, or fails it when the dead-letter queue is unknown
+	PanicDeadLetter PanicPolicy = "dead_letter"
+)
+
+// HandlerPanicError is the panic of the handler recovered by the PanicPolicy
+type HandlerPanicError struct {
+	// Value is the value passed to panic
+	Value interface{}
+	// Stack is the stack trace of the goroutine at the panic
+	Stack []byte
+}
+
+func (e *HandlerPanicError) Error() string {
+	return fmt.Sprintf("worker: handler panicked: %v", e.Value)
+}
+
+// Unwrap returns the value of the panic if it's an error, e.g. to match the runtime.Error
+func (e *HandlerPanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// panicPolicy returns the policy applied to the panic of the handler
+func (worker *Worker) panicPolicy() PanicPolicy {
+	switch worker.Config.PanicPolicy {
+	case "":
+		return PanicCrash
+	case PanicPark:
+		if worker.Config.ParkingLot == nil {
+			return PanicFail
+		}
+	case PanicDeadLetter:
+		if worker.deadLetterQueueURL == "" {
+			return PanicFail
+		}
+	}
+	return worker.Config.PanicPolicy
+}
+
+// callHandlerRecovered calls the handler, and converts its panic to the HandlerPanicError reported by the OnHandlerPanic hook
+// unless PanicCrash
+func (worker *Worker) callHandlerRecovered(ctx context.Context, h Handler, msg *types.Message) (err error) {
+	if worker.panicPolicy() == PanicCrash {
+		return callHandler(ctx, h, msg)
+	}
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		panicked := &HandlerPanicError{Value: r, Stack: debug.Stack()}
+		worker.stats.addPanicked()
+		worker.logEventWith(ctx, LogEventHandlerPanic, map[string]interface{}{"stack": string(panicked.Stack)},
+			"worker: Recovered the panic of the handler, id=%s, panic=%v", aws.ToString(msg.MessageId), r)
+		if worker.Config.Hooks.OnHandlerPanic != nil {
+			worker.Config.Hooks.OnHandlerPanic(ctx, msg, panicked)
+		}
+		err = panicked
+	}()
+	return callHandler(ctx, h, msg)
+}
+
+// handlePanic parks or dead-letters the message whose handler panicked by the PanicPolicy.
+// It reports false for PanicFail to handle the panic as the failure of the handler.
+func (worker *Worker) handlePanic(ctx context.Context, m *types.Message, panicked *HandlerPanicError) (Outcome, bool, error) {
+	switch worker.panicPolicy() {
+	case PanicPark:
+		outcome, err := worker.parkMessage(ctx, m, &ParkError{Reason: panicked.Error()})
+		return outcome, true, err
+	case PanicDeadLetter:
+		if err := worker.requeue(ctx, worker.deadLetterQueueURL, m, 0, RetryAttempt(m)); err != nil {
+			return OutcomeFailed, true, fmt.Errorf("worker: failed to send the message to the dead-letter queue, err=%+v: %w", err, panicked)
+		}
+		return OutcomeDeadLettered, true, fmt.Errorf("worker: message is sent to the dead-letter queue: %w", panicked)
+	}
+	return "", false, nil
+}
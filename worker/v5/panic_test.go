@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockedRedriveSqsClient is the queue with the redrive policy to the my-sqs-queue-dlq
+type mockedRedriveSqsClient struct {
+	*mockedSenderSqsClient
+}
+
+func (c *mockedRedriveSqsClient) GetQueueAttributes(ctx context.Context, input *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	return &sqs.GetQueueAttributesOutput{Attributes: map[string]string{
+		"RedrivePolicy": `{"deadLetterTargetArn":"arn:aws:sqs:eu-west-1:123456789:my-sqs-queue-dlq","maxReceiveCount":5}`,
+	}}, nil
+}
+
+func TestHandlerPanic(t *testing.T) {
+	message := func() *types.Message {
+		return &types.Message{MessageId: aws.String("m"), ReceiptHandle: aws.String("m"), Body: aws.String("body")}
+	}
+	panicking := HandlerFunc(func(msg *types.Message) error {
+		var m map[string]int
+		m["nil"] = 1
+		return nil
+	})
+
+	t.Run("crash by default", func(t *testing.T) {
+		worker := New(context.Background(), &countingDeleteSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+		assert.Panics(t, func() { _, _ = worker.handle(context.Background(), message(), panicking) })
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		client := &countingDeleteSqsClient{}
+		var hooked *HandlerPanicError
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", PanicPolicy: PanicFail,
+			Hooks: Hooks{OnHandlerPanic: func(ctx context.Context, msg *types.Message, err *HandlerPanicError) { hooked = err }}})
+		outcome, err := worker.handle(context.Background(), message(), panicking)
+		assert.Equal(t, OutcomeFailed, outcome)
+		var panicked *HandlerPanicError
+		if assert.True(t, errors.As(err, &panicked)) {
+			assert.True(t, strings.Contains(string(panicked.Stack), "TestHandlerPanic"), "the stack is of the panic")
+			var runtimeErr runtime.Error
+			assert.True(t, errors.As(err, &runtimeErr), "the panic value is unwrapped")
+		}
+		assert.Equal(t, panicked, hooked)
+		assert.Zero(t, client.deleted)
+		assert.Equal(t, int64(1), worker.Stats().Panicked)
+	})
+
+	t.Run("park", func(t *testing.T) {
+		client := &countingDeleteSqsClient{}
+		lot := &mockedParkingLot{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", PanicPolicy: PanicPark, ParkingLot: lot})
+		outcome, err := worker.handle(context.Background(), message(), HandlerFunc(func(msg *types.Message) error { panic("legacy") }))
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeParked, outcome)
+		if assert.Len(t, lot.parked, 1) {
+			assert.Equal(t, "worker: handler panicked: legacy", lot.parked[0].Reason)
+		}
+		assert.Equal(t, int64(1), client.deleted)
+	})
+
+	t.Run("dead letter by the redrive policy", func(t *testing.T) {
+		client := &mockedRedriveSqsClient{&mockedSenderSqsClient{&mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}}
+		client.On("SendMessage", mock.MatchedBy(func(in *sqs.SendMessageInput) bool {
+			return aws.ToString(in.QueueUrl) == "https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue-dlq"
+		})).Return().Once()
+		client.On("DeleteMessage", mock.Anything).Return().Once()
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", PanicPolicy: PanicDeadLetter})
+		assert.Equal(t, PanicDeadLetter, worker.panicPolicy())
+		outcome, err := worker.handle(context.Background(), message(), panicking)
+		assert.Error(t, err)
+		assert.Equal(t, OutcomeDeadLettered, outcome)
+		client.AssertExpectations(t)
+	})
+
+	t.Run("park without the parking lot fails", func(t *testing.T) {
+		worker := New(context.Background(), &countingDeleteSqsClient{}, &Config{QueueName: "my-sqs-queue", PanicPolicy: PanicPark})
+		assert.Equal(t, PanicFail, worker.panicPolicy())
+		outcome, err := worker.handle(context.Background(), message(), panicking)
+		assert.Error(t, err)
+		assert.Equal(t, OutcomeFailed, outcome)
+	})
+
+	t.Run("not retried in process", func(t *testing.T) {
+		calls := 0
+		worker := New(context.Background(), &countingDeleteSqsClient{}, &Config{QueueName: "my-sqs-queue", PanicPolicy: PanicFail, HandlerRetry: &HandlerRetry{}})
+		_, err := worker.handle(context.Background(), message(), HandlerFunc(func(msg *types.Message) error {
+			calls++
+			panic("legacy")
+		}))
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// ParkedMessage is the message moved to the ParkingLot with its metadata
+type ParkedMessage struct {
+	QueueURL   string                                 `json:"queue_url"`
+	MessageID  string                                 `json:"message_id"`
+	Body       string                                 `json:"body"`
+	Attributes map[string]types.MessageAttributeValue `json:"attributes,omitempty"`
+	Reason     string                                 `json:"reason"`
+	ParkedAt   time.Time                              `json:"parked_at"`
+	// ExpiresAt is the end of the TTL, the expired messages are discarded on unpark
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Expired reports whether the TTL of the parked message has passed
+func (p *ParkedMessage) Expired(now time.Time) bool {
+	return !p.ExpiresAt.IsZero() && now.After(p.ExpiresAt)
+}
+
+// ParkingLot interface stores the parked messages until operators fix the root cause (e.g. parking package)
+type ParkingLot interface {
+	Park(ctx context.Context, m *ParkedMessage) error
+}
+
+// ParkError is returned by the handler to park the message: it's moved to the ParkingLot and deleted from the queue
+type ParkError struct {
+	Reason string
+}
+
+func (e *ParkError) Error() string {
+	return fmt.Sprintf("message is parked: %s", e.Reason)
+}
+
+// Park returns the ParkError with the reason
+func Park(reason string) error {
+	return &ParkError{Reason: reason}
+}
+
+// parkMessage moves the message to the ParkingLot and deletes the original
+func (worker *Worker) parkMessage(ctx context.Context, m *types.Message, parked *ParkError) (Outcome, error) {
+	if worker.Config.ParkingLot == nil {
+		return OutcomeFailed, fmt.Errorf("worker: no parking lot is configured: %w", parked)
+	}
+	now := worker.now()
+	p := &ParkedMessage{
+		QueueURL:   worker.Config.QueueURL,
+		MessageID:  aws.ToString(m.MessageId),
+		Body:       aws.ToString(m.Body),
+		Attributes: m.MessageAttributes,
+		Reason:     parked.Reason,
+		ParkedAt:   now,
+		ExpiresAt:  now.Add(worker.Config.ParkTTL),
+	}
+	if err := worker.Config.ParkingLot.Park(ctx, p); err != nil {
+		return OutcomeFailed, fmt.Errorf("worker: failed to park the message, err=%+v: %w", err, parked)
+	}
+	if err := worker.deleteMessage(ctx, m); err != nil {
+		return OutcomeFailed, fmt.Errorf("worker: failed to delete the parked message, err=%w", err)
+	}
+	return OutcomeParked, nil
+}
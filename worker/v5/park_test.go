@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockedParkingLot struct {
+	parked []*ParkedMessage
+}
+
+func (l *mockedParkingLot) Park(ctx context.Context, m *ParkedMessage) error {
+	l.parked = append(l.parked, m)
+	return nil
+}
+
+func TestParkMessage(t *testing.T) {
+	client := &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}
+	client.On("DeleteMessage", mock.Anything).Return().Once()
+	lot := &mockedParkingLot{}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", ParkingLot: lot})
+	parking := HandlerFunc(func(msg *types.Message) error { return Park("downstream is down") })
+
+	m := &types.Message{MessageId: aws.String("id"), Body: aws.String("body"), ReceiptHandle: aws.String("receipt")}
+	outcome, err := worker.handle(context.Background(), m, parking)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeParked, outcome)
+	client.AssertExpectations(t)
+	if assert.Len(t, lot.parked, 1) {
+		p := lot.parked[0]
+		assert.Equal(t, "https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue", p.QueueURL)
+		assert.Equal(t, "downstream is down", p.Reason)
+		assert.Equal(t, "body", p.Body)
+		assert.Equal(t, worker.Config.ParkTTL, p.ExpiresAt.Sub(p.ParkedAt))
+		assert.False(t, p.Expired(p.ParkedAt))
+	}
+
+	worker.Config.ParkingLot = nil
+	outcome, err = worker.handle(context.Background(), m, parking)
+	assert.Error(t, err, "no parking lot")
+	assert.Equal(t, OutcomeFailed, outcome)
+}
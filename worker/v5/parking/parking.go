@@ -0,0 +1,58 @@
+// Package parking provides the ParkingLot implementations storing the parked messages in a queue or S3,
+// and the unpark functions re-injecting them into the original queues after operators fix the root cause.
+package parking
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+)
+
+// UnparkOptions is the options of the unpark functions
+type UnparkOptions struct {
+	// Filter selects the parked messages to unpark (default: all), the others are kept
+	Filter func(m *worker.ParkedMessage) bool
+	// Max is the maximum number of unparked messages (default: 0, unlimited)
+	Max int
+}
+
+func (o *UnparkOptions) accept(m *worker.ParkedMessage) bool {
+	return o.Filter == nil || o.Filter(m)
+}
+
+// UnparkResult is the result of the unpark
+type UnparkResult struct {
+	// Unparked is the number of messages re-injected into the original queues
+	Unparked int
+	// Expired is the number of messages discarded since the TTL passed
+	Expired int
+	// Skipped is the number of messages kept since they were not selected by the Filter
+	Skipped int
+}
+
+// reinject sends the parked message to the original queue with its attributes
+func reinject(ctx context.Context, sender worker.SenderAPI, m *worker.ParkedMessage) error {
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(m.QueueURL),
+		MessageBody: aws.String(m.Body),
+	}
+	if len(m.Attributes) > 0 {
+		input.MessageAttributes = m.Attributes
+	}
+	if _, err := sender.SendMessage(ctx, input); err != nil {
+		return fmt.Errorf("failed to re-inject the parked message, id=%s, err=%w", m.MessageID, err)
+	}
+	return nil
+}
+
+func decode(data []byte) (*worker.ParkedMessage, error) {
+	var m worker.ParkedMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid parked message: %w", err)
+	}
+	return &m, nil
+}
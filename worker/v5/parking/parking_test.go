@@ -0,0 +1,129 @@
+package parking
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSQS is the in-memory queues keyed by the queue URL
+type fakeSQS struct {
+	queues map[string][]types.Message
+	seq    int
+}
+
+func (f *fakeSQS) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.seq++
+	id := aws.String(string(rune('a' + f.seq)))
+	f.queues[aws.ToString(params.QueueUrl)] = append(f.queues[aws.ToString(params.QueueUrl)], types.Message{
+		MessageId: id, ReceiptHandle: id, Body: params.MessageBody, MessageAttributes: params.MessageAttributes,
+	})
+	return &sqs.SendMessageOutput{MessageId: id}, nil
+}
+
+func (f *fakeSQS) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	url := aws.ToString(params.QueueUrl)
+	messages := f.queues[url]
+	// the received messages are invisible until deleted in this fake
+	f.queues[url] = nil
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (f *fakeSQS) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func parked(id, reason string, expiresAt time.Time) *worker.ParkedMessage {
+	return &worker.ParkedMessage{
+		QueueURL:   "https://sqs/source",
+		MessageID:  id,
+		Body:       `{"id":"` + id + `"}`,
+		Attributes: map[string]types.MessageAttributeValue{"Type": {DataType: aws.String("String"), StringValue: aws.String("aws")}},
+		Reason:     reason,
+		ParkedAt:   time.Unix(1650000000, 0),
+		ExpiresAt:  expiresAt,
+	}
+}
+
+func TestQueueLot(t *testing.T) {
+	client := &fakeSQS{queues: map[string][]types.Message{}}
+	lot := NewQueueLot(client, "https://sqs/parking")
+	future := time.Now().Add(time.Hour)
+	ctx := context.Background()
+	assert.NoError(t, lot.Park(ctx, parked("1", "downstream", future)))
+	assert.NoError(t, lot.Park(ctx, parked("2", "schema", future)))
+	assert.NoError(t, lot.Park(ctx, parked("3", "downstream", time.Now().Add(-time.Hour))))
+
+	result, err := UnparkQueue(ctx, client, "https://sqs/parking", UnparkOptions{
+		Filter: func(m *worker.ParkedMessage) bool { return m.Reason == "downstream" },
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, UnparkResult{Unparked: 1, Expired: 1, Skipped: 1}, result)
+	if source := client.queues["https://sqs/source"]; assert.Len(t, source, 1) {
+		assert.Equal(t, `{"id":"1"}`, aws.ToString(source[0].Body))
+		assert.Equal(t, "aws", aws.ToString(source[0].MessageAttributes["Type"].StringValue), "the attributes are restored")
+	}
+}
+
+type fakeS3 struct {
+	objects map[string][]byte
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, _ := io.ReadAll(params.Body)
+	f.objects[aws.ToString(params.Key)] = body
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	keys := []string{}
+	for k := range f.objects {
+		if strings.HasPrefix(k, aws.ToString(params.Prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	out := &s3.ListObjectsV2Output{}
+	for _, k := range keys {
+		out.Contents = append(out.Contents, s3types.Object{Key: aws.String(k)})
+	}
+	return out, nil
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(f.objects[aws.ToString(params.Key)]))}, nil
+}
+
+func (f *fakeS3) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	delete(f.objects, aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestS3Lot(t *testing.T) {
+	store := &fakeS3{objects: map[string][]byte{}}
+	lot := NewS3Lot(store, "bucket", "parked")
+	ctx := context.Background()
+	assert.NoError(t, lot.Park(ctx, parked("1", "downstream", time.Now().Add(time.Hour))))
+	assert.NoError(t, lot.Park(ctx, parked("2", "downstream", time.Now().Add(time.Hour))))
+	_, ok := store.objects["parked/2022/04/15/1650000000000000000-1.json"]
+	assert.True(t, ok, "the key is time-ordered")
+
+	sender := &fakeSQS{queues: map[string][]types.Message{}}
+	result, err := UnparkS3(ctx, store, sender, "bucket", "parked", UnparkOptions{Max: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, UnparkResult{Unparked: 1}, result)
+	assert.Len(t, sender.queues["https://sqs/source"], 1)
+	assert.Len(t, store.objects, 1, "the rest is kept by Max")
+}
@@ -0,0 +1,92 @@
+package parking
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+)
+
+// QueueLot stores the parked messages in the parking queue as JSON.
+// Set the MessageRetentionPeriod of the parking queue to the ParkTTL or longer.
+type QueueLot struct {
+	Client   worker.SenderAPI
+	QueueURL string
+}
+
+// NewQueueLot creates QueueLot struct
+func NewQueueLot(client worker.SenderAPI, queueURL string) *QueueLot {
+	return &QueueLot{Client: client, QueueURL: queueURL}
+}
+
+// Park sends the parked message to the parking queue
+func (q *QueueLot) Park(ctx context.Context, m *worker.ParkedMessage) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if _, err := q.Client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(q.QueueURL),
+		MessageBody: aws.String(string(body)),
+	}); err != nil {
+		return fmt.Errorf("failed to send the message to the parking queue, err=%w", err)
+	}
+	return nil
+}
+
+// UnparkQueueAPI interface is the minimum interface required for UnparkQueue
+type UnparkQueueAPI interface {
+	worker.QueueDeleteReceiverAPI
+	worker.SenderAPI
+}
+
+// UnparkQueue re-injects the messages in the parking queue into their original queues until the parking queue is empty.
+// The expired messages are deleted, and the messages not selected by the Filter are left invisible until
+// their visibility timeout so that they are not received again during the unpark.
+func UnparkQueue(ctx context.Context, client UnparkQueueAPI, parkingURL string, opts UnparkOptions) (UnparkResult, error) {
+	var result UnparkResult
+	for {
+		resp, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(parkingURL),
+			MaxNumberOfMessages: 10,
+			WaitTimeSeconds:     1,
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to receive the parked messages, err=%w", err)
+		}
+		if len(resp.Messages) == 0 {
+			return result, nil
+		}
+		for _, pm := range resp.Messages {
+			if opts.Max > 0 && result.Unparked >= opts.Max {
+				return result, nil
+			}
+			m, err := decode([]byte(aws.ToString(pm.Body)))
+			if err != nil {
+				return result, err
+			}
+			switch {
+			case m.Expired(time.Now()):
+				result.Expired++
+			case !opts.accept(m):
+				result.Skipped++
+				continue
+			default:
+				if err := reinject(ctx, client, m); err != nil {
+					return result, err
+				}
+				result.Unparked++
+			}
+			if _, err := client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(parkingURL),
+				ReceiptHandle: pm.ReceiptHandle,
+			}); err != nil {
+				return result, fmt.Errorf("failed to delete the parked message, id=%s, err=%w", m.MessageID, err)
+			}
+		}
+	}
+}
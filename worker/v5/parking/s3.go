@@ -0,0 +1,117 @@
+package parking
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+)
+
+// S3PutAPI interface is the minimum interface required for the S3Lot
+type S3PutAPI interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// S3Lot stores the parked messages in S3 as JSON objects.
+// The object key is {Prefix}/yyyy/mm/dd/{unix-nano}-{message-id}.json with the Expires of the ExpiresAt,
+// configure a lifecycle rule on the prefix to delete the objects after the ParkTTL.
+type S3Lot struct {
+	Client S3PutAPI
+	Bucket string
+	Prefix string
+}
+
+// NewS3Lot creates S3Lot struct
+func NewS3Lot(client S3PutAPI, bucket, prefix string) *S3Lot {
+	return &S3Lot{Client: client, Bucket: bucket, Prefix: prefix}
+}
+
+// Park puts the parked message to S3
+func (s *S3Lot) Park(ctx context.Context, m *worker.ParkedMessage) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	parkedAt := m.ParkedAt.UTC()
+	key := fmt.Sprintf("%s/%s/%d-%s.json", s.Prefix, parkedAt.Format("2006/01/02"), parkedAt.UnixNano(), m.MessageID)
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	}
+	if !m.ExpiresAt.IsZero() {
+		input.Expires = aws.Time(m.ExpiresAt)
+	}
+	if _, err := s.Client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to put the parked message, key=%s, err=%w", key, err)
+	}
+	return nil
+}
+
+// UnparkS3API interface is the minimum interface required for UnparkS3
+type UnparkS3API interface {
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// UnparkS3 re-injects the parked messages under the prefix into their original queues in the parked order,
+// and deletes the unparked and expired objects
+func UnparkS3(ctx context.Context, client UnparkS3API, sender worker.SenderAPI, bucket, prefix string, opts UnparkOptions) (UnparkResult, error) {
+	var result UnparkResult
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(bucket), Prefix: aws.String(prefix + "/")}
+	for {
+		out, err := client.ListObjectsV2(ctx, input)
+		if err != nil {
+			return result, fmt.Errorf("failed to list the parked messages, err=%w", err)
+		}
+		for _, obj := range out.Contents {
+			if opts.Max > 0 && result.Unparked >= opts.Max {
+				return result, nil
+			}
+			m, err := getParked(ctx, client, bucket, aws.ToString(obj.Key))
+			if err != nil {
+				return result, err
+			}
+			switch {
+			case m.Expired(time.Now()):
+				result.Expired++
+			case !opts.accept(m):
+				result.Skipped++
+				continue
+			default:
+				if err := reinject(ctx, sender, m); err != nil {
+					return result, err
+				}
+				result.Unparked++
+			}
+			if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: obj.Key}); err != nil {
+				return result, fmt.Errorf("failed to delete the parked message, key=%s, err=%w", aws.ToString(obj.Key), err)
+			}
+		}
+		if !out.IsTruncated {
+			return result, nil
+		}
+		input.ContinuationToken = out.NextContinuationToken
+	}
+}
+
+func getParked(ctx context.Context, client UnparkS3API, bucket, key string) (*worker.ParkedMessage, error) {
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the parked message, key=%s, err=%w", key, err)
+	}
+	defer out.Body.Close()
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
@@ -0,0 +1,47 @@
+// Package payload provides the PayloadStore implementation offloading the oversized message bodies to S3
+// in the format of the extended client (amazon-sqs-java-extended-client-lib).
+package payload
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+)
+
+// S3PutAPI interface is the minimum interface required for the S3Store
+type S3PutAPI interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// S3Store stores the message bodies in S3 with the key {Prefix}/{uuid}.
+// The objects are left to the consumers, configure a lifecycle rule on the prefix to delete them after the retention of the queue.
+type S3Store struct {
+	Client S3PutAPI
+	Bucket string
+	Prefix string
+}
+
+// NewS3Store creates S3Store struct
+func NewS3Store(client S3PutAPI, bucket, prefix string) *S3Store {
+	return &S3Store{Client: client, Bucket: bucket, Prefix: prefix}
+}
+
+// Put stores the message body to S3
+func (s *S3Store) Put(ctx context.Context, body string) (string, string, error) {
+	key := uuid.NewString()
+	if s.Prefix != "" {
+		key = s.Prefix + "/" + key
+	}
+	if _, err := s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(body),
+	}); err != nil {
+		return "", "", fmt.Errorf("failed to put the message body, key=%s, err=%w", key, err)
+	}
+	return s.Bucket, key, nil
+}
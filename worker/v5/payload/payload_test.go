@@ -0,0 +1,38 @@
+package payload
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ worker.PayloadStore = (*S3Store)(nil)
+
+type fakeS3 struct {
+	objects map[string]string
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)] = string(body)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestS3Store(t *testing.T) {
+	client := &fakeS3{objects: map[string]string{}}
+	store := NewS3Store(client, "my-bucket", "oversized")
+	bucket, key, err := store.Put(context.Background(), "large body")
+	assert.NoError(t, err)
+	assert.Equal(t, "my-bucket", bucket)
+	assert.True(t, strings.HasPrefix(key, "oversized/"))
+	assert.Equal(t, "large body", client.objects["my-bucket/"+key])
+}
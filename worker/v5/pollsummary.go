@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"time"
+)
+
+// pollSummary is the summary of a poll logged as a single event
+type pollSummary struct {
+	// wait is the duration of the receive call
+	wait     time.Duration
+	messages int
+	// dispatch is the duration from the receive until the batch is processed, or enqueued to the Workers
+	dispatch time.Duration
+	// outcomes is the count of the outcomes of the batch, which is nil for the Workers processing it asynchronously
+	outcomes map[Outcome]int
+}
+
+func countOutcomes(results []BatchResult) map[Outcome]int {
+	counts := map[Outcome]int{}
+	for _, r := range results {
+		if r.Outcome != "" {
+			counts[r.Outcome]++
+		}
+	}
+	return counts
+}
+
+// logPoll logs the summary of the poll as LogEventReceived, or LogEventEmptyReceive if no messages were received
+func (worker *Worker) logPoll(ctx context.Context, s pollSummary) {
+	fields := map[string]interface{}{
+		"wait_ms":  s.wait.Milliseconds(),
+		"messages": s.messages,
+	}
+	if s.messages == 0 {
+		worker.logEventWith(ctx, LogEventEmptyReceive, fields, "worker: Received no messages, wait=%s", s.wait)
+		return
+	}
+	fields["dispatch_ms"] = s.dispatch.Milliseconds()
+	outcomes := make(map[string]int, len(s.outcomes))
+	for outcome, n := range s.outcomes {
+		outcomes[string(outcome)] = n
+	}
+	if s.outcomes != nil {
+		fields["outcomes"] = outcomes
+	}
+	worker.logEventWith(ctx, LogEventReceived, fields, "worker: Received %d messages, wait=%s, dispatch=%s, outcomes=%v",
+		s.messages, s.wait, s.dispatch, outcomes)
+}
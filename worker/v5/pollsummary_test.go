@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/common/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollSummary(t *testing.T) {
+	client := &batchesSqsClient{batches: [][]types.Message{testBatch("1", "2", "3")}}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue"})
+	var buf bytes.Buffer
+	worker.Log.Output(&buf)
+	worker.Log.Level(logging.TraceLevel)
+
+	err := worker.DrainOnce(context.Background(), HandlerFunc(func(msg *types.Message) error {
+		if aws.ToString(msg.MessageId) == "3" {
+			return errors.New("failed")
+		}
+		return nil
+	}))
+	assert.NoError(t, err)
+
+	var polls []map[string]interface{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var line map[string]interface{}
+		assert.NoError(t, dec.Decode(&line))
+		if event := line["event"]; event == string(LogEventReceived) || event == string(LogEventEmptyReceive) {
+			polls = append(polls, line)
+		}
+	}
+	if assert.Len(t, polls, 1+drainEmptyReceives, "a single log per poll") {
+		assert.Equal(t, float64(3), polls[0]["messages"])
+		assert.Contains(t, polls[0], "wait_ms")
+		assert.Contains(t, polls[0], "dispatch_ms")
+		assert.Equal(t, map[string]interface{}{"succeeded": float64(2), "failed": float64(1)}, polls[0]["outcomes"])
+		assert.Equal(t, float64(0), polls[1]["messages"])
+	}
+}
@@ -0,0 +1,177 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// workPool is the shared work queue scheduler enabled by Config.Workers.
+// The goroutines pull messages from the queue regardless of the batch, so slow messages don't block
+// fast ones delivered in the same batch, and the polling continues while the queue has room.
+// With KeyFunc, each goroutine pulls from its own queue to keep the messages of the same key serial.
+// With PriorityAttribute, the goroutines pull from the priority lanes instead, so the high-priority messages jump the queue.
+type workPool struct {
+	worker *Worker
+	// stop is the context of the polling, whose end releases the queued messages instead of handling them
+	stop   context.Context
+	queues []chan workItem
+	lanes  *priorityLanes
+}
+
+type workItem struct {
+	m        *types.Message
+	batch    *workBatch
+	index    int
+	received time.Time
+}
+
+// workBatch tracks the completion of a received batch for OnBatchProcessed
+type workBatch struct {
+	results []BatchResult
+	pending int32
+}
+
+func (b *workBatch) complete(ctx context.Context, worker *Worker, index int, outcome Outcome, err error) {
+	if b == nil {
+		return
+	}
+	b.results[index].Outcome, b.results[index].Err = outcome, err
+	if atomic.AddInt32(&b.pending, -1) == 0 {
+		worker.Config.Hooks.OnBatchProcessed(ctx, b.results)
+	}
+}
+
+// startPool starts the Workers handling the messages under the handlerCtx until the end of ctx
+func (worker *Worker) startPool(ctx, handlerCtx context.Context, h Handler) *workPool {
+	n := worker.Config.Workers
+	p := &workPool{worker: worker, stop: ctx}
+	if worker.prefetch != nil {
+		worker.goOwned(func() { worker.prefetch.run(p.stop) })
+	}
+	ctx = handlerCtx
+	if worker.prioritized() {
+		p.lanes = newPriorityLanes(worker.Config.PriorityLanes, n)
+		for i := 0; i < n; i++ {
+			worker.goOwned(func() { p.runLanes(ctx, h) })
+		}
+		return p
+	}
+	if worker.Config.KeyFunc != nil {
+		p.queues = make([]chan workItem, n)
+		for i := range p.queues {
+			p.queues[i] = make(chan workItem, 1)
+		}
+	} else {
+		p.queues = []chan workItem{make(chan workItem, n)}
+	}
+	for i := 0; i < n; i++ {
+		queue := p.queues[i%len(p.queues)]
+		worker.goOwned(func() { p.run(ctx, h, queue) })
+	}
+	return p
+}
+
+func (p *workPool) run(ctx context.Context, h Handler, queue <-chan workItem) {
+	for item := range queue {
+		p.process(ctx, h, item)
+	}
+}
+
+func (p *workPool) runLanes(ctx context.Context, h Handler) {
+	for {
+		item, ok := p.lanes.get()
+		if !ok {
+			return
+		}
+		p.process(ctx, h, item)
+	}
+}
+
+func (p *workPool) process(ctx context.Context, h Handler, item workItem) {
+	extendedAt, extended := p.worker.prefetch.take(item.m)
+	if err := p.stop.Err(); err != nil {
+		p.worker.release(ctx, item.m)
+		p.worker.inflight.remove(item.m)
+		item.batch.complete(ctx, p.worker, item.index, OutcomeFailed, err)
+		return
+	}
+	itemCtx := withReceivedAt(ctx, item.received)
+	if extended {
+		// the visibility window restarted at the extension while the message was queued
+		itemCtx = withReceivedAt(ctx, extendedAt)
+	}
+	outcome, err := p.worker.handleInflight(itemCtx, item.m, h)
+	p.worker.inflight.remove(item.m)
+	item.batch.complete(ctx, p.worker, item.index, outcome, err)
+}
+
+// push puts the item into the queue of the message, blocking while the queue is full until the end of the context
+func (p *workPool) push(ctx context.Context, item workItem) error {
+	if p.lanes != nil {
+		return p.lanes.put(ctx, p.worker.priorityOf(item.m), item)
+	}
+	queue := p.queues[0]
+	if p.worker.Config.KeyFunc != nil {
+		queue = p.queues[laneOf(p.worker.Config.KeyFunc(item.m), len(p.queues))]
+	}
+	select {
+	case queue <- item:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue puts the messages into the work queue, blocking while the queue is full.
+// The messages not enqueued by the end of the context are released.
+func (p *workPool) enqueue(ctx context.Context, messages []types.Message) {
+	worker := p.worker
+	worker.stats.addReceived(len(messages))
+
+	received := worker.receivedAt(ctx)
+	var batch *workBatch
+	if worker.Config.Hooks.OnBatchProcessed != nil {
+		batch = &workBatch{results: newBatchResults(messages), pending: int32(len(messages))}
+	}
+	order := worker.dispatchOrder(messages)
+	for k, i := range order {
+		m := &messages[i]
+		worker.inflight.add(m)
+		worker.prefetch.add(m, received)
+		if err := p.push(ctx, workItem{m: m, batch: batch, index: i, received: received}); err != nil {
+			worker.prefetch.take(m)
+			worker.inflight.remove(m)
+			for _, j := range order[k:] {
+				worker.release(ctx, &messages[j])
+				batch.complete(ctx, worker, j, OutcomeFailed, ctx.Err())
+			}
+			return
+		}
+	}
+}
+
+// close lets the goroutines exit after the queued messages are processed or released
+func (p *workPool) close() {
+	if p.lanes != nil {
+		p.lanes.close()
+	}
+	for _, q := range p.queues {
+		close(q)
+	}
+}
+
+// release makes the message not processed on shutdown visible immediately unless KeepVisibilityOnShutdown
+func (worker *Worker) release(ctx context.Context, m *types.Message) {
+	if worker.Config.KeepVisibilityOnShutdown {
+		return
+	}
+	if err := worker.changeVisibility(withoutCancel{ctx}, m, 0); err != nil {
+		worker.Log.Warnf(withoutCancel{ctx}, "worker: Failed to release the queued message, id=%s, err=%+v", aws.ToString(m.MessageId), err)
+		return
+	}
+	worker.stats.addReleased()
+}
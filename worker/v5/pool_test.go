@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// batchSqsClient returns the batches in order, and blocks until the context is done after them
+type batchSqsClient struct {
+	nopSqsClient
+	batches chan []types.Message
+}
+
+func (c *batchSqsClient) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	select {
+	case messages := <-c.batches:
+		return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestWorkPool(t *testing.T) {
+	client := &batchSqsClient{batches: make(chan []types.Message, 2)}
+	client.batches <- []types.Message{
+		{MessageId: aws.String("slow"), Body: aws.String("slow"), ReceiptHandle: aws.String("r1")},
+		{MessageId: aws.String("fast1"), Body: aws.String("fast1"), ReceiptHandle: aws.String("r2")},
+	}
+	client.batches <- []types.Message{
+		{MessageId: aws.String("fast2"), Body: aws.String("fast2"), ReceiptHandle: aws.String("r3")},
+	}
+	batches := make(chan []BatchResult, 2)
+	worker := New(context.Background(), client, &Config{
+		QueueName: "my-sqs-queue",
+		Workers:   2,
+		Hooks: Hooks{
+			OnBatchProcessed: func(ctx context.Context, results []BatchResult) { batches <- results },
+		},
+	})
+
+	handled := make(chan string, 3)
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		worker.Start(ctx, HandlerFunc(func(msg *types.Message) error {
+			if aws.ToString(msg.Body) == "slow" {
+				<-release
+			}
+			handled <- aws.ToString(msg.Body)
+			return nil
+		}))
+		close(done)
+	}()
+
+	assert.ElementsMatch(t, []string{"fast1", "fast2"}, []string{<-handled, <-handled}, "the slow message doesn't block the next batch")
+	second := <-batches
+	assert.Equal(t, "fast2", aws.ToString(second[0].Message.MessageId))
+
+	close(release)
+	assert.Equal(t, "slow", <-handled)
+	first := <-batches
+	if assert.Len(t, first, 2) {
+		assert.Equal(t, OutcomeSucceeded, first[0].Outcome)
+		assert.Equal(t, OutcomeSucceeded, first[1].Outcome)
+	}
+	cancel()
+	<-done
+	assert.Equal(t, int64(3), worker.Stats().Succeeded)
+}
+
+func TestWorkPoolShutdown(t *testing.T) {
+	client := &mockedVisibilitySqsClient{&mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+	client.On("ChangeMessageVisibility", &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String("https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue"),
+		ReceiptHandle:     aws.String("queued"),
+		VisibilityTimeout: 0,
+	}).Return().Once()
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Workers: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := &workPool{worker: worker, stop: ctx, queues: []chan workItem{make(chan workItem, 1)}}
+	cancel()
+	pool.enqueue(ctx, []types.Message{{MessageId: aws.String("queued"), ReceiptHandle: aws.String("queued")}})
+	pool.close()
+	pool.run(ctx, HandlerFunc(func(msg *types.Message) error {
+		t.Fatal("the message is not handled after the shutdown")
+		return nil
+	}), pool.queues[0])
+
+	assert.Equal(t, 0, worker.inflight.count())
+	client.AssertExpectations(t)
+}
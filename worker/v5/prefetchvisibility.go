@@ -0,0 +1,175 @@
+package worker
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// maxVisibilityBatchSize is the maximum number of entries of ChangeMessageVisibilityBatch
+const maxVisibilityBatchSize = 10
+
+// VisibilityBatchAPI interface is optionally implemented by the client to change the visibility of messages in batch
+type VisibilityBatchAPI interface {
+	ChangeMessageVisibilityBatch(ctx context.Context, params *sqs.ChangeMessageVisibilityBatchInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error)
+}
+
+// PrefetchVisibility extends the visibility of the messages queued for the Workers with ChangeMessageVisibilityBatch
+// when they approach the visibility timeout before a goroutine picks them up, so that they aren't redelivered
+// to another consumer while still queued in memory.
+// The visibility window of the extended message restarts at the extension, e.g. for DeleteBatching and CancelOnVisibilityExpiry.
+type PrefetchVisibility struct {
+	// VisibilityTimeout is the visibility timeout of the queue, and the extension is the same
+	// (default: Config.VisibilityTimeout or 30 seconds, the default of SQS)
+	VisibilityTimeout time.Duration
+	// Margin is the margin before the visibility timeout expires since the receive or the last extension (default: 5 seconds)
+	Margin time.Duration
+}
+
+func (v *PrefetchVisibility) populateDefaultValues() {
+	if v.VisibilityTimeout <= 0 {
+		v.VisibilityTimeout = 30 * time.Second
+	}
+	if v.Margin <= 0 {
+		v.Margin = 5 * time.Second
+	}
+	if v.Margin >= v.VisibilityTimeout {
+		v.Margin = v.VisibilityTimeout / 2
+	}
+}
+
+// queuedVisibility is the visibility of a queued message
+type queuedVisibility struct {
+	expiresAt  time.Time
+	extendedAt time.Time
+}
+
+// prefetchExtender tracks the visibility of the queued messages and extends it before it expires
+type prefetchExtender struct {
+	worker *Worker
+	client VisibilityBatchAPI
+	config *PrefetchVisibility
+
+	mu     sync.Mutex
+	queued map[*types.Message]*queuedVisibility
+}
+
+func (worker *Worker) newPrefetchExtender(ctx context.Context) *prefetchExtender {
+	config := worker.Config.PrefetchVisibility
+	if config == nil || worker.Config.Workers <= 0 || worker.Config.TapMode {
+		return nil
+	}
+	client, ok := worker.SqsClient.(VisibilityBatchAPI)
+	if !ok {
+		worker.Log.Warnf(ctx, "worker: PrefetchVisibility is disabled, the sqs client does not support ChangeMessageVisibilityBatch")
+		return nil
+	}
+	if config.VisibilityTimeout <= 0 && worker.Config.VisibilityTimeout > 0 {
+		config.VisibilityTimeout = time.Duration(worker.Config.VisibilityTimeout) * time.Second
+	}
+	config.populateDefaultValues()
+	return &prefetchExtender{worker: worker, client: client, config: config, queued: map[*types.Message]*queuedVisibility{}}
+}
+
+// add tracks the message received at the time until it's taken
+func (e *prefetchExtender) add(m *types.Message, received time.Time) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.queued[m] = &queuedVisibility{expiresAt: received.Add(e.config.VisibilityTimeout)}
+}
+
+// take untracks the message dequeued, and returns the time of its last extension if it was extended
+func (e *prefetchExtender) take(m *types.Message) (time.Time, bool) {
+	if e == nil {
+		return time.Time{}, false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	v, ok := e.queued[m]
+	delete(e.queued, m)
+	if !ok || v.extendedAt.IsZero() {
+		return time.Time{}, false
+	}
+	return v.extendedAt, true
+}
+
+// run extends the visibility of the due messages every half of the Margin until the end of the context
+func (e *prefetchExtender) run(ctx context.Context) {
+	ticker := e.worker.clock().NewTicker(e.config.Margin / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			e.extendDue(ctx)
+		}
+	}
+}
+
+// extendDue extends the visibility of the queued messages expiring within the Margin in batches
+func (e *prefetchExtender) extendDue(ctx context.Context) {
+	now := e.worker.now()
+	e.mu.Lock()
+	var due []*types.Message
+	for m, v := range e.queued {
+		if v.expiresAt.Sub(now) <= e.config.Margin {
+			due = append(due, m)
+		}
+	}
+	e.mu.Unlock()
+	for start := 0; start < len(due); start += maxVisibilityBatchSize {
+		end := start + maxVisibilityBatchSize
+		if end > len(due) {
+			end = len(due)
+		}
+		e.extend(ctx, due[start:end], now)
+	}
+}
+
+// extend changes the visibility of the messages, and restarts the visibility window of the ones succeeded
+func (e *prefetchExtender) extend(ctx context.Context, messages []*types.Message, now time.Time) {
+	params := &sqs.ChangeMessageVisibilityBatchInput{
+		QueueUrl: aws.String(e.worker.Config.QueueURL),
+		Entries:  make([]types.ChangeMessageVisibilityBatchRequestEntry, len(messages)),
+	}
+	timeout := int32(e.config.VisibilityTimeout / time.Second)
+	for i, m := range messages {
+		params.Entries[i] = types.ChangeMessageVisibilityBatchRequestEntry{Id: aws.String(strconv.Itoa(i)), ReceiptHandle: m.ReceiptHandle, VisibilityTimeout: timeout}
+	}
+	callCtx, finishSpan := e.worker.startClientSpan(ctx, "ChangeMessageVisibilityBatch")
+	out, err := e.client.ChangeMessageVisibilityBatch(callCtx, params, e.worker.Config.sqsOptions()...)
+	finishSpan(err)
+	if err != nil {
+		e.worker.Log.Warnf(ctx, "worker: Failed to extend the visibility of the queued messages, count=%d, err=%+v", len(messages), err)
+		return
+	}
+	failed := make(map[string]bool, len(out.Failed))
+	for _, f := range out.Failed {
+		failed[aws.ToString(f.Id)] = true
+		e.worker.Log.Warnf(ctx, "worker: Failed to extend the visibility of the queued message, code=%s, message=%s", aws.ToString(f.Code), aws.ToString(f.Message))
+	}
+	extended := 0
+	e.mu.Lock()
+	for i, m := range messages {
+		v, ok := e.queued[m]
+		if !ok || failed[strconv.Itoa(i)] {
+			continue
+		}
+		v.expiresAt, v.extendedAt = now.Add(e.config.VisibilityTimeout), now
+		extended++
+	}
+	e.mu.Unlock()
+	e.worker.stats.addPrefetchExtended(extended)
+	if extended > 0 {
+		e.worker.logEvent(ctx, LogEventPrefetchExtended, "worker: Extended the visibility of the queued messages, count=%d, timeout=%s", extended, e.config.VisibilityTimeout)
+	}
+}
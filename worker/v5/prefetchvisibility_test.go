@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type visibilityBatchSqsClient struct {
+	nopSqsClient
+	mu      sync.Mutex
+	batches []*sqs.ChangeMessageVisibilityBatchInput
+	failed  map[string]bool
+}
+
+func (c *visibilityBatchSqsClient) ChangeMessageVisibilityBatch(ctx context.Context, params *sqs.ChangeMessageVisibilityBatchInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batches = append(c.batches, params)
+	out := &sqs.ChangeMessageVisibilityBatchOutput{}
+	for _, e := range params.Entries {
+		if c.failed[aws.ToString(e.ReceiptHandle)] {
+			out.Failed = append(out.Failed, types.BatchResultErrorEntry{Id: e.Id, Code: aws.String("ReceiptHandleIsInvalid")})
+		}
+	}
+	return out, nil
+}
+
+func TestPrefetchVisibility(t *testing.T) {
+	t.Run("disabled without the Workers", func(t *testing.T) {
+		worker := New(context.Background(), &visibilityBatchSqsClient{}, &Config{QueueName: "my-sqs-queue", PrefetchVisibility: &PrefetchVisibility{}})
+		assert.Nil(t, worker.prefetch)
+	})
+
+	t.Run("the queued messages are extended before the visibility expires", func(t *testing.T) {
+		client := &visibilityBatchSqsClient{failed: map[string]bool{"failing": true}}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Workers: 1, VisibilityTimeout: 60, PrefetchVisibility: &PrefetchVisibility{}})
+		assert.Equal(t, time.Minute, worker.Config.PrefetchVisibility.VisibilityTimeout)
+		now := time.Now()
+		var due, failing, fresh []*types.Message
+		for i := 0; i < 12; i++ {
+			m := &types.Message{MessageId: aws.String("due"), ReceiptHandle: aws.String("due")}
+			worker.prefetch.add(m, now.Add(-56*time.Second))
+			due = append(due, m)
+		}
+		failing = append(failing, &types.Message{ReceiptHandle: aws.String("failing")})
+		worker.prefetch.add(failing[0], now.Add(-time.Minute))
+		fresh = append(fresh, &types.Message{ReceiptHandle: aws.String("fresh")})
+		worker.prefetch.add(fresh[0], now)
+
+		worker.prefetch.extendDue(context.Background())
+		if assert.Len(t, client.batches, 2, "the due messages are extended in the batches of 10") {
+			assert.Len(t, client.batches[0].Entries, 10)
+			assert.Len(t, client.batches[1].Entries, 3)
+			assert.Equal(t, int32(60), client.batches[0].Entries[0].VisibilityTimeout)
+		}
+		assert.Equal(t, int64(12), worker.Stats().PrefetchExtended)
+
+		extendedAt, extended := worker.prefetch.take(due[0])
+		assert.True(t, extended)
+		assert.False(t, extendedAt.Before(now))
+		_, extended = worker.prefetch.take(failing[0])
+		assert.False(t, extended, "the failed entry is not extended")
+		_, extended = worker.prefetch.take(fresh[0])
+		assert.False(t, extended, "the message far from the expiry is not extended")
+
+		worker.prefetch.extendDue(context.Background())
+		assert.Len(t, client.batches, 2, "the extended messages are not due again")
+	})
+}
@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// priorityOf returns the lane(0 <= lane < PriorityLanes) of the message by the PriorityAttribute,
+// the higher lane is dispatched first and the message without the valid attribute is in the lowest lane 0
+func (worker *Worker) priorityOf(m *types.Message) int {
+	v, ok := m.MessageAttributes[worker.Config.PriorityAttribute]
+	if !ok || v.StringValue == nil {
+		return 0
+	}
+	p, err := strconv.Atoi(*v.StringValue)
+	if err != nil || p < 0 {
+		return 0
+	}
+	if p >= worker.Config.PriorityLanes {
+		return worker.Config.PriorityLanes - 1
+	}
+	return p
+}
+
+// prioritized reports whether the messages are dispatched by the priority
+func (worker *Worker) prioritized() bool {
+	return worker.Config.PriorityAttribute != "" && worker.Config.KeyFunc == nil
+}
+
+// dispatchOrder returns the indexes of the messages in the order of the dispatch, the higher priority first
+// and the receive order within the same priority
+func (worker *Worker) dispatchOrder(messages []types.Message) []int {
+	order := make([]int, len(messages))
+	for i := range order {
+		order[i] = i
+	}
+	if !worker.prioritized() {
+		return order
+	}
+	priorities := make([]int, len(messages))
+	for i := range messages {
+		priorities[i] = worker.priorityOf(&messages[i])
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return priorities[order[a]] > priorities[order[b]]
+	})
+	return order
+}
+
+// priorityLanes is the bounded work queue of the workPool with a FIFO lane per priority,
+// where the goroutines take the item of the highest non-empty lane
+type priorityLanes struct {
+	mu       sync.Mutex
+	lanes    [][]workItem
+	size     int
+	capacity int
+	closed   bool
+	changed  chan struct{}
+}
+
+func newPriorityLanes(lanes, capacity int) *priorityLanes {
+	return &priorityLanes{lanes: make([][]workItem, lanes), capacity: capacity, changed: make(chan struct{})}
+}
+
+// put adds the item to the lane, blocking while the queue is full until the end of the context
+func (q *priorityLanes) put(ctx context.Context, lane int, item workItem) error {
+	for {
+		q.mu.Lock()
+		if q.size < q.capacity {
+			q.lanes[lane] = append(q.lanes[lane], item)
+			q.size++
+			q.notify()
+			q.mu.Unlock()
+			return nil
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// get takes the item of the highest non-empty lane, blocking while the queue is empty.
+// It returns false when the queue is closed and empty.
+func (q *priorityLanes) get() (workItem, bool) {
+	for {
+		q.mu.Lock()
+		for lane := len(q.lanes) - 1; lane >= 0; lane-- {
+			if len(q.lanes[lane]) == 0 {
+				continue
+			}
+			item := q.lanes[lane][0]
+			q.lanes[lane][0] = workItem{}
+			q.lanes[lane] = q.lanes[lane][1:]
+			q.size--
+			q.notify()
+			q.mu.Unlock()
+			return item, true
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return workItem{}, false
+		}
+		changed := q.changed
+		q.mu.Unlock()
+		<-changed
+	}
+}
+
+// close lets the goroutines exit after the queued items are taken
+func (q *priorityLanes) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.notify()
+}
+
+// notify wakes up all waiters, it must be called with the lock held
+func (q *priorityLanes) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func prioritizedMessage(id, priority string) types.Message {
+	m := types.Message{MessageId: aws.String(id), ReceiptHandle: aws.String(id)}
+	if priority != "" {
+		m.MessageAttributes = map[string]types.MessageAttributeValue{
+			"priority": {DataType: aws.String("Number"), StringValue: aws.String(priority)},
+		}
+	}
+	return m
+}
+
+func TestPriorityOf(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", PriorityAttribute: "priority"})
+	for priority, lane := range map[string]int{"": 0, "1": 1, "2": 2, "9": 2, "-1": 0, "high": 0} {
+		m := prioritizedMessage("m", priority)
+		assert.Equal(t, lane, worker.priorityOf(&m), "priority=%q", priority)
+	}
+}
+
+func TestPriorityDispatchOrder(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", PriorityAttribute: "priority", SubBatchSize: 1})
+	messages := []types.Message{
+		prioritizedMessage("low1", ""),
+		prioritizedMessage("high", "2"),
+		prioritizedMessage("low2", "0"),
+		prioritizedMessage("mid", "1"),
+	}
+	var handled []string
+	worker.run(context.Background(), HandlerFunc(func(msg *types.Message) error {
+		handled = append(handled, aws.ToString(msg.MessageId))
+		return nil
+	}), messages)
+
+	assert.Equal(t, []string{"high", "mid", "low1", "low2"}, handled, "the higher priority first, the receive order within the same priority")
+}
+
+func TestPriorityLanes(t *testing.T) {
+	q := newPriorityLanes(3, 4)
+	ctx := context.Background()
+	for _, item := range []struct {
+		id   string
+		lane int
+	}{{"low1", 0}, {"mid", 1}, {"low2", 0}, {"high", 2}} {
+		m := prioritizedMessage(item.id, "")
+		assert.NoError(t, q.put(ctx, item.lane, workItem{m: &m}))
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	m := prioritizedMessage("full", "")
+	assert.Error(t, q.put(canceled, 2, workItem{m: &m}), "put blocks while the queue is full")
+
+	var taken []string
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			item, ok := q.get()
+			if !ok {
+				return
+			}
+			taken = append(taken, aws.ToString(item.m.MessageId))
+		}
+	}()
+	q.close()
+	wg.Wait()
+	assert.Equal(t, []string{"high", "mid", "low1", "low2"}, taken, "the items queued before close are taken")
+}
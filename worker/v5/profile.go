@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"fmt"
+	"time"
+)
+
+// Profile is the preset combination of the tunables for a kind of workload.
+// Apply sets only the fields left zero in the Config, so the explicit values override the profile.
+type Profile struct {
+	// Name is the name of the profile in the FileConfig (e.g. low_latency)
+	Name               string
+	MaxNumberOfMessage int32
+	WaitTimeSecond     int32
+	// Workers is the size of the work pool prefetching the messages while the handlers are busy
+	Workers          int
+	Concurrency      int
+	ThrottleCooldown time.Duration
+	HandlerRetry     *HandlerRetry
+	DeleteBatching   *DeleteBatching
+}
+
+// ProfileLowLatency is for the interactive requests: the messages are dispatched as soon as they arrive
+// through a small work pool, the deletes are not buffered and the blips are retried quickly in process.
+func ProfileLowLatency() Profile {
+	return Profile{
+		Name:               "low_latency",
+		MaxNumberOfMessage: 5,
+		WaitTimeSecond:     20,
+		Workers:            16,
+		ThrottleCooldown:   5 * time.Second,
+		HandlerRetry:       &HandlerRetry{MaxAttempts: 2, Backoff: 50 * time.Millisecond, MaxBackoff: 200 * time.Millisecond},
+	}
+}
+
+// ProfileCostOptimized minimizes the SQS requests: the full batches are long-polled, the deletes are batched
+// and the transient errors are retried in process rather than paying for the redeliveries.
+func ProfileCostOptimized() Profile {
+	return Profile{
+		Name:               "cost_optimized",
+		MaxNumberOfMessage: 10,
+		WaitTimeSecond:     20,
+		Concurrency:        10,
+		ThrottleCooldown:   30 * time.Second,
+		HandlerRetry:       &HandlerRetry{MaxAttempts: 3, Backoff: 200 * time.Millisecond, MaxBackoff: 2 * time.Second},
+		DeleteBatching:     &DeleteBatching{MaxWait: time.Second},
+	}
+}
+
+// ProfileBulk is for the backlog processing (e.g. the nightly scans): a large work pool keeps the polling ahead
+// of the handlers, and the deletes are batched with a longer wait.
+func ProfileBulk() Profile {
+	return Profile{
+		Name:               "bulk",
+		MaxNumberOfMessage: 10,
+		WaitTimeSecond:     20,
+		Workers:            64,
+		ThrottleCooldown:   30 * time.Second,
+		HandlerRetry:       &HandlerRetry{MaxAttempts: 3, Backoff: 500 * time.Millisecond, MaxBackoff: 5 * time.Second},
+		DeleteBatching:     &DeleteBatching{MaxWait: 2 * time.Second},
+	}
+}
+
+// Profiles returns the preset profiles
+func Profiles() []Profile {
+	return []Profile{ProfileLowLatency(), ProfileCostOptimized(), ProfileBulk()}
+}
+
+// ProfileByName returns the preset profile of the name
+func ProfileByName(name string) (Profile, error) {
+	for _, p := range Profiles() {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Profile{}, fmt.Errorf("unknown profile: %s", name)
+}
+
+// Apply sets the tunables of the profile to the fields left zero in the config, and returns the config
+func (p Profile) Apply(config *Config) *Config {
+	if config.MaxNumberOfMessage == 0 {
+		config.MaxNumberOfMessage = p.MaxNumberOfMessage
+	}
+	if config.WaitTimeSecond == 0 {
+		config.WaitTimeSecond = p.WaitTimeSecond
+	}
+	if config.Workers == 0 && config.Concurrency == 0 {
+		// the Concurrency is ignored with the Workers, so they are set together or not at all
+		config.Workers = p.Workers
+		config.Concurrency = p.Concurrency
+	}
+	if config.ThrottleCooldown == 0 {
+		config.ThrottleCooldown = p.ThrottleCooldown
+	}
+	if config.HandlerRetry == nil && p.HandlerRetry != nil {
+		retry := *p.HandlerRetry
+		config.HandlerRetry = &retry
+	}
+	if config.DeleteBatching == nil && p.DeleteBatching != nil {
+		batching := *p.DeleteBatching
+		config.DeleteBatching = &batching
+	}
+	return config
+}
@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileApply(t *testing.T) {
+	config := ProfileBulk().Apply(&Config{QueueName: "my-sqs-queue", WaitTimeSecond: 5})
+	assert.Equal(t, int32(10), config.MaxNumberOfMessage)
+	assert.Equal(t, int32(5), config.WaitTimeSecond, "the explicit value overrides the profile")
+	assert.Equal(t, 64, config.Workers)
+	assert.Equal(t, 2*time.Second, config.DeleteBatching.MaxWait)
+	assert.Equal(t, 3, config.HandlerRetry.MaxAttempts)
+
+	config.HandlerRetry.MaxAttempts = 1
+	assert.Equal(t, 3, ProfileBulk().Apply(&Config{}).HandlerRetry.MaxAttempts, "the profiles don't share the nested configs")
+
+	config = ProfileLowLatency().Apply(&Config{Concurrency: 4})
+	assert.Equal(t, 4, config.Concurrency)
+	assert.Equal(t, 0, config.Workers, "the explicit Concurrency isn't combined with the Workers of the profile")
+	assert.Nil(t, config.DeleteBatching)
+}
+
+func TestProfileByName(t *testing.T) {
+	for _, name := range []string{"low_latency", "cost_optimized", "bulk"} {
+		p, err := ProfileByName(name)
+		assert.NoError(t, err)
+		assert.Equal(t, name, p.Name)
+	}
+	_, err := ProfileByName("fast")
+	assert.Error(t, err)
+}
+
+func TestQueueConfigProfile(t *testing.T) {
+	q := &QueueConfig{Name: "my-sqs-queue", Profile: "cost_optimized", MaxNumberOfMessage: 5}
+	config := q.WorkerConfig()
+	assert.Equal(t, int32(5), config.MaxNumberOfMessage)
+	assert.Equal(t, 10, config.Concurrency)
+	assert.NotNil(t, config.DeleteBatching)
+
+	err := (&FileConfig{Queues: []QueueConfig{{Name: "my-sqs-queue", Profile: "fast"}}}).Validate()
+	assert.EqualError(t, err, `invalid config: queues[0].profile: must be low_latency, cost_optimized or bulk, got "fast"`)
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// OriginMessageIDAttribute is the message attribute of the MessageId which started the chain of the publishes
+	OriginMessageIDAttribute = "OriginMessageId"
+	// ParentMessageIDAttribute is the message attribute of the MessageId of the consumed message which published the message
+	ParentMessageIDAttribute = "ParentMessageId"
+)
+
+// Publisher publishes the messages from the handler with the correlation of the consumed message,
+// preserving the end-to-end traceability across the queues.
+type Publisher struct {
+	Client SenderAPI
+	// Attributes is the names of the message attributes copied from the consumed message
+	Attributes []string
+	// FIFOIDs derives the MessageGroupId and the MessageDeduplicationId unset on the message to the FIFO queue
+	// from the consumed message (default: DefaultFIFOIDs)
+	FIFOIDs FIFOIDs
+}
+
+// NewPublisher creates Publisher struct copying the named message attributes
+func NewPublisher(client SenderAPI, attributes ...string) *Publisher {
+	return &Publisher{Client: client, Attributes: attributes}
+}
+
+// Publish sends the message with the attributes injected by InjectAttributes,
+// and with the FIFOIDs derived from the consumed message when sent to the FIFO queue
+func (p *Publisher) Publish(ctx context.Context, input *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	InjectAttributes(ctx, input, p.Attributes...)
+	setFIFOIDs(p.FIFOIDs, consumedMessage(ctx), input)
+	out, err := p.Client.SendMessage(ctx, input, optFns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish the message, queue=%s, err=%w", aws.ToString(input.QueueUrl), err)
+	}
+	return out, nil
+}
+
+// InjectAttributes copies the correlation of the message consumed in the handler context onto the message to publish:
+// the AWSTraceHeader, the named message attributes, the correlation fields of the FieldsExtractor (e.g. project_id)
+// and the lineage (HopCountAttribute, OriginMessageIDAttribute, ParentMessageIDAttribute).
+// The attributes already set on the input are kept, and the attributes over the limit of SQS are skipped.
+func InjectAttributes(ctx context.Context, input *sqs.SendMessageInput, names ...string) {
+	consumed := consumedMessage(ctx)
+	if consumed == nil {
+		return
+	}
+	if input.MessageAttributes == nil {
+		input.MessageAttributes = map[string]types.MessageAttributeValue{}
+	}
+	set := func(name string, v types.MessageAttributeValue) {
+		if _, ok := input.MessageAttributes[name]; ok || len(input.MessageAttributes) >= maxMessageAttributes {
+			return
+		}
+		input.MessageAttributes[name] = v
+	}
+
+	if trace, ok := consumed.Attributes[string(types.MessageSystemAttributeNameAWSTraceHeader)]; ok {
+		if _, ok := input.MessageSystemAttributes[string(types.MessageSystemAttributeNameForSendsAWSTraceHeader)]; !ok {
+			if input.MessageSystemAttributes == nil {
+				input.MessageSystemAttributes = map[string]types.MessageSystemAttributeValue{}
+			}
+			input.MessageSystemAttributes[string(types.MessageSystemAttributeNameForSendsAWSTraceHeader)] = types.MessageSystemAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(trace),
+			}
+		}
+	}
+	lineage := map[string]types.MessageAttributeValue{}
+	setLineage(lineage, consumed, true)
+	for _, name := range []string{HopCountAttribute, OriginMessageIDAttribute} {
+		if v, ok := lineage[name]; ok {
+			set(name, v)
+		}
+	}
+	if id := aws.ToString(consumed.MessageId); id != "" {
+		set(ParentMessageIDAttribute, types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(id)})
+	}
+	for _, name := range names {
+		if attr, ok := consumed.MessageAttributes[name]; ok {
+			set(name, attr)
+		}
+	}
+	for name, v := range LogFieldsFromContext(ctx) {
+		dataType := "String"
+		switch v.(type) {
+		case int, int32, int64, uint, uint32, uint64, float32, float64:
+			dataType = "Number"
+		}
+		set(name, types.MessageAttributeValue{DataType: aws.String(dataType), StringValue: aws.String(fmt.Sprint(v))})
+	}
+}
+
+// consumedMessage returns the message processed in the handler context
+func consumedMessage(ctx context.Context) *types.Message {
+	if p, ok := ctx.Value(progressKey{}).(*progress); ok {
+		return p.m
+	}
+	return nil
+}
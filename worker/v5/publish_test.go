@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPublisher(t *testing.T) {
+	client := &mockedSenderSqsClient{&mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+	client.On("DeleteMessage", mock.Anything).Return()
+	var published *sqs.SendMessageInput
+	client.On("SendMessage", mock.Anything).Run(func(args mock.Arguments) {
+		published = args.Get(0).(*sqs.SendMessageInput)
+	}).Return()
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", FieldsExtractor: RISKENFields})
+	publisher := NewPublisher(client, "Tenant")
+
+	consumed := &types.Message{
+		MessageId:     aws.String("consumed"),
+		ReceiptHandle: aws.String("receipt"),
+		Body:          aws.String(`{"project_id":1001}`),
+		Attributes:    map[string]string{"AWSTraceHeader": "Root=1-5759e988-bd862e3fe1be46a994272793"},
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			OriginMessageIDAttribute: {DataType: aws.String("String"), StringValue: aws.String("origin")},
+			"Tenant":                 {DataType: aws.String("String"), StringValue: aws.String("acme")},
+			"Internal":               {DataType: aws.String("String"), StringValue: aws.String("not copied")},
+		},
+	}
+	_, err := worker.handle(context.Background(), consumed, ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+		_, err := publisher.Publish(ctx, &sqs.SendMessageInput{
+			QueueUrl:    aws.String("https://sqs/downstream"),
+			MessageBody: aws.String("downstream"),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"Tenant": {DataType: aws.String("String"), StringValue: aws.String("explicit")},
+			},
+		})
+		return err
+	}))
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, published) {
+		value := func(name string) string { return aws.ToString(published.MessageAttributes[name].StringValue) }
+		assert.Equal(t, "origin", value(OriginMessageIDAttribute), "the origin of the chain is kept")
+		assert.Equal(t, "consumed", value(ParentMessageIDAttribute))
+		assert.Equal(t, "1", value(HopCountAttribute))
+		assert.Equal(t, "explicit", value("Tenant"), "the explicit attributes are kept")
+		assert.Equal(t, "1001", value("project_id"))
+		assert.Equal(t, "Number", aws.ToString(published.MessageAttributes["project_id"].DataType))
+		assert.NotContains(t, published.MessageAttributes, "Internal")
+		assert.Equal(t, "Root=1-5759e988-bd862e3fe1be46a994272793", aws.ToString(published.MessageSystemAttributes["AWSTraceHeader"].StringValue))
+	}
+}
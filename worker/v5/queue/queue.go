@@ -0,0 +1,47 @@
+// Package queue is the stable contract of the SQS client required by the worker.
+// The other components can depend on the minimal interfaces without importing the whole worker,
+// and the worker package keeps the aliases of them (e.g. worker.QueueAPI is queue.API).
+package queue
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// Receiver interface receives the messages from the queue
+type Receiver interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+}
+
+// Deleter interface deletes the processed messages from the queue
+type Deleter interface {
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+}
+
+// URLResolver interface resolves the queue URL from the queue name
+type URLResolver interface {
+	GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error)
+}
+
+// Sender interface sends the messages to the queue
+type Sender interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// VisibilityChanger interface changes the visibility of the received messages
+type VisibilityChanger interface {
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+}
+
+// DeleteReceiver interface is the minimum interface required to run the receive loop of a worker
+type DeleteReceiver interface {
+	Deleter
+	Receiver
+}
+
+// API interface is the minimum interface required to create a worker from the queue name
+type API interface {
+	URLResolver
+	DeleteReceiver
+}
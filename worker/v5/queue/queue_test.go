@@ -0,0 +1,12 @@
+package queue
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// the sqs client implements the contract
+var (
+	_ API               = (*sqs.Client)(nil)
+	_ Sender            = (*sqs.Client)(nil)
+	_ VisibilityChanger = (*sqs.Client)(nil)
+)
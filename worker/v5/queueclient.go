@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// QueueClient is the SQS client of the worker and the queue of the message being handled,
+// available to the ContextHandler by QueueClientFromContext for the advanced operations
+// without constructing another client, e.g. to change the visibility of its own message or to send the follow-ups.
+type QueueClient struct {
+	// Client is the SqsClient of the worker, assert it to e.g. SenderAPI or VisibilityChangerAPI for the other operations
+	Client QueueDeleteReceiverAPI
+	// QueueURL is the URL of the queue the message was received from
+	QueueURL string
+	// Options is the options the worker calls the client with, e.g. the Region and the CallOptions
+	Options []func(*sqs.Options)
+}
+
+// QueueClientFromContext returns the QueueClient of the worker handling the message in the handler context.
+// The calls with the client bypass the worker, e.g. the TapMode and the RequestStats.
+func QueueClientFromContext(ctx context.Context) (QueueClient, bool) {
+	p, ok := ctx.Value(progressKey{}).(*progress)
+	if !ok {
+		return QueueClient{}, false
+	}
+	return QueueClient{
+		Client:   p.worker.SqsClient,
+		QueueURL: p.worker.Config.QueueURL,
+		Options:  p.worker.Config.sqsOptions(),
+	}, true
+}
@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueClientFromContext(t *testing.T) {
+	_, ok := QueueClientFromContext(context.Background())
+	assert.False(t, ok)
+
+	client := &recordingSenderSqsClient{}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Region: "eu-west-1"})
+	m := &types.Message{MessageId: aws.String("m"), ReceiptHandle: aws.String("m")}
+	_, err := worker.handle(context.Background(), m, ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+		q, ok := QueueClientFromContext(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, worker.Config.QueueURL, q.QueueURL)
+		assert.Len(t, q.Options, 1, "the region of the worker")
+		sender, ok := q.Client.(SenderAPI)
+		if assert.True(t, ok) {
+			_, err := sender.SendMessage(ctx, &sqs.SendMessageInput{QueueUrl: aws.String(q.QueueURL), MessageBody: aws.String("follow-up")}, q.Options...)
+			return err
+		}
+		return nil
+	}))
+	assert.NoError(t, err)
+	if assert.Len(t, client.sent, 1) {
+		assert.Equal(t, "follow-up", aws.ToString(client.sent[0].MessageBody))
+	}
+}
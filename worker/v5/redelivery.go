@@ -0,0 +1,113 @@
+package worker
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// maxRememberedFailures is the number of the failed messages remembered for the reason of their redelivery
+const maxRememberedFailures = 10000
+
+// RedeliveryReason is the reason why the message was delivered again
+type RedeliveryReason string
+
+const (
+	// RedeliveryHandlerError means the previous delivery to this worker failed in the handler
+	RedeliveryHandlerError RedeliveryReason = "handler_error"
+	// RedeliveryVisibilityExpiry means the visibility of the previous delivery to this worker expired during the handler
+	RedeliveryVisibilityExpiry RedeliveryReason = "visibility_expiry"
+	// RedeliveryUnknown means the previous delivery is unknown to this worker, e.g. another replica or the process crashed
+	RedeliveryUnknown RedeliveryReason = "unknown"
+)
+
+// RedeliveryStats is the number of the messages received with ApproximateReceiveCount > 1 by the reason
+type RedeliveryStats struct {
+	HandlerError     int64
+	VisibilityExpiry int64
+	Unknown          int64
+}
+
+// redeliveries tracks the redelivered messages, remembering the failed MessageIds to tag the reason of their redelivery
+type redeliveries struct {
+	handlerError     int64
+	visibilityExpiry int64
+	unknown          int64
+
+	mu       sync.Mutex
+	failures map[string]RedeliveryReason
+	order    []string
+}
+
+func newRedeliveries() *redeliveries {
+	return &redeliveries{failures: map[string]RedeliveryReason{}}
+}
+
+// observe counts the message if it's redelivered, and returns the reason
+func (r *redeliveries) observe(m *types.Message) (RedeliveryReason, bool) {
+	if r == nil {
+		return "", false
+	}
+	attr, ok := m.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)]
+	if !ok {
+		return "", false
+	}
+	count, err := strconv.Atoi(attr)
+	if err != nil || count <= 1 {
+		return "", false
+	}
+	reason := RedeliveryUnknown
+	r.mu.Lock()
+	if failed, ok := r.failures[aws.ToString(m.MessageId)]; ok {
+		reason = failed
+		delete(r.failures, aws.ToString(m.MessageId))
+	}
+	r.mu.Unlock()
+	switch reason {
+	case RedeliveryHandlerError:
+		atomic.AddInt64(&r.handlerError, 1)
+	case RedeliveryVisibilityExpiry:
+		atomic.AddInt64(&r.visibilityExpiry, 1)
+	default:
+		atomic.AddInt64(&r.unknown, 1)
+	}
+	return reason, true
+}
+
+// remember records the message left in the queue with the reason of its redelivery
+func (r *redeliveries) remember(m *types.Message, reason RedeliveryReason) {
+	if r == nil || m.MessageId == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.order) >= maxRememberedFailures {
+		delete(r.failures, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.failures[aws.ToString(m.MessageId)] = reason
+	r.order = append(r.order, aws.ToString(m.MessageId))
+}
+
+func (r *redeliveries) stats() RedeliveryStats {
+	if r == nil {
+		return RedeliveryStats{}
+	}
+	return RedeliveryStats{
+		HandlerError:     atomic.LoadInt64(&r.handlerError),
+		VisibilityExpiry: atomic.LoadInt64(&r.visibilityExpiry),
+		Unknown:          atomic.LoadInt64(&r.unknown),
+	}
+}
+
+// observeRedelivery counts and logs the redelivered message
+func (worker *Worker) observeRedelivery(ctx context.Context, m *types.Message) {
+	if reason, ok := worker.redeliveries.observe(m); ok {
+		worker.logEventWith(ctx, LogEventRedelivered, map[string]interface{}{"reason": string(reason)},
+			"worker: Received the redelivered message, id=%s, reason=%s", aws.ToString(m.MessageId), reason)
+	}
+}
@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedelivery(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+	delivery := func(id string, count string) *types.Message {
+		return &types.Message{
+			MessageId:     aws.String(id),
+			ReceiptHandle: aws.String(id),
+			Attributes:    map[string]string{string(types.MessageSystemAttributeNameApproximateReceiveCount): count},
+		}
+	}
+	failing := HandlerFunc(func(msg *types.Message) error { return errors.New("failed") })
+	succeeding := HandlerFunc(func(msg *types.Message) error { return nil })
+	ctx := context.Background()
+
+	_, _ = worker.handle(ctx, delivery("1", "1"), failing)
+	_, _ = worker.handle(ctx, delivery("1", "2"), succeeding)
+	_, _ = worker.handle(ctx, delivery("2", "3"), succeeding)
+	_, _ = worker.handle(ctx, delivery("3", "1"), succeeding)
+
+	assert.Equal(t, RedeliveryStats{HandlerError: 1, Unknown: 1}, worker.Stats().Redelivered)
+}
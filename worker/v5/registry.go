@@ -0,0 +1,195 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultRegistry is the process-wide Registry the workers are registered to while they are running
+var DefaultRegistry = NewRegistry()
+
+// Registry is the set of the workers in the process, so that one scrape endpoint covers all the queues a service consumes
+type Registry struct {
+	mu      sync.Mutex
+	workers map[*Worker]struct{}
+}
+
+// NewRegistry creates the empty Registry
+func NewRegistry() *Registry {
+	return &Registry{workers: map[*Worker]struct{}{}}
+}
+
+// Register adds the worker to the registry, it's done for the DefaultRegistry by Run
+func (r *Registry) Register(worker *Worker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.workers[worker] = struct{}{}
+}
+
+// Unregister removes the worker from the registry
+func (r *Registry) Unregister(worker *Worker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.workers, worker)
+}
+
+// WorkerSnapshot is the state of a registered worker
+type WorkerSnapshot struct {
+	QueueName string
+	QueueURL  string
+	Region    string
+	Running   bool
+	InFlight  int
+	Stats     Stats
+}
+
+// labels returns the Prometheus labels of the worker
+func (s WorkerSnapshot) labels() map[string]string {
+	return map[string]string{"queue": s.QueueName, "region": s.Region}
+}
+
+// Snapshot returns the state of the registered workers in the order of the queue name
+func (r *Registry) Snapshot() []WorkerSnapshot {
+	r.mu.Lock()
+	workers := make([]*Worker, 0, len(r.workers))
+	for w := range r.workers {
+		workers = append(workers, w)
+	}
+	r.mu.Unlock()
+	snapshots := make([]WorkerSnapshot, len(workers))
+	for i, w := range workers {
+		snapshots[i] = WorkerSnapshot{
+			QueueName: w.Config.QueueName,
+			QueueURL:  w.Config.QueueURL,
+			Region:    w.region(),
+			Running:   w.Running(),
+			InFlight:  w.inflight.count(),
+			Stats:     w.Stats(),
+		}
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if snapshots[i].QueueName == snapshots[j].QueueName {
+			return snapshots[i].Region < snapshots[j].Region
+		}
+		return snapshots[i].QueueName < snapshots[j].QueueName
+	})
+	return snapshots
+}
+
+// region returns the region of the queue by the Region or the queue URL
+func (worker *Worker) region() string {
+	if worker.Config.Region != "" {
+		return worker.Config.Region
+	}
+	if arn := strings.Split(queueARN(worker.Config.QueueURL), ":"); len(arn) == 6 {
+		return arn[3]
+	}
+	return ""
+}
+
+// registryMetric is a metric of the MetricsHandler
+type registryMetric struct {
+	name  string
+	kind  string
+	help  string
+	value func(s WorkerSnapshot) int64
+}
+
+var registryMetrics = []registryMetric{
+	{"sqs_worker_received_total", "counter", "The number of messages received from the queue", func(s WorkerSnapshot) int64 { return s.Stats.Received }},
+	{"sqs_worker_succeeded_total", "counter", "The number of messages handled and deleted successfully", func(s WorkerSnapshot) int64 { return s.Stats.Succeeded }},
+	{"sqs_worker_failed_total", "counter", "The number of messages that failed to be handled or deleted", func(s WorkerSnapshot) int64 { return s.Stats.Failed }},
+	{"sqs_worker_delete_failed_total", "counter", "The number of messages handled but failed to be deleted", func(s WorkerSnapshot) int64 { return s.Stats.DeleteFailed }},
+	{"sqs_worker_released_total", "counter", "The number of unfinished messages released on Handoff or shutdown", func(s WorkerSnapshot) int64 { return s.Stats.Released }},
+	{"sqs_worker_lost_ownership_total", "counter", "The number of handlers canceled since the visibility of the message expired", func(s WorkerSnapshot) int64 { return s.Stats.LostOwnership }},
+	{"sqs_worker_throttled_total", "counter", "The number of receives throttled by SQS", func(s WorkerSnapshot) int64 { return s.Stats.Throttled }},
+	{"sqs_worker_expired_total", "counter", "The number of messages older than the MaxMessageAge", func(s WorkerSnapshot) int64 { return s.Stats.Expired }},
+	{"sqs_worker_handler_retried_total", "counter", "The number of the in-process retries of the handler", func(s WorkerSnapshot) int64 { return s.Stats.HandlerRetried }},
+	{"sqs_worker_empty_receives_total", "counter", "The number of receives returning no messages", func(s WorkerSnapshot) int64 { return s.Stats.EmptyReceives }},
+	{"sqs_worker_non_empty_receives_total", "counter", "The number of receives returning messages", func(s WorkerSnapshot) int64 { return s.Stats.NonEmptyReceives }},
+	{"sqs_worker_leaked_handlers_total", "counter", "The number of the handlers still running after the shutdown", func(s WorkerSnapshot) int64 { return s.Stats.LeakedHandlers }},
+	{"sqs_worker_goroutines", "gauge", "The number of the goroutines started by the worker", func(s WorkerSnapshot) int64 { return s.Stats.Goroutines }},
+	{"sqs_worker_warm_pool_size", "gauge", "The number of the goroutines of the warm pool", func(s WorkerSnapshot) int64 { return s.Stats.WarmPoolSize }},
+	{"sqs_worker_warm_pool_busy", "gauge", "The number of the goroutines of the warm pool handling the messages", func(s WorkerSnapshot) int64 { return s.Stats.WarmPoolBusy }},
+	{"sqs_worker_in_flight", "gauge", "The number of messages being processed", func(s WorkerSnapshot) int64 { return int64(s.InFlight) }},
+	{"sqs_worker_running", "gauge", "The number of the workers polling the queue", func(s WorkerSnapshot) int64 {
+		if s.Running {
+			return 1
+		}
+		return 0
+	}},
+}
+
+// MetricsHandler returns the http.Handler serving the metrics of the registered workers in the Prometheus text format,
+// labeled by the queue and the region. The workers of the same queue are summed up.
+func (r *Registry) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		snapshots := r.Snapshot()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		for _, m := range registryMetrics {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+			var series []string
+			values := map[string]int64{}
+			for _, s := range snapshots {
+				labels := formatLabels(s.labels())
+				if _, ok := values[labels]; !ok {
+					series = append(series, labels)
+				}
+				values[labels] += m.value(s)
+			}
+			for _, labels := range series {
+				fmt.Fprintf(w, "%s%s %d\n", m.name, labels, values[labels])
+			}
+		}
+	})
+}
+
+// ServiceDiscoveryGroup is the target group of the Prometheus HTTP service discovery
+type ServiceDiscoveryGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// ServiceDiscoveryHandler returns the http.Handler serving the target group per queue of the registered workers
+// for the Prometheus http_sd_configs, targeting the MetricsHandler on the address (e.g. "10.0.0.1:8080").
+// The labels are prefixed by __meta_sqs_worker_ to be relabeled, e.g. to target the services by the queue.
+func (r *Registry) ServiceDiscoveryHandler(target string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		groups := []ServiceDiscoveryGroup{}
+		seen := map[string]bool{}
+		for _, s := range r.Snapshot() {
+			key := s.QueueName + "\x00" + s.Region
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			labels := map[string]string{}
+			for k, v := range s.labels() {
+				labels["__meta_sqs_worker_"+k] = v
+			}
+			groups = append(groups, ServiceDiscoveryGroup{Targets: []string{target}, Labels: labels})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(groups)
+	})
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// formatLabels formats the labels in the order of the names, e.g. {queue="my-sqs-queue",region="ap-northeast-1"}
+func formatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, labelValueEscaper.Replace(labels[name]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
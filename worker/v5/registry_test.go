@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	first := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+	second := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+	other := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "another-queue", Region: "ap-northeast-1"})
+	for _, w := range []*Worker{first, second, other} {
+		registry.Register(w)
+	}
+	h := HandlerFunc(func(msg *types.Message) error { return nil })
+	_, _ = first.handle(context.Background(), &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("1")}, h)
+	_, _ = second.handle(context.Background(), &types.Message{MessageId: aws.String("2"), ReceiptHandle: aws.String("2")}, h)
+
+	t.Run("snapshot", func(t *testing.T) {
+		snapshots := registry.Snapshot()
+		assert.Len(t, snapshots, 3)
+		assert.Equal(t, "another-queue", snapshots[0].QueueName)
+		assert.Equal(t, "ap-northeast-1", snapshots[0].Region)
+		assert.Equal(t, "eu-west-1", snapshots[1].Region, "the region is parsed from the queue URL")
+		assert.Equal(t, int64(1), snapshots[1].Stats.Succeeded)
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		registry.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		body := rec.Body.String()
+		assert.Contains(t, body, "# TYPE sqs_worker_succeeded_total counter\n")
+		assert.Contains(t, body, `sqs_worker_succeeded_total{queue="another-queue",region="ap-northeast-1"} 0`+"\n")
+		assert.Contains(t, body, `sqs_worker_succeeded_total{queue="my-sqs-queue",region="eu-west-1"} 2`+"\n", "the workers of the same queue are summed up")
+		assert.Contains(t, body, "# TYPE sqs_worker_in_flight gauge\n")
+	})
+
+	t.Run("service discovery", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		registry.ServiceDiscoveryHandler("10.0.0.1:8080").ServeHTTP(rec, httptest.NewRequest("GET", "/sd", nil))
+		var groups []ServiceDiscoveryGroup
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &groups))
+		assert.Equal(t, []ServiceDiscoveryGroup{
+			{Targets: []string{"10.0.0.1:8080"}, Labels: map[string]string{"__meta_sqs_worker_queue": "another-queue", "__meta_sqs_worker_region": "ap-northeast-1"}},
+			{Targets: []string{"10.0.0.1:8080"}, Labels: map[string]string{"__meta_sqs_worker_queue": "my-sqs-queue", "__meta_sqs_worker_region": "eu-west-1"}},
+		}, groups)
+	})
+
+	t.Run("unregister", func(t *testing.T) {
+		registry.Unregister(second)
+		assert.Len(t, registry.Snapshot(), 2)
+	})
+
+	t.Run("run registers to the default registry", func(t *testing.T) {
+		worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+		_, _, end, err := worker.begin(context.Background())
+		assert.NoError(t, err)
+		registered := func() bool {
+			DefaultRegistry.mu.Lock()
+			defer DefaultRegistry.mu.Unlock()
+			_, ok := DefaultRegistry.workers[worker]
+			return ok
+		}
+		assert.True(t, registered())
+		end()
+		worker.stopHandling()
+		assert.False(t, registered())
+	})
+}
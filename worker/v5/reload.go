@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"golang.org/x/time/rate"
+)
+
+// ConfigPatch is the set of tunables updated at runtime by UpdateConfig.
+// Nil fields are left unchanged.
+type ConfigPatch struct {
+	Concurrency        *int
+	RateLimit          *float64
+	MaxNumberOfMessage *int32
+	WaitTimeSecond     *int32
+	// WarmPool resizes the goroutines of the Config.WarmPool, which must be enabled at New
+	WarmPool *int
+}
+
+func (p *ConfigPatch) validate() error {
+	if p.Concurrency != nil && *p.Concurrency < 0 {
+		return fmt.Errorf("invalid Concurrency: %d", *p.Concurrency)
+	}
+	if p.RateLimit != nil && *p.RateLimit < 0 {
+		return fmt.Errorf("invalid RateLimit: %v", *p.RateLimit)
+	}
+	if p.MaxNumberOfMessage != nil && (*p.MaxNumberOfMessage < 1 || *p.MaxNumberOfMessage > 10) {
+		return fmt.Errorf("invalid MaxNumberOfMessage: %d", *p.MaxNumberOfMessage)
+	}
+	if p.WaitTimeSecond != nil && (*p.WaitTimeSecond < 0 || *p.WaitTimeSecond > 20) {
+		return fmt.Errorf("invalid WaitTimeSecond: %d", *p.WaitTimeSecond)
+	}
+	if p.WarmPool != nil && *p.WarmPool < 1 {
+		return fmt.Errorf("invalid WarmPool: %d", *p.WarmPool)
+	}
+	return nil
+}
+
+// newLimiter returns the limiter for the messages per second(0 means unlimited)
+func newLimiter(perSecond float64) *rate.Limiter {
+	l := rate.NewLimiter(rate.Inf, 1)
+	setRateLimit(l, perSecond)
+	return l
+}
+
+func setRateLimit(l *rate.Limiter, perSecond float64) {
+	if perSecond <= 0 {
+		l.SetLimit(rate.Inf)
+		return
+	}
+	l.SetLimit(rate.Limit(perSecond))
+	l.SetBurst(int(math.Max(1, math.Ceil(perSecond))))
+}
+
+// UpdateConfig applies the patch without stopping polling, or none of it when the patch is invalid.
+// The receive parameters take effect from the next poll, and the concurrency and the rate limit take effect immediately.
+func (worker *Worker) UpdateConfig(patch ConfigPatch) error {
+	if err := patch.validate(); err != nil {
+		return err
+	}
+	if patch.WarmPool != nil && worker.warm == nil {
+		return errors.New("worker: the WarmPool is not enabled")
+	}
+	worker.mu.Lock()
+	defer worker.mu.Unlock()
+	if patch.Concurrency != nil {
+		worker.Config.Concurrency = *patch.Concurrency
+		worker.sem.setLimit(*patch.Concurrency)
+	}
+	if patch.RateLimit != nil {
+		worker.Config.RateLimit = *patch.RateLimit
+		setRateLimit(worker.limiter, *patch.RateLimit)
+	}
+	if patch.WarmPool != nil {
+		worker.Config.WarmPool = *patch.WarmPool
+		worker.warm.resize(*patch.WarmPool)
+	}
+	if patch.MaxNumberOfMessage != nil {
+		worker.Config.MaxNumberOfMessage = *patch.MaxNumberOfMessage
+	}
+	if patch.WaitTimeSecond != nil {
+		worker.Config.WaitTimeSecond = *patch.WaitTimeSecond
+	}
+	worker.receiveInput = nil
+	return nil
+}
+
+// WatchConfig applies the patches from the channel until the context is done or the channel is closed
+func (worker *Worker) WatchConfig(ctx context.Context, patches <-chan ConfigPatch) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case patch, ok := <-patches:
+			if !ok {
+				return
+			}
+			if err := worker.UpdateConfig(patch); err != nil {
+				worker.Log.Warnf(ctx, "worker: Failed to update the config, err=%+v", err)
+				continue
+			}
+			worker.Log.Infof(ctx, "worker: Updated the config, concurrency=%d, rate_limit=%v, max_number_of_message=%d, wait_time_second=%d",
+				worker.Config.Concurrency, worker.Config.RateLimit, worker.Config.MaxNumberOfMessage, worker.Config.WaitTimeSecond)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateConfig(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+
+	t.Run("the tunables are updated", func(t *testing.T) {
+		concurrency, rateLimit := 2, 5.0
+		maxMessages, waitTime := int32(5), int32(3)
+		assert.NoError(t, worker.UpdateConfig(ConfigPatch{
+			Concurrency:        &concurrency,
+			RateLimit:          &rateLimit,
+			MaxNumberOfMessage: &maxMessages,
+			WaitTimeSecond:     &waitTime,
+		}))
+
+		params := worker.receiveParams()
+		assert.Equal(t, int32(5), params.MaxNumberOfMessages)
+		assert.Equal(t, int32(3), params.WaitTimeSeconds)
+		assert.Equal(t, 2, worker.sem.limit)
+		assert.Equal(t, 5.0, float64(worker.limiter.Limit()))
+	})
+
+	t.Run("the invalid patch is rejected", func(t *testing.T) {
+		maxMessages := int32(11)
+		assert.Error(t, worker.UpdateConfig(ConfigPatch{MaxNumberOfMessage: &maxMessages}))
+		assert.Equal(t, int32(5), worker.Config.MaxNumberOfMessage, "the config is unchanged")
+	})
+}
+
+func TestConcurrency(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", Concurrency: 2})
+	var running, peak int32
+	handlerFunc := HandlerFunc(func(msg *types.Message) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	})
+	messages := make([]types.Message, 6)
+	for i := range messages {
+		messages[i] = types.Message{Body: aws.String("body")}
+	}
+
+	worker.run(context.Background(), handlerFunc, messages)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&peak), "the handlers are limited by the concurrency")
+}
+
+func TestSemaphore(t *testing.T) {
+	s := newSemaphore(1)
+	assert.NoError(t, s.acquire(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, s.acquire(ctx), "the context is done while waiting")
+
+	acquired := make(chan struct{})
+	go func() {
+		_ = s.acquire(context.Background())
+		close(acquired)
+	}()
+	s.setLimit(2)
+	<-acquired
+}
@@ -0,0 +1,123 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// standardPricePerMillion is the price(USD) of a million requests of the standard queue without the free tier
+	standardPricePerMillion = 0.40
+	// fifoPricePerMillion is the price(USD) of a million requests of the FIFO queue without the free tier
+	fifoPricePerMillion = 0.50
+	// secondsPerMonth is the seconds of the 30-day month the cost is estimated for
+	secondsPerMonth = 30 * 24 * 60 * 60
+)
+
+// RequestBudget warns by LogEventRequestBudget when the monthly cost projected from the SQS requests in the Window
+// exceeds the MonthlyCost, e.g. when the short WaitTimeSecond makes the idle worker poll the empty queue frequently
+type RequestBudget struct {
+	// MonthlyCost is the budget(USD) of the requests of the worker per month
+	MonthlyCost float64
+	// PricePerMillion is the price(USD) of a million requests (default: 0.40, or 0.50 for the FIFO queue)
+	PricePerMillion float64
+	// Window is the period the request rate is measured in for the projection (default: 1 minute)
+	Window time.Duration
+}
+
+func (b *RequestBudget) populateDefaultValues() {
+	if b.Window <= 0 {
+		b.Window = time.Minute
+	}
+}
+
+// RequestStats is the snapshot of the SQS requests sent by the worker
+type RequestStats struct {
+	// Requests is the number of the requests by the operation, e.g. ReceiveMessage
+	Requests map[string]int64
+	// Total is the number of all the requests
+	Total int64
+	// Since is the time the worker started counting the requests
+	Since time.Time
+	// EstimatedMonthlyCost is the cost(USD) per month projected from the request rate since Since, without the free tier
+	EstimatedMonthlyCost float64
+}
+
+// requestCounter counts the SQS requests by the operation, and the requests in the current window of the RequestBudget
+type requestCounter struct {
+	mu          sync.Mutex
+	since       time.Time
+	counts      map[string]int64
+	windowStart time.Time
+	window      int64
+}
+
+// pricePerMillion returns the price of a million requests of the queue
+func (worker *Worker) pricePerMillion() float64 {
+	if b := worker.Config.RequestBudget; b != nil && b.PricePerMillion > 0 {
+		return b.PricePerMillion
+	}
+	if strings.HasSuffix(worker.Config.QueueName, ".fifo") {
+		return fifoPricePerMillion
+	}
+	return standardPricePerMillion
+}
+
+// monthlyCost projects the monthly cost of the requests in the elapsed time
+func (worker *Worker) monthlyCost(requests int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(requests) / elapsed.Seconds() * secondsPerMonth * worker.pricePerMillion() / 1e6
+}
+
+// countRequest counts the request of the operation, and checks the RequestBudget at the end of each window
+func (worker *Worker) countRequest(ctx context.Context, operation string) {
+	c := &worker.requests
+	now := worker.now()
+	c.mu.Lock()
+	if c.counts == nil {
+		c.counts = map[string]int64{}
+		c.since, c.windowStart = now, now
+	}
+	c.counts[operation]++
+	c.window++
+	budget := worker.Config.RequestBudget
+	if budget == nil || now.Sub(c.windowStart) < budget.Window {
+		c.mu.Unlock()
+		return
+	}
+	requests, elapsed := c.window, now.Sub(c.windowStart)
+	c.window, c.windowStart = 0, now
+	c.mu.Unlock()
+
+	cost := worker.monthlyCost(requests, elapsed)
+	if cost <= budget.MonthlyCost {
+		return
+	}
+	hint := ""
+	if worker.Config.WaitTimeSecond < 20 {
+		hint = fmt.Sprintf(", the WaitTimeSecond is %d and the empty receives return early, raise it up to 20 for the long polling", worker.Config.WaitTimeSecond)
+	}
+	worker.logEvent(ctx, LogEventRequestBudget, "worker: The requests exceed the budget, queue=%s, requests=%d in %s, estimated=$%.2f/month, budget=$%.2f/month%s",
+		worker.Config.QueueName, requests, elapsed.Truncate(time.Second), cost, budget.MonthlyCost, hint)
+}
+
+// RequestStats returns the SQS requests sent by the worker and their estimated monthly cost
+func (worker *Worker) RequestStats() RequestStats {
+	c := &worker.requests
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := RequestStats{Requests: make(map[string]int64, len(c.counts)), Since: c.since}
+	for op, n := range c.counts {
+		stats.Requests[op] = n
+		stats.Total += n
+	}
+	if stats.Total > 0 {
+		stats.EstimatedMonthlyCost = worker.monthlyCost(stats.Total, worker.since(c.since))
+	}
+	return stats
+}
@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/common/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// settableClock is the SystemClock whose Now is set by the test
+type settableClock struct {
+	SystemClock
+	now time.Time
+}
+
+func (c *settableClock) Now() time.Time {
+	return c.now
+}
+
+func TestRequestStats(t *testing.T) {
+	t.Run("counted by the operation", func(t *testing.T) {
+		clock := &settableClock{now: time.Now()}
+		worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", Clock: clock})
+		m := &types.Message{MessageId: aws.String("m"), ReceiptHandle: aws.String("m")}
+		assert.NoError(t, worker.deleteMessage(context.Background(), m))
+		assert.NoError(t, worker.deleteMessage(context.Background(), m))
+		clock.now = clock.now.Add(time.Second)
+
+		stats := worker.RequestStats()
+		assert.Equal(t, int64(2), stats.Requests["DeleteMessage"])
+		assert.GreaterOrEqual(t, stats.Total, int64(2))
+		// 2 requests a second for the month at $0.40 per million
+		assert.InDelta(t, float64(stats.Total)*secondsPerMonth*0.40/1e6, stats.EstimatedMonthlyCost, 1e-9)
+	})
+
+	t.Run("fifo price", func(t *testing.T) {
+		worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue.fifo"})
+		assert.Equal(t, fifoPricePerMillion, worker.pricePerMillion())
+		worker.Config.RequestBudget = &RequestBudget{PricePerMillion: 1}
+		assert.Equal(t, 1.0, worker.pricePerMillion(), "the PricePerMillion overrides the default price")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		worker := &Worker{Config: &Config{QueueName: "my-sqs-queue"}}
+		stats := worker.RequestStats()
+		assert.Zero(t, stats.Total)
+		assert.Zero(t, stats.EstimatedMonthlyCost)
+	})
+}
+
+func TestRequestBudget(t *testing.T) {
+	newWorker := func(monthlyCost float64) (*Worker, *settableClock, *bytes.Buffer) {
+		clock := &settableClock{now: time.Now()}
+		worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", Clock: clock, WaitTimeSecond: 1,
+			RequestBudget: &RequestBudget{MonthlyCost: monthlyCost, Window: time.Second}})
+		var buf bytes.Buffer
+		worker.Log.Output(&buf)
+		worker.Log.Level(logging.DebugLevel)
+		return worker, clock, &buf
+	}
+
+	t.Run("exceeded", func(t *testing.T) {
+		worker, clock, buf := newWorker(1)
+		// a million requests a second cost far more than $1 a month
+		worker.requests.window = 1000000
+		worker.countRequest(context.Background(), "ReceiveMessage")
+		assert.Empty(t, buf.String(), "the budget is checked at the end of the window")
+		clock.now = clock.now.Add(time.Second)
+		worker.countRequest(context.Background(), "ReceiveMessage")
+		assert.Contains(t, buf.String(), `"event":"request_budget"`)
+		assert.Contains(t, buf.String(), "WaitTimeSecond is 1")
+		assert.Zero(t, worker.requests.window, "the window restarts")
+	})
+
+	t.Run("within the budget", func(t *testing.T) {
+		worker, clock, buf := newWorker(1000)
+		worker.countRequest(context.Background(), "ReceiveMessage")
+		clock.now = clock.now.Add(time.Second)
+		worker.countRequest(context.Background(), "ReceiveMessage")
+		assert.NotContains(t, buf.String(), "request_budget")
+	})
+}
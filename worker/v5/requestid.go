@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"errors"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+// RequestIDFromError returns the AWS request ID and the HTTP status of the failed SDK call.
+// The SDK deserialization middleware attaches them to the error, so they are available without the SDK debug logging.
+// It returns false when the error has no response (e.g. a network error or a canceled context).
+func RequestIDFromError(err error) (requestID string, httpStatus int, ok bool) {
+	var re *awshttp.ResponseError
+	if !errors.As(err, &re) {
+		return "", 0, false
+	}
+	requestID = re.ServiceRequestID()
+	if re.Response != nil {
+		httpStatus = re.HTTPStatusCode()
+	}
+	return requestID, httpStatus, true
+}
+
+// requestFields returns the log fields of the AWS request ID and the HTTP status of the error
+func requestFields(err error) map[string]interface{} {
+	requestID, httpStatus, ok := RequestIDFromError(err)
+	if !ok {
+		return nil
+	}
+	return map[string]interface{}{"aws_request_id": requestID, "http_status": httpStatus}
+}
@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func responseError(status int, requestID string) error {
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+			Err:      errors.New("api error"),
+		},
+		RequestID: requestID,
+	}
+}
+
+type responseErrorSqsClient struct {
+	*mockedSqsClient
+}
+
+func (c *responseErrorSqsClient) DeleteMessage(ctx context.Context, input *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	return nil, &smithyOperationError{err: responseError(http.StatusForbidden, "request-1")}
+}
+
+// smithyOperationError wraps the error as the SDK client does for the operation
+type smithyOperationError struct {
+	err error
+}
+
+func (e *smithyOperationError) Error() string {
+	return fmt.Sprintf("operation error SQS: DeleteMessage, %v", e.err)
+}
+func (e *smithyOperationError) Unwrap() error { return e.err }
+
+func TestRequestIDFromError(t *testing.T) {
+	requestID, status, ok := RequestIDFromError(fmt.Errorf("wrapped: %w", responseError(http.StatusServiceUnavailable, "request-1")))
+	assert.True(t, ok)
+	assert.Equal(t, "request-1", requestID)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	_, _, ok = RequestIDFromError(errors.New("connection reset"))
+	assert.False(t, ok)
+}
+
+func TestRequestIDLogged(t *testing.T) {
+	client := &responseErrorSqsClient{&mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue"})
+	var buf bytes.Buffer
+	worker.Log.Output(&buf)
+
+	err := worker.handleMessage(context.Background(), &types.Message{ReceiptHandle: aws.String("receipt")}, HandlerFunc(func(msg *types.Message) error { return nil }))
+	assert.Error(t, err)
+
+	var line map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "handler_error", line["event"])
+	assert.Equal(t, "request-1", line["aws_request_id"])
+	assert.Equal(t, float64(http.StatusForbidden), line["http_status"])
+}
@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/go-sqs-poller/worker/v5/queue"
+)
+
+// maxDelaySeconds is the maximum DelaySeconds accepted by SQS
+const maxDelaySeconds = 900
+
+var errSendNotSupported = errors.New("the sqs client does not support SendMessage")
+
+// SenderAPI interface is optionally implemented by the client to re-send messages.
+// It's an alias of queue.Sender.
+type SenderAPI = queue.Sender
+
+func delaySeconds(d time.Duration) int32 {
+	sec := int32(d / time.Second)
+	if sec < 0 {
+		return 0
+	}
+	if sec > maxDelaySeconds {
+		return maxDelaySeconds
+	}
+	return sec
+}
+
+// Requeue re-publishes the message to the same queue with the delay(up to 15 minutes),
+// the incremented RetryAttemptAttribute and the lineage (HopCountAttribute, OriginMessageIDAttribute), then deletes the original.
+func (worker *Worker) Requeue(ctx context.Context, m *types.Message, delay time.Duration) error {
+	return worker.RequeueTo(ctx, worker.Config.QueueURL, m, delay)
+}
+
+// RequeueTo is the same as Requeue but re-publishes the message to the queue specified by queueURL
+func (worker *Worker) RequeueTo(ctx context.Context, queueURL string, m *types.Message, delay time.Duration) error {
+	return worker.requeue(ctx, queueURL, m, delay, RetryAttempt(m)+1)
+}
+
+func (worker *Worker) requeue(ctx context.Context, queueURL string, m *types.Message, delay time.Duration, attempt int) error {
+	if err := worker.resend(ctx, queueURL, m, delay, attempt, true); err != nil {
+		return err
+	}
+	if err := worker.deleteMessage(ctx, m); err != nil {
+		return fmt.Errorf("failed to delete the original message, err=%w", err)
+	}
+	return nil
+}
+
+// postpone re-sends the message to its queue with the delay, keeping the attempt and the HopCount since it's not a republish,
+// then deletes the original
+func (worker *Worker) postpone(ctx context.Context, m *types.Message, delay time.Duration) error {
+	if err := worker.resend(ctx, worker.Config.QueueURL, m, delay, RetryAttempt(m), false); err != nil {
+		return err
+	}
+	if err := worker.deleteMessage(ctx, m); err != nil {
+		return fmt.Errorf("failed to delete the original message, err=%w", err)
+	}
+	return nil
+}
+
+// resend sends the copy of the message with the attempt and the lineage to the queue, leaving the original as is.
+// The HopCount is incremented for the republish.
+func (worker *Worker) resend(ctx context.Context, queueURL string, m *types.Message, delay time.Duration, attempt int, republish bool) error {
+	if worker.tapped(ctx, m, "SendMessage") {
+		return nil
+	}
+	client, ok := worker.SqsClient.(SenderAPI)
+	if !ok {
+		return errSendNotSupported
+	}
+
+	attrs := make(map[string]types.MessageAttributeValue, len(m.MessageAttributes)+1)
+	for k, v := range m.MessageAttributes {
+		attrs[k] = v
+	}
+	attrs[RetryAttemptAttribute] = types.MessageAttributeValue{
+		DataType:    aws.String("Number"),
+		StringValue: aws.String(strconv.Itoa(attempt)),
+	}
+	setLineage(attrs, m, republish)
+	body, attrs, err := worker.fitMessage(ctx, aws.ToString(m.Body), attrs)
+	if err != nil {
+		return err
+	}
+	params := &sqs.SendMessageInput{
+		QueueUrl:          aws.String(queueURL),
+		MessageBody:       aws.String(body),
+		DelaySeconds:      delaySeconds(delay),
+		MessageAttributes: attrs,
+	}
+	setFIFOIDs(worker.Config.FIFOIDs, m, params)
+	sendCtx, finishSpan := worker.startClientSpan(ctx, "SendMessage")
+	_, err = client.SendMessage(sendCtx, params, worker.Config.sqsOptions()...)
+	finishSpan(err)
+	if err != nil {
+		return fmt.Errorf("failed to send the message, err=%w", err)
+	}
+	return nil
+}
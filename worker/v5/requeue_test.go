@@ -0,0 +1,41 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequeue(t *testing.T) {
+	client := &mockedSenderSqsClient{&mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue"})
+	m := &types.Message{Body: aws.String("body"), ReceiptHandle: aws.String("receipt")}
+
+	client.On("SendMessage", &sqs.SendMessageInput{
+		QueueUrl:     aws.String(worker.Config.QueueURL),
+		MessageBody:  aws.String("body"),
+		DelaySeconds: 120,
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			RetryAttemptAttribute: {DataType: aws.String("Number"), StringValue: aws.String("1")},
+			HopCountAttribute:     {DataType: aws.String("Number"), StringValue: aws.String("1")},
+		},
+	}).Return().Once()
+	client.On("DeleteMessage", &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(worker.Config.QueueURL),
+		ReceiptHandle: aws.String("receipt"),
+	}).Return().Once()
+
+	assert.NoError(t, worker.Requeue(context.Background(), m, 2*time.Minute))
+	client.AssertExpectations(t)
+}
+
+func TestDelaySeconds(t *testing.T) {
+	assert.Equal(t, int32(0), delaySeconds(-time.Second))
+	assert.Equal(t, int32(30), delaySeconds(30*time.Second))
+	assert.Equal(t, int32(900), delaySeconds(time.Hour))
+}
@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// RetryAttemptAttribute is the message attribute name storing the retry attempt count
+const RetryAttemptAttribute = "RetryAttempt"
+
+// RetryAttempt returns the retry attempt count of the message (0 for the first delivery)
+func RetryAttempt(m *types.Message) int {
+	attr, ok := m.MessageAttributes[RetryAttemptAttribute]
+	if !ok {
+		return 0
+	}
+	attempt, err := strconv.Atoi(aws.ToString(attr.StringValue))
+	if err != nil || attempt < 0 {
+		return 0
+	}
+	return attempt
+}
+
+// retryDelay returns the delay for the attempt(1-origin) from the tiered RetryDelays
+func (config *Config) retryDelay(attempt int) time.Duration {
+	idx := attempt - 1
+	if idx >= len(config.RetryDelays) {
+		idx = len(config.RetryDelays) - 1
+	}
+	return config.RetryDelays[idx]
+}
+
+// retryMessage re-sends the failed message to the retry queue and deletes the original
+func (worker *Worker) retryMessage(ctx context.Context, m *types.Message, cause error) (Outcome, error) {
+	attempt := RetryAttempt(m) + 1
+	if attempt > worker.Config.MaxRetryAttempts {
+		return OutcomeFailed, fmt.Errorf("worker: retry attempts exhausted(%d), message is left to the redrive policy: %w", attempt-1, cause)
+	}
+	if !worker.budget.allow() {
+		return worker.deadLetter(ctx, m, cause)
+	}
+	delay := worker.Config.retryDelay(attempt)
+	if err := worker.requeue(ctx, worker.Config.RetryQueueURL, m, delay, attempt); err != nil {
+		return OutcomeFailed, fmt.Errorf("worker: failed to retry the message, err=%+v: %w", err, cause)
+	}
+	return OutcomeRetried, fmt.Errorf("worker: message is scheduled for retry(attempt=%d, delay=%ds): %w", attempt, delaySeconds(delay), cause)
+}
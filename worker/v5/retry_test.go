@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockedSenderSqsClient struct {
+	*mockedSqsClient
+}
+
+func (c *mockedSenderSqsClient) SendMessage(ctx context.Context, input *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	c.Called(input)
+	return &sqs.SendMessageOutput{MessageId: aws.String("new-message-id")}, nil
+}
+
+func TestRetryMessage(t *testing.T) {
+	client := &mockedSenderSqsClient{&mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+	worker := New(context.Background(), client, &Config{
+		QueueName:      "my-sqs-queue",
+		RetryQueueName: "my-sqs-queue-retry",
+		RetryDelays:    []time.Duration{30 * time.Second, time.Hour},
+	})
+	failing := HandlerFunc(func(msg *types.Message) error { return errors.New("failure") })
+
+	t.Run("the retry queue url is resolved", func(t *testing.T) {
+		assert.Equal(t, "https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue-retry", worker.Config.RetryQueueURL)
+		assert.Equal(t, 2, worker.Config.MaxRetryAttempts, "MaxRetryAttempts has been set by default")
+	})
+
+	t.Run("the failed message is re-sent with the tiered delay", func(t *testing.T) {
+		client.On("SendMessage", mock.MatchedBy(func(in *sqs.SendMessageInput) bool {
+			return in.DelaySeconds == 900 && aws.ToString(in.MessageAttributes[RetryAttemptAttribute].StringValue) == "2" &&
+				aws.ToString(in.QueueUrl) == worker.Config.RetryQueueURL
+		})).Return().Once()
+		client.On("DeleteMessage", mock.Anything).Return().Once()
+
+		m := &types.Message{
+			Body:          aws.String("body"),
+			ReceiptHandle: aws.String("receipt"),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				RetryAttemptAttribute: {DataType: aws.String("Number"), StringValue: aws.String("1")},
+			},
+		}
+		err := worker.handleMessage(context.Background(), m, failing)
+		assert.Error(t, err)
+		client.AssertExpectations(t)
+	})
+
+	t.Run("the message is left when attempts are exhausted", func(t *testing.T) {
+		m := &types.Message{
+			Body: aws.String("body"),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				RetryAttemptAttribute: {DataType: aws.String("Number"), StringValue: aws.String("2")},
+			},
+		}
+		err := worker.handleMessage(context.Background(), m, failing)
+		assert.Error(t, err)
+		client.AssertNumberOfCalls(t, "SendMessage", 1)
+		client.AssertNumberOfCalls(t, "DeleteMessage", 1)
+	})
+}
+
+func TestRetryAttempt(t *testing.T) {
+	assert.Equal(t, 0, RetryAttempt(&types.Message{}))
+	assert.Equal(t, 3, RetryAttempt(&types.Message{MessageAttributes: map[string]types.MessageAttributeValue{
+		RetryAttemptAttribute: {DataType: aws.String("Number"), StringValue: aws.String("3")},
+	}}))
+}
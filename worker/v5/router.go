@@ -0,0 +1,194 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"golang.org/x/time/rate"
+)
+
+// RouteFunc derives the route (e.g. the message type) of a message
+type RouteFunc func(m *types.Message) string
+
+// AttributeRoute returns the RouteFunc reading the string message attribute
+func AttributeRoute(name string) RouteFunc {
+	return func(m *types.Message) string {
+		attr, ok := m.MessageAttributes[name]
+		if !ok {
+			return ""
+		}
+		return aws.ToString(attr.StringValue)
+	}
+}
+
+// Router is the Handler dispatching messages to the handler of their route.
+// A route can be paused (e.g. while its downstream API is down) while other routes keep processing,
+// the paused messages are returned to the queue with PauseDelay.
+type Router struct {
+	// PauseDelay is the delay of the paused messages (default: 1 minute)
+	PauseDelay time.Duration
+	// PauseRequeue re-queues the paused messages with the delay(up to 15 minutes) instead of extending the visibility
+	PauseRequeue bool
+	// RateLimitMaxWait is the maximum wait for the rate limit of the route (default: 1 second).
+	// The message waiting longer is returned to the queue like the paused route, so that it doesn't hold the concurrency of the other routes.
+	RateLimitMaxWait time.Duration
+
+	route    RouteFunc
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	fallback Handler
+	paused   map[string]struct{}
+	limiters map[string]*rate.Limiter
+}
+
+// NewRouter creates Router struct
+func NewRouter(route RouteFunc) *Router {
+	return &Router{
+		PauseDelay:       time.Minute,
+		route:            route,
+		handlers:         map[string]Handler{},
+		paused:           map[string]struct{}{},
+		limiters:         map[string]*rate.Limiter{},
+		RateLimitMaxWait: time.Second,
+	}
+}
+
+// Handle registers the handler of the route
+func (r *Router) Handle(route string, h Handler) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[route] = h
+	return r
+}
+
+// Default registers the handler of the messages without a registered route
+func (r *Router) Default(h Handler) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = h
+	return r
+}
+
+// RateLimit limits the messages per second of the route (0 removes the limit),
+// so that the slow downstream of a route doesn't dictate the throughput of the others sharing the queue.
+// It can be changed while the messages are processed.
+func (r *Router) RateLimit(route string, perSecond float64) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if perSecond <= 0 {
+		delete(r.limiters, route)
+		return r
+	}
+	if l, ok := r.limiters[route]; ok {
+		setRateLimit(l, perSecond)
+		return r
+	}
+	r.limiters[route] = newLimiter(perSecond)
+	return r
+}
+
+// Pause stops processing the messages of the route until Resume
+func (r *Router) Pause(route string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused[route] = struct{}{}
+}
+
+// Resume restarts processing the messages of the route
+func (r *Router) Resume(route string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.paused, route)
+}
+
+// Paused reports whether the route is paused
+func (r *Router) Paused(route string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.paused[route]
+	return ok
+}
+
+// HandleMessage handles the message with the background context
+func (r *Router) HandleMessage(msg *types.Message) error {
+	return r.HandleMessageWithContext(context.Background(), msg)
+}
+
+// HandleMessageWithContext dispatches the message to the handler of its route
+func (r *Router) HandleMessageWithContext(ctx context.Context, msg *types.Message) error {
+	route := r.route(msg)
+	r.mu.RLock()
+	h, ok := r.handlers[route]
+	if !ok {
+		h = r.fallback
+	}
+	_, paused := r.paused[route]
+	limiter := r.limiters[route]
+	r.mu.RUnlock()
+	if paused {
+		return &PausedError{Route: route, Delay: r.PauseDelay, Requeue: r.PauseRequeue}
+	}
+	if h == nil {
+		return NewInvalidEventError(route, "no handler is registered for the route")
+	}
+	if limiter != nil {
+		if err := r.waitRateLimit(ctx, route, limiter); err != nil {
+			return err
+		}
+	}
+	return callHandler(ctx, h, msg)
+}
+
+// waitRateLimit waits for the rate limit of the route up to the RateLimitMaxWait,
+// or returns the PausedError to return the message until the limit allows it
+func (r *Router) waitRateLimit(ctx context.Context, route string, limiter *rate.Limiter) error {
+	reservation := limiter.Reserve()
+	delay := reservation.Delay()
+	if delay > r.RateLimitMaxWait {
+		reservation.Cancel()
+		return &PausedError{Route: route, Delay: delay.Truncate(time.Second) + time.Second, Requeue: r.PauseRequeue}
+	}
+	if delay <= 0 {
+		return nil
+	}
+	timer := clockFromContext(ctx).NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		return ctx.Err()
+	}
+}
+
+// PausedError is returned by the handler to return the message to the queue without counting it as a failure
+type PausedError struct {
+	Route string
+	// Delay is the time until the message is received again
+	Delay time.Duration
+	// Requeue re-queues the message with the delay instead of extending the visibility
+	Requeue bool
+}
+
+func (e *PausedError) Error() string {
+	return fmt.Sprintf("route %q is paused", e.Route)
+}
+
+// pauseMessage returns the paused message to the queue by extending the visibility or re-queueing it
+func (worker *Worker) pauseMessage(ctx context.Context, m *types.Message, paused *PausedError) (Outcome, error) {
+	if paused.Requeue {
+		if err := worker.postpone(ctx, m, paused.Delay); err != nil {
+			return OutcomeFailed, fmt.Errorf("worker: failed to requeue the paused message, err=%+v: %w", err, paused)
+		}
+		return OutcomePaused, nil
+	}
+	if err := worker.changeVisibility(ctx, m, int32(paused.Delay/time.Second)); err != nil {
+		return OutcomeFailed, fmt.Errorf("worker: failed to extend the visibility of the paused message, err=%+v: %w", err, paused)
+	}
+	return OutcomePaused, nil
+}
@@ -0,0 +1,118 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func routedMessage(route string) *types.Message {
+	return &types.Message{
+		MessageId:         aws.String(route),
+		Body:              aws.String(route),
+		ReceiptHandle:     aws.String("receipt-" + route),
+		MessageAttributes: map[string]types.MessageAttributeValue{"Type": {DataType: aws.String("String"), StringValue: aws.String(route)}},
+	}
+}
+
+func TestRouter(t *testing.T) {
+	handled := []string{}
+	record := HandlerFunc(func(msg *types.Message) error {
+		handled = append(handled, aws.ToString(msg.Body))
+		return nil
+	})
+	router := NewRouter(AttributeRoute("Type")).Handle("aws", record).Handle("google", record)
+
+	assert.NoError(t, router.HandleMessage(routedMessage("aws")))
+	router.Pause("google")
+	assert.True(t, router.Paused("google"))
+	err := router.HandleMessage(routedMessage("google"))
+	assert.Equal(t, &PausedError{Route: "google", Delay: time.Minute}, err)
+	assert.IsType(t, InvalidEventError{}, router.HandleMessage(routedMessage("unknown")), "no handler")
+
+	router.Resume("google")
+	router.Default(record)
+	assert.NoError(t, router.HandleMessage(routedMessage("google")))
+	assert.NoError(t, router.HandleMessage(routedMessage("unknown")))
+	assert.Equal(t, []string{"aws", "google", "unknown"}, handled)
+}
+
+func TestPausedRoute(t *testing.T) {
+	client := &mockedVisibilitySqsClient{&mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+	client.On("DeleteMessage", &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String("https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue"),
+		ReceiptHandle: aws.String("receipt-aws"),
+	}).Return().Once()
+	client.On("ChangeMessageVisibility", &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String("https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue"),
+		ReceiptHandle:     aws.String("receipt-google"),
+		VisibilityTimeout: 300,
+	}).Return().Once()
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue"})
+	ok := HandlerFunc(func(msg *types.Message) error { return nil })
+	router := NewRouter(AttributeRoute("Type")).Handle("aws", ok).Handle("google", ok)
+	router.PauseDelay = 5 * time.Minute
+	router.Pause("google")
+
+	outcome, err := worker.handle(context.Background(), routedMessage("aws"), router)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeSucceeded, outcome)
+	outcome, err = worker.handle(context.Background(), routedMessage("google"), router)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomePaused, outcome)
+
+	client.AssertExpectations(t)
+	stats := worker.Stats()
+	assert.Equal(t, int64(1), stats.Succeeded)
+	assert.Equal(t, int64(0), stats.Failed, "the paused message is not a failure")
+}
+
+func TestPausedRouteRequeue(t *testing.T) {
+	client := &mockedSenderSqsClient{&mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+	client.On("SendMessage", mock.MatchedBy(func(in *sqs.SendMessageInput) bool {
+		return in.DelaySeconds == 120 && aws.ToString(in.QueueUrl) == "https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue" &&
+			aws.ToString(in.MessageAttributes[HopCountAttribute].StringValue) == "0"
+	})).Return().Once()
+	client.On("DeleteMessage", mock.Anything).Return().Once()
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue"})
+	router := NewRouter(AttributeRoute("Type"))
+	router.PauseDelay = 2 * time.Minute
+	router.PauseRequeue = true
+	router.Pause("google")
+
+	outcome, err := worker.handle(context.Background(), routedMessage("google"), router)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomePaused, outcome)
+	client.AssertExpectations(t)
+}
+
+func TestRouteRateLimit(t *testing.T) {
+	record := HandlerFunc(func(msg *types.Message) error { return nil })
+	router := NewRouter(AttributeRoute("Type")).Handle("aws", record).Handle("google", record).RateLimit("aws", 0.5)
+	router.RateLimitMaxWait = 100 * time.Millisecond
+
+	assert.NoError(t, router.HandleMessage(routedMessage("aws")))
+	err := router.HandleMessage(routedMessage("aws"))
+	if paused, ok := err.(*PausedError); assert.True(t, ok, "the message exceeding the limit is returned to the queue") {
+		assert.Equal(t, "aws", paused.Route)
+		assert.Equal(t, 2*time.Second, paused.Delay, "the delay is until the limit allows the message")
+	}
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, router.HandleMessage(routedMessage("google")), "the other routes are not limited")
+	}
+
+	router.RateLimit("aws", 20)
+	start := time.Now()
+	assert.NoError(t, router.HandleMessage(routedMessage("aws")))
+	assert.NoError(t, router.HandleMessage(routedMessage("aws")))
+	assert.True(t, time.Since(start) < 200*time.Millisecond, "the short wait is waited")
+
+	router.RateLimit("aws", 0)
+	assert.NoError(t, router.HandleMessage(routedMessage("aws")))
+}
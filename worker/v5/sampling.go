@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"sync"
+	"time"
+)
+
+// LogSampling is the rate-based sampling of the repetitive logs.
+// In each Tick, the first First logs of an event class are emitted, and then every Thereafter-th log.
+type LogSampling struct {
+	// Events is the sampled event classes (default: empty_receive, received, deleted)
+	Events []LogEvent
+	// Tick is the sampling window (default: 1 second)
+	Tick time.Duration
+	// First is the number of logs emitted unconditionally in each Tick (default: 10)
+	First int
+	// Thereafter is the sampling interval after First, 0 means the default of 100, a negative value drops all logs after First
+	Thereafter int
+}
+
+func (s *LogSampling) populateDefaultValues() {
+	if s.Events == nil {
+		s.Events = []LogEvent{LogEventEmptyReceive, LogEventReceived, LogEventDeleted}
+	}
+	if s.Tick <= 0 {
+		s.Tick = time.Second
+	}
+	if s.First <= 0 {
+		s.First = 10
+	}
+	if s.Thereafter < 0 {
+		s.Thereafter = 0
+	} else if s.Thereafter == 0 {
+		s.Thereafter = 100
+	}
+}
+
+type sampler struct {
+	config   *LogSampling
+	mu       sync.Mutex
+	counters map[LogEvent]*sampleCounter
+	now      func() time.Time
+}
+
+type sampleCounter struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+func newSampler(config *LogSampling) *sampler {
+	if config == nil {
+		return nil
+	}
+	config.populateDefaultValues()
+	s := &sampler{config: config, counters: map[LogEvent]*sampleCounter{}, now: time.Now}
+	for _, e := range config.Events {
+		s.counters[e] = &sampleCounter{}
+	}
+	return s
+}
+
+// sample reports whether the log should be emitted, with the number of logs suppressed since the last emitted one
+func (s *sampler) sample(event LogEvent) (bool, int) {
+	if s == nil {
+		return true, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.counters[event]
+	if !ok {
+		return true, 0
+	}
+	now := s.now()
+	if now.Sub(c.start) >= s.config.Tick {
+		c.start = now
+		c.count = 0
+	}
+	c.count++
+	if c.count <= s.config.First ||
+		(s.config.Thereafter > 0 && (c.count-s.config.First)%s.config.Thereafter == 0) {
+		suppressed := c.suppressed
+		c.suppressed = 0
+		return true, suppressed
+	}
+	c.suppressed++
+	return false, 0
+}
@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampler(t *testing.T) {
+	now := time.Unix(1650000000, 0)
+	s := newSampler(&LogSampling{First: 2, Thereafter: 3})
+	s.now = func() time.Time { return now }
+
+	var emitted []int
+	var suppressed []int
+	for i := 1; i <= 8; i++ {
+		if ok, n := s.sample(LogEventDeleted); ok {
+			emitted = append(emitted, i)
+			suppressed = append(suppressed, n)
+		}
+	}
+	assert.Equal(t, []int{1, 2, 5, 8}, emitted, "the first logs and then every 3rd log are emitted")
+	assert.Equal(t, []int{0, 0, 2, 2}, suppressed)
+
+	ok, _ := s.sample(LogEventHandlerError)
+	assert.True(t, ok, "the events not configured are never sampled")
+
+	now = now.Add(time.Second)
+	ok, _ = s.sample(LogEventDeleted)
+	assert.True(t, ok, "the counter is reset on the next tick")
+
+	var nilSampler *sampler
+	ok, _ = nilSampler.sample(LogEventDeleted)
+	assert.True(t, ok, "the sampling is disabled by default")
+}
+
+func TestSamplerThereafter(t *testing.T) {
+	emitted := func(thereafter int) int {
+		s := newSampler(&LogSampling{First: 1, Thereafter: thereafter})
+		s.now = func() time.Time { return time.Unix(1650000000, 0) }
+		n := 0
+		for i := 0; i < 301; i++ {
+			if ok, _ := s.sample(LogEventDeleted); ok {
+				n++
+			}
+		}
+		return n
+	}
+	assert.Equal(t, 4, emitted(0), "0 means the default of 100")
+	assert.Equal(t, 1, emitted(-1), "a negative value drops all logs after First")
+}
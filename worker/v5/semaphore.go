@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// semaphore limits the number of concurrent handlers, and its limit can be resized at runtime
+type semaphore struct {
+	mu      sync.Mutex
+	limit   int // 0 means unlimited
+	inUse   int
+	waiting int
+	changed chan struct{}
+}
+
+func newSemaphore(limit int) *semaphore {
+	return &semaphore{limit: limit, changed: make(chan struct{})}
+}
+
+// acquire blocks until a slot is available or the context is done
+func (s *semaphore) acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	for {
+		s.mu.Lock()
+		if s.limit <= 0 || s.inUse < s.limit {
+			s.inUse++
+			s.mu.Unlock()
+			return nil
+		}
+		changed := s.changed
+		s.waiting++
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+			s.mu.Lock()
+			s.waiting--
+			s.mu.Unlock()
+		case <-ctx.Done():
+			s.mu.Lock()
+			s.waiting--
+			s.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *semaphore) release() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inUse--
+	s.notify()
+}
+
+// setLimit resizes the semaphore, the running handlers over the new limit are not interrupted
+func (s *semaphore) setLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = limit
+	s.notify()
+}
+
+// notify wakes up all waiters, it must be called with the lock held
+func (s *semaphore) notify() {
+	if s.waiting == 0 {
+		return
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
@@ -0,0 +1,129 @@
+package worker
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+
+	"github.com/ca-risken/common/pkg/logging"
+)
+
+// Membership is the coordination of the replicas consuming the sharded queues, e.g. backed by heartbeat leases in DynamoDB.
+// The replica registers itself on its own, and Members returns the IDs of the live replicas including this one.
+type Membership interface {
+	Members(ctx context.Context) ([]string, error)
+}
+
+// MembershipFunc is used to define the Membership
+type MembershipFunc func(ctx context.Context) ([]string, error)
+
+// Members wraps a function returning the live replicas
+func (f MembershipFunc) Members(ctx context.Context) ([]string, error) {
+	return f(ctx)
+}
+
+// AssignShards returns the shards owned by the member by the rendezvous hashing.
+// Every member with the same view of the members assigns each shard to exactly one member,
+// and only the shards of the joined or left members move on the change.
+func AssignShards(shards, members []string, member string) []string {
+	var owned []string
+	for _, shard := range shards {
+		var owner string
+		var best uint64
+		for _, m := range members {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(shard))
+			_, _ = h.Write([]byte{0})
+			_, _ = h.Write([]byte(m))
+			if score := h.Sum64(); owner == "" || score > best || (score == best && m < owner) {
+				owner, best = m, score
+			}
+		}
+		if owner == member {
+			owned = append(owned, shard)
+		}
+	}
+	return owned
+}
+
+// ShardedQueues consumes the shards of the sharded queues (e.g. queue-0..queue-N) assigned to this replica,
+// so that each queue has exactly one active consumer among the replicas.
+// The assignment is refreshed at every Interval, and the shards moved to another replica are stopped.
+// The workers drain their in-flight messages on the move, and both replicas may receive from the moved shard
+// until the next refresh of the old owner; use the ProcessingLock when the handler must not overlap.
+type ShardedQueues struct {
+	Log logging.Logger
+	// ReplicaID is the ID of this replica in the Membership
+	ReplicaID string
+	// Shards is the names of the sharded queues
+	Shards []string
+	// Template is the config copied for the worker of each shard with the QueueName of the shard
+	Template Config
+	// Interval is the interval of refreshing the assignment on the Clock of the Template (default: 30 seconds)
+	Interval time.Duration
+
+	client     QueueAPI
+	membership Membership
+	group      *workerGroup
+}
+
+// NewShardedQueues creates ShardedQueues struct consuming the shards assigned to the replica
+func NewShardedQueues(client QueueAPI, membership Membership, replicaID string, shards []string, template Config) *ShardedQueues {
+	return &ShardedQueues{
+		Log:        logging.NewLogger(),
+		ReplicaID:  replicaID,
+		Shards:     shards,
+		Template:   template,
+		Interval:   30 * time.Second,
+		client:     client,
+		membership: membership,
+		group:      newWorkerGroup("shard"),
+	}
+}
+
+// Run consumes the assigned shards with the handler until the context is done, and returns after all workers stopped
+func (s *ShardedQueues) Run(ctx context.Context, h Handler) {
+	ticker := s.Template.clock().NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		s.assign(ctx, h)
+		select {
+		case <-ctx.Done():
+			s.group.wait()
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// assign runs the workers of the shards assigned to this replica
+func (s *ShardedQueues) assign(ctx context.Context, h Handler) {
+	members, err := s.membership.Members(ctx)
+	if err != nil {
+		// the current assignment is kept until the membership is available
+		s.Log.Warnf(ctx, "shard: Failed to get the members, replica=%s, err=%+v", s.ReplicaID, err)
+		return
+	}
+	want := map[string]func() *Worker{}
+	for _, shard := range AssignShards(s.Shards, members, s.ReplicaID) {
+		shard := shard
+		want[shard] = func() *Worker {
+			config := s.Template
+			config.QueueName = shard
+			return New(ctx, s.client, &config)
+		}
+	}
+	s.group.reconcile(ctx, s.Log, h, want)
+}
+
+// Assigned returns the names of the shards consumed by this replica
+func (s *ShardedQueues) Assigned() []string {
+	running := s.group.snapshot()
+	shards := make([]string, 0, len(running))
+	for _, shard := range s.Shards {
+		if _, ok := running[shard]; ok {
+			shards = append(shards, shard)
+		}
+	}
+	return shards
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssignShards(t *testing.T) {
+	shards := make([]string, 16)
+	for i := range shards {
+		shards[i] = fmt.Sprintf("queue-%d", i)
+	}
+	members := []string{"replica-a", "replica-b", "replica-c"}
+
+	owners := map[string]string{}
+	for _, m := range members {
+		for _, shard := range AssignShards(shards, members, m) {
+			_, dup := owners[shard]
+			assert.False(t, dup, "each shard has exactly one owner")
+			owners[shard] = m
+		}
+	}
+	assert.Len(t, owners, len(shards))
+
+	// only the shards of the left member move
+	for _, m := range members[:2] {
+		for _, shard := range AssignShards(shards, members[:2], m) {
+			if owners[shard] != "replica-c" {
+				assert.Equal(t, owners[shard], m)
+			}
+		}
+	}
+	assert.Empty(t, AssignShards(shards, members, "replica-z"))
+}
+
+func TestShardedQueues(t *testing.T) {
+	var mu sync.Mutex
+	members := []string{"replica-a"}
+	var membershipErr error
+	membership := MembershipFunc(func(ctx context.Context) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return members, membershipErr
+	})
+	shards := []string{"queue-0", "queue-1", "queue-2", "queue-3"}
+	s := NewShardedQueues(&blockingReceiveSqsClient{polling: make(chan struct{}, 1)}, membership, "replica-a", shards, Config{})
+	s.Interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx, HandlerFunc(func(msg *types.Message) error { return nil }))
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return len(s.Assigned()) == 4 }, time.Second, 5*time.Millisecond, "the only replica owns all shards")
+
+	mu.Lock()
+	members = []string{"replica-a", "replica-b"}
+	mu.Unlock()
+	want := AssignShards(shards, []string{"replica-a", "replica-b"}, "replica-a")
+	assert.Eventually(t, func() bool { return assert.ObjectsAreEqual(want, s.Assigned()) }, time.Second, 5*time.Millisecond,
+		"the shards of the joined replica are stopped")
+
+	mu.Lock()
+	membershipErr = errors.New("dynamodb is down")
+	mu.Unlock()
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, want, s.Assigned(), "the assignment is kept while the membership is unavailable")
+
+	cancel()
+	<-done
+}
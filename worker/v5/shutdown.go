@@ -0,0 +1,174 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// ShutdownReport is the result of the shutdown after the context of Start is done.
+// It lets operators verify that the deployments are not silently abandoning the work.
+type ShutdownReport struct {
+	QueueName string
+	// InFlight is the number of messages in flight at the cancel
+	InFlight int
+	// Drained is the number of in-flight messages finished within the DrainTimeout
+	Drained int
+	// VisibilityReset is the number of unfinished messages made visible for other replicas
+	VisibilityReset int
+	// ResetFailed is the number of unfinished messages that failed to be made visible
+	ResetFailed int
+	// Abandoned is the number of messages still running after the DrainTimeout with KeepVisibilityOnShutdown
+	Abandoned int
+	// DeleteFailed is the number of messages failed to be deleted during the drain
+	DeleteFailed int
+	// Leaked is the handlers still running the LeakGracePeriod after their context was canceled in the ShutdownClose
+	Leaked []LeakedHandler
+	// Duration is the time spent for the shutdown
+	Duration time.Duration
+	// Phases is the time spent for each ShutdownPhase in the order
+	Phases []ShutdownPhaseTiming
+}
+
+// ShutdownPhase is the phase of the shutdown after the context of Start is done, run in the order of the constants.
+// Hooks.OnShutdownPhase is called at the end of each phase, so that the application interleaves its own teardown
+// at the right point, e.g. flushing the result buffers after ShutdownWaitHandlers.
+type ShutdownPhase string
+
+const (
+	// ShutdownStopReceiving stops the receives, and the messages queued for the Workers are released
+	ShutdownStopReceiving ShutdownPhase = "stop_receiving"
+	// ShutdownWaitHandlers waits for the in-flight handlers up to the DrainTimeout,
+	// and makes the unfinished messages visible unless KeepVisibilityOnShutdown
+	ShutdownWaitHandlers ShutdownPhase = "wait_handlers"
+	// ShutdownFlushDeletes sends the deletes buffered by the DeleteBatching
+	ShutdownFlushDeletes ShutdownPhase = "flush_deletes"
+	// ShutdownClose cancels the context of the handlers still running, waits the LeakGracePeriod for them to return
+	// and reports the leaked ones, then the OnShutdown hook follows
+	ShutdownClose ShutdownPhase = "close"
+)
+
+// ShutdownPhaseTiming is the time spent for a ShutdownPhase, excluding the OnShutdownPhase hook
+type ShutdownPhaseTiming struct {
+	Phase    ShutdownPhase
+	Duration time.Duration
+}
+
+// ShutdownReport returns the report of the last shutdown, nil if the worker has not been stopped
+func (worker *Worker) ShutdownReport() *ShutdownReport {
+	worker.mu.Lock()
+	defer worker.mu.Unlock()
+	return worker.shutdownReport
+}
+
+// shutdownPhase runs the phase of the shutdown, and records its time before calling the OnShutdownPhase hook
+func (worker *Worker) shutdownPhase(ctx context.Context, report *ShutdownReport, phase ShutdownPhase, run func()) {
+	start := time.Now()
+	run()
+	elapsed := time.Since(start)
+	report.Phases = append(report.Phases, ShutdownPhaseTiming{Phase: phase, Duration: elapsed})
+	worker.Log.Debugf(ctx, "worker: Finished the shutdown phase, queue=%s, phase=%s, duration=%s", report.QueueName, phase, elapsed)
+	if worker.Config.Hooks.OnShutdownPhase != nil {
+		worker.Config.Hooks.OnShutdownPhase(ctx, phase, report)
+	}
+}
+
+// handlerContext returns the context of the handlers detached from the cancellation of ctx,
+// so that the in-flight handlers finish and delete their messages while the worker drains instead of failing with the polling.
+// It's canceled by the returned function after the drain, so the handlers still running see the cancellation
+// after their messages are made visible again.
+func handlerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithCancel(withoutCancel{ctx})
+}
+
+// stopHandlingAfter cancels the handlers the DrainTimeout after the end of ctx for the synchronous runs without the shutdown,
+// e.g. DrainOnce, until the returned function is called
+func (worker *Worker) stopHandlingAfter(ctx context.Context) func() {
+	stopped := make(chan struct{})
+	worker.goOwned(func() {
+		select {
+		case <-stopped:
+			return
+		case <-ctx.Done():
+		}
+		timer := worker.clock().NewTimer(worker.Config.DrainTimeout)
+		defer timer.Stop()
+		select {
+		case <-stopped:
+		case <-timer.C():
+			worker.stopHandling()
+		}
+	})
+	return func() { close(stopped) }
+}
+
+// stopHandling cancels the handlers still running after the drain
+func (worker *Worker) stopHandling() {
+	worker.mu.Lock()
+	stop := worker.stopHandlers
+	worker.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
+// shutdown drains the in-flight messages after the context is done, in the order of the ShutdownPhase.
+// The messages still running at the DrainTimeout are made visible immediately unless KeepVisibilityOnShutdown,
+// so that another replica picks them up instead of waiting out the full visibility timeout.
+func (worker *Worker) shutdown(ctx context.Context) *ShutdownReport {
+	drainCtx := withoutCancel{ctx}
+	start := time.Now()
+	report := &ShutdownReport{QueueName: worker.Config.QueueName, InFlight: worker.inflight.count()}
+	released := atomic.LoadInt64(&worker.stats.released)
+	deleteFailed := atomic.LoadInt64(&worker.stats.deleteFailed)
+
+	worker.shutdownPhase(drainCtx, report, ShutdownStopReceiving, func() {
+		worker.mu.Lock()
+		if worker.stopPolling != nil {
+			worker.stopPolling()
+		}
+		worker.mu.Unlock()
+	})
+	worker.shutdownPhase(drainCtx, report, ShutdownWaitHandlers, func() {
+		if worker.Config.KeepVisibilityOnShutdown {
+			if !worker.inflight.wait(worker.clock(), worker.Config.DrainTimeout) {
+				report.Abandoned = worker.inflight.count()
+			}
+			return
+		}
+		result := worker.drain(drainCtx, worker.Config.DrainTimeout, 0)
+		report.ResetFailed = result.ReleaseFailed
+	})
+	worker.shutdownPhase(drainCtx, report, ShutdownFlushDeletes, func() {
+		if worker.deletes != nil {
+			worker.deletes.flushDue()
+		}
+		// the queued messages of the work pool are released apart from the drain
+		report.VisibilityReset = int(atomic.LoadInt64(&worker.stats.released) - released)
+		report.Drained = report.InFlight - report.VisibilityReset - report.ResetFailed - report.Abandoned
+		if report.Drained < 0 {
+			report.Drained = 0
+		}
+		report.DeleteFailed = int(atomic.LoadInt64(&worker.stats.deleteFailed) - deleteFailed)
+	})
+	worker.shutdownPhase(drainCtx, report, ShutdownClose, func() {
+		worker.stopHandling()
+		report.Leaked = worker.detectLeaks(drainCtx)
+	})
+	report.Duration = time.Since(start)
+
+	worker.mu.Lock()
+	worker.shutdownReport = report
+	worker.mu.Unlock()
+	if report.ResetFailed > 0 || report.Abandoned > 0 || report.DeleteFailed > 0 {
+		worker.Log.Warnf(drainCtx, "worker: Shutdown finished with unfinished work, queue=%s, in_flight=%d, drained=%d, visibility_reset=%d, reset_failed=%d, abandoned=%d, delete_failed=%d",
+			report.QueueName, report.InFlight, report.Drained, report.VisibilityReset, report.ResetFailed, report.Abandoned, report.DeleteFailed)
+	} else {
+		worker.Log.Infof(drainCtx, "worker: Shutdown finished, queue=%s, in_flight=%d, drained=%d, visibility_reset=%d",
+			report.QueueName, report.InFlight, report.Drained, report.VisibilityReset)
+	}
+	if worker.Config.Hooks.OnShutdown != nil {
+		worker.Config.Hooks.OnShutdown(drainCtx, report)
+	}
+	return report
+}
@@ -0,0 +1,175 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingDeleteSqsClient struct {
+	nopSqsClient
+}
+
+func (c *failingDeleteSqsClient) DeleteMessage(ctx context.Context, input *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	return nil, errors.New("delete failure")
+}
+
+func TestShutdownReport(t *testing.T) {
+	client := &failingDeleteSqsClient{}
+	var hooked *ShutdownReport
+	worker := New(context.Background(), client, &Config{
+		QueueName:                "my-sqs-queue",
+		DrainTimeout:             time.Second,
+		KeepVisibilityOnShutdown: true,
+		Hooks: Hooks{
+			OnShutdown: func(ctx context.Context, report *ShutdownReport) { hooked = report },
+		},
+	})
+	assert.Nil(t, worker.ShutdownReport())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		_ = worker.handleMessage(withoutCancel{ctx}, &types.Message{ReceiptHandle: aws.String("receipt")}, HandlerFunc(func(msg *types.Message) error {
+			close(started)
+			<-release
+			return nil
+		}))
+		close(done)
+	}()
+	<-started
+	cancel()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+
+	report := worker.shutdown(ctx)
+	<-done
+	assert.Equal(t, &ShutdownReport{
+		QueueName:    "my-sqs-queue",
+		InFlight:     1,
+		Drained:      1,
+		DeleteFailed: 1,
+		Duration:     report.Duration,
+		Phases:       report.Phases,
+	}, report)
+	assert.Same(t, report, hooked)
+	assert.Same(t, report, worker.ShutdownReport())
+	assert.Equal(t, int64(1), worker.Stats().DeleteFailed)
+}
+
+// ctxDeleteSqsClient records the error of the context of each delete
+type ctxDeleteSqsClient struct {
+	batchSqsClient
+	deletes chan error
+}
+
+func (c *ctxDeleteSqsClient) DeleteMessage(ctx context.Context, input *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	c.deletes <- ctx.Err()
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func TestShutdownHandlerContext(t *testing.T) {
+	newClient := func() *ctxDeleteSqsClient {
+		client := &ctxDeleteSqsClient{batchSqsClient{batches: make(chan []types.Message, 1)}, make(chan error, 1)}
+		client.batches <- []types.Message{{MessageId: aws.String("m"), ReceiptHandle: aws.String("m")}}
+		return client
+	}
+
+	for _, workers := range []int{0, 2} {
+		t.Run(fmt.Sprintf("drained workers=%d", workers), func(t *testing.T) {
+			client := newClient()
+			worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Workers: workers, DrainTimeout: time.Minute})
+			ctx, cancel := context.WithCancel(context.Background())
+			started := make(chan struct{})
+			handlerErr := make(chan error, 1)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				worker.Start(ctx, ContextHandlerFunc(func(handlerCtx context.Context, msg *types.Message) error {
+					close(started)
+					<-ctx.Done()
+					handlerErr <- handlerCtx.Err()
+					return nil
+				}))
+			}()
+			<-started
+			cancel()
+			<-done
+			assert.NoError(t, <-handlerErr, "the handler isn't canceled with the polling")
+			assert.NoError(t, <-client.deletes, "the drained message is deleted")
+			assert.Equal(t, int64(1), worker.Stats().Succeeded)
+		})
+	}
+
+	t.Run("drain timeout", func(t *testing.T) {
+		worker := New(context.Background(), newClient(), &Config{QueueName: "my-sqs-queue", DrainTimeout: 10 * time.Millisecond, KeepVisibilityOnShutdown: true})
+		ctx, cancel := context.WithCancel(context.Background())
+		started := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			worker.Start(ctx, ContextHandlerFunc(func(handlerCtx context.Context, msg *types.Message) error {
+				close(started)
+				<-handlerCtx.Done()
+				return handlerCtx.Err()
+			}))
+		}()
+		<-started
+		cancel()
+		<-done
+		assert.Equal(t, 1, worker.ShutdownReport().Abandoned, "the stuck handler is canceled after the drain")
+	})
+}
+
+func TestShutdownPhases(t *testing.T) {
+	client := &batchDeleteSqsClient{mockedSqsClient: &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}}
+	var phases []ShutdownPhase
+	flushedAtWait := -1
+	worker := New(context.Background(), client, &Config{
+		QueueName:      "my-sqs-queue",
+		DeleteBatching: &DeleteBatching{MaxWait: time.Hour, VisibilityTimeout: time.Hour},
+		Hooks: Hooks{
+			OnShutdownPhase: func(ctx context.Context, phase ShutdownPhase, report *ShutdownReport) {
+				phases = append(phases, phase)
+				if phase == ShutdownWaitHandlers {
+					client.mu.Lock()
+					flushedAtWait = len(client.batches)
+					client.mu.Unlock()
+				}
+			},
+		},
+	})
+	deleted := make(chan error)
+	go func() {
+		deleted <- worker.deleteMessage(context.Background(), &types.Message{MessageId: aws.String("m"), ReceiptHandle: aws.String("m")})
+	}()
+	assert.Eventually(t, func() bool {
+		worker.deletes.mu.Lock()
+		defer worker.deletes.mu.Unlock()
+		return len(worker.deletes.pending) == 1
+	}, time.Second, time.Millisecond)
+
+	report := worker.shutdown(context.Background())
+	assert.NoError(t, <-deleted, "the buffered delete is flushed without waiting for MaxWait")
+
+	want := []ShutdownPhase{ShutdownStopReceiving, ShutdownWaitHandlers, ShutdownFlushDeletes, ShutdownClose}
+	assert.Equal(t, want, phases, "the hook is called at the end of each phase in the order")
+	if assert.Len(t, report.Phases, len(want)) {
+		for i, p := range report.Phases {
+			assert.Equal(t, want[i], p.Phase)
+		}
+	}
+	assert.Equal(t, 0, flushedAtWait, "the deletes are flushed after the handlers")
+	assert.Len(t, client.batches, 1)
+}
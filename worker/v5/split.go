@@ -0,0 +1,200 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// SplitMode is the delete semantics of the message split into the events by the Splitting
+type SplitMode string
+
+const (
+	// SplitAllOrNothing handles the events in order and stops at the first failed event,
+	// then the whole message is retried, so the events before it are handled again on the redelivery
+	SplitAllOrNothing SplitMode = "all_or_nothing"
+	// SplitPerEvent handles all the events, then the failed events are re-sent as the new message by the retry topology
+	// and the original is deleted, so that only the failed events are handled again
+	SplitPerEvent SplitMode = "per_event"
+)
+
+// Splitter splits the body of the message carrying the N events of the producer, and joins the failed events to re-send
+type Splitter interface {
+	Split(body string) ([]string, error)
+	Join(events []string) (string, error)
+}
+
+// JSONArraySplitter splits the JSON array body into its elements, and the body other than the array is the only event
+type JSONArraySplitter struct{}
+
+// Split returns the elements of the JSON array body
+func (JSONArraySplitter) Split(body string) ([]string, error) {
+	if !strings.HasPrefix(strings.TrimSpace(body), "[") {
+		return []string{body}, nil
+	}
+	var elements []json.RawMessage
+	if err := json.Unmarshal([]byte(body), &elements); err != nil {
+		return nil, err
+	}
+	events := make([]string, len(elements))
+	for i, e := range elements {
+		events[i] = string(e)
+	}
+	return events, nil
+}
+
+// Join returns the JSON array of the events
+func (JSONArraySplitter) Join(events []string) (string, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, e := range events {
+		if !json.Valid([]byte(e)) {
+			return "", fmt.Errorf("invalid JSON event, index=%d", i)
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(e)
+	}
+	buf.WriteByte(']')
+	return buf.String(), nil
+}
+
+// Splitting expands the fan-in message, i.e. the N events batched in one message by the producer,
+// into the events passed to the handler one by one, after the Transformers (e.g. decompression).
+// The message given to the handler is a copy of the message whose Body is the event, and SplitEvent reports its position.
+type Splitting struct {
+	// Splitter splits the body into the events (default: JSONArraySplitter)
+	Splitter Splitter
+	// Mode is the delete semantics of the events (default: SplitAllOrNothing)
+	Mode SplitMode
+}
+
+type splitEventKey struct{}
+
+type splitEvent struct {
+	index int
+	count int
+}
+
+// SplitEvent returns the index of the event in the message and the number of the events, or false outside of the Splitting
+func SplitEvent(ctx context.Context) (index, count int, ok bool) {
+	e, ok := ctx.Value(splitEventKey{}).(splitEvent)
+	return e.index, e.count, ok
+}
+
+func (s *Splitting) splitter() Splitter {
+	if s.Splitter == nil {
+		return JSONArraySplitter{}
+	}
+	return s.Splitter
+}
+
+func (s *Splitting) mode() SplitMode {
+	if s.Mode == "" {
+		return SplitAllOrNothing
+	}
+	return s.Mode
+}
+
+// callSplit calls the handler with each event of the message under the handlerCtx, and reports their outcomes to OnSplitEvents.
+// With SplitPerEvent, the failed events are re-sent with ctx when some of the events were handled, the invalid events are dropped,
+// and the events handled on the earlier receive of the message are skipped.
+// The error of the first failed event is returned when the whole message is to be retried.
+func (worker *Worker) callSplit(ctx, handlerCtx context.Context, h Handler, msg *types.Message) error {
+	s := worker.Config.Splitting
+	events, err := s.splitter().Split(aws.ToString(msg.Body))
+	if err != nil {
+		return NewInvalidEventError(aws.ToString(msg.MessageId), fmt.Sprintf("failed to split the message, err=%+v", err))
+	}
+	perEvent := s.mode() == SplitPerEvent
+	handled := worker.splits.take(msg, len(events))
+	results := make([]SplitEventResult, 0, len(events))
+	var failed []string
+	var firstErr error
+	for i, event := range events {
+		if handled != nil && handled[i] {
+			worker.stats.addSplitSkipped()
+			results = append(results, SplitEventResult{Index: i, Outcome: OutcomeDuplicate})
+			continue
+		}
+		e := *msg
+		e.Body = aws.String(event)
+		err := worker.callHandlerWithRetry(context.WithValue(handlerCtx, splitEventKey{}, splitEvent{index: i, count: len(events)}), h, &e)
+		worker.stats.addSplitEvent()
+		result := SplitEventResult{Index: i, Outcome: OutcomeSucceeded, Err: err}
+		_, invalid := err.(InvalidEventError)
+		if invalid && perEvent {
+			result.Outcome = OutcomeInvalid
+			worker.logEvent(ctx, LogEventInvalidEvent, "worker: Dropped the invalid event, id=%s, index=%d, err=%s", aws.ToString(msg.MessageId), i, err.Error())
+		} else if err != nil {
+			// the invalid event fails the whole message as invalid with SplitAllOrNothing
+			result.Outcome = OutcomeFailed
+			if invalid {
+				result.Outcome = OutcomeInvalid
+			}
+			failed = append(failed, event)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		results = append(results, result)
+		if err != nil && !perEvent {
+			break
+		}
+	}
+	err = firstErr
+	if perEvent && len(failed) > 0 && len(failed) < len(events) {
+		if err = worker.resendFailedEvents(ctx, msg, failed, len(events), firstErr); err == nil {
+			for i := range results {
+				if results[i].Outcome == OutcomeFailed {
+					results[i].Outcome = OutcomeRetried
+				}
+			}
+		}
+	}
+	if err != nil {
+		worker.splits.remember(msg, results)
+	}
+	if worker.Config.Hooks.OnSplitEvents != nil {
+		worker.Config.Hooks.OnSplitEvents(ctx, msg, results)
+	}
+	return err
+}
+
+// resendFailedEvents re-sends the failed events as the new message to the retry queue (or the queue without it)
+// with the attempt incremented, so that the original with the succeeded events is deleted.
+// The whole message is retried when the attempts are exhausted or the re-send failed.
+func (worker *Worker) resendFailedEvents(ctx context.Context, msg *types.Message, failed []string, count int, cause error) error {
+	attempt := RetryAttempt(msg) + 1
+	if attempt > worker.Config.MaxRetryAttempts {
+		return fmt.Errorf("worker: retry attempts of the failed events exhausted(%d), message is left to the redrive policy: %w", attempt-1, cause)
+	}
+	body, err := worker.Config.Splitting.splitter().Join(failed)
+	if err != nil {
+		return fmt.Errorf("worker: failed to join the failed events, err=%+v: %w", err, cause)
+	}
+	queueURL := worker.Config.RetryQueueURL
+	if queueURL == "" {
+		queueURL = worker.Config.QueueURL
+	}
+	remainder := *msg
+	remainder.Body = aws.String(body)
+	delay := worker.Config.retryDelay(attempt)
+	if err := worker.resend(ctx, queueURL, &remainder, delay, attempt, true); err != nil {
+		if errors.Is(err, errSendNotSupported) {
+			return cause
+		}
+		return fmt.Errorf("worker: failed to re-send the failed events, err=%+v: %w", err, cause)
+	}
+	worker.stats.addSplitResent(len(failed))
+	worker.logEvent(ctx, LogEventSplitResent, "worker: Re-sent the failed events of the message, id=%s, failed=%d/%d, attempt=%d, delay=%ds, err=%+v",
+		aws.ToString(msg.MessageId), len(failed), count, attempt, delaySeconds(delay), cause)
+	return nil
+}
@@ -0,0 +1,182 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingSenderSqsClient struct {
+	countingDeleteSqsClient
+	mu   sync.Mutex
+	sent []*sqs.SendMessageInput
+}
+
+func (c *recordingSenderSqsClient) SendMessage(ctx context.Context, input *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, input)
+	return &sqs.SendMessageOutput{MessageId: aws.String("new-message-id")}, nil
+}
+
+func TestJSONArraySplitter(t *testing.T) {
+	events, err := JSONArraySplitter{}.Split(` [{"id":1}, "two", 3]`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"id":1}`, `"two"`, `3`}, events)
+
+	events, err = JSONArraySplitter{}.Split(`{"id":1}`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"id":1}`}, events, "the body other than the array is the only event")
+
+	_, err = JSONArraySplitter{}.Split(`[{"id":1}`)
+	assert.Error(t, err)
+
+	body, err := JSONArraySplitter{}.Join([]string{`{"id":1}`, `3`})
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"id":1},3]`, body)
+}
+
+func TestSplitting(t *testing.T) {
+	message := func(body string) *types.Message {
+		return &types.Message{MessageId: aws.String("m"), ReceiptHandle: aws.String("m"), Body: aws.String(body)}
+	}
+	// failing fails the events of the ids, and records the handled events with their positions
+	failing := func(handled *[]string, ids ...string) Handler {
+		return ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+			index, count, ok := SplitEvent(ctx)
+			assert.True(t, ok)
+			assert.Less(t, index, count)
+			*handled = append(*handled, aws.ToString(msg.Body))
+			for _, id := range ids {
+				if aws.ToString(msg.Body) == id {
+					return errors.New("failure")
+				}
+			}
+			if aws.ToString(msg.Body) == `"invalid"` {
+				return NewInvalidEventError("test", "invalid")
+			}
+			return nil
+		})
+	}
+
+	t.Run("all or nothing", func(t *testing.T) {
+		client := &recordingSenderSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Splitting: &Splitting{}})
+		var handled []string
+		outcome, err := worker.handle(context.Background(), message(`[1,2,3]`), failing(&handled, "2"))
+		assert.Error(t, err)
+		assert.Equal(t, OutcomeFailed, outcome)
+		assert.Equal(t, []string{"1", "2"}, handled, "the events after the failed one are not handled")
+		assert.Zero(t, client.deleted)
+		assert.Empty(t, client.sent)
+
+		handled = nil
+		outcome, err = worker.handle(context.Background(), message(`[1,2,3]`), failing(&handled))
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeSucceeded, outcome)
+		assert.Equal(t, []string{"1", "2", "3"}, handled)
+		assert.Equal(t, int64(1), client.deleted)
+		assert.Equal(t, int64(5), worker.Stats().SplitEvents)
+	})
+
+	t.Run("per event", func(t *testing.T) {
+		client := &recordingSenderSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Splitting: &Splitting{Mode: SplitPerEvent}})
+		var handled []string
+		outcome, err := worker.handle(context.Background(), message(`[1,2,"invalid",3]`), failing(&handled, "1", "3"))
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeSucceeded, outcome)
+		assert.Equal(t, []string{"1", "2", `"invalid"`, "3"}, handled)
+		assert.Equal(t, int64(1), client.deleted, "the original is deleted")
+		if assert.Len(t, client.sent, 1) {
+			sent := client.sent[0]
+			assert.Equal(t, "[1,3]", aws.ToString(sent.MessageBody), "only the failed events are re-sent")
+			assert.Equal(t, "https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue", aws.ToString(sent.QueueUrl))
+			assert.Equal(t, "1", aws.ToString(sent.MessageAttributes[RetryAttemptAttribute].StringValue))
+			assert.Equal(t, int32(10), sent.DelaySeconds)
+		}
+		assert.Equal(t, int64(2), worker.Stats().SplitResent)
+	})
+
+	t.Run("per event with all the events failed", func(t *testing.T) {
+		client := &recordingSenderSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Splitting: &Splitting{Mode: SplitPerEvent}})
+		var handled []string
+		outcome, err := worker.handle(context.Background(), message(`[1,2]`), failing(&handled, "1", "2"))
+		assert.Error(t, err)
+		assert.Equal(t, OutcomeFailed, outcome)
+		assert.Zero(t, client.deleted)
+		assert.Empty(t, client.sent, "the whole message is retried by the redelivery")
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		client := &recordingSenderSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Splitting: &Splitting{}})
+		var handled []string
+		outcome, err := worker.handle(context.Background(), message(`[1,`), failing(&handled))
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeInvalid, outcome)
+		assert.Empty(t, handled)
+		assert.Equal(t, int64(1), client.deleted)
+	})
+}
+
+func TestSplitEventResults(t *testing.T) {
+	message := &types.Message{MessageId: aws.String("m"), ReceiptHandle: aws.String("m"), Body: aws.String(`[1,2,3]`)}
+	var results []SplitEventResult
+	var handled []string
+	fail := map[string]bool{"2": true}
+	h := HandlerFunc(func(msg *types.Message) error {
+		handled = append(handled, aws.ToString(msg.Body))
+		if fail[aws.ToString(msg.Body)] {
+			return errors.New("failure")
+		}
+		return nil
+	})
+	outcomes := func() []Outcome {
+		var outcomes []Outcome
+		for _, r := range results {
+			outcomes = append(outcomes, r.Outcome)
+		}
+		return outcomes
+	}
+
+	t.Run("the failed subset is re-sent", func(t *testing.T) {
+		client := &recordingSenderSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Splitting: &Splitting{Mode: SplitPerEvent},
+			Hooks: Hooks{OnSplitEvents: func(ctx context.Context, msg *types.Message, r []SplitEventResult) { results = r }}})
+		_, err := worker.handle(context.Background(), message, h)
+		assert.NoError(t, err)
+		assert.Equal(t, []Outcome{OutcomeSucceeded, OutcomeRetried, OutcomeSucceeded}, outcomes())
+		assert.Error(t, results[1].Err)
+	})
+
+	t.Run("the handled events are skipped on the redelivery of the whole message", func(t *testing.T) {
+		// the client without SendMessage can't re-send the subset
+		client := &countingDeleteSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Splitting: &Splitting{Mode: SplitPerEvent},
+			Hooks: Hooks{OnSplitEvents: func(ctx context.Context, msg *types.Message, r []SplitEventResult) { results = r }}})
+		handled = nil
+		outcome, err := worker.handle(context.Background(), message, h)
+		assert.Error(t, err)
+		assert.Equal(t, OutcomeFailed, outcome)
+		assert.Equal(t, []Outcome{OutcomeSucceeded, OutcomeFailed, OutcomeSucceeded}, outcomes())
+		assert.Zero(t, client.deleted)
+
+		delete(fail, "2")
+		handled = nil
+		outcome, err = worker.handle(context.Background(), message, h)
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeSucceeded, outcome)
+		assert.Equal(t, []string{"2"}, handled, "only the failed event is handled again")
+		assert.Equal(t, []Outcome{OutcomeDuplicate, OutcomeSucceeded, OutcomeDuplicate}, outcomes())
+		assert.Equal(t, int64(2), worker.Stats().SplitSkipped)
+		assert.Equal(t, int64(1), client.deleted)
+	})
+}
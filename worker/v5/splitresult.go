@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// maxRememberedSplits is the number of the split messages whose handled events are remembered for their redelivery
+const maxRememberedSplits = 10000
+
+// SplitEventResult is the outcome of an event of the split message passed to OnSplitEvents.
+// The Outcome is OutcomeSucceeded, OutcomeInvalid (dropped), OutcomeRetried (re-sent in the subset of the failed events),
+// OutcomeFailed (left to the redelivery of the whole message), or OutcomeDuplicate (handled on an earlier receive and skipped).
+type SplitEventResult struct {
+	Index   int
+	Outcome Outcome
+	Err     error
+}
+
+// handled reports whether the event needs no further handling on the redelivery
+func (r SplitEventResult) handled() bool {
+	return r.Outcome != OutcomeFailed && r.Outcome != OutcomeRetried
+}
+
+// splitProgress remembers the handled events of the split messages redelivered as a whole with SplitPerEvent,
+// i.e. when the subset of the failed events could not be re-sent, so that the redelivery to the worker skips them.
+// It's in memory, so the redelivery to another consumer handles all the events again.
+type splitProgress struct {
+	mu      sync.Mutex
+	handled map[string][]bool
+	order   []string
+}
+
+func newSplitProgress(splitting *Splitting) *splitProgress {
+	if splitting == nil || splitting.mode() != SplitPerEvent {
+		return nil
+	}
+	return &splitProgress{handled: map[string][]bool{}}
+}
+
+// remember records the handled events of the message to be redelivered
+func (p *splitProgress) remember(m *types.Message, results []SplitEventResult) {
+	if p == nil || m.MessageId == nil {
+		return
+	}
+	handled := make([]bool, len(results))
+	for i, r := range results {
+		handled[i] = r.handled()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := *m.MessageId
+	if _, ok := p.handled[id]; !ok {
+		p.order = append(p.order, id)
+	}
+	p.handled[id] = handled
+	for len(p.order) > maxRememberedSplits {
+		delete(p.handled, p.order[0])
+		p.order = p.order[1:]
+	}
+}
+
+// take returns the events of the message handled on the earlier receive and forgets them,
+// or nil if none is remembered for the number of the events
+func (p *splitProgress) take(m *types.Message, count int) []bool {
+	if p == nil || m.MessageId == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	handled, ok := p.handled[*m.MessageId]
+	if !ok {
+		return nil
+	}
+	delete(p.handled, *m.MessageId)
+	for i, id := range p.order {
+		if id == *m.MessageId {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+	if len(handled) != count {
+		return nil
+	}
+	return handled
+}
@@ -0,0 +1,258 @@
+package worker
+
+import "sync/atomic"
+
+// Stats is a snapshot of the worker statistics
+type Stats struct {
+	// QueueName is the name of the queue consumed by the worker
+	QueueName string
+	// Received is the number of messages received from the queue
+	Received int64
+	// Succeeded is the number of messages handled and deleted successfully
+	Succeeded int64
+	// Failed is the number of messages that failed to be handled or deleted
+	Failed int64
+	// DeleteFailed is the number of messages handled but failed to be deleted
+	DeleteFailed int64
+	// Released is the number of unfinished messages whose visibility was reset on Handoff or shutdown
+	Released int64
+	// LostOwnership is the number of handlers canceled since the visibility of the message expired
+	LostOwnership int64
+	// Throttled is the number of receives throttled by SQS
+	Throttled int64
+	// HopLimited is the number of messages parked, dropped or failed since they exceeded the MaxHops
+	HopLimited int64
+	// Expired is the number of messages older than the MaxMessageAge
+	Expired int64
+	// Canceled is the number of messages skipped since their correlation key was canceled by CancelRelated
+	Canceled int64
+	// HandlerRetried is the number of the in-process retries of the handler by the HandlerRetry
+	HandlerRetried int64
+	// FailuresSampled is the number of the failed payloads stored by the FailureSampling
+	FailuresSampled int64
+	// EmptyReceives is the number of receives returning no messages
+	EmptyReceives int64
+	// NonEmptyReceives is the number of receives returning messages
+	NonEmptyReceives int64
+	// SplitEvents is the number of the events handled from the messages split by the Splitting
+	SplitEvents int64
+	// SplitResent is the number of the failed events re-sent by the SplitPerEvent
+	SplitResent int64
+	// SplitSkipped is the number of the events skipped on the redelivery since they were handled on the earlier receive
+	SplitSkipped int64
+	// Panicked is the number of the panics of the handler recovered by the PanicPolicy
+	Panicked int64
+	// PrefetchExtended is the number of the visibility extensions of the messages queued for the Workers by the PrefetchVisibility
+	PrefetchExtended int64
+	// Tapped is the number of the deletes, sends and visibility changes skipped by the TapMode
+	Tapped int64
+	// Mirrored is the number of messages sent to the mirror queue
+	Mirrored int64
+	// MirrorFailed is the number of messages that failed to be sent to the mirror queue
+	MirrorFailed int64
+	// WarmPoolSize is the number of the goroutines of the WarmPool running now
+	WarmPoolSize int64
+	// WarmPoolBusy is the number of the goroutines of the WarmPool handling the messages now
+	WarmPoolBusy int64
+	// Goroutines is the number of the goroutines started by the worker and running now
+	Goroutines int64
+	// RunningHandlers is the number of the handlers running now
+	RunningHandlers int64
+	// LeakedHandlers is the number of the handlers still running after the shutdown
+	LeakedHandlers int64
+	// Redelivered is the number of messages received with ApproximateReceiveCount > 1 by the reason
+	Redelivered RedeliveryStats
+	// Buffers is the statistics of the buffer pool served by Buffer
+	Buffers BufferPoolStats
+	// DeadLetterQueueARN is the ARN of the dead-letter queue configured by the redrive policy(empty if none)
+	DeadLetterQueueARN string
+}
+
+type stats struct {
+	received         int64
+	succeeded        int64
+	failed           int64
+	deleteFailed     int64
+	released         int64
+	throttled        int64
+	emptyReceives    int64
+	hopLimited       int64
+	expired          int64
+	canceled         int64
+	handlerRetried   int64
+	failuresSampled  int64
+	receives         int64
+	lostOwnership    int64
+	splitEvents      int64
+	splitResent      int64
+	splitSkipped     int64
+	panicked         int64
+	prefetchExtended int64
+	tapped           int64
+	mirrored         int64
+	mirrorFailed     int64
+}
+
+func (s *stats) addReceived(n int) {
+	atomic.AddInt64(&s.received, int64(n))
+}
+
+func (s *stats) addSucceeded() {
+	atomic.AddInt64(&s.succeeded, 1)
+}
+
+func (s *stats) addFailed() {
+	atomic.AddInt64(&s.failed, 1)
+}
+
+func (s *stats) addDeleteFailed() {
+	atomic.AddInt64(&s.deleteFailed, 1)
+}
+
+func (s *stats) addReleased() {
+	atomic.AddInt64(&s.released, 1)
+}
+
+func (s *stats) addThrottled() {
+	atomic.AddInt64(&s.throttled, 1)
+}
+
+func (s *stats) addReceive(empty bool) {
+	if empty {
+		atomic.AddInt64(&s.emptyReceives, 1)
+		return
+	}
+	atomic.AddInt64(&s.receives, 1)
+}
+
+func (s *stats) addHopLimited() {
+	atomic.AddInt64(&s.hopLimited, 1)
+}
+
+func (s *stats) addExpired() {
+	atomic.AddInt64(&s.expired, 1)
+}
+
+func (s *stats) addCanceled() {
+	atomic.AddInt64(&s.canceled, 1)
+}
+
+func (s *stats) addHandlerRetried() {
+	atomic.AddInt64(&s.handlerRetried, 1)
+}
+
+func (s *stats) addFailureSampled() {
+	atomic.AddInt64(&s.failuresSampled, 1)
+}
+
+func (s *stats) addLostOwnership() {
+	atomic.AddInt64(&s.lostOwnership, 1)
+}
+
+func (s *stats) addSplitEvent() {
+	atomic.AddInt64(&s.splitEvents, 1)
+}
+
+func (s *stats) addSplitResent(n int) {
+	atomic.AddInt64(&s.splitResent, int64(n))
+}
+
+func (s *stats) addSplitSkipped() {
+	atomic.AddInt64(&s.splitSkipped, 1)
+}
+
+func (s *stats) addPanicked() {
+	atomic.AddInt64(&s.panicked, 1)
+}
+
+func (s *stats) addPrefetchExtended(n int) {
+	atomic.AddInt64(&s.prefetchExtended, int64(n))
+}
+
+func (s *stats) addTapped() {
+	atomic.AddInt64(&s.tapped, 1)
+}
+
+func (s *stats) addMirrored() {
+	atomic.AddInt64(&s.mirrored, 1)
+}
+
+func (s *stats) addMirrorFailed() {
+	atomic.AddInt64(&s.mirrorFailed, 1)
+}
+
+// add sums the counters of the other statistics into the statistics, leaving the QueueName and the DeadLetterQueueARN of a single worker
+func (s *Stats) add(other Stats) {
+	s.Received += other.Received
+	s.Succeeded += other.Succeeded
+	s.Failed += other.Failed
+	s.DeleteFailed += other.DeleteFailed
+	s.Released += other.Released
+	s.LostOwnership += other.LostOwnership
+	s.Throttled += other.Throttled
+	s.HopLimited += other.HopLimited
+	s.Expired += other.Expired
+	s.Canceled += other.Canceled
+	s.HandlerRetried += other.HandlerRetried
+	s.FailuresSampled += other.FailuresSampled
+	s.EmptyReceives += other.EmptyReceives
+	s.NonEmptyReceives += other.NonEmptyReceives
+	s.SplitEvents += other.SplitEvents
+	s.SplitResent += other.SplitResent
+	s.SplitSkipped += other.SplitSkipped
+	s.Panicked += other.Panicked
+	s.PrefetchExtended += other.PrefetchExtended
+	s.Tapped += other.Tapped
+	s.Mirrored += other.Mirrored
+	s.MirrorFailed += other.MirrorFailed
+	s.WarmPoolSize += other.WarmPoolSize
+	s.WarmPoolBusy += other.WarmPoolBusy
+	s.Goroutines += other.Goroutines
+	s.RunningHandlers += other.RunningHandlers
+	s.LeakedHandlers += other.LeakedHandlers
+	s.Redelivered.HandlerError += other.Redelivered.HandlerError
+	s.Redelivered.VisibilityExpiry += other.Redelivered.VisibilityExpiry
+	s.Redelivered.Unknown += other.Redelivered.Unknown
+	s.Buffers.Gets += other.Buffers.Gets
+	s.Buffers.Allocated += other.Buffers.Allocated
+	s.Buffers.Returned += other.Buffers.Returned
+	s.Buffers.Discarded += other.Buffers.Discarded
+}
+
+// Stats returns the current statistics of the worker
+func (worker *Worker) Stats() Stats {
+	warmSize, warmBusy := worker.warm.stats()
+	return Stats{
+		QueueName:          worker.Config.QueueName,
+		Received:           atomic.LoadInt64(&worker.stats.received),
+		Succeeded:          atomic.LoadInt64(&worker.stats.succeeded),
+		Failed:             atomic.LoadInt64(&worker.stats.failed),
+		DeleteFailed:       atomic.LoadInt64(&worker.stats.deleteFailed),
+		Released:           atomic.LoadInt64(&worker.stats.released),
+		Throttled:          atomic.LoadInt64(&worker.stats.throttled),
+		HopLimited:         atomic.LoadInt64(&worker.stats.hopLimited),
+		Expired:            atomic.LoadInt64(&worker.stats.expired),
+		Canceled:           atomic.LoadInt64(&worker.stats.canceled),
+		HandlerRetried:     atomic.LoadInt64(&worker.stats.handlerRetried),
+		FailuresSampled:    atomic.LoadInt64(&worker.stats.failuresSampled),
+		EmptyReceives:      atomic.LoadInt64(&worker.stats.emptyReceives),
+		NonEmptyReceives:   atomic.LoadInt64(&worker.stats.receives),
+		LostOwnership:      atomic.LoadInt64(&worker.stats.lostOwnership),
+		SplitEvents:        atomic.LoadInt64(&worker.stats.splitEvents),
+		SplitResent:        atomic.LoadInt64(&worker.stats.splitResent),
+		SplitSkipped:       atomic.LoadInt64(&worker.stats.splitSkipped),
+		Panicked:           atomic.LoadInt64(&worker.stats.panicked),
+		PrefetchExtended:   atomic.LoadInt64(&worker.stats.prefetchExtended),
+		Tapped:             atomic.LoadInt64(&worker.stats.tapped),
+		Mirrored:           atomic.LoadInt64(&worker.stats.mirrored),
+		MirrorFailed:       atomic.LoadInt64(&worker.stats.mirrorFailed),
+		WarmPoolSize:       warmSize,
+		WarmPoolBusy:       warmBusy,
+		Goroutines:         atomic.LoadInt64(&worker.goroutines.owned),
+		RunningHandlers:    int64(worker.goroutines.runningHandlers()),
+		LeakedHandlers:     atomic.LoadInt64(&worker.goroutines.leaked),
+		Redelivered:        worker.redeliveries.stats(),
+		Buffers:            worker.buffers.stats(),
+		DeadLetterQueueARN: worker.deadLetterQueueARN,
+	}
+}
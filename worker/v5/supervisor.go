@@ -0,0 +1,177 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/ca-risken/common/pkg/logging"
+)
+
+// Supervisor owns several Workers, starts them with the shared context,
+// restarts the ones that exit unexpectedly with backoff, and drains them all on shutdown.
+type Supervisor struct {
+	Log logging.Logger
+	// MinBackoff is the initial delay before restarting a worker, waited on the Clock of the worker (default: 1 second)
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum delay before restarting a worker (default: 1 minute)
+	MaxBackoff time.Duration
+	// Recoverable decides whether the worker is restarted after the error (default: any error other than AccessDeniedError)
+	Recoverable func(err error) bool
+
+	mu      sync.Mutex
+	members []*member
+}
+
+type member struct {
+	worker   *Worker
+	handler  Handler
+	restarts int
+}
+
+// NewSupervisor creates Supervisor struct
+func NewSupervisor() *Supervisor {
+	return &Supervisor{
+		Log:        logging.NewLogger(),
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+	}
+}
+
+// Add registers the worker and the handler, it must be called before Run
+func (s *Supervisor) Add(worker *Worker, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.members = append(s.members, &member{worker: worker, handler: h})
+}
+
+// Run starts all workers and blocks until the context is done, then drains them all by Handoff.
+// The workers keep processing in-flight messages with the context values after the context is done.
+func (s *Supervisor) Run(ctx context.Context) []HandoffResult {
+	s.mu.Lock()
+	members := append([]*member{}, s.members...)
+	s.mu.Unlock()
+
+	workerCtx, cancel := context.WithCancel(withoutCancel{ctx})
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, m := range members {
+		wg.Add(1)
+		go func(m *member) {
+			defer wg.Done()
+			s.supervise(ctx, workerCtx, m)
+		}(m)
+	}
+
+	<-ctx.Done()
+	results := make([]HandoffResult, len(members))
+	var drain sync.WaitGroup
+	for i, m := range members {
+		drain.Add(1)
+		go func(i int, m *member) {
+			defer drain.Done()
+			results[i] = m.worker.Handoff(workerCtx)
+		}(i, m)
+	}
+	drain.Wait()
+	cancel()
+	wg.Wait()
+	return results
+}
+
+// supervise runs the worker and restarts it until the supervisor context is done
+func (s *Supervisor) supervise(ctx, workerCtx context.Context, m *member) {
+	backoff := s.MinBackoff
+	for {
+		err := runWorker(workerCtx, m.worker, m.handler)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("worker stopped unexpectedly")
+		}
+		recoverable := s.Recoverable
+		if recoverable == nil {
+			recoverable = recoverableByDefault
+		}
+		if !recoverable(err) {
+			s.Log.Errorf(ctx, "supervisor: Worker exited with unrecoverable error, queue=%s, err=%+v", m.worker.Config.QueueName, err)
+			return
+		}
+		s.mu.Lock()
+		m.restarts++
+		s.mu.Unlock()
+		s.Log.Warnf(ctx, "supervisor: Restarting the worker in %s, queue=%s, err=%+v", backoff, m.worker.Config.QueueName, err)
+		timer := m.worker.clock().NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+		backoff *= 2
+		if backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+// runWorker runs the worker and converts the panic to the error
+func runWorker(ctx context.Context, worker *Worker, h Handler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("worker panicked: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return worker.Run(ctx, h)
+}
+
+// recoverableByDefault is the default Recoverable, which doesn't restart the worker on the misconfiguration
+func recoverableByDefault(err error) bool {
+	var denied *AccessDeniedError
+	return !errors.As(err, &denied)
+}
+
+// Stats returns the statistics of all workers
+func (s *Supervisor) Stats() []Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]Stats, 0, len(s.members))
+	for _, m := range s.members {
+		stats = append(stats, m.worker.Stats())
+	}
+	return stats
+}
+
+// TotalStats returns the sum of the statistics of all workers
+func (s *Supervisor) TotalStats() Stats {
+	var total Stats
+	for _, st := range s.Stats() {
+		total.add(st)
+	}
+	return total
+}
+
+// Restarts returns the number of restarts per queue name
+func (s *Supervisor) Restarts() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	restarts := make(map[string]int, len(s.members))
+	for _, m := range s.members {
+		restarts[m.worker.Config.QueueName] += m.restarts
+	}
+	return restarts
+}
+
+// withoutCancel keeps the values of the parent context without the cancellation and deadline
+type withoutCancel struct {
+	parent context.Context
+}
+
+func (withoutCancel) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (withoutCancel) Done() <-chan struct{}               { return nil }
+func (withoutCancel) Err() error                          { return nil }
+func (c withoutCancel) Value(key interface{}) interface{} { return c.parent.Value(key) }
@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type panickingSqsClient struct {
+	nopSqsClient
+	calls int32
+}
+
+func (c *panickingSqsClient) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	if atomic.AddInt32(&c.calls, 1) == 1 {
+		panic("boom")
+	}
+	if atomic.LoadInt32(&c.calls) == 2 {
+		return &sqs.ReceiveMessageOutput{Messages: []types.Message{{Body: aws.String("body")}}}, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestSupervisor(t *testing.T) {
+	client := &panickingSqsClient{}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", DrainTimeout: time.Second})
+	handled := make(chan struct{})
+	supervisor := NewSupervisor()
+	supervisor.MinBackoff = time.Millisecond
+	supervisor.Add(worker, HandlerFunc(func(msg *types.Message) error {
+		close(handled)
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan []HandoffResult)
+	go func() { done <- supervisor.Run(ctx) }()
+
+	<-handled
+	cancel()
+	results := <-done
+	assert.Equal(t, []HandoffResult{{Status: HandoffStatusDrained}}, results)
+	assert.Equal(t, map[string]int{"my-sqs-queue": 1}, supervisor.Restarts(), "the panicked worker is restarted")
+	assert.Equal(t, int64(1), supervisor.TotalStats().Succeeded)
+	assert.Equal(t, "my-sqs-queue", supervisor.Stats()[0].QueueName)
+}
+
+func TestSupervisorTotalStats(t *testing.T) {
+	// every counter of the Stats is set to 1, so a counter missed by the total stays 1
+	var one Stats
+	var set func(v reflect.Value)
+	set = func(v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			switch f := v.Field(i); f.Kind() {
+			case reflect.Int64:
+				f.SetInt(1)
+			case reflect.Struct:
+				set(f)
+			}
+		}
+	}
+	set(reflect.ValueOf(&one).Elem())
+
+	var total Stats
+	total.add(one)
+	total.add(one)
+	var check func(v reflect.Value, path string)
+	check = func(v reflect.Value, path string) {
+		for i := 0; i < v.NumField(); i++ {
+			name := path + v.Type().Field(i).Name
+			switch f := v.Field(i); f.Kind() {
+			case reflect.Int64:
+				assert.Equal(t, int64(2), f.Int(), name)
+			case reflect.Struct:
+				check(f, name+".")
+			}
+		}
+	}
+	check(reflect.ValueOf(total), "")
+}
@@ -0,0 +1,120 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// QueueTagsAPI interface is optionally implemented by the client to read the queue tags
+type QueueTagsAPI interface {
+	ListQueueTags(ctx context.Context, params *sqs.ListQueueTagsInput, optFns ...func(*sqs.Options)) (*sqs.ListQueueTagsOutput, error)
+}
+
+// QueueTagPrefix is the prefix of the queue tags applied as the config overrides by QueueTagOverrides
+const QueueTagPrefix = "worker:"
+
+// The queue tags applied as the config overrides
+const (
+	QueueTagConcurrency        = QueueTagPrefix + "concurrency"
+	QueueTagRateLimit          = QueueTagPrefix + "rate-limit"
+	QueueTagMaxNumberOfMessage = QueueTagPrefix + "max-number-of-message"
+	QueueTagWaitTimeSecond     = QueueTagPrefix + "wait-time-second"
+	QueueTagVisibilityTimeout  = QueueTagPrefix + "visibility-timeout"
+	QueueTagDrainTimeout       = QueueTagPrefix + "drain-timeout"
+)
+
+// queueTagOverrides is the config overrides by the queue tags
+type queueTagOverrides struct {
+	patch             ConfigPatch
+	visibilityTimeout *int32
+	drainTimeout      *time.Duration
+}
+
+// parseQueueTags converts the tags to the overrides, the invalid and unknown tags of the prefix are returned as errors
+func parseQueueTags(tags map[string]string) (queueTagOverrides, []error) {
+	var o queueTagOverrides
+	var errs []error
+	for key, value := range tags {
+		if !strings.HasPrefix(key, QueueTagPrefix) {
+			continue
+		}
+		var err error
+		switch key {
+		case QueueTagConcurrency:
+			var n int
+			if n, err = strconv.Atoi(value); err == nil {
+				o.patch.Concurrency = &n
+			}
+		case QueueTagRateLimit:
+			var f float64
+			if f, err = strconv.ParseFloat(value, 64); err == nil {
+				o.patch.RateLimit = &f
+			}
+		case QueueTagMaxNumberOfMessage, QueueTagWaitTimeSecond, QueueTagVisibilityTimeout:
+			var n int64
+			if n, err = strconv.ParseInt(value, 10, 32); err == nil {
+				v := int32(n)
+				switch key {
+				case QueueTagMaxNumberOfMessage:
+					o.patch.MaxNumberOfMessage = &v
+				case QueueTagWaitTimeSecond:
+					o.patch.WaitTimeSecond = &v
+				default:
+					o.visibilityTimeout = &v
+				}
+			}
+		case QueueTagDrainTimeout:
+			var d time.Duration
+			if d, err = time.ParseDuration(value); err == nil {
+				o.drainTimeout = &d
+			}
+		default:
+			err = fmt.Errorf("unknown tag")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s=%s: %w", key, value, err))
+		}
+	}
+	return o, errs
+}
+
+// applyQueueTags reads the queue tags and applies them over the config at startup,
+// so that the infrastructure as code can tune the consumers without redeploying the application.
+// The invalid tags are ignored with warnings.
+func (worker *Worker) applyQueueTags(ctx context.Context, client QueueAPI) {
+	tagsClient, ok := client.(QueueTagsAPI)
+	if !ok {
+		worker.Log.Warnf(ctx, "worker: QueueTagOverrides is ignored, the sqs client does not support ListQueueTags")
+		return
+	}
+	callCtx, finishSpan := worker.startClientSpan(ctx, "ListQueueTags")
+	out, err := tagsClient.ListQueueTags(callCtx, &sqs.ListQueueTagsInput{QueueUrl: aws.String(worker.Config.QueueURL)}, worker.Config.sqsOptions()...)
+	finishSpan(err)
+	if err != nil {
+		worker.Log.Warnf(ctx, "worker: Failed to list the queue tags, queue=%s, err=%+v", worker.Config.QueueName, err)
+		return
+	}
+	o, errs := parseQueueTags(out.Tags)
+	for _, err := range errs {
+		worker.Log.Warnf(ctx, "worker: Ignored the invalid queue tag, queue=%s, err=%+v", worker.Config.QueueName, err)
+	}
+	if err := worker.UpdateConfig(o.patch); err != nil {
+		worker.Log.Warnf(ctx, "worker: Ignored the queue tags, queue=%s, err=%+v", worker.Config.QueueName, err)
+		return
+	}
+	if v := o.visibilityTimeout; v != nil && *v >= 0 && *v <= 43200 {
+		worker.Config.VisibilityTimeout = *v
+	}
+	if d := o.drainTimeout; d != nil && *d > 0 {
+		worker.Config.DrainTimeout = *d
+	}
+	worker.Log.Infof(ctx, "worker: Applied the queue tags, queue=%s, concurrency=%d, rate_limit=%v, max_number_of_message=%d, wait_time_second=%d, visibility_timeout=%d, drain_timeout=%s",
+		worker.Config.QueueName, worker.Config.Concurrency, worker.Config.RateLimit, worker.Config.MaxNumberOfMessage,
+		worker.Config.WaitTimeSecond, worker.Config.VisibilityTimeout, worker.Config.DrainTimeout)
+}
@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockedTagsSqsClient struct {
+	*mockedSqsClient
+	Tags map[string]string
+}
+
+func (c *mockedTagsSqsClient) ListQueueTags(ctx context.Context, input *sqs.ListQueueTagsInput, optFns ...func(*sqs.Options)) (*sqs.ListQueueTagsOutput, error) {
+	return &sqs.ListQueueTagsOutput{Tags: c.Tags}, nil
+}
+
+func TestQueueTagOverrides(t *testing.T) {
+	client := &mockedTagsSqsClient{
+		mockedSqsClient: &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}},
+		Tags: map[string]string{
+			"worker:concurrency":           "4",
+			"worker:rate-limit":            "2.5",
+			"worker:max-number-of-message": "5",
+			"worker:visibility-timeout":    "120",
+			"worker:drain-timeout":         "1m",
+			"worker:wait-time-second":      "forever",
+			"worker:unknown":               "1",
+			"team":                         "risken",
+		},
+	}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Concurrency: 10, QueueTagOverrides: true})
+
+	assert.Equal(t, 4, worker.Config.Concurrency)
+	assert.Equal(t, 4, worker.sem.limit, "the concurrency takes effect")
+	assert.Equal(t, 2.5, worker.Config.RateLimit)
+	assert.Equal(t, int32(5), worker.Config.MaxNumberOfMessage)
+	assert.Equal(t, int32(120), worker.Config.VisibilityTimeout)
+	assert.Equal(t, time.Minute, worker.Config.DrainTimeout)
+	assert.Equal(t, int32(20), worker.Config.WaitTimeSecond, "the invalid tag is ignored")
+}
+
+func TestParseQueueTags(t *testing.T) {
+	o, errs := parseQueueTags(map[string]string{"worker:concurrency": "x", "worker:handler-timeout": "1m", "env": "prod"})
+	assert.Nil(t, o.patch.Concurrency)
+	assert.Len(t, errs, 2)
+}
+
+func TestQueueTagOverridesDisabled(t *testing.T) {
+	client := &mockedTagsSqsClient{
+		mockedSqsClient: &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}},
+		Tags:            map[string]string{"worker:concurrency": "4"},
+	}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", Concurrency: 10})
+	assert.Equal(t, 10, worker.Config.Concurrency)
+}
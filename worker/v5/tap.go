@@ -0,0 +1,21 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// tapped reports whether the worker leaves the message as is by the TapMode, instead of deleting it,
+// sending its copy or changing its visibility, and counts and logs it
+func (worker *Worker) tapped(ctx context.Context, m *types.Message, action string) bool {
+	if !worker.Config.TapMode {
+		return false
+	}
+	worker.stats.addTapped()
+	if worker.logEnabled(LogEventTapped) {
+		worker.logEvent(ctx, LogEventTapped, "worker: Left the message in the queue by the TapMode, id=%s, skipped=%s", aws.ToString(m.MessageId), action)
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type tapSqsClient struct {
+	recordingSenderSqsClient
+	visibilityChanged int64
+}
+
+func (c *tapSqsClient) ChangeMessageVisibility(ctx context.Context, input *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	atomic.AddInt64(&c.visibilityChanged, 1)
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func TestTapMode(t *testing.T) {
+	m := func() *types.Message {
+		return &types.Message{MessageId: aws.String("m"), ReceiptHandle: aws.String("m")}
+	}
+
+	t.Run("succeeded", func(t *testing.T) {
+		client := &tapSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", TapMode: true})
+		outcome, err := worker.handle(context.Background(), m(), HandlerFunc(func(msg *types.Message) error { return nil }))
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeSucceeded, outcome)
+		assert.Zero(t, atomic.LoadInt64(&client.deleted), "the message is left in the queue")
+		assert.Equal(t, int64(1), worker.Stats().Tapped)
+	})
+
+	t.Run("retried", func(t *testing.T) {
+		client := &tapSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", TapMode: true,
+			RetryQueueURL: "https://sqs.eu-west-1.amazonaws.com/123456789012/my-sqs-queue-retry"})
+		_, _ = worker.handle(context.Background(), m(), HandlerFunc(func(msg *types.Message) error { return errors.New("failed") }))
+		assert.Empty(t, client.sent, "no copy is sent to the retry queue")
+		assert.Zero(t, atomic.LoadInt64(&client.deleted))
+	})
+
+	t.Run("visibility", func(t *testing.T) {
+		client := &tapSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", TapMode: true})
+		assert.NoError(t, worker.changeVisibility(context.Background(), m(), 0))
+		assert.Zero(t, atomic.LoadInt64(&client.visibilityChanged))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		client := &tapSqsClient{}
+		worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue"})
+		assert.NoError(t, worker.deleteMessage(context.Background(), m()))
+		assert.NoError(t, worker.changeVisibility(context.Background(), m(), 0))
+		assert.Equal(t, int64(1), atomic.LoadInt64(&client.deleted))
+		assert.Equal(t, int64(1), atomic.LoadInt64(&client.visibilityChanged))
+		assert.Zero(t, worker.Stats().Tapped)
+	})
+}
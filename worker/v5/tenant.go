@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// TenantConfigFunc returns the aws.Config of the tenant of the message (e.g. from the tenant account ID in the message).
+// The config is injected into the handler context, and it's available to the ContextHandler by TenantConfigFromContext.
+type TenantConfigFunc func(ctx context.Context, m *types.Message) (aws.Config, error)
+
+// TenantRole is the role assumed for the tenant
+type TenantRole struct {
+	RoleARN    string
+	ExternalID string
+}
+
+// TenantRoleFunc returns the role of the tenant of the message
+type TenantRoleFunc func(ctx context.Context, m *types.Message) (*TenantRole, error)
+
+type tenantConfigKey struct{}
+
+// TenantConfigFromContext returns the aws.Config of the tenant injected by Config.TenantConfig
+func TenantConfigFromContext(ctx context.Context) (aws.Config, bool) {
+	cfg, ok := ctx.Value(tenantConfigKey{}).(aws.Config)
+	return cfg, ok
+}
+
+// AssumeRoleTenantConfig returns the TenantConfigFunc assuming the role of the tenant with the base config.
+// The credentials are cached per role and refreshed before the expiration.
+func AssumeRoleTenantConfig(base aws.Config, role TenantRoleFunc) TenantConfigFunc {
+	client := sts.NewFromConfig(base)
+	var mu sync.Mutex
+	caches := map[TenantRole]*aws.CredentialsCache{}
+	return func(ctx context.Context, m *types.Message) (aws.Config, error) {
+		r, err := role(ctx, m)
+		if err != nil {
+			return aws.Config{}, err
+		}
+		mu.Lock()
+		cache, ok := caches[*r]
+		if !ok {
+			cache = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(client, r.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+				if r.ExternalID != "" {
+					o.ExternalID = aws.String(r.ExternalID)
+				}
+			}))
+			caches[*r] = cache
+		}
+		mu.Unlock()
+		cfg := base.Copy()
+		cfg.Credentials = cache
+		return cfg, nil
+	}
+}
+
+// tenantContext injects the tenant config into the context when Config.TenantConfig is set
+func (worker *Worker) tenantContext(ctx context.Context, m *types.Message) (context.Context, error) {
+	if worker.Config.TenantConfig == nil {
+		return ctx, nil
+	}
+	cfg, err := worker.Config.TenantConfig(ctx, m)
+	if err != nil {
+		return ctx, fmt.Errorf("worker: failed to get the tenant config, id=%s, err=%w", aws.ToString(m.MessageId), err)
+	}
+	return context.WithValue(ctx, tenantConfigKey{}, cfg), nil
+}
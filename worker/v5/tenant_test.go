@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantConfig(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{
+		QueueName: "my-sqs-queue",
+		TenantConfig: func(ctx context.Context, m *types.Message) (aws.Config, error) {
+			tenant := m.MessageAttributes["TenantID"].StringValue
+			if tenant == nil {
+				return aws.Config{}, errors.New("no tenant")
+			}
+			return aws.Config{Region: aws.ToString(tenant)}, nil
+		},
+	})
+	var region string
+	h := ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+		cfg, ok := TenantConfigFromContext(ctx)
+		assert.True(t, ok)
+		region = cfg.Region
+		return nil
+	})
+
+	m := &types.Message{
+		MessageId:         aws.String("id"),
+		ReceiptHandle:     aws.String("receipt"),
+		MessageAttributes: map[string]types.MessageAttributeValue{"TenantID": {DataType: aws.String("String"), StringValue: aws.String("tenant-a")}},
+	}
+	assert.NoError(t, worker.handleMessage(context.Background(), m, h))
+	assert.Equal(t, "tenant-a", region)
+
+	err := worker.handleMessage(context.Background(), &types.Message{MessageId: aws.String("no-tenant")}, h)
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), worker.Stats().Failed)
+}
+
+func TestAssumeRoleTenantConfig(t *testing.T) {
+	tenantConfig := AssumeRoleTenantConfig(aws.Config{Region: "ap-northeast-1"}, func(ctx context.Context, m *types.Message) (*TenantRole, error) {
+		return &TenantRole{RoleARN: "arn:aws:iam::123456789012:role/" + aws.ToString(m.MessageId), ExternalID: "external"}, nil
+	})
+	a1, err := tenantConfig(context.Background(), &types.Message{MessageId: aws.String("a")})
+	assert.NoError(t, err)
+	a2, _ := tenantConfig(context.Background(), &types.Message{MessageId: aws.String("a")})
+	b, _ := tenantConfig(context.Background(), &types.Message{MessageId: aws.String("b")})
+
+	assert.Equal(t, "ap-northeast-1", a1.Region)
+	assert.Same(t, a1.Credentials, a2.Credentials, "the credentials are cached per role")
+	assert.NotSame(t, a1.Credentials, b.Credentials)
+}
@@ -0,0 +1,15 @@
+package worker
+
+import "context"
+
+// cooldown waits for the ThrottleCooldown after the receive is throttled, and reports false when the context is done
+func (worker *Worker) cooldown(ctx context.Context) bool {
+	timer := worker.clock().NewTimer(worker.Config.ThrottleCooldown)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C():
+		return true
+	}
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptedReceiveSqsClient returns the errors in order from ReceiveMessage, and cancels the context after them
+type scriptedReceiveSqsClient struct {
+	*mockedSqsClient
+	mu     sync.Mutex
+	errs   []error
+	calls  []time.Time
+	cancel context.CancelFunc
+}
+
+func (c *scriptedReceiveSqsClient) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, time.Now())
+	if len(c.errs) == 0 {
+		c.cancel()
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+	err := c.errs[0]
+	c.errs = c.errs[1:]
+	return nil, err
+}
+
+func TestIsThrottled(t *testing.T) {
+	assert.True(t, IsThrottled(&smithy.GenericAPIError{Code: "OverLimit"}))
+	assert.True(t, IsThrottled(fmt.Errorf("operation error: %w", &smithy.GenericAPIError{Code: "RequestThrottled"})))
+	assert.False(t, IsThrottled(&smithy.GenericAPIError{Code: "AccessDenied"}))
+	assert.False(t, IsThrottled(errors.New("connection reset by peer")))
+}
+
+func TestThrottleCooldown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := &scriptedReceiveSqsClient{
+		mockedSqsClient: &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}},
+		errs:            []error{errors.New("connection reset by peer"), &smithy.GenericAPIError{Code: "OverLimit"}},
+		cancel:          cancel,
+	}
+	worker := New(ctx, client, &Config{QueueName: "my-sqs-queue", ThrottleCooldown: 50 * time.Millisecond})
+	worker.Start(ctx, HandlerFunc(func(msg *types.Message) error { return nil }))
+
+	assert.Len(t, client.calls, 3)
+	assert.True(t, client.calls[1].Sub(client.calls[0]) < 50*time.Millisecond, "the transient error is not cooled down")
+	assert.True(t, client.calls[2].Sub(client.calls[1]) >= 50*time.Millisecond, "the throttling is cooled down")
+	assert.Equal(t, int64(1), worker.Stats().Throttled)
+}
@@ -0,0 +1,102 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Transformer transforms the message before it's dispatched to the handler (e.g. decompress, decrypt, unwrap the envelope, validate).
+// The message is a shallow copy of the received one, so the transformer can replace the fields (e.g. Body) in place,
+// while the received message is kept as is for the delete and the retry.
+// Replace the maps (e.g. MessageAttributes) rather than modifying them, since they are shared with the received message.
+type Transformer interface {
+	Transform(ctx context.Context, msg *types.Message) error
+}
+
+// TransformerFunc is used to define the Transformer
+type TransformerFunc func(ctx context.Context, msg *types.Message) error
+
+// Transform wraps a function for transforming the message
+func (f TransformerFunc) Transform(ctx context.Context, msg *types.Message) error {
+	return f(ctx, msg)
+}
+
+// TransformStage is a named stage of the transformation pipeline
+type TransformStage struct {
+	// Name is the name of the stage reported in the error and the TransformStats
+	Name        string
+	Transformer Transformer
+	// MapError maps the error of the stage when set, e.g. to NewInvalidEventError to delete the message that can never be decoded.
+	// Otherwise the error is handled as a failure of the handler.
+	MapError func(err error) error
+}
+
+// TransformStats is a snapshot of the statistics of a TransformStage
+type TransformStats struct {
+	Name string
+	// Transformed is the number of messages transformed by the stage successfully
+	Transformed int64
+	// Failed is the number of messages the stage failed to transform
+	Failed int64
+	// Duration is the total time spent in the stage
+	Duration time.Duration
+}
+
+type transformStage struct {
+	TransformStage
+	transformed int64
+	failed      int64
+	nanos       int64
+}
+
+func newTransformStages(stages []TransformStage) []*transformStage {
+	if len(stages) == 0 {
+		return nil
+	}
+	transforms := make([]*transformStage, len(stages))
+	for i := range stages {
+		transforms[i] = &transformStage{TransformStage: stages[i]}
+	}
+	return transforms
+}
+
+// transform runs the pipeline in order on a copy of the message, and returns the message for the handler.
+// The received message is returned as is without any stage.
+func (worker *Worker) transform(ctx context.Context, m *types.Message) (*types.Message, error) {
+	if len(worker.transforms) == 0 {
+		return m, nil
+	}
+	msg := *m
+	for _, s := range worker.transforms {
+		start := time.Now()
+		err := s.Transformer.Transform(ctx, &msg)
+		atomic.AddInt64(&s.nanos, int64(time.Since(start)))
+		if err != nil {
+			atomic.AddInt64(&s.failed, 1)
+			if s.MapError != nil {
+				return nil, s.MapError(err)
+			}
+			return nil, fmt.Errorf("worker: failed to transform the message, stage=%s: %w", s.Name, err)
+		}
+		atomic.AddInt64(&s.transformed, 1)
+	}
+	return &msg, nil
+}
+
+// TransformStats returns the statistics of the transformation pipeline in the order of Config.Transformers
+func (worker *Worker) TransformStats() []TransformStats {
+	stats := make([]TransformStats, len(worker.transforms))
+	for i, s := range worker.transforms {
+		stats[i] = TransformStats{
+			Name:        s.Name,
+			Transformed: atomic.LoadInt64(&s.transformed),
+			Failed:      atomic.LoadInt64(&s.failed),
+			Duration:    time.Duration(atomic.LoadInt64(&s.nanos)),
+		}
+	}
+	return stats
+}
@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func upperTransformer() TransformerFunc {
+	return func(ctx context.Context, msg *types.Message) error {
+		msg.Body = aws.String(strings.ToUpper(aws.ToString(msg.Body)))
+		return nil
+	}
+}
+
+func TestTransformers(t *testing.T) {
+	client := &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}
+	client.On("DeleteMessage", mock.Anything).Return()
+	worker := New(context.Background(), client, &Config{
+		QueueName: "my-sqs-queue",
+		Transformers: []TransformStage{
+			{Name: "upper", Transformer: upperTransformer()},
+			{Name: "envelope", Transformer: TransformerFunc(func(ctx context.Context, msg *types.Message) error {
+				msg.Body = aws.String("<" + aws.ToString(msg.Body) + ">")
+				return nil
+			})},
+		},
+	})
+	m := &types.Message{Body: aws.String("scan"), ReceiptHandle: aws.String("scan")}
+	var received string
+	err := worker.handleMessage(context.Background(), m, HandlerFunc(func(msg *types.Message) error {
+		received = aws.ToString(msg.Body)
+		return nil
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "<SCAN>", received, "the stages are run in order")
+	assert.Equal(t, "scan", aws.ToString(m.Body), "the received message is kept as is")
+	stats := worker.TransformStats()
+	assert.Equal(t, "upper", stats[0].Name)
+	assert.Equal(t, int64(1), stats[0].Transformed)
+	assert.Equal(t, int64(1), stats[1].Transformed)
+}
+
+func TestTransformerError(t *testing.T) {
+	t.Run("failed", func(t *testing.T) {
+		worker := New(context.Background(), &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}, &Config{
+			QueueName: "my-sqs-queue",
+			Transformers: []TransformStage{
+				{Name: "decrypt", Transformer: TransformerFunc(func(ctx context.Context, msg *types.Message) error {
+					return errors.New("kms unavailable")
+				})},
+				{Name: "upper", Transformer: upperTransformer()},
+			},
+		})
+		called := false
+		err := worker.handleMessage(context.Background(), &types.Message{Body: aws.String("scan")}, HandlerFunc(func(msg *types.Message) error {
+			called = true
+			return nil
+		}))
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "stage=decrypt")
+		assert.False(t, called, "the handler is not called")
+		stats := worker.TransformStats()
+		assert.Equal(t, int64(1), stats[0].Failed)
+		assert.Equal(t, int64(0), stats[1].Transformed, "the later stages are skipped")
+	})
+
+	t.Run("mapped to invalid", func(t *testing.T) {
+		client := &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}
+		client.On("DeleteMessage", mock.Anything).Return()
+		worker := New(context.Background(), client, &Config{
+			QueueName: "my-sqs-queue",
+			Transformers: []TransformStage{
+				{
+					Name: "decompress",
+					Transformer: TransformerFunc(func(ctx context.Context, msg *types.Message) error {
+						return errors.New("gzip: invalid header")
+					}),
+					MapError: func(err error) error { return NewInvalidEventError("decompress", err.Error()) },
+				},
+			},
+		})
+		outcome, err := worker.handle(context.Background(), &types.Message{Body: aws.String("scan"), ReceiptHandle: aws.String("scan")}, HandlerFunc(func(msg *types.Message) error {
+			return nil
+		}))
+
+		assert.NoError(t, err)
+		assert.Equal(t, OutcomeInvalid, outcome, "the undecodable message is deleted")
+		client.AssertNumberOfCalls(t, "DeleteMessage", 1)
+	})
+}
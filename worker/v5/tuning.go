@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Tuning is the JSON of the live tunables served and updated by TuningHandler.
+// Nil fields of the update are left unchanged.
+type Tuning struct {
+	QueueName   string   `json:"queue_name,omitempty"`
+	Concurrency *int     `json:"concurrency,omitempty"`
+	RateLimit   *float64 `json:"rate_limit,omitempty"`
+}
+
+// SetConcurrency resizes the maximum number of concurrent handlers immediately (0 means unlimited).
+// The running handlers over the new limit are not interrupted, and the new handlers wait for the slots.
+func (worker *Worker) SetConcurrency(n int) error {
+	return worker.UpdateConfig(ConfigPatch{Concurrency: &n})
+}
+
+// SetRateLimit changes the maximum messages per second immediately (0 means unlimited)
+func (worker *Worker) SetRateLimit(perSecond float64) error {
+	return worker.UpdateConfig(ConfigPatch{RateLimit: &perSecond})
+}
+
+// tuning returns the current tunables
+func (worker *Worker) tuning() Tuning {
+	worker.mu.Lock()
+	defer worker.mu.Unlock()
+	concurrency, rateLimit := worker.Config.Concurrency, worker.Config.RateLimit
+	return Tuning{QueueName: worker.Config.QueueName, Concurrency: &concurrency, RateLimit: &rateLimit}
+}
+
+// TuningHandler returns the http.Handler for the admin endpoint throttling the worker during an incident.
+// GET serves the current Tuning, and PUT or POST applies the Tuning in the body and serves the result, e.g.
+//
+//	curl -X PUT -d '{"concurrency":2,"rate_limit":5}' http://localhost:8080/tuning
+func (worker *Worker) TuningHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var t Tuning
+			if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+				http.Error(w, "invalid tuning: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := worker.UpdateConfig(ConfigPatch{Concurrency: t.Concurrency, RateLimit: t.RateLimit}); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			current := worker.tuning()
+			worker.Log.Warnf(r.Context(), "worker: Tuned the worker by the admin endpoint, queue=%s, concurrency=%d, rate_limit=%v",
+				current.QueueName, *current.Concurrency, *current.RateLimit)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(worker.tuning())
+	})
+}
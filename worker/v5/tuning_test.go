@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetConcurrency(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", Concurrency: 1})
+	assert.NoError(t, worker.sem.acquire(context.Background()))
+
+	acquired := make(chan struct{})
+	go func() {
+		_ = worker.sem.acquire(context.Background())
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("the second handler must wait for the slot")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.NoError(t, worker.SetConcurrency(2))
+	<-acquired
+	assert.Equal(t, 2, worker.Config.Concurrency)
+
+	assert.Error(t, worker.SetConcurrency(-1))
+}
+
+func TestSetRateLimit(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+	assert.NoError(t, worker.SetRateLimit(5))
+	assert.Equal(t, 5.0, float64(worker.limiter.Limit()))
+	assert.Error(t, worker.SetRateLimit(-1))
+}
+
+func TestTuningHandler(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", Concurrency: 10})
+	handler := worker.TuningHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/tuning", strings.NewReader(`{"rate_limit":2.5}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got Tuning
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "my-sqs-queue", got.QueueName)
+	assert.Equal(t, 10, *got.Concurrency, "the concurrency is left unchanged")
+	assert.Equal(t, 2.5, *got.RateLimit)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/tuning", strings.NewReader(`{"concurrency":-1}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/tuning", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
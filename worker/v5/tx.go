@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Tx is the transaction used by TxHandler (e.g. *sql.Tx)
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
+
+// TxBeginFunc begins a new transaction
+type TxBeginFunc func(ctx context.Context) (Tx, error)
+
+// TxHandlerFunc handles the message within the transaction
+type TxHandlerFunc func(ctx context.Context, tx Tx, msg *types.Message) error
+
+// TxHandler processes messages in the order of begin → handle → commit → delete.
+// The message is deleted by the worker only after the transaction is committed,
+// so on commit failure the message is left in the queue and redelivered.
+// Note that the message is redelivered if the delete fails after the commit, so the handler should be idempotent
+// (e.g. record the message ID in the same transaction).
+type TxHandler struct {
+	Begin  TxBeginFunc
+	Handle TxHandlerFunc
+}
+
+// NewTxHandler creates TxHandler struct
+func NewTxHandler(begin TxBeginFunc, handle TxHandlerFunc) *TxHandler {
+	return &TxHandler{Begin: begin, Handle: handle}
+}
+
+// HandleMessage handles the message with the background context
+func (h *TxHandler) HandleMessage(msg *types.Message) error {
+	return h.HandleMessageWithContext(context.Background(), msg)
+}
+
+// HandleMessageWithContext handles the message within the transaction
+func (h *TxHandler) HandleMessageWithContext(ctx context.Context, msg *types.Message) error {
+	tx, err := h.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("worker: failed to begin the transaction, err=%w", err)
+	}
+	if err := h.Handle(ctx, tx, msg); err != nil {
+		if rerr := tx.Rollback(); rerr != nil {
+			return fmt.Errorf("worker: failed to rollback the transaction, rollback_err=%+v: %w", rerr, err)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("worker: failed to commit the transaction, err=%w", err)
+	}
+	return nil
+}
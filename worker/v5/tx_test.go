@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockedTx struct {
+	mock.Mock
+}
+
+func (tx *mockedTx) Commit() error {
+	return tx.Called().Error(0)
+}
+
+func (tx *mockedTx) Rollback() error {
+	return tx.Called().Error(0)
+}
+
+func TestTxHandler(t *testing.T) {
+	client := &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}
+	client.On("DeleteMessage", mock.Anything).Return()
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue"})
+	m := &types.Message{Body: aws.String("body"), ReceiptHandle: aws.String("receipt")}
+
+	cases := []struct {
+		name       string
+		handleErr  error
+		commitErr  error
+		wantTx     string
+		wantErr    bool
+		wantDelete bool
+	}{
+		{name: "committed message is deleted", wantTx: "Commit", wantDelete: true},
+		{name: "commit failure leaves the message", commitErr: errors.New("commit"), wantTx: "Commit", wantErr: true},
+		{name: "handler failure rolls back", handleErr: errors.New("handle"), wantTx: "Rollback", wantErr: true},
+		{name: "invalid event rolls back and is deleted", handleErr: NewInvalidEventError("event", "msg"), wantTx: "Rollback", wantDelete: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tx := &mockedTx{}
+			tx.On("Commit").Return(c.commitErr)
+			tx.On("Rollback").Return(nil)
+			h := NewTxHandler(
+				func(ctx context.Context) (Tx, error) { return tx, nil },
+				func(ctx context.Context, tx Tx, msg *types.Message) error { return c.handleErr },
+			)
+
+			before := len(client.Calls)
+			err := worker.handleMessage(context.Background(), m, h)
+			assert.Equal(t, c.wantErr, err != nil)
+			tx.AssertCalled(t, c.wantTx)
+			assert.Len(t, tx.Calls, 1, "the transaction is finished once")
+			assert.Equal(t, c.wantDelete, len(client.Calls) > before, "the message is deleted only after commit")
+		})
+	}
+}
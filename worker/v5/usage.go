@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// Usage is the metadata of the work done by the handler for a message, e.g. items processed and bytes scanned.
+// It's the basis of the per-tenant usage accounting with the correlation fields of the context.
+type Usage map[string]int64
+
+// RecordUsage adds the value to the usage of the message from the context of the ContextHandler, e.g.
+//
+//	worker.RecordUsage(ctx, "findings", int64(len(findings)))
+//
+// The usage is passed to the Hooks.OnUsage and the AuditRecord, and summed up in Worker.Usage.
+// It's a no-op outside of the handler context.
+func RecordUsage(ctx context.Context, name string, value int64) {
+	p, ok := ctx.Value(progressKey{}).(*progress)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.usage == nil {
+		p.usage = Usage{}
+	}
+	p.usage[name] += value
+}
+
+// usageTotals is the sum of the usage of all messages processed by the worker
+type usageTotals struct {
+	mu     sync.Mutex
+	totals Usage
+}
+
+func (u *usageTotals) add(usage Usage) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.totals == nil {
+		u.totals = Usage{}
+	}
+	for k, v := range usage {
+		u.totals[k] += v
+	}
+}
+
+// Usage returns the sum of the usage recorded by the handlers since the worker was created
+func (worker *Worker) Usage() Usage {
+	worker.usage.mu.Lock()
+	defer worker.usage.mu.Unlock()
+	usage := make(Usage, len(worker.usage.totals))
+	for k, v := range worker.usage.totals {
+		usage[k] = v
+	}
+	return usage
+}
+
+// reportUsage aggregates the usage of the message and passes it to the hook
+func (worker *Worker) reportUsage(ctx context.Context, usage Usage) {
+	if len(usage) == 0 {
+		return
+	}
+	worker.usage.add(usage)
+	if worker.Config.Hooks.OnUsage != nil {
+		worker.Config.Hooks.OnUsage(ctx, usage)
+	}
+}
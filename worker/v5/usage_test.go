@@ -0,0 +1,142 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type recordingAuditSink struct {
+	records []*AuditRecord
+}
+
+func (s *recordingAuditSink) Record(ctx context.Context, record *AuditRecord) error {
+	s.records = append(s.records, record)
+	return nil
+}
+
+func TestRecordUsage(t *testing.T) {
+	client := &mockedSqsClient{Config: &aws.Config{Region: "eu-west-1"}}
+	client.On("DeleteMessage", mock.Anything).Return()
+	var hooked []Usage
+	var projects []interface{}
+	sink := &recordingAuditSink{}
+	worker := New(context.Background(), client, &Config{
+		QueueName:       "my-sqs-queue",
+		AuditSink:       sink,
+		FieldsExtractor: RISKENFields,
+		Hooks: Hooks{
+			OnUsage: func(ctx context.Context, usage Usage) {
+				hooked = append(hooked, usage)
+				projects = append(projects, LogFieldsFromContext(ctx)["project_id"])
+			},
+		},
+	})
+	h := ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+		RecordUsage(ctx, "findings", 2)
+		RecordUsage(ctx, "findings", 3)
+		RecordUsage(ctx, "bytes_scanned", 1024)
+		return nil
+	})
+	for i := 0; i < 2; i++ {
+		err := worker.handleMessage(context.Background(), &types.Message{
+			MessageId:     aws.String("id"),
+			Body:          aws.String(`{"project_id":1001}`),
+			ReceiptHandle: aws.String("receipt"),
+		}, h)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, []Usage{{"findings": 5, "bytes_scanned": 1024}, {"findings": 5, "bytes_scanned": 1024}}, hooked)
+	assert.Equal(t, []interface{}{uint64(1001), uint64(1001)}, projects, "the usage is attributed to the tenant by the correlation fields")
+	assert.Equal(t, Usage{"findings": 5, "bytes_scanned": 1024}, sink.records[0].Usage)
+	assert.Equal(t, Usage{"findings": 10, "bytes_scanned": 2048}, worker.Usage())
+}
+
+func TestRecordUsageOutsideHandler(t *testing.T) {
+	RecordUsage(context.Background(), "findings", 1)
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+	assert.Empty(t, worker.Usage())
+}
+
+func TestAuditProcessedAt(t *testing.T) {
+	clock := &settableClock{now: time.Unix(1650000000, 0)}
+	sink := &recordingAuditSink{}
+	worker := New(context.Background(), &countingDeleteSqsClient{}, &Config{QueueName: "my-sqs-queue", AuditSink: sink, Clock: clock})
+	_, err := worker.handle(context.Background(), &types.Message{MessageId: aws.String("m"), ReceiptHandle: aws.String("m")}, HandlerFunc(func(msg *types.Message) error { return nil }))
+	assert.NoError(t, err)
+	if assert.Len(t, sink.records, 1) {
+		assert.Equal(t, clock.now, sink.records[0].ProcessedAt, "the time of the Clock")
+	}
+}
+
+// ctxAuditSink records the error of the context of each Record
+type ctxAuditSink struct {
+	errs []error
+}
+
+func (s *ctxAuditSink) Record(ctx context.Context, record *AuditRecord) error {
+	s.errs = append(s.errs, ctx.Err())
+	return nil
+}
+
+func TestAuditContext(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		config Config
+	}{
+		{name: "CancelOnVisibilityExpiry", config: Config{VisibilityTimeout: 30, CancelOnVisibilityExpiry: true}},
+		{name: "CorrelationKey", config: Config{CorrelationKey: func(msg *types.Message) string { return "scan-1" }}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			sink := &ctxAuditSink{}
+			var usageErrs []error
+			config := c.config
+			config.QueueName = "my-sqs-queue"
+			config.AuditSink = sink
+			config.Hooks.OnUsage = func(ctx context.Context, usage Usage) { usageErrs = append(usageErrs, ctx.Err()) }
+			worker := New(context.Background(), &countingDeleteSqsClient{}, &config)
+			_, err := worker.handle(context.Background(), &types.Message{MessageId: aws.String("m"), Body: aws.String(`{}`), ReceiptHandle: aws.String("m")}, ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+				RecordUsage(ctx, "findings", 1)
+				return nil
+			}))
+			assert.NoError(t, err)
+			assert.Equal(t, []error{nil}, sink.errs, "the audit is recorded with the context not canceled by the watch")
+			assert.Equal(t, []error{nil}, usageErrs, "the usage is reported with the context not canceled by the watch")
+		})
+	}
+}
+
+func TestAuditSkippedMessages(t *testing.T) {
+	sink := &recordingAuditSink{}
+	worker := New(context.Background(), &countingDeleteSqsClient{}, &Config{
+		QueueName:      "my-sqs-queue",
+		AuditSink:      sink,
+		MaxHops:        1,
+		HopLimitAction: HopLimitDrop,
+		MaxMessageAge:  time.Hour,
+		CorrelationKey: func(msg *types.Message) string { return "scan-1" },
+	})
+	ctx := context.Background()
+	looping := &types.Message{MessageId: aws.String("looping"), ReceiptHandle: aws.String("looping"), MessageAttributes: map[string]types.MessageAttributeValue{
+		HopCountAttribute: {DataType: aws.String("Number"), StringValue: aws.String("2")},
+	}}
+	_, _ = worker.handle(ctx, looping, HandlerFunc(func(msg *types.Message) error { return nil }))
+	_, _ = worker.handle(ctx, sentAgo("stale", 2*time.Hour), HandlerFunc(func(msg *types.Message) error { return nil }))
+	_, _ = worker.handle(ctx, &types.Message{MessageId: aws.String("abort"), ReceiptHandle: aws.String("abort")}, ContextHandlerFunc(func(ctx context.Context, msg *types.Message) error {
+		CancelRelated(ctx, "scan-1")
+		return nil
+	}))
+	_, _ = worker.handle(ctx, &types.Message{MessageId: aws.String("canceled"), ReceiptHandle: aws.String("canceled")}, HandlerFunc(func(msg *types.Message) error { return nil }))
+
+	var outcomes []Outcome
+	for _, r := range sink.records {
+		outcomes = append(outcomes, r.Outcome)
+	}
+	assert.Equal(t, []Outcome{OutcomeDropped, OutcomeExpired, OutcomeSucceeded, OutcomeCanceled}, outcomes, "every terminal outcome is audited")
+}
@@ -3,10 +3,12 @@ package worker
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 )
 
 func (config *Config) populateDefaultValues() {
@@ -17,23 +19,140 @@ func (config *Config) populateDefaultValues() {
 	if config.WaitTimeSecond == 0 {
 		config.WaitTimeSecond = 20
 	}
+
+	if config.MessageAttributeNames == nil {
+		config.MessageAttributeNames = []string{"All"}
+	}
+
+	if config.MessageSystemAttributeNames == nil {
+		config.MessageSystemAttributeNames = []types.MessageSystemAttributeName{"All"}
+	}
+
+	if config.KeyedLanes <= 0 {
+		config.KeyedLanes = 10
+	}
+
+	if config.PriorityLanes <= 0 {
+		config.PriorityLanes = 3
+	}
+
+	if config.DeadLetterPollInterval <= 0 {
+		config.DeadLetterPollInterval = time.Minute
+	}
+
+	if config.ThrottleCooldown <= 0 {
+		config.ThrottleCooldown = 30 * time.Second
+	}
+	if config.MaxAccessDenied <= 0 {
+		config.MaxAccessDenied = 3
+	}
+
+	if config.HealthProbeInterval <= 0 {
+		config.HealthProbeInterval = 10 * time.Second
+	}
+
+	if config.DrainTimeout <= 0 {
+		config.DrainTimeout = 30 * time.Second
+	}
+	if config.LeakGracePeriod <= 0 {
+		config.LeakGracePeriod = time.Second
+	}
+
+	if len(config.RetryDelays) == 0 {
+		config.RetryDelays = []time.Duration{10 * time.Second, time.Minute, 5 * time.Minute, 15 * time.Minute}
+	}
+
+	if config.MaxRetryAttempts <= 0 {
+		config.MaxRetryAttempts = len(config.RetryDelays)
+	}
+
+	if config.FailureSampling != nil {
+		config.FailureSampling.populateDefaultValues()
+	}
+
+	if config.HandlerRetry != nil {
+		config.HandlerRetry.populateDefaultValues()
+	}
+
+	if config.Mirror != nil {
+		config.Mirror.populateDefaultValues()
+	}
+
+	if config.RequestBudget != nil {
+		config.RequestBudget.populateDefaultValues()
+	}
+
+	if config.ParkTTL <= 0 {
+		config.ParkTTL = 14 * 24 * time.Hour
+	}
+
+	if config.MaxMessageAge > 0 && !hasSystemAttribute(config.MessageSystemAttributeNames, types.MessageSystemAttributeNameSentTimestamp) {
+		config.MessageSystemAttributeNames = append(config.MessageSystemAttributeNames, types.MessageSystemAttributeNameSentTimestamp)
+	}
+
+	if config.CancellationTTL <= 0 {
+		config.CancellationTTL = time.Hour
+	}
+
+	if config.MaxPooledBufferSize <= 0 {
+		config.MaxPooledBufferSize = 1 << 20
+	}
 }
 
-func getQueueURL(ctx context.Context, client QueueAPI, queueName string) (queueURL string) {
+// hasSystemAttribute reports whether the attribute is received with the names
+func hasSystemAttribute(names []types.MessageSystemAttributeName, name types.MessageSystemAttributeName) bool {
+	for _, n := range names {
+		if n == name || n == "All" {
+			return true
+		}
+	}
+	return false
+}
+
+// sqsOptions returns the options applied to every sqs call of the worker
+func (config *Config) sqsOptions() []func(*sqs.Options) {
+	var opts []func(*sqs.Options)
+	if config.Region != "" {
+		region := config.Region
+		opts = append(opts, func(o *sqs.Options) { o.Region = region })
+	}
+	if config.CallOptions != nil {
+		opts = append(opts, config.CallOptions.apply)
+	}
+	return opts
+}
+
+func (worker *Worker) getQueueURL(ctx context.Context, client QueueAPI, queueName string) (string, error) {
+	config := worker.Config
 	params := &sqs.GetQueueUrlInput{
 		QueueName: aws.String(queueName), // Required
 	}
-	response, err := client.GetQueueUrl(ctx, params)
+	if config.QueueOwnerAWSAccountID != "" {
+		params.QueueOwnerAWSAccountId = aws.String(config.QueueOwnerAWSAccountID)
+	}
+	callCtx, finishSpan := worker.startClientSpan(ctx, "GetQueueUrl")
+	response, err := client.GetQueueUrl(callCtx, params, config.sqsOptions()...)
+	finishSpan(err)
+	if err != nil && IsQueueMissing(err) {
+		return "", fmt.Errorf("worker: the queue does not exist, queue=%s, account=%s, err=%w", queueName, config.QueueOwnerAWSAccountID, err)
+	}
 	if err != nil {
-		fmt.Println(err.Error())
-		return
+		return "", fmt.Errorf("worker: failed to get the queue url, queue=%s, err=%w", queueName, err)
 	}
-	queueURL = aws.ToString(response.QueueUrl)
-
-	return
+	return aws.ToString(response.QueueUrl), nil
 }
 
+// CreateSqsClient creates the sqs client for the region(the default region is used if empty) and the custom endpoint
 func CreateSqsClient(ctx context.Context, region, sqsEndpoint string) (QueueDeleteReceiverAPI, error) {
+	return newSqsClient(ctx, region, sqsEndpoint)
+}
+
+// CreateQueueClient creates the sqs client for the Config.Region, which can be passed to New
+func CreateQueueClient(ctx context.Context, config *Config, sqsEndpoint string) (QueueAPI, error) {
+	return newSqsClient(ctx, config.Region, sqsEndpoint)
+}
+
+func newSqsClient(ctx context.Context, region, sqsEndpoint string) (*sqs.Client, error) {
 	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
 		if service != sqs.ServiceID {
 			// returning EndpointNotFoundError will allow the service to fallback to it's default resolution
@@ -52,7 +171,11 @@ func CreateSqsClient(ctx context.Context, region, sqsEndpoint string) (QueueDele
 			SigningRegion: region,
 		}, nil
 	})
-	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithEndpointResolverWithOptions(customResolver))
+	optFns := []func(*awsconfig.LoadOptions) error{awsconfig.WithEndpointResolverWithOptions(customResolver)}
+	if region != "" {
+		optFns = append(optFns, awsconfig.WithRegion(region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, optFns...)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to load aws configuration, err=%+w", err)
 	}
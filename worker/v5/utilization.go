@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LowUtilization configures the OnLowUtilization hook called when the utilization of the receives,
+// the ratio of the receives returning messages, stays below the Threshold for a Window.
+// It's useful for right-sizing the WaitTimeSecond and the replica counts.
+type LowUtilization struct {
+	// Threshold is the utilization(0..1) below which the hook is called
+	Threshold float64
+	// Window is the period the utilization is measured over (default: 5 minutes)
+	Window time.Duration
+}
+
+// UtilizationEvent is passed to the OnLowUtilization hook at the end of the window
+type UtilizationEvent struct {
+	QueueName string
+	// Utilization is the ratio of the non-empty receives in the window
+	Utilization   float64
+	EmptyReceives int64
+	Receives      int64
+	Window        time.Duration
+}
+
+// Utilization returns the effective utilization, the ratio of the receives returning messages (0 before any receive)
+func (s Stats) Utilization() float64 {
+	total := s.EmptyReceives + s.NonEmptyReceives
+	if total == 0 {
+		return 0
+	}
+	return float64(s.NonEmptyReceives) / float64(total)
+}
+
+// utilizationWindow counts the receives in the current window of the LowUtilization
+type utilizationWindow struct {
+	mu       sync.Mutex
+	start    time.Time
+	empty    int64
+	receives int64
+}
+
+// observeReceive counts the successful receive, and calls the OnLowUtilization hook at the end of the window
+func (worker *Worker) observeReceive(ctx context.Context, messages int) {
+	worker.stats.addReceive(messages == 0)
+	config := worker.Config.LowUtilization
+	if config == nil || worker.Config.Hooks.OnLowUtilization == nil {
+		return
+	}
+	window := config.Window
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	w := &worker.utilization
+	w.mu.Lock()
+	now := worker.now()
+	if w.start.IsZero() {
+		w.start = now
+	}
+	w.receives++
+	if messages == 0 {
+		w.empty++
+	}
+	var event *UtilizationEvent
+	if now.Sub(w.start) >= window {
+		event = &UtilizationEvent{
+			QueueName:     worker.Config.QueueName,
+			Utilization:   float64(w.receives-w.empty) / float64(w.receives),
+			EmptyReceives: w.empty,
+			Receives:      w.receives,
+			Window:        now.Sub(w.start),
+		}
+		w.start, w.empty, w.receives = now, 0, 0
+	}
+	w.mu.Unlock()
+	if event != nil && event.Utilization < config.Threshold {
+		worker.Config.Hooks.OnLowUtilization(ctx, event)
+	}
+}
@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUtilization(t *testing.T) {
+	client := &batchesSqsClient{batches: [][]types.Message{testBatch("1"), {}, {}, {}}}
+	var events []*UtilizationEvent
+	worker := New(context.Background(), client, &Config{
+		QueueName:      "my-sqs-queue",
+		LowUtilization: &LowUtilization{Threshold: 0.5, Window: time.Nanosecond},
+		Hooks: Hooks{
+			OnLowUtilization: func(ctx context.Context, event *UtilizationEvent) { events = append(events, event) },
+		},
+	})
+	assert.NoError(t, worker.DrainOnce(context.Background(), HandlerFunc(func(msg *types.Message) error { return nil })))
+
+	stats := worker.Stats()
+	assert.Equal(t, int64(1), stats.NonEmptyReceives)
+	assert.Equal(t, int64(drainEmptyReceives), stats.EmptyReceives)
+	assert.InDelta(t, 0.25, stats.Utilization(), 0.001)
+	assert.Equal(t, 0.0, Stats{}.Utilization())
+
+	assert.NotEmpty(t, events, "the hook is called for the windows below the threshold")
+	for _, event := range events {
+		assert.Equal(t, "my-sqs-queue", event.QueueName)
+		assert.True(t, event.Utilization < 0.5)
+	}
+}
@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// warmPool is the pool of the goroutines pre-spawned by Config.WarmPool while polling,
+// running the messages of the per-batch scheduler instead of a goroutine per message.
+// The submit blocks while all the goroutines are busy, so the size also bounds the parallelism of the batch.
+type warmPool struct {
+	worker *Worker
+	tasks  chan func()
+	busy   int64
+
+	mu   sync.Mutex
+	size int
+	// stops is the stop channel of each running goroutine, nil while not polling
+	stops []chan struct{}
+	// stopped is closed when the polling ends, so that the submit falls back to its own goroutine
+	stopped chan struct{}
+}
+
+func newWarmPool(worker *Worker, size int) *warmPool {
+	if size <= 0 {
+		return nil
+	}
+	return &warmPool{worker: worker, tasks: make(chan func()), size: size}
+}
+
+// start spawns the goroutines, and the returned function stops them after their running task
+func (p *warmPool) start() func() {
+	if p == nil {
+		return func() {}
+	}
+	p.mu.Lock()
+	p.stopped = make(chan struct{})
+	p.stops = nil
+	p.grow(p.size)
+	p.mu.Unlock()
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		close(p.stopped)
+		p.stopped = nil
+		p.stops = nil
+	}
+}
+
+// grow spawns the n goroutines, called with the lock held
+func (p *warmPool) grow(n int) {
+	stopped := p.stopped
+	for i := 0; i < n; i++ {
+		stop := make(chan struct{})
+		p.stops = append(p.stops, stop)
+		p.worker.goOwned(func() { p.run(stop, stopped) })
+	}
+}
+
+func (p *warmPool) run(stop, stopped <-chan struct{}) {
+	for {
+		select {
+		case f := <-p.tasks:
+			atomic.AddInt64(&p.busy, 1)
+			f()
+			atomic.AddInt64(&p.busy, -1)
+		case <-stop:
+			return
+		case <-stopped:
+			return
+		}
+	}
+}
+
+// resize changes the number of the goroutines, the retired ones exit after their running task
+func (p *warmPool) resize(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.size = size
+	if p.stopped == nil {
+		return
+	}
+	if n := size - len(p.stops); n > 0 {
+		p.grow(n)
+		return
+	}
+	for _, stop := range p.stops[size:] {
+		close(stop)
+	}
+	p.stops = p.stops[:size]
+}
+
+// submit runs the function in an idle goroutine of the pool, waiting for one while all are busy,
+// or in its own goroutine when the pool isn't running
+func (p *warmPool) submit(f func()) {
+	p.mu.Lock()
+	stopped := p.stopped
+	p.mu.Unlock()
+	if stopped == nil {
+		p.worker.goOwned(f)
+		return
+	}
+	select {
+	case p.tasks <- f:
+	case <-stopped:
+		p.worker.goOwned(f)
+	}
+}
+
+// stats returns the number of the running goroutines and the busy ones
+func (p *warmPool) stats() (size, busy int64) {
+	if p == nil {
+		return 0, 0
+	}
+	p.mu.Lock()
+	size = int64(len(p.stops))
+	p.mu.Unlock()
+	return size, atomic.LoadInt64(&p.busy)
+}
+
+// goHandler runs the handling of the message in the warm pool when enabled, otherwise in its own goroutine
+func (worker *Worker) goHandler(f func()) {
+	if worker.warm == nil {
+		worker.goOwned(f)
+		return
+	}
+	worker.warm.submit(f)
+}
@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmPool(t *testing.T) {
+	client := &batchSqsClient{batches: make(chan []types.Message, 1)}
+	client.batches <- []types.Message{
+		{MessageId: aws.String("m1"), ReceiptHandle: aws.String("r1")},
+		{MessageId: aws.String("m2"), ReceiptHandle: aws.String("r2")},
+		{MessageId: aws.String("m3"), ReceiptHandle: aws.String("r3")},
+	}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue", WarmPool: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	var handled int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		worker.Start(ctx, HandlerFunc(func(msg *types.Message) error {
+			atomic.AddInt64(&handled, 1)
+			<-release
+			return nil
+		}))
+	}()
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt64(&handled) == 2 }, time.Second, time.Millisecond)
+	stats := worker.Stats()
+	assert.Equal(t, int64(2), stats.WarmPoolSize)
+	assert.Equal(t, int64(2), stats.WarmPoolBusy)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&handled), "the third message waits for an idle goroutine")
+
+	size := 3
+	assert.NoError(t, worker.UpdateConfig(ConfigPatch{WarmPool: &size}))
+	assert.Eventually(t, func() bool { return atomic.LoadInt64(&handled) == 3 }, time.Second, time.Millisecond, "the grown pool picks up the third message")
+	assert.Equal(t, int64(3), worker.Stats().WarmPoolSize)
+
+	size = 1
+	assert.NoError(t, worker.UpdateConfig(ConfigPatch{WarmPool: &size}))
+	close(release)
+	assert.Eventually(t, func() bool { return worker.Stats().WarmPoolBusy == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, int64(1), worker.Stats().WarmPoolSize)
+
+	cancel()
+	<-done
+	assert.Eventually(t, func() bool {
+		stats := worker.Stats()
+		return stats.WarmPoolSize == 0 && stats.Goroutines == 0
+	}, time.Second, time.Millisecond, "the goroutines exit when the polling ends")
+}
+
+func TestWarmPoolUpdateConfig(t *testing.T) {
+	size := 0
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", WarmPool: 2})
+	assert.Error(t, worker.UpdateConfig(ConfigPatch{WarmPool: &size}))
+
+	size = 2
+	concurrency := 3
+	worker = New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", Concurrency: 1})
+	assert.EqualError(t, worker.UpdateConfig(ConfigPatch{Concurrency: &concurrency, WarmPool: &size}), "worker: the WarmPool is not enabled", "the WarmPool must be enabled at New")
+	assert.Equal(t, 1, worker.Config.Concurrency, "none of the patch is applied")
+}
+
+func TestWarmPoolNotPolling(t *testing.T) {
+	worker := New(context.Background(), &countingDeleteSqsClient{}, &Config{QueueName: "my-sqs-queue", WarmPool: 1})
+	outcomes := worker.run(context.Background(), HandlerFunc(func(msg *types.Message) error { return nil }), []types.Message{
+		{MessageId: aws.String("m1"), ReceiptHandle: aws.String("r1")},
+		{MessageId: aws.String("m2"), ReceiptHandle: aws.String("r2")},
+	})
+	assert.Equal(t, 2, outcomes[OutcomeSucceeded], "the messages run in their own goroutines while not polling")
+}
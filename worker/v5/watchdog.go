@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// StuckHandlerEvent is the diagnostics of the handler exceeding the WatchdogTimeout
+type StuckHandlerEvent struct {
+	MessageID string
+	Elapsed   time.Duration
+	// Stack is the stack trace of the goroutine running the handler
+	Stack string
+	// LastCheckpoint is the time of the last Checkpoint by the handler, zero if none
+	LastCheckpoint time.Time
+	// Checkpoints is the number of Checkpoint calls by the handler
+	Checkpoints int
+}
+
+// startWatchdog reports the stack of the current goroutine if it's still running after the WatchdogTimeout.
+// The returned function stops the watchdog.
+func (worker *Worker) startWatchdog(ctx context.Context, m *types.Message, p *progress) func() {
+	if worker.Config.WatchdogTimeout <= 0 {
+		return func() {}
+	}
+	gid := goroutineID()
+	start := worker.now()
+	timer := worker.clock().AfterFunc(worker.Config.WatchdogTimeout, func() {
+		event := &StuckHandlerEvent{
+			MessageID: aws.ToString(m.MessageId),
+			Elapsed:   worker.since(start),
+			Stack:     goroutineStack(gid),
+		}
+		event.LastCheckpoint, event.Checkpoints = p.snapshot()
+		since := "never"
+		if !event.LastCheckpoint.IsZero() {
+			since = worker.since(event.LastCheckpoint).String() + " ago"
+		}
+		worker.Log.Warnf(ctx, "worker: Handler is stuck, id=%s, elapsed=%s, checkpoints=%d, last_checkpoint=%s, stack=\n%s",
+			event.MessageID, event.Elapsed, event.Checkpoints, since, event.Stack)
+		if worker.Config.Hooks.OnStuckHandler != nil {
+			worker.Config.Hooks.OnStuckHandler(ctx, event)
+		}
+	})
+	return func() { timer.Stop() }
+}
+
+// goroutineID returns the ID of the current goroutine parsed from the stack header("goroutine 123 [running]:")
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
+
+// goroutineStack returns the stack of the goroutine from the dump of all goroutines
+func goroutineStack(id uint64) string {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	header := []byte("goroutine " + strconv.FormatUint(id, 10) + " ")
+	for _, block := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(block, header) {
+			return string(block)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func stuckHandlerForTest(release chan struct{}) error {
+	<-release
+	return nil
+}
+
+func TestWatchdog(t *testing.T) {
+	events := make(chan *StuckHandlerEvent, 1)
+	worker := New(context.Background(), &nopSqsClient{}, &Config{
+		QueueName:       "my-sqs-queue",
+		WatchdogTimeout: 10 * time.Millisecond,
+		Hooks: Hooks{
+			OnStuckHandler: func(ctx context.Context, event *StuckHandlerEvent) { events <- event },
+		},
+	})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		_ = worker.handleMessage(context.Background(), &types.Message{MessageId: aws.String("stuck")}, HandlerFunc(func(msg *types.Message) error {
+			return stuckHandlerForTest(release)
+		}))
+		close(done)
+	}()
+
+	event := <-events
+	close(release)
+	<-done
+	assert.Equal(t, "stuck", event.MessageID)
+	assert.True(t, event.Elapsed >= 10*time.Millisecond)
+	assert.True(t, strings.Contains(event.Stack, "stuckHandlerForTest"), "the stack of the stuck handler is reported")
+}
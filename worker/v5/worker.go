@@ -2,14 +2,18 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/ca-risken/common/pkg/logging"
+	"github.com/ca-risken/go-sqs-poller/worker/v5/queue"
+	"golang.org/x/time/rate"
 )
 
 // HandlerFunc is used to define the Handler that is run on for each message
@@ -25,6 +29,33 @@ type Handler interface {
 	HandleMessage(msg *types.Message) error
 }
 
+// ContextHandler interface is optionally implemented by the Handler to receive the context of the message processing
+type ContextHandler interface {
+	Handler
+	HandleMessageWithContext(ctx context.Context, msg *types.Message) error
+}
+
+// ContextHandlerFunc is used to define the Handler with the context
+type ContextHandlerFunc func(ctx context.Context, msg *types.Message) error
+
+// HandleMessage wraps a function for handling sqs messages with the background context
+func (f ContextHandlerFunc) HandleMessage(msg *types.Message) error {
+	return f(context.Background(), msg)
+}
+
+// HandleMessageWithContext wraps a function for handling sqs messages with the context
+func (f ContextHandlerFunc) HandleMessageWithContext(ctx context.Context, msg *types.Message) error {
+	return f(ctx, msg)
+}
+
+// callHandler invokes the handler with the context if the handler supports it
+func callHandler(ctx context.Context, h Handler, msg *types.Message) error {
+	if ch, ok := h.(ContextHandler); ok {
+		return ch.HandleMessageWithContext(ctx, msg)
+	}
+	return h.HandleMessage(msg)
+}
+
 // InvalidEventError struct
 type InvalidEventError struct {
 	event string
@@ -42,23 +73,51 @@ func NewInvalidEventError(event, msg string) InvalidEventError {
 
 // QueueAPI interface is the minimum interface required from a queue implementation to invoke New worker.
 // Invoking worker.New() takes in a queue name which is why GetQueueUrl is needed.
-type QueueAPI interface {
-	GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error)
-	QueueDeleteReceiverAPI
-}
+// It's an alias of queue.API.
+type QueueAPI = queue.API
 
 // QueueDeleteReceiverAPI interface is the minimum interface required to run a worker.
 // When a worker is in its Receive loop, it requires this interface.
-type QueueDeleteReceiverAPI interface {
-	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
-	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
-}
+// It's an alias of queue.DeleteReceiver.
+type QueueDeleteReceiverAPI = queue.DeleteReceiver
 
 // Worker struct
 type Worker struct {
 	Config    *Config
 	Log       logging.Logger
 	SqsClient QueueDeleteReceiverAPI
+
+	stats              stats
+	deadLetterQueueARN string
+	deadLetterQueueURL string
+	deadLetterWorker   *Worker
+	inflight           inflight
+	goroutines         goroutineAccounting
+	requests           requestCounter
+	usage              usageTotals
+
+	sem            *semaphore
+	limiter        *rate.Limiter
+	sampler        *sampler
+	recent         *recentMessages
+	redeliveries   *redeliveries
+	utilization    utilizationWindow
+	buffers        *bufferPool
+	cancels        *cancellations
+	failureBudget  *rate.Limiter
+	receiveInput   *sqs.ReceiveMessageInput
+	budget         *retryBudget
+	transforms     []*transformStage
+	splits         *splitProgress
+	deletes        *deleteBatcher
+	prefetch       *prefetchExtender
+	warm           *warmPool
+	mu             sync.Mutex
+	stopPolling    context.CancelFunc
+	stopHandlers   context.CancelFunc
+	accessDenied   int32
+	running        bool
+	shutdownReport *ShutdownReport
 }
 
 // Config struct
@@ -67,89 +126,781 @@ type Config struct {
 	QueueName          string
 	QueueURL           string
 	WaitTimeSecond     int32
+	// Region is the region of the queue, it overrides the region of the client for every call when set.
+	// It lets a single client resolve and consume queues in multiple regions deterministically.
+	Region string
+	// QueueOwnerAWSAccountID is the account ID of the queue owner, it's used to resolve the queue URL of other accounts
+	QueueOwnerAWSAccountID string
+	// MessageAttributeNames is the message attribute names to receive (default: All)
+	MessageAttributeNames []string
+	// MessageSystemAttributeNames is the message system attribute names to receive (default: All)
+	// The features reading the system attributes (e.g. the retry reads ApproximateReceiveCount) need them to be received.
+	MessageSystemAttributeNames []types.MessageSystemAttributeName
+	// QueueTagOverrides applies the queue tags of QueueTagPrefix (e.g. worker:concurrency) over the config at startup
+	QueueTagOverrides bool
+	// Concurrency is the maximum number of concurrent handlers (default: 0, unlimited)
+	Concurrency int
+	// SubBatchSize caps the number of messages from one receive dispatched simultaneously (default: 0, the whole batch).
+	// The batch is processed in sub-batches of the size, so that a batch of heavy messages doesn't spike memory/CPU.
+	// It's ignored with KeyFunc or Workers, which bound the parallelism by themselves.
+	SubBatchSize int
+	// Workers enables the shared work queue scheduler with the number of goroutines pulling the messages
+	// instead of waiting for each batch to finish before the next receive (default: 0, per-batch).
+	// With KeyFunc, the messages are hashed into Workers lanes instead of KeyedLanes.
+	Workers int
+	// WarmPool pre-spawns the number of goroutines while polling to run the messages of the per-batch scheduler,
+	// instead of launching a goroutine per message, to reduce the scheduler churn of the high message rate (default: 0, per message).
+	// The batch waits for an idle goroutine while all are busy, so the size also bounds the parallelism.
+	// It's resized at runtime by UpdateConfig, and ignored with Workers, which runs its own goroutines.
+	WarmPool int
+	// RateLimit is the maximum number of messages handled per second (default: 0, unlimited)
+	RateLimit float64
+
+	// KeyFunc enables keyed serial execution when set.
+	// Messages are hashed by the key into KeyedLanes lanes, and each lane is processed sequentially.
+	KeyFunc KeyFunc
+	// KeyedLanes is the number of lanes used with KeyFunc (default: 10)
+	KeyedLanes int
+	// PriorityAttribute enables the soft prioritization within the queue when set: the message attribute of the name
+	// is the priority lane (0 to PriorityLanes-1, higher first), and the messages without it are in the lowest lane 0.
+	// The higher lanes are dispatched first within the batch and jump the queue of the Workers. It's ignored with KeyFunc.
+	PriorityAttribute string
+	// PriorityLanes is the number of the priority lanes with PriorityAttribute (default: 3)
+	PriorityLanes int
+	// DedupWindow suppresses the duplicate dispatch of a MessageId received again within the window (default: 0, disabled).
+	// It should not exceed the visibility timeout, the failed messages are removed from the window for redelivery.
+	DedupWindow time.Duration
+
+	// DeadLetterHandler enables a low-rate poller on the dead-letter queue detected from the redrive policy.
+	// It's useful for alerting or automated repair.
+	DeadLetterHandler Handler
+	// DeadLetterPollInterval is the interval between polls of the dead-letter queue (default: 1 minute)
+	DeadLetterPollInterval time.Duration
+
+	// DrainTimeout is the maximum time to wait for in-flight messages on Handoff and shutdown (default: 30 seconds)
+	DrainTimeout time.Duration
+	// HandoffVisibilityTimeout is the visibility timeout(seconds) set to the unfinished messages on Handoff,
+	// so that the successor can receive them promptly (default: 0)
+	HandoffVisibilityTimeout int32
+	// KeepVisibilityOnShutdown disables resetting the visibility of the messages still running
+	// at the DrainTimeout after the context is done
+	KeepVisibilityOnShutdown bool
+	// LeakGracePeriod is the time the handlers are given to return after their context is canceled at the end of the shutdown,
+	// then the handlers still running are reported as leaked by LogEventLeakedHandler and ShutdownReport.Leaked (default: 1 second)
+	LeakGracePeriod time.Duration
+
+	// RetryQueueName enables the retry topology when set.
+	// Failed messages are re-sent to the retry queue with DelaySeconds derived from the attempt count, and the original is deleted.
+	RetryQueueName string
+	RetryQueueURL  string
+	// RetryDelays is the tiered delays per attempt, the last one is used for later attempts (default: 10s, 1m, 5m, 15m)
+	RetryDelays []time.Duration
+	// MaxRetryAttempts is the maximum number of re-sends, then the message is left to the queue's redrive policy (default: len(RetryDelays))
+	MaxRetryAttempts int
+	// RetryBudget limits the retries shared across the worker when set.
+	// When it's exhausted, the failed messages are sent to the dead-letter queue instead of the retry queue.
+	RetryBudget *RetryBudget
+	// PayloadStore offloads the bodies of the re-sent messages exceeding the size limit of SQS in the extended client format when set.
+	// Otherwise the re-send of the oversized message fails with ErrMessageTooLarge.
+	PayloadStore PayloadStore
+
+	// CallOptions configures the retries and the timeouts of the worker's own SQS calls when set
+	CallOptions *CallOptions
+	// ThrottleCooldown is the pause of the polling after the receive is throttled by SQS (default: 30 seconds)
+	ThrottleCooldown time.Duration
+
+	// MaxAccessDenied is the number of the consecutive receives denied by the IAM regarded as the misconfiguration,
+	// which stops the worker with AccessDeniedError through the OnFatalError hook (default: 3).
+	// The denied receives are retried after the ThrottleCooldown until then, for the propagation of the IAM changes.
+	MaxAccessDenied int
+
+	// LowUtilization enables the OnLowUtilization hook when set
+	LowUtilization *LowUtilization
+
+	// HealthProbe is checked before each receive when set, and the polling is paused while it fails
+	HealthProbe HealthProbe
+	// HealthProbeInterval is the interval of the probes while the HealthProbe fails (default: 10 seconds)
+	HealthProbeInterval time.Duration
+
+	// HandlerRetry retries the handler in process for the transient errors when set
+	HandlerRetry *HandlerRetry
+
+	// FailureSampling persists a sampled subset of the payloads failed in the handler when set
+	FailureSampling *FailureSampling
+
+	// PrefetchVisibility extends the visibility of the messages queued for the Workers before it expires when set
+	PrefetchVisibility *PrefetchVisibility
+
+	// DeleteBatching sends the deletes of the handled messages with DeleteMessageBatch when set and supported by the client
+	DeleteBatching *DeleteBatching
+
+	// Transformers is the pipeline transforming each message in order before it's dispatched to the handler,
+	// e.g. decompress, decrypt, unwrap the envelope and validate
+	Transformers []TransformStage
+	// Splitting expands the fan-in messages carrying the N events into the events handled one by one when set
+	Splitting *Splitting
+	// MaxPooledBufferSize is the capacity over which the buffers taken by Buffer are not returned to the pool (default: 1MiB)
+	MaxPooledBufferSize int
+
+	// ParkingLot stores the messages parked by the handler returning Park(reason) when set
+	ParkingLot ParkingLot
+	// ParkTTL is the time the parked messages are kept for unpark (default: 14 days)
+	ParkTTL time.Duration
+
+	// MaxHops enables the loop detection when set: the message whose HopCount is over it is parked or dropped
+	// by the HopLimitAction without the handler. It must be over the MaxRetryAttempts since the retries are hops too.
+	MaxHops int
+	// HopLimitAction is the action for the message exceeding the MaxHops (default: HopLimitPark)
+	HopLimitAction HopLimitAction
+
+	// MaxMessageAge enables the TTL of the messages when set: the message sent earlier than it (by the SentTimestamp)
+	// is handled by the ExpiredMessagePolicy instead of the handler, e.g. the stale scan requests.
+	MaxMessageAge time.Duration
+	// ExpiredMessagePolicy is the action for the message older than the MaxMessageAge (default: ExpiredDelete)
+	ExpiredMessagePolicy ExpiredMessagePolicy
+	// ExpiredMessageHandler handles the expired messages with ExpiredHandle, and they are deleted when it's not set
+	ExpiredMessageHandler Handler
+
+	// CorrelationKey derives the correlation key of the message for CancelRelated when set, e.g. the scan ID of the chunks
+	CorrelationKey KeyFunc
+	// CanceledMessageAction is the action for the messages of the key canceled by CancelRelated (default: CanceledDelete)
+	CanceledMessageAction CanceledMessageAction
+	// CancellationTTL is the time the canceled keys are remembered to skip the messages received later (default: 1 hour)
+	CancellationTTL time.Duration
+
+	// ProcessingLock enables exactly-once processing when set.
+	// The lock is acquired before the handler, and confirmed after the delete or released on failure.
+	ProcessingLock ProcessingLock
+
+	// AuditSink receives the audit record of every processed message when set
+	AuditSink AuditSink
+
+	// WatchdogTimeout enables the watchdog reporting the stack of the handler running longer than it (default: 0, disabled)
+	WatchdogTimeout time.Duration
+	// VisibilityTimeout is the visibility timeout(seconds) requested for the received messages (default: 0, the queue's)
+	VisibilityTimeout int32
+	// CancelOnVisibilityExpiry cancels the handler context when the VisibilityTimeout (extended by Checkpoint) expires
+	// while the handler is running, and the message is left to the other consumers as OutcomeLostOwnership.
+	CancelOnVisibilityExpiry bool
+	// CheckpointVisibilityTimeout is the visibility timeout(seconds) extended by Checkpoint (default: 0, not extended)
+	CheckpointVisibilityTimeout int32
+	// MessageDeadline is the processing deadline of each message from its receive, set as the deadline of the handler context
+	// so that it bounds the downstream HTTP/gRPC calls made with the context (default: 0, no deadline)
+	MessageDeadline time.Duration
+	// CleanupMargin ends the handler context earlier than the MessageDeadline by the margin,
+	// reserved for the compensating actions of the handler with CleanupContext (default: 0)
+	CleanupMargin time.Duration
+
+	// PanicPolicy recovers the panic of the handler as the HandlerPanicError and handles the message by the policy when set
+	// (default: PanicCrash, not recovered)
+	PanicPolicy PanicPolicy
+
+	// FieldsExtractor attaches the correlation fields of each message to its logs, span and handler context when set,
+	// e.g. RISKENFields for project_id and scan_id
+	FieldsExtractor FieldsExtractor
+
+	// TraceSQSCalls creates the client spans of the ReceiveMessage, DeleteMessage, DeleteMessageBatch and ChangeMessageVisibility calls
+	// as the children of the span in the context, tagged with the queue URL, to tell the SQS latency from the handler latency
+	TraceSQSCalls bool
+
+	// TapMode processes the messages but never deletes them, sends their copies (e.g. to the retry or the dead-letter queue)
+	// nor changes their visibility, for the read-only observers of the queue consumed by another system, e.g. to validate a migration.
+	// The received messages are still invisible to the other consumers for the visibility timeout of the receive,
+	// so set the short VisibilityTimeout to hand them back promptly.
+	TapMode bool
+
+	// FIFOIDs derives the MessageGroupId and the MessageDeduplicationId of the messages the worker sends to the FIFO queues,
+	// e.g. by Requeue, the retry topology and the Mirror (default: DefaultFIFOIDs)
+	FIFOIDs FIFOIDs
+
+	// Mirror re-publishes every received message to the mirror queue when set, e.g. for the shadow environment
+	Mirror *Mirror
+
+	// RequestBudget warns when the monthly cost projected from the SQS requests exceeds the budget when set,
+	// see RequestStats for the requests and the estimated cost
+	RequestBudget *RequestBudget
+
+	// TenantConfig injects the aws.Config of the tenant of each message into the handler context when set,
+	// e.g. AssumeRoleTenantConfig for multi-tenant scan requests
+	TenantConfig TenantConfigFunc
+
+	// Clock is the source of the time of the backoffs, the heartbeats, the batching windows and the idle detection
+	// (default: SystemClock), e.g. workertest.FakeClock to test them without sleeps
+	Clock Clock
+
+	// Hooks is the set of callbacks invoked by the worker
+	Hooks Hooks
+
+	// LogLevels overrides the log level per event class (e.g. LogEventInvalidEvent: logging.InfoLevel)
+	LogLevels map[LogEvent]logging.Level
+	// LogSampling enables the sampling of the repetitive logs for high-throughput workers when set
+	LogSampling *LogSampling
 }
 
-// New sets up a new Worker
+// New sets up a new Worker.
+// The context bounds the startup calls (e.g. GetQueueUrl), and the errors of them are only printed, see Create.
 func New(ctx context.Context, client QueueAPI, config *Config) *Worker {
-	config.populateDefaultValues()
-	config.QueueURL = getQueueURL(ctx, client, config.QueueName)
+	worker, err := newWorker(ctx, client, config)
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+	return worker
+}
 
-	return &Worker{
-		Config:    config,
-		Log:       logging.NewLogger(),
-		SqsClient: client,
+// Create sets up a new Worker like New, but returns the error when the queue URL can't be resolved
+// or the context is done during the startup, e.g. by the global startup timeout.
+func Create(ctx context.Context, client QueueAPI, config *Config) (*Worker, error) {
+	worker, err := newWorker(ctx, client, config)
+	if err != nil {
+		return nil, err
 	}
+	return worker, nil
 }
 
-// Start starts the polling and will continue polling till the application is forcibly stopped
+// newWorker sets up the worker, and returns it with the first error of the startup
+func newWorker(ctx context.Context, client QueueAPI, config *Config) (*Worker, error) {
+	config.populateDefaultValues()
+	worker := &Worker{
+		Config:        config,
+		Log:           logging.NewLogger(),
+		SqsClient:     client,
+		sem:           newSemaphore(config.Concurrency),
+		limiter:       newLimiter(config.RateLimit),
+		sampler:       newSampler(config.LogSampling),
+		recent:        newRecentMessages(config.DedupWindow),
+		redeliveries:  newRedeliveries(),
+		buffers:       newBufferPool(config.MaxPooledBufferSize),
+		failureBudget: newFailureBudget(config.FailureSampling),
+		budget:        newRetryBudget(config.RetryBudget),
+		transforms:    newTransformStages(config.Transformers),
+		splits:        newSplitProgress(config.Splitting),
+	}
+	queueURL, err := worker.getQueueURL(ctx, client, config.QueueName)
+	config.QueueURL = queueURL
+	if config.RetryQueueName != "" && config.RetryQueueURL == "" {
+		retryQueueURL, retryErr := worker.getQueueURL(ctx, client, config.RetryQueueName)
+		config.RetryQueueURL = retryQueueURL
+		if err == nil {
+			err = retryErr
+		}
+	}
+	if config.CorrelationKey != nil {
+		worker.cancels = newCancellations(config.CancellationTTL, worker.now)
+	}
+	if config.QueueTagOverrides {
+		worker.applyQueueTags(ctx, client)
+	}
+	worker.deletes = worker.newDeleteBatcher(ctx)
+	worker.prefetch = worker.newPrefetchExtender(ctx)
+	if config.Workers <= 0 {
+		worker.warm = newWarmPool(worker, config.WarmPool)
+	}
+	worker.initDeadLetterQueue(ctx, client)
+	if err == nil && ctx.Err() != nil {
+		err = fmt.Errorf("worker: startup was interrupted, queue=%s, err=%w", config.QueueName, ctx.Err())
+	}
+	return worker, err
+}
+
+// ErrAlreadyRunning is returned by Run when the worker is already polling
+var ErrAlreadyRunning = errors.New("worker: the worker is already running")
+
+// Start starts the polling and will continue polling till the application is forcibly stopped.
+// The call while the worker is running is ignored with a warning, see Run.
 func (worker *Worker) Start(ctx context.Context, h Handler) {
+	if err := worker.Run(ctx, h); err != nil {
+		worker.Log.Warnf(ctx, "worker: Failed to run, queue=%s, err=%+v", worker.Config.QueueName, err)
+	}
+}
+
+// Run starts the polling like Start, but returns ErrAlreadyRunning immediately when the worker is already running,
+// so that the frameworks managing the lifecycle never poll the queue twice.
+// The worker stopped by the end of the context or Handoff can be run again.
+// The worker is registered to the DefaultRegistry while it's running.
+// The error of the OnWarmup hook is returned without polling, and the terminal error stopping the polling,
+// e.g. AccessDeniedError, after the in-flight messages are drained.
+func (worker *Worker) Run(ctx context.Context, h Handler) error {
+	pollCtx, handlerCtx, end, err := worker.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer end()
+	return worker.poll(ctx, pollCtx, handlerCtx, h)
+}
+
+// begin marks the worker running and warms it up, end must be called after the polling stops.
+// The receives run under the pollCtx canceled by the end of ctx or Handoff, and the handlers under the handlerCtx
+// outliving them until the drain finishes, see handlerContext.
+func (worker *Worker) begin(ctx context.Context) (pollCtx, handlerCtx context.Context, end func(), err error) {
+	pollCtx, stopPolling := context.WithCancel(ctx)
+	worker.mu.Lock()
+	if worker.running {
+		worker.mu.Unlock()
+		stopPolling()
+		return nil, nil, nil, ErrAlreadyRunning
+	}
+	handlerCtx, stopHandlers := handlerContext(ctx)
+	worker.running = true
+	worker.stopPolling = stopPolling
+	worker.stopHandlers = stopHandlers
+	worker.mu.Unlock()
+	DefaultRegistry.Register(worker)
+	end = func() {
+		stopPolling()
+		DefaultRegistry.Unregister(worker)
+		worker.mu.Lock()
+		worker.running = false
+		worker.mu.Unlock()
+	}
+	if worker.Config.Hooks.OnWarmup != nil {
+		if err := worker.Config.Hooks.OnWarmup(ctx); err != nil {
+			end()
+			stopHandlers()
+			return nil, nil, nil, fmt.Errorf("worker: failed to warm up, err=%w", err)
+		}
+	}
+	worker.logStarted(ctx)
+	return pollCtx, handlerCtx, end, nil
+}
+
+// Running reports whether the worker is polling
+func (worker *Worker) Running() bool {
+	worker.mu.Lock()
+	defer worker.mu.Unlock()
+	return worker.running
+}
+
+// poll receives the messages under the pollCtx and processes them under the handlerCtx until the context is done or the polling is stopped
+func (worker *Worker) poll(ctx, pollCtx, handlerCtx context.Context, h Handler) error {
+	if worker.deadLetterWorker != nil {
+		worker.goOwned(func() { worker.pollDeadLetterQueue(pollCtx, handlerCtx) })
+	}
+	var pool *workPool
+	if worker.Config.Workers > 0 {
+		pool = worker.startPool(ctx, handlerCtx, h)
+		defer pool.close()
+	}
+	defer worker.warm.start()()
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("worker: Stopping polling because a context kill signal was sent")
-			return
+			worker.shutdown(ctx)
+			return nil
+		case <-pollCtx.Done():
+			if ctx.Err() != nil {
+				log.Println("worker: Stopping polling because a context kill signal was sent")
+				worker.shutdown(ctx)
+				return nil
+			}
+			worker.Log.Info(ctx, "worker: Stopping polling because the worker is handing off")
+			return nil
 		default:
-			worker.Log.Debug(ctx, "worker: Start Polling")
-
-			params := &sqs.ReceiveMessageInput{
-				QueueUrl:            aws.String(worker.Config.QueueURL), // Required
-				MaxNumberOfMessages: worker.Config.MaxNumberOfMessage,
-				AttributeNames: []types.QueueAttributeName{
-					"All", // Required
-				},
-				WaitTimeSeconds: worker.Config.WaitTimeSecond,
+			if !worker.waitHealthy(pollCtx) {
+				continue
+			}
+			params := worker.receiveParams()
+			receiveCtx, cancelReceive := callContext(pollCtx, worker.Config.receiveTimeout())
+			receiveCtx, finishSpan := worker.startClientSpan(receiveCtx, "ReceiveMessage")
+			polled := time.Now()
+			resp, err := worker.SqsClient.ReceiveMessage(receiveCtx, params, worker.Config.sqsOptions()...)
+			finishSpan(err)
+			cancelReceive()
+			summary := pollSummary{wait: time.Since(polled)}
+			if err != nil && IsThrottled(err) {
+				worker.stats.addThrottled()
+				worker.logEventWith(ctx, LogEventThrottled, requestFields(err), "worker: Receive was throttled, cooling down for %s, err=%+v", worker.Config.ThrottleCooldown, err)
+				worker.cooldown(pollCtx)
+				continue
+			}
+			if err != nil && IsAccessDenied(err) {
+				if fatal := worker.deniedReceive(err); fatal != nil {
+					worker.fail(ctx, LogEventAccessDenied, fatal)
+					worker.shutdown(ctx)
+					return fatal
+				}
+				worker.logEventWith(ctx, LogEventAccessDenied, requestFields(err), "worker: Receive was denied, retrying in %s, queue=%s, err=%+v", worker.Config.ThrottleCooldown, worker.Config.QueueName, err)
+				worker.cooldown(pollCtx)
+				continue
+			}
+			if err != nil && IsQueueMissing(err) {
+				// the polling is retried slowly as the queue may be recreated, e.g. by the deployment of the infrastructure
+				worker.logEventWith(ctx, LogEventReceiveError, requestFields(err), "worker: The queue does not exist, retrying in %s, queue=%s, err=%+v", worker.Config.ThrottleCooldown, worker.Config.QueueName, err)
+				worker.cooldown(pollCtx)
+				continue
 			}
-
-			resp, err := worker.SqsClient.ReceiveMessage(ctx, params)
 			if err != nil {
-				log.Println(err)
+				worker.logEventWith(ctx, LogEventReceiveError, requestFields(err), "worker: Failed to receive messages, err=%+v", err)
 				continue
 			}
-			if len(resp.Messages) > 0 {
-				worker.run(ctx, h, &resp.Messages)
+			worker.allowedReceive()
+			if worker.Config.Hooks.OnBatchReceived != nil {
+				worker.Config.Hooks.OnBatchReceived(ctx, resp)
+			}
+			worker.observeReceive(ctx, len(resp.Messages))
+			summary.messages = len(resp.Messages)
+			if len(resp.Messages) == 0 {
+				worker.logPoll(ctx, summary)
+				continue
+			}
+			received := time.Now()
+			batchCtx := withReceivedAt(handlerCtx, worker.now())
+			if pool != nil {
+				pool.enqueue(withReceivedAt(ctx, worker.now()), resp.Messages)
+				summary.dispatch = time.Since(received)
+				worker.logPoll(ctx, summary)
+				continue
+			}
+			batchDone := make(chan struct{})
+			worker.goOwned(func() {
+				defer close(batchDone)
+				summary.outcomes = worker.run(batchCtx, h, resp.Messages)
+				summary.dispatch = time.Since(received)
+			})
+			select {
+			case <-batchDone:
+				worker.logPoll(ctx, summary)
+			case <-ctx.Done():
+				log.Println("worker: Stopping polling because a context kill signal was sent")
+				worker.shutdown(ctx)
+				return nil
 			}
 		}
 	}
 }
 
-// poll launches goroutine per received message and wait for all message to be processed
-func (worker *Worker) run(ctx context.Context, h Handler, messages *[]types.Message) {
-	numMessages := len(*messages)
-	worker.Log.Info(ctx, fmt.Sprintf("worker: Received %d messages", numMessages))
+// receiveParams returns the input of the receive, which is reused until the config is updated and must not be modified
+func (worker *Worker) receiveParams() *sqs.ReceiveMessageInput {
+	worker.mu.Lock()
+	defer worker.mu.Unlock()
+	if worker.receiveInput != nil {
+		return worker.receiveInput
+	}
+	worker.receiveInput = &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(worker.Config.QueueURL), // Required
+		MaxNumberOfMessages: worker.Config.MaxNumberOfMessage,
+		// the pinned SDK sends the system attribute names through the deprecated AttributeNames
+		AttributeNames:        systemAttributeNames(worker.Config.MessageSystemAttributeNames),
+		MessageAttributeNames: worker.Config.MessageAttributeNames,
+		WaitTimeSeconds:       worker.Config.WaitTimeSecond,
+		VisibilityTimeout:     worker.Config.VisibilityTimeout,
+	}
+	return worker.receiveInput
+}
+
+// run launches goroutine per received message and wait for all message to be processed, then returns the count of the outcomes.
+// Each goroutine owns the element of the messages by index, so the message is neither copied nor shared.
+func (worker *Worker) run(ctx context.Context, h Handler, messages []types.Message) map[Outcome]int {
+	numMessages := len(messages)
+	worker.stats.addReceived(numMessages)
+
+	results := newBatchResults(messages)
+	defer func() {
+		if worker.Config.Hooks.OnBatchProcessed != nil {
+			worker.Config.Hooks.OnBatchProcessed(ctx, results)
+		}
+	}()
 
+	if worker.Config.KeyFunc != nil {
+		worker.runKeyed(ctx, h, messages, results)
+		return countOutcomes(results)
+	}
+
+	size := numMessages
+	if worker.Config.SubBatchSize > 0 && worker.Config.SubBatchSize < size {
+		size = worker.Config.SubBatchSize
+	}
+	order := worker.dispatchOrder(messages)
+	for start := 0; start < numMessages; start += size {
+		end := start + size
+		if end > numMessages {
+			end = numMessages
+		}
+		var wg sync.WaitGroup
+		wg.Add(end - start)
+		for _, i := range order[start:end] {
+			i := i
+			worker.goHandler(func() {
+				// launch goroutine
+				defer wg.Done()
+				results[i].Outcome, results[i].Err = worker.handle(ctx, &messages[i], h)
+			})
+		}
+		wg.Wait()
+	}
+	return countOutcomes(results)
+}
+
+// runKeyed launches goroutine per lane and processes messages in each lane sequentially
+func (worker *Worker) runKeyed(ctx context.Context, h Handler, messages []types.Message, results []BatchResult) {
+	index := make(map[*types.Message]int, len(messages))
+	for i := range messages {
+		index[&messages[i]] = i
+	}
 	var wg sync.WaitGroup
-	wg.Add(numMessages)
-	for _, i := range *messages {
-		go func(m types.Message) {
-			// launch goroutine
+	for _, lane := range worker.splitLanes(messages) {
+		if len(lane) == 0 {
+			continue
+		}
+		wg.Add(1)
+		lane := lane
+		worker.goHandler(func() {
 			defer wg.Done()
-			if err := worker.handleMessage(ctx, &m, h); err != nil {
-				worker.Log.Error(ctx, err.Error())
+			for _, m := range lane {
+				i := index[m]
+				results[i].Outcome, results[i].Err = worker.handle(ctx, m, h)
 			}
-		}(i)
+		})
 	}
 
 	wg.Wait()
 }
 
 func (worker *Worker) handleMessage(ctx context.Context, m *types.Message, h Handler) error {
-	var err error
-	err = h.HandleMessage(m)
+	_, err := worker.handle(ctx, m, h)
+	return err
+}
+
+// handle processes the message and returns the outcome
+func (worker *Worker) handle(ctx context.Context, m *types.Message, h Handler) (Outcome, error) {
+	worker.inflight.add(m)
+	defer worker.inflight.remove(m)
+	return worker.handleInflight(ctx, m, h)
+}
+
+// handleInflight processes the message already tracked as in-flight
+func (worker *Worker) handleInflight(ctx context.Context, m *types.Message, h Handler) (Outcome, error) {
+	if !worker.recent.dispatch(m) {
+		worker.logEvent(ctx, LogEventDuplicateReceive, "worker: Skipped the duplicate receive, id=%s", aws.ToString(m.MessageId))
+		return OutcomeDuplicate, nil
+	}
+	if mirror := worker.Config.Mirror; mirror != nil {
+		if mirror.Timing == MirrorAfterProcessing {
+			defer worker.mirror(ctx, m)
+		} else {
+			worker.mirror(ctx, m)
+		}
+	}
+	worker.observeRedelivery(ctx, m)
+	start := time.Now()
+	if worker.exceedsHopLimit(m) {
+		outcome, err := worker.bailOutLoop(ctx, m)
+		worker.audit(ctx, m, outcome, time.Since(start), nil, err)
+		if err != nil {
+			worker.recent.forget(m)
+			worker.stats.addFailed()
+			worker.logEvent(ctx, LogEventHandlerError, "%s", err.Error())
+		}
+		return outcome, err
+	}
+	if age, ok := worker.expired(m); ok {
+		policy := worker.expiredMessagePolicy()
+		worker.onExpiredMessage(ctx, m, age, policy)
+		if policy != ExpiredHandle {
+			outcome, err := worker.expireMessage(ctx, m, age, policy)
+			worker.audit(ctx, m, outcome, time.Since(start), nil, err)
+			if err != nil {
+				worker.recent.forget(m)
+				worker.stats.addFailed()
+				worker.logEvent(ctx, LogEventHandlerError, "%s", err.Error())
+			}
+			return outcome, err
+		}
+		worker.stats.addExpired()
+		worker.logEvent(ctx, LogEventExpired, "worker: Dispatching the expired message to the ExpiredMessageHandler, id=%s, age=%s", aws.ToString(m.MessageId), age.Truncate(time.Second))
+		h = worker.Config.ExpiredMessageHandler
+	}
+	key := worker.correlationKey(m)
+	if key != "" && worker.cancels.isCanceled(key) {
+		outcome, err := worker.skipCanceled(ctx, m)
+		worker.audit(ctx, m, outcome, time.Since(start), nil, err)
+		if err != nil {
+			worker.recent.forget(m)
+			worker.stats.addFailed()
+			worker.logEvent(ctx, LogEventHandlerError, "%s", err.Error())
+		}
+		return outcome, err
+	}
+	if err := worker.sem.acquire(ctx); err != nil {
+		worker.recent.forget(m)
+		worker.logEvent(ctx, LogEventHandlerError, "%s", err.Error())
+		return OutcomeFailed, err
+	}
+	defer worker.sem.release()
+	if worker.limiter != nil {
+		if err := worker.limiter.Wait(ctx); err != nil {
+			worker.recent.forget(m)
+			worker.logEvent(ctx, LogEventHandlerError, "%s", err.Error())
+			return OutcomeFailed, err
+		}
+	}
+	ctx = worker.correlationContext(ctx, m)
+	ctx, err := worker.tenantContext(ctx, m)
+	if err != nil {
+		worker.recent.forget(m)
+		worker.stats.addFailed()
+		worker.logEvent(ctx, LogEventHandlerError, "%s", err.Error())
+		return OutcomeFailed, err
+	}
+	process := worker.processMessage
+	if worker.Config.ProcessingLock != nil {
+		process = worker.processWithLock
+	}
+	start = time.Now()
+	ctx, p := worker.withProgress(ctx, m)
+	stopWatchdog := worker.startWatchdog(ctx, m, p)
+	// the result is recorded with the context before the watches, which cancel their context on stop
+	recordCtx := ctx
+	ctx, stopOwnership := worker.watchOwnership(ctx, p)
+	ctx, stopCancellation := worker.watchCancellation(ctx, key, p)
+	exitHandler := worker.enterHandler(m)
+	outcome, err := process(ctx, m, h)
+	exitHandler()
+	p.releaseBuffers()
+	stopCancellation()
+	stopOwnership()
+	stopWatchdog()
+	worker.budget.record()
+	usage := p.takeUsage()
+	worker.audit(recordCtx, m, outcome, time.Since(start), usage, err)
+	worker.reportUsage(recordCtx, usage)
+	if err != nil {
+		worker.recent.forget(m)
+		worker.stats.addFailed()
+		if outcome == OutcomeFailed {
+			worker.redeliveries.remember(m, RedeliveryHandlerError)
+		}
+		worker.logEventWith(ctx, LogEventHandlerError, requestFields(err), "%s", err.Error())
+		return outcome, err
+	}
+	switch outcome {
+	case OutcomePaused:
+		worker.recent.forget(m)
+		worker.logEvent(ctx, LogEventPaused, "worker: Returned the message of the paused route, id=%s", aws.ToString(m.MessageId))
+		return outcome, nil
+	case OutcomeLostOwnership:
+		worker.recent.forget(m)
+		worker.stats.addLostOwnership()
+		worker.redeliveries.remember(m, RedeliveryVisibilityExpiry)
+		worker.logEvent(ctx, LogEventLostOwnership, "worker: Canceled the handler since the visibility of the message expired, id=%s", aws.ToString(m.MessageId))
+		return outcome, nil
+	case OutcomeParked:
+		worker.logEvent(ctx, LogEventParked, "worker: Parked the message, id=%s", aws.ToString(m.MessageId))
+		return outcome, nil
+	case OutcomeCanceled:
+		return outcome, nil
+	case OutcomeLocked:
+		worker.recent.forget(m)
+		return outcome, nil
+	}
+	worker.stats.addSucceeded()
+	return outcome, nil
+}
+
+func (worker *Worker) processMessage(ctx context.Context, m *types.Message, h Handler) (Outcome, error) {
+	if canceledByKey(ctx) {
+		// the key was canceled while the message was waiting for the handler
+		return worker.skipCanceled(ctx, m)
+	}
+	msg, err := worker.transform(ctx, m)
+	if err == nil {
+		handlerCtx, cancel := worker.handlerDeadline(ctx)
+		if worker.Config.Splitting != nil {
+			err = worker.callSplit(ctx, handlerCtx, h, msg)
+		} else {
+			err = worker.callHandlerWithRetry(handlerCtx, h, msg)
+		}
+		cancel()
+	}
+	var deferred *DeferredError
+	if errors.As(err, &deferred) {
+		err = worker.awaitDeferred(ctx, deferred)
+	}
+	if lostOwnership(ctx) {
+		// the message can be processed by another consumer, so it's neither deleted nor retried
+		return OutcomeLostOwnership, nil
+	}
+	if canceledByKey(ctx) {
+		// the result is pointless since a related message canceled the key during the handler
+		return worker.skipCanceled(ctx, m)
+	}
+	if err != nil {
+		worker.sampleFailure(ctx, m, err)
+	}
 	if _, ok := err.(InvalidEventError); ok {
-		worker.Log.Error(ctx, err.Error())
+		worker.logEvent(ctx, LogEventInvalidEvent, "%s", err.Error())
+		if err := worker.deleteMessage(ctx, m); err != nil {
+			return OutcomeFailed, err
+		}
+		return OutcomeInvalid, nil
+	} else if errors.Is(err, errDeferExpired) {
+		// the message is already visible to the other consumers, so it's not retried
+		return OutcomeFailed, err
 	} else if err != nil {
-		return err
+		var panicked *HandlerPanicError
+		if errors.As(err, &panicked) {
+			if outcome, handled, err := worker.handlePanic(ctx, m, panicked); handled {
+				return outcome, err
+			}
+		}
+		var paused *PausedError
+		if errors.As(err, &paused) {
+			return worker.pauseMessage(ctx, m, paused)
+		}
+		var parked *ParkError
+		if errors.As(err, &parked) {
+			return worker.parkMessage(ctx, m, parked)
+		}
+		if worker.Config.RetryQueueURL != "" {
+			return worker.retryMessage(ctx, m, err)
+		}
+		return OutcomeFailed, err
 	}
 
-	params := &sqs.DeleteMessageInput{
-		QueueUrl:      aws.String(worker.Config.QueueURL), // Required
-		ReceiptHandle: m.ReceiptHandle,                    // Required
+	if err := worker.deleteMessage(ctx, m); err != nil {
+		return OutcomeFailed, &deleteFailedError{err: err}
+	}
+	return OutcomeSucceeded, nil
+}
+
+// deleteFailedError is the failure of the delete after the handler succeeded
+type deleteFailedError struct {
+	err error
+}
+
+func (e *deleteFailedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *deleteFailedError) Unwrap() error {
+	return e.err
+}
+
+func (worker *Worker) deleteMessage(ctx context.Context, m *types.Message) error {
+	if worker.tapped(ctx, m, "DeleteMessage") {
+		return nil
+	}
+	var err error
+	if worker.deletes != nil {
+		err = worker.deletes.delete(ctx, m)
+	} else {
+		params := &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(worker.Config.QueueURL), // Required
+			ReceiptHandle: m.ReceiptHandle,                    // Required
+		}
+		deleteCtx, cancel := callContext(ctx, worker.Config.deleteTimeout())
+		deleteCtx, finishSpan := worker.startClientSpan(deleteCtx, "DeleteMessage")
+		_, err = worker.SqsClient.DeleteMessage(deleteCtx, params, worker.Config.sqsOptions()...)
+		finishSpan(err)
+		cancel()
 	}
-	_, err = worker.SqsClient.DeleteMessage(ctx, params)
 	if err != nil {
+		worker.stats.addDeleteFailed()
 		return err
 	}
-	worker.Log.Debug(ctx, fmt.Sprintf("worker: deleted message from queue: %s", aws.ToString(m.ReceiptHandle)))
+	if worker.logEnabled(LogEventDeleted) {
+		worker.logEvent(ctx, LogEventDeleted, "worker: deleted message from queue: %s", aws.ToString(m.ReceiptHandle))
+	}
 
 	return nil
 }
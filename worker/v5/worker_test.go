@@ -3,13 +3,16 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/common/pkg/logging"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -127,9 +130,185 @@ func buildClientParams() *sqs.ReceiveMessageInput {
 	url := aws.String("https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue")
 
 	return &sqs.ReceiveMessageInput{
-		QueueUrl:            url,
-		MaxNumberOfMessages: int32(maxNumberOfMessages),
-		AttributeNames:      []types.QueueAttributeName{"All"},
-		WaitTimeSeconds:     int32(waitTimeSecond),
+		QueueUrl:              url,
+		MaxNumberOfMessages:   int32(maxNumberOfMessages),
+		AttributeNames:        []types.QueueAttributeName{"All"},
+		MessageAttributeNames: []string{"All"},
+		WaitTimeSeconds:       int32(waitTimeSecond),
 	}
 }
+
+// nopSqsClient is the allocation free client for benchmarks
+type nopSqsClient struct {
+	QueueAPI
+}
+
+func (c *nopSqsClient) GetQueueUrl(ctx context.Context, urlInput *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue")}, nil
+}
+
+func (c *nopSqsClient) DeleteMessage(ctx context.Context, input *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func BenchmarkRun(b *testing.B) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+	worker.Log.Level(logging.WarnLevel)
+	handlerFunc := HandlerFunc(func(msg *types.Message) error { return nil })
+	messages := make([]types.Message, 10)
+	for i := range messages {
+		messages[i] = types.Message{Body: aws.String(`{ "foo": "bar", "qux": "baz" }`), ReceiptHandle: aws.String(fmt.Sprint(i))}
+	}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	started := time.Now()
+	for i := 0; i < b.N; i++ {
+		worker.run(ctx, handlerFunc, messages)
+	}
+	b.ReportMetric(float64(b.N*len(messages))/time.Since(started).Seconds(), "msg/s")
+}
+
+// fixedReceiveSqsClient returns the same batch on every receive for benchmarks
+type fixedReceiveSqsClient struct {
+	nopSqsClient
+	output sqs.ReceiveMessageOutput
+}
+
+func (c *fixedReceiveSqsClient) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	return &c.output, nil
+}
+
+// BenchmarkPoll measures the whole cycle of the receive, the dispatch and the delete per batch
+func BenchmarkPoll(b *testing.B) {
+	client := &fixedReceiveSqsClient{}
+	for i := 0; i < 10; i++ {
+		client.output.Messages = append(client.output.Messages, types.Message{
+			MessageId:     aws.String(fmt.Sprint(i)),
+			Body:          aws.String(`{ "foo": "bar", "qux": "baz" }`),
+			ReceiptHandle: aws.String(fmt.Sprint(i)),
+		})
+	}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue"})
+	worker.Log.Level(logging.WarnLevel)
+	handlerFunc := HandlerFunc(func(msg *types.Message) error { return nil })
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	started := time.Now()
+	polls := 0
+	_ = worker.drainUntil(ctx, ctx, ctx, handlerFunc, false, func(received bool) bool {
+		polls++
+		return polls >= b.N
+	})
+	b.ReportMetric(float64(b.N*len(client.output.Messages))/time.Since(started).Seconds(), "msg/s")
+}
+
+type capturingSqsClient struct {
+	nopSqsClient
+	input   *sqs.GetQueueUrlInput
+	options sqs.Options
+}
+
+func (c *capturingSqsClient) GetQueueUrl(ctx context.Context, urlInput *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	c.input = urlInput
+	for _, fn := range optFns {
+		fn(&c.options)
+	}
+	return c.nopSqsClient.GetQueueUrl(ctx, urlInput)
+}
+
+func TestRegionAndAccount(t *testing.T) {
+	client := &capturingSqsClient{options: sqs.Options{Region: "eu-west-1"}}
+	New(context.Background(), client, &Config{
+		QueueName:              "my-sqs-queue",
+		Region:                 "ap-northeast-1",
+		QueueOwnerAWSAccountID: "123456789012",
+	})
+
+	assert.Equal(t, "ap-northeast-1", client.options.Region, "the region is overridden")
+	assert.Equal(t, "123456789012", aws.ToString(client.input.QueueOwnerAWSAccountId), "the queue owner is set")
+}
+
+func TestSubBatchSize(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue", SubBatchSize: 3})
+	messages := make([]types.Message, 10)
+	for i := range messages {
+		messages[i] = types.Message{Body: aws.String(fmt.Sprint(i)), ReceiptHandle: aws.String(fmt.Sprint(i))}
+	}
+	var running, peak, handled int32
+	worker.run(context.Background(), HandlerFunc(func(msg *types.Message) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}), messages)
+
+	assert.Equal(t, int32(10), handled)
+	assert.Equal(t, int32(3), peak, "the messages are dispatched in sub-batches")
+}
+
+// blockingReceiveSqsClient blocks the receive until the context is done
+type blockingReceiveSqsClient struct {
+	nopSqsClient
+	polling chan struct{}
+}
+
+func (c *blockingReceiveSqsClient) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	select {
+	case c.polling <- struct{}{}:
+	default:
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRunTwice(t *testing.T) {
+	client := &blockingReceiveSqsClient{polling: make(chan struct{}, 1)}
+	worker := New(context.Background(), client, &Config{QueueName: "my-sqs-queue"})
+	h := HandlerFunc(func(msg *types.Message) error { return nil })
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- worker.Run(ctx, h) }()
+		<-client.polling
+		assert.True(t, worker.Running())
+		assert.Equal(t, ErrAlreadyRunning, worker.Run(ctx, h), "the second Run doesn't poll the queue")
+		worker.Start(ctx, h)
+
+		cancel()
+		assert.NoError(t, <-done)
+		assert.False(t, worker.Running(), "the stopped worker can be run again")
+	}
+}
+
+type hangingURLSqsClient struct {
+	nopSqsClient
+}
+
+func (c *hangingURLSqsClient) GetQueueUrl(ctx context.Context, urlInput *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCreate(t *testing.T) {
+	worker, err := Create(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://sqs.eu-west-1.amazonaws.com/123456789/my-sqs-queue", worker.Config.QueueURL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	worker, err = Create(ctx, &hangingURLSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+	assert.Nil(t, worker)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "the startup respects the deadline of the context")
+}
@@ -0,0 +1,172 @@
+package workertest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+)
+
+// FakeClock is the worker.Clock moved only by Advance, so that the backoffs, the heartbeats,
+// the batching windows and the idle detection of the worker are tested deterministically without sleeps.
+// The timers and the tickers due by Advance fire in the order of their deadlines.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	seq     int
+	waiters []*fakeWaiter
+	changed chan struct{}
+}
+
+var _ worker.Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns the FakeClock starting at the time
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, changed: make(chan struct{})}
+}
+
+// fakeWaiter is the timer, the ticker or the AfterFunc of the FakeClock
+type fakeWaiter struct {
+	clock    *FakeClock
+	seq      int
+	deadline time.Time
+	period   time.Duration
+	c        chan time.Time
+	f        func()
+}
+
+// Now returns the current time of the clock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer creates the Timer firing when the clock is advanced by the duration
+func (c *FakeClock) NewTimer(d time.Duration) worker.Timer {
+	return c.add(d, 0, make(chan time.Time, 1), nil)
+}
+
+// AfterFunc creates the Timer calling the function in its own goroutine when the clock is advanced by the duration
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) worker.Timer {
+	return c.add(d, 0, nil, f)
+}
+
+// NewTicker creates the Ticker firing every duration the clock is advanced by
+func (c *FakeClock) NewTicker(d time.Duration) worker.Ticker {
+	if d <= 0 {
+		panic("workertest: non-positive interval for NewTicker")
+	}
+	return fakeTicker{c.add(d, d, make(chan time.Time, 1), nil)}
+}
+
+func (c *FakeClock) add(d, period time.Duration, ch chan time.Time, f func()) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	w := &fakeWaiter{clock: c, seq: c.seq, deadline: c.now.Add(d), period: period, c: ch, f: f}
+	if d <= 0 && period == 0 {
+		w.fire(c.now)
+		return w
+	}
+	c.waiters = append(c.waiters, w)
+	c.notify()
+	return w
+}
+
+// Advance moves the clock forward by the duration, firing the timers and the tickers due on the way
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.waiters, func(i, j int) bool {
+			a, b := c.waiters[i], c.waiters[j]
+			if a.deadline.Equal(b.deadline) {
+				return a.seq < b.seq
+			}
+			return a.deadline.Before(b.deadline)
+		})
+		if len(c.waiters) == 0 || c.waiters[0].deadline.After(end) {
+			break
+		}
+		w := c.waiters[0]
+		c.now = w.deadline
+		if w.period > 0 {
+			w.deadline = w.deadline.Add(w.period)
+		} else {
+			c.waiters = c.waiters[1:]
+		}
+		w.fire(c.now)
+	}
+	c.now = end
+	c.notify()
+}
+
+// Waiters returns the number of the timers and the tickers not fired or stopped yet
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil blocks until the number of the timers and the tickers waiting on the clock reaches n,
+// e.g. before Advance so that the worker has started the backoff to be advanced
+func (c *FakeClock) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		waiting, changed := len(c.waiters), c.changed
+		c.mu.Unlock()
+		if waiting >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+// notify wakes up BlockUntil, called with the lock held
+func (c *FakeClock) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// fire sends the time or calls the function, dropping the tick when the previous one is not received yet like time.Ticker
+func (w *fakeWaiter) fire(now time.Time) {
+	if w.f != nil {
+		go w.f()
+		return
+	}
+	select {
+	case w.c <- now:
+	default:
+	}
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+// Stop stops the timer, and reports whether it was stopped before firing
+func (w *fakeWaiter) Stop() bool {
+	c := w.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, waiter := range c.waiters {
+		if waiter == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			c.notify()
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+// Stop stops the ticker
+func (t fakeTicker) Stop() {
+	t.fakeWaiter.Stop()
+}
@@ -0,0 +1,70 @@
+package workertest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("timer", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		timer := clock.NewTimer(time.Second)
+		clock.Advance(999 * time.Millisecond)
+		select {
+		case <-timer.C():
+			t.Fatal("the timer fired early")
+		default:
+		}
+		clock.Advance(time.Millisecond)
+		assert.Equal(t, start.Add(time.Second), <-timer.C())
+		assert.False(t, timer.Stop())
+		assert.Equal(t, 0, clock.Waiters())
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		timer := clock.NewTimer(time.Second)
+		assert.True(t, timer.Stop())
+		clock.Advance(time.Hour)
+		select {
+		case <-timer.C():
+			t.Fatal("the stopped timer fired")
+		default:
+		}
+	})
+
+	t.Run("ticker", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		ticker := clock.NewTicker(time.Second)
+		defer ticker.Stop()
+		clock.Advance(time.Second)
+		assert.Equal(t, start.Add(time.Second), <-ticker.C())
+		clock.Advance(3 * time.Second)
+		assert.Equal(t, start.Add(2*time.Second), <-ticker.C(), "the ticks not received are dropped")
+		assert.Equal(t, start.Add(4*time.Second), clock.Now())
+	})
+
+	t.Run("after func", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		fired := make(chan time.Time, 1)
+		clock.AfterFunc(time.Minute, func() { fired <- clock.Now() })
+		clock.Advance(2 * time.Minute)
+		assert.Equal(t, start.Add(2*time.Minute), <-fired)
+	})
+
+	t.Run("block until", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			<-clock.NewTimer(time.Second).C()
+		}()
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		<-done
+	})
+}
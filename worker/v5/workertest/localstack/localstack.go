@@ -0,0 +1,145 @@
+// Package localstack provides helpers to run integration tests of the worker against LocalStack or elasticmq.
+//
+// The endpoint is read from the LOCALSTACK_ENDPOINT environment variable (default: http://localhost:4566),
+// and the tests are skipped when the endpoint is not reachable.
+package localstack
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+)
+
+const (
+	// EndpointEnv is the environment variable name of the endpoint
+	EndpointEnv = "LOCALSTACK_ENDPOINT"
+	// DefaultEndpoint is the default endpoint of LocalStack
+	DefaultEndpoint = "http://localhost:4566"
+	// DefaultRegion is the region used for LocalStack
+	DefaultRegion = "us-east-1"
+)
+
+// Env is the connection to LocalStack/elasticmq
+type Env struct {
+	Client   *sqs.Client
+	Endpoint string
+	Region   string
+}
+
+// Connect creates the sqs client for the endpoint with dummy credentials and checks the connectivity
+func Connect(ctx context.Context, endpoint string) (*Env, error) {
+	resolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+		return aws.Endpoint{PartitionID: "aws", URL: endpoint, SigningRegion: region}, nil
+	})
+	cfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRegion(DefaultRegion),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("test", "test", "")),
+		awsconfig.WithEndpointResolverWithOptions(resolver),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws configuration, err=%w", err)
+	}
+	client := sqs.NewFromConfig(cfg)
+	pingCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	if _, err := client.ListQueues(pingCtx, &sqs.ListQueuesInput{}); err != nil {
+		return nil, fmt.Errorf("failed to connect to %s, err=%w", endpoint, err)
+	}
+	return &Env{Client: client, Endpoint: endpoint, Region: DefaultRegion}, nil
+}
+
+// New connects to the endpoint from the environment variable, and skips the test if it's not reachable
+func New(t testing.TB) *Env {
+	t.Helper()
+	endpoint := os.Getenv(EndpointEnv)
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	env, err := Connect(context.Background(), endpoint)
+	if err != nil {
+		t.Skipf("localstack is not available: %+v", err)
+	}
+	return env
+}
+
+// CreateQueue creates the queue with a unique name derived from the name, and deletes it on the test cleanup.
+// The name ending with ".fifo" creates the FIFO queue.
+func (e *Env) CreateQueue(t testing.TB, name string) (queueName, queueURL string) {
+	t.Helper()
+	attrs := map[string]string{}
+	queueName = fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	if strings.HasSuffix(name, ".fifo") {
+		queueName = fmt.Sprintf("%s-%d.fifo", strings.TrimSuffix(name, ".fifo"), time.Now().UnixNano())
+		attrs[string(types.QueueAttributeNameFifoQueue)] = "true"
+		attrs[string(types.QueueAttributeNameContentBasedDeduplication)] = "true"
+	}
+	out, err := e.Client.CreateQueue(context.Background(), &sqs.CreateQueueInput{
+		QueueName:  aws.String(queueName),
+		Attributes: attrs,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the queue %s: %+v", queueName, err)
+	}
+	queueURL = aws.ToString(out.QueueUrl)
+	t.Cleanup(func() {
+		_, _ = e.Client.DeleteQueue(context.Background(), &sqs.DeleteQueueInput{QueueUrl: aws.String(queueURL)})
+	})
+	return queueName, queueURL
+}
+
+// Publish sends the fixture bodies to the queue
+func (e *Env) Publish(t testing.TB, queueURL string, bodies ...string) {
+	t.Helper()
+	for _, body := range bodies {
+		input := &sqs.SendMessageInput{QueueUrl: aws.String(queueURL), MessageBody: aws.String(body)}
+		if strings.HasSuffix(queueURL, ".fifo") {
+			input.MessageGroupId = aws.String("fixture")
+		}
+		if _, err := e.Client.SendMessage(context.Background(), input); err != nil {
+			t.Fatalf("failed to publish the fixture: %+v", err)
+		}
+	}
+}
+
+// RunWorker starts the worker for the queue in the background, and stops it on the test cleanup
+func (e *Env) RunWorker(t testing.TB, config *worker.Config, h worker.Handler) *worker.Worker {
+	t.Helper()
+	if config.WaitTimeSecond == 0 {
+		config.WaitTimeSecond = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := worker.New(ctx, e.Client, config)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Start(ctx, h)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return w
+}
+
+// WaitFor polls the condition until it's true or the timeout elapses
+func WaitFor(t testing.TB, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("condition is not satisfied within %s", timeout)
+}
@@ -0,0 +1,33 @@
+package localstack
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+)
+
+func TestRunWorker(t *testing.T) {
+	env := New(t)
+	queueName, queueURL := env.CreateQueue(t, "localstack-test")
+	env.Publish(t, queueURL, `{"id":1}`, `{"id":2}`)
+
+	var mu sync.Mutex
+	var got []string
+	w := env.RunWorker(t, &worker.Config{QueueName: queueName}, worker.HandlerFunc(func(msg *types.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, aws.ToString(msg.Body))
+		return nil
+	}))
+
+	WaitFor(t, 10*time.Second, func() bool { return w.Stats().Succeeded == 2 })
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 {
+		t.Fatalf("unexpected messages: %v", got)
+	}
+}
@@ -0,0 +1,59 @@
+// Package workertest provides helpers for the tests using the worker
+package workertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ca-risken/go-sqs-poller/worker/v5"
+)
+
+// PurgeAPI interface is the minimum interface required for PurgeQueue
+type PurgeAPI interface {
+	worker.QueueDeleteReceiverAPI
+	PurgeQueue(ctx context.Context, params *sqs.PurgeQueueInput, optFns ...func(*sqs.Options)) (*sqs.PurgeQueueOutput, error)
+}
+
+// PurgeQueue deletes all messages in the queue to reset the state between test cases.
+// SQS allows one PurgeQueue per 60 seconds, so the queue is drained by receive and delete
+// instead of waiting when a purge is already in progress.
+func PurgeQueue(ctx context.Context, client PurgeAPI, queueURL string) error {
+	_, err := client.PurgeQueue(ctx, &sqs.PurgeQueueInput{QueueUrl: aws.String(queueURL)})
+	if err == nil {
+		return nil
+	}
+	var inProgress *types.PurgeQueueInProgress
+	if !errors.As(err, &inProgress) {
+		return fmt.Errorf("failed to purge the queue, url=%s, err=%w", queueURL, err)
+	}
+	return DrainQueue(ctx, client, queueURL)
+}
+
+// DrainQueue receives and deletes the messages until the receive returns no messages
+func DrainQueue(ctx context.Context, client worker.QueueDeleteReceiverAPI, queueURL string) error {
+	for {
+		resp, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(queueURL),
+			MaxNumberOfMessages: 10,
+			WaitTimeSeconds:     1,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to receive messages, url=%s, err=%w", queueURL, err)
+		}
+		if len(resp.Messages) == 0 {
+			return nil
+		}
+		for _, m := range resp.Messages {
+			if _, err := client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(queueURL),
+				ReceiptHandle: m.ReceiptHandle,
+			}); err != nil {
+				return fmt.Errorf("failed to delete the message, id=%s, err=%w", aws.ToString(m.MessageId), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package workertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePurgeClient struct {
+	purgeErr error
+	messages []types.Message
+	deleted  []string
+}
+
+func (c *fakePurgeClient) PurgeQueue(ctx context.Context, params *sqs.PurgeQueueInput, optFns ...func(*sqs.Options)) (*sqs.PurgeQueueOutput, error) {
+	if c.purgeErr != nil {
+		return nil, c.purgeErr
+	}
+	c.messages = nil
+	return &sqs.PurgeQueueOutput{}, nil
+}
+
+func (c *fakePurgeClient) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	n := int(params.MaxNumberOfMessages)
+	if n > len(c.messages) {
+		n = len(c.messages)
+	}
+	out := &sqs.ReceiveMessageOutput{Messages: c.messages[:n]}
+	c.messages = c.messages[n:]
+	return out, nil
+}
+
+func (c *fakePurgeClient) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	c.deleted = append(c.deleted, aws.ToString(params.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func newFakePurgeClient(n int, purgeErr error) *fakePurgeClient {
+	c := &fakePurgeClient{purgeErr: purgeErr}
+	for i := 0; i < n; i++ {
+		c.messages = append(c.messages, types.Message{ReceiptHandle: aws.String("receipt")})
+	}
+	return c
+}
+
+func TestPurgeQueue(t *testing.T) {
+	t.Run("purged", func(t *testing.T) {
+		client := newFakePurgeClient(3, nil)
+		assert.NoError(t, PurgeQueue(context.Background(), client, "https://sqs/queue"))
+		assert.Empty(t, client.messages)
+		assert.Empty(t, client.deleted)
+	})
+	t.Run("drained in the cooldown", func(t *testing.T) {
+		client := newFakePurgeClient(15, &types.PurgeQueueInProgress{Message: aws.String("in progress")})
+		assert.NoError(t, PurgeQueue(context.Background(), client, "https://sqs/queue"))
+		assert.Empty(t, client.messages)
+		assert.Len(t, client.deleted, 15)
+	})
+	t.Run("failed", func(t *testing.T) {
+		client := newFakePurgeClient(1, errors.New("access denied"))
+		assert.Error(t, PurgeQueue(context.Background(), client, "https://sqs/queue"))
+		assert.Len(t, client.messages, 1)
+	})
+}
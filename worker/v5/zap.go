@@ -0,0 +1,182 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/ca-risken/common/pkg/logging"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// ZapLogger is the logging.Logger implementation backed by zap.
+// Trace level logs are written at zap's Debug level.
+type ZapLogger struct {
+	logger *zap.Logger
+	level  uint32
+}
+
+var _ logging.Logger = (*ZapLogger)(nil)
+
+// NewZapLogger creates ZapLogger struct with Info level
+func NewZapLogger(l *zap.Logger) *ZapLogger {
+	return &ZapLogger{logger: l, level: uint32(logging.InfoLevel)}
+}
+
+// Enabled reports whether the level is enabled
+func (z *ZapLogger) Enabled(level logging.Level) bool {
+	return level <= logging.Level(atomic.LoadUint32(&z.level))
+}
+
+// WithZap replaces the logger of the worker with the zap logger
+func (worker *Worker) WithZap(l *zap.Logger) *Worker {
+	worker.Log = NewZapLogger(l)
+	return worker
+}
+
+func zapLevel(level logging.Level) zapcore.Level {
+	switch level {
+	case logging.PanicLevel:
+		return zapcore.PanicLevel
+	case logging.FatalLevel:
+		return zapcore.FatalLevel
+	case logging.ErrorLevel:
+		return zapcore.ErrorLevel
+	case logging.WarnLevel:
+		return zapcore.WarnLevel
+	case logging.InfoLevel:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
+func (z *ZapLogger) log(ctx context.Context, level logging.Level, fields map[string]interface{}, msg string) {
+	if level > logging.Level(atomic.LoadUint32(&z.level)) {
+		return
+	}
+	ctxFields := LogFieldsFromContext(ctx)
+	zfields := make([]zap.Field, 0, len(fields)+len(ctxFields)+2)
+	for k, v := range ctxFields {
+		if _, ok := fields[k]; !ok {
+			zfields = append(zfields, zap.Any(k, v))
+		}
+	}
+	for k, v := range fields {
+		zfields = append(zfields, zap.Any(k, v))
+	}
+	if span, ok := tracer.SpanFromContext(ctx); ok {
+		zfields = append(zfields,
+			zap.Uint64("dd.trace_id", span.Context().TraceID()),
+			zap.Uint64("dd.span_id", span.Context().SpanID()),
+		)
+	}
+	if ce := z.logger.Check(zapLevel(level), msg); ce != nil {
+		ce.Write(zfields...)
+	}
+}
+
+// Debugf logs at Debug level
+func (z *ZapLogger) Debugf(ctx context.Context, format string, args ...interface{}) {
+	z.log(ctx, logging.DebugLevel, nil, fmt.Sprintf(format, args...))
+}
+
+// Infof logs at Info level
+func (z *ZapLogger) Infof(ctx context.Context, format string, args ...interface{}) {
+	z.log(ctx, logging.InfoLevel, nil, fmt.Sprintf(format, args...))
+}
+
+// Warnf logs at Warn level
+func (z *ZapLogger) Warnf(ctx context.Context, format string, args ...interface{}) {
+	z.log(ctx, logging.WarnLevel, nil, fmt.Sprintf(format, args...))
+}
+
+// Errorf logs at Error level
+func (z *ZapLogger) Errorf(ctx context.Context, format string, args ...interface{}) {
+	z.log(ctx, logging.ErrorLevel, nil, fmt.Sprintf(format, args...))
+}
+
+// Fatalf logs at Fatal level and exits
+func (z *ZapLogger) Fatalf(ctx context.Context, format string, args ...interface{}) {
+	z.log(ctx, logging.FatalLevel, nil, fmt.Sprintf(format, args...))
+}
+
+// Panicf logs at Panic level and panics
+func (z *ZapLogger) Panicf(ctx context.Context, format string, args ...interface{}) {
+	z.log(ctx, logging.PanicLevel, nil, fmt.Sprintf(format, args...))
+}
+
+// Debug logs at Debug level
+func (z *ZapLogger) Debug(ctx context.Context, args ...interface{}) {
+	z.log(ctx, logging.DebugLevel, nil, fmt.Sprint(args...))
+}
+
+// Info logs at Info level
+func (z *ZapLogger) Info(ctx context.Context, args ...interface{}) {
+	z.log(ctx, logging.InfoLevel, nil, fmt.Sprint(args...))
+}
+
+// Warn logs at Warn level
+func (z *ZapLogger) Warn(ctx context.Context, args ...interface{}) {
+	z.log(ctx, logging.WarnLevel, nil, fmt.Sprint(args...))
+}
+
+// Error logs at Error level
+func (z *ZapLogger) Error(ctx context.Context, args ...interface{}) {
+	z.log(ctx, logging.ErrorLevel, nil, fmt.Sprint(args...))
+}
+
+// Fatal logs at Fatal level and exits
+func (z *ZapLogger) Fatal(ctx context.Context, args ...interface{}) {
+	z.log(ctx, logging.FatalLevel, nil, fmt.Sprint(args...))
+}
+
+// Panic logs at Panic level and panics
+func (z *ZapLogger) Panic(ctx context.Context, args ...interface{}) {
+	z.log(ctx, logging.PanicLevel, nil, fmt.Sprint(args...))
+}
+
+// Level sets the minimum level, the zap logger's own level is applied as well
+func (z *ZapLogger) Level(level logging.Level) {
+	atomic.StoreUint32(&z.level, uint32(level))
+}
+
+// Output replaces the zap core with the JSON core writing to w
+func (z *ZapLogger) Output(w io.Writer) {
+	z.logger = z.logger.WithOptions(zap.WrapCore(func(zapcore.Core) zapcore.Core {
+		return zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(w), zapcore.DebugLevel)
+	}))
+}
+
+// GenerateRequestID generates the request ID with the prefix
+func (z *ZapLogger) GenerateRequestID(prefix string) (string, error) {
+	u, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s", prefix, u.String()), nil
+}
+
+// Notify logs with the notify field
+func (z *ZapLogger) Notify(ctx context.Context, level logging.Level, args ...interface{}) {
+	z.log(ctx, level, map[string]interface{}{"notify": true}, fmt.Sprint(args...))
+}
+
+// Notifyf logs with the notify field
+func (z *ZapLogger) Notifyf(ctx context.Context, level logging.Level, format string, args ...interface{}) {
+	z.log(ctx, level, map[string]interface{}{"notify": true}, fmt.Sprintf(format, args...))
+}
+
+// WithItems logs with the structured fields
+func (z *ZapLogger) WithItems(ctx context.Context, level logging.Level, fields map[string]interface{}, args ...interface{}) {
+	z.log(ctx, level, fields, fmt.Sprint(args...))
+}
+
+// WithItemsf logs with the structured fields
+func (z *ZapLogger) WithItemsf(ctx context.Context, level logging.Level, fields map[string]interface{}, format string, args ...interface{}) {
+	z.log(ctx, level, fields, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ca-risken/common/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestZapLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue"}).WithZap(zap.New(core))
+	ctx := context.Background()
+
+	worker.Log.Debug(ctx, "dropped by level")
+	worker.Log.Warnf(ctx, "warn %d", 1)
+	worker.Log.Level(logging.TraceLevel)
+	worker.logEvent(ctx, LogEventEmptyReceive, "worker: Received no messages")
+
+	entries := logs.AllUntimed()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+	assert.Equal(t, "warn 1", entries[0].Message)
+	assert.Equal(t, zapcore.DebugLevel, entries[1].Level, "trace is mapped to debug")
+	assert.Equal(t, map[string]interface{}{"event": "empty_receive"}, entries[1].ContextMap())
+}
@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"reflect"
+	"unsafe"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// BytesHandlerFunc is used to define the Handler receiving the body as []byte without copying it.
+// The body references the memory of the message body decoded by the SDK (or replaced by the Transformers), so:
+//   - it must not be modified, since the memory is shared with the immutable string of msg.Body
+//   - it stays valid while it's referenced, but retaining it after the handler keeps the whole body alive,
+//     so copy the parts kept longer (e.g. in a cache)
+//   - it's nil when the body is empty
+type BytesHandlerFunc func(ctx context.Context, msg *types.Message, body []byte) error
+
+// HandleMessage wraps a function for handling the body bytes with the background context
+func (f BytesHandlerFunc) HandleMessage(msg *types.Message) error {
+	return f(context.Background(), msg, bodyBytesNoCopy(msg.Body))
+}
+
+// HandleMessageWithContext wraps a function for handling the body bytes with the context
+func (f BytesHandlerFunc) HandleMessageWithContext(ctx context.Context, msg *types.Message) error {
+	return f(ctx, msg, bodyBytesNoCopy(msg.Body))
+}
+
+// UnsafeBodyBytes returns the body of the message without copying it, or nil when the body is empty.
+// The same lifetime rules as BytesHandlerFunc apply, in particular the returned bytes must not be modified.
+func (m *Message) UnsafeBodyBytes() []byte {
+	return bodyBytesNoCopy(m.Body)
+}
+
+// bodyBytesNoCopy returns the bytes sharing the memory of the body
+func bodyBytesNoCopy(body *string) []byte {
+	if body == nil || len(*body) == 0 {
+		return nil
+	}
+	header := (*reflect.StringHeader)(unsafe.Pointer(body))
+	return unsafe.Slice((*byte)(unsafe.Pointer(header.Data)), header.Len)
+}
@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytesHandlerFunc(t *testing.T) {
+	worker := New(context.Background(), &nopSqsClient{}, &Config{QueueName: "my-sqs-queue"})
+	m := &types.Message{MessageId: aws.String("scan"), Body: aws.String(`{"scan_id":1}`), ReceiptHandle: aws.String("scan")}
+	var received []byte
+	err := worker.handleMessage(context.Background(), m, BytesHandlerFunc(func(ctx context.Context, msg *types.Message, body []byte) error {
+		received = body
+		return nil
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"scan_id":1}`, string(received))
+	assert.True(t, &received[0] == &bodyBytesNoCopy(m.Body)[0], "the body is not copied")
+}
+
+func TestUnsafeBodyBytes(t *testing.T) {
+	assert.Nil(t, NewMessage(&types.Message{}).UnsafeBodyBytes())
+	assert.Nil(t, NewMessage(&types.Message{Body: aws.String("")}).UnsafeBodyBytes())
+	assert.Equal(t, []byte("scan"), NewMessage(&types.Message{Body: aws.String("scan")}).UnsafeBodyBytes())
+}
+
+// bodySink keeps the benchmarked bytes from being optimized away
+var bodySink []byte
+
+func BenchmarkBodyBytes(b *testing.B) {
+	m := NewMessage(&types.Message{Body: aws.String(string(make([]byte, 256*1024)))})
+	b.Run("copy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			bodySink = m.BodyBytes()
+		}
+	})
+	b.Run("no-copy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			bodySink = m.UnsafeBodyBytes()
+		}
+	})
+}